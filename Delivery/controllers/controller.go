@@ -1,7 +1,13 @@
 package controllers
 
 import (
+	"crypto/subtle"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Hailemari/clean_architecture_task_manager/Domain"
 	"github.com/gin-gonic/gin"
@@ -17,19 +23,26 @@ func NewTaskController(useCase domain.TaskUseCaseInterface) domain.TaskControlle
 }
 
 func (c *TaskController) GetTasks(ctx *gin.Context) {
-    tasks, err := c.useCase.GetTasks()
+    query, err := bindTaskQuery(ctx)
     if err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    ctx.JSON(http.StatusOK, tasks)
+    principal, _ := domain.PrincipalFromContext(ctx)
+    page, err := c.useCase.GetTasks(principal, query)
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    respondWithTaskPage(ctx, page)
 }
 
 func (c *TaskController) GetTask(ctx *gin.Context) {
     id, _ := primitive.ObjectIDFromHex(ctx.Param("id"))
-    task, found, err := c.useCase.GetTask(id)
+    principal, _ := domain.PrincipalFromContext(ctx)
+    task, found, err := c.useCase.GetTask(principal, id)
     if err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
         return
     }
     if !found {
@@ -45,8 +58,11 @@ func (c *TaskController) AddTask(ctx *gin.Context) {
         ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    if err := c.useCase.AddTask(task); err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    principal, _ := domain.PrincipalFromContext(ctx)
+    task.CreatedBy = principal.UserID
+    task.AssignedTo = nil
+    if err := c.useCase.AddTask(principal, task); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
         return
     }
     ctx.JSON(http.StatusCreated, gin.H{"message": "task added"})
@@ -59,8 +75,9 @@ func (c *TaskController) UpdateTask(ctx *gin.Context) {
         ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    if err := c.useCase.UpdateTask(id, task); err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.useCase.UpdateTask(principal, id, task); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
         return
     }
     ctx.JSON(http.StatusOK, gin.H{"message": "task updated"})
@@ -68,34 +85,278 @@ func (c *TaskController) UpdateTask(ctx *gin.Context) {
 
 func (c *TaskController) DeleteTask(ctx *gin.Context) {
     id, _ := primitive.ObjectIDFromHex(ctx.Param("id"))
-    if err := c.useCase.DeleteTask(id); err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.useCase.DeleteTask(principal, id); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
         return
     }
     ctx.JSON(http.StatusOK, gin.H{"message": "task deleted"})
 }
 
+func (c *TaskController) GetMyTasks(ctx *gin.Context) {
+    query, err := bindTaskQuery(ctx)
+    if err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    page, err := c.useCase.GetMyTasks(principal, query)
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    respondWithTaskPage(ctx, page)
+}
+
+func (c *TaskController) AssignTask(ctx *gin.Context) {
+    id, _ := primitive.ObjectIDFromHex(ctx.Param("id"))
+    var input struct {
+        Username string `json:"username" binding:"required"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.useCase.AssignTask(principal, id, input.Username); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "task assigned"})
+}
+
+func (c *TaskController) UnassignTask(ctx *gin.Context) {
+    id, _ := primitive.ObjectIDFromHex(ctx.Param("id"))
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.useCase.UnassignTask(principal, id); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "task unassigned"})
+}
+
+func (c *TaskController) SetTaskVisibility(ctx *gin.Context) {
+    id, _ := primitive.ObjectIDFromHex(ctx.Param("id"))
+    var input struct {
+        Visibility string `json:"visibility" binding:"required"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.useCase.SetTaskVisibility(principal, id, input.Visibility); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "task visibility updated"})
+}
+
+// ShareTask lets a user see the task while its visibility is shared.
+func (c *TaskController) ShareTask(ctx *gin.Context) {
+    id, _ := primitive.ObjectIDFromHex(ctx.Param("id"))
+    var input struct {
+        Username string `json:"username" binding:"required"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.useCase.ShareTask(principal, id, input.Username); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "task shared"})
+}
+
+func (c *TaskController) UnshareTask(ctx *gin.Context) {
+    id, _ := primitive.ObjectIDFromHex(ctx.Param("id"))
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.useCase.UnshareTask(principal, id, ctx.Param("username")); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "task unshared"})
+}
+
+// bindTaskQuery reads the filter, sort and paging query parameters shared by
+// the task listing endpoints. A leading "-" on sort requests descending order.
+func bindTaskQuery(ctx *gin.Context) (domain.TaskQuery, error) {
+    query := domain.TaskQuery{
+        Status:        ctx.Query("status"),
+        TitleContains: ctx.Query("title"),
+        SortBy:        strings.TrimPrefix(ctx.Query("sort"), "-"),
+        Descending:    strings.HasPrefix(ctx.Query("sort"), "-"),
+        Cursor:        ctx.Query("cursor"),
+    }
+    var err error
+    if v := ctx.Query("due_after"); v != "" {
+        if query.DueAfter, err = time.Parse(time.RFC3339, v); err != nil {
+            return query, errors.New("due_after must be an RFC 3339 timestamp")
+        }
+    }
+    if v := ctx.Query("due_before"); v != "" {
+        if query.DueBefore, err = time.Parse(time.RFC3339, v); err != nil {
+            return query, errors.New("due_before must be an RFC 3339 timestamp")
+        }
+    }
+    if v := ctx.Query("limit"); v != "" {
+        if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit < 1 {
+            return query, errors.New("limit must be a positive integer")
+        }
+    }
+    return query, nil
+}
+
+func respondWithTaskPage(ctx *gin.Context, page domain.TaskPage) {
+    respondWithPage(ctx, "tasks", page.Tasks, page.NextCursor)
+}
+
+// respondWithPage writes one page of a listing under key, with a link to the
+// next page when there is one.
+func respondWithPage(ctx *gin.Context, key string, items interface{}, nextCursor string) {
+    body := gin.H{key: items}
+    if nextCursor != "" {
+        params := ctx.Request.URL.Query()
+        params.Set("cursor", nextCursor)
+        body["next_cursor"] = nextCursor
+        body["next"] = ctx.Request.URL.Path + "?" + params.Encode()
+    }
+    ctx.JSON(http.StatusOK, body)
+}
+
+func statusForError(err error) int {
+    switch {
+    case errors.Is(err, domain.ErrInvalidTaskQuery), errors.Is(err, domain.ErrInvalidAccessTokenRequest),
+        errors.Is(err, domain.ErrInvalidTwoFactorCode), errors.Is(err, domain.ErrValidation),
+        errors.Is(err, domain.ErrInvalidUserQuery), errors.Is(err, domain.ErrInvalidRole), errors.Is(err, domain.ErrInvalidTaskPolicy),
+        errors.Is(err, domain.ErrInvalidVisibility), errors.Is(err, domain.ErrInvalidSCIMFilter), errors.Is(err, domain.ErrInvalidSCIMRequest),
+        errors.Is(err, domain.ErrSCIMImmutable):
+        return http.StatusBadRequest
+    case errors.Is(err, domain.ErrScopeNotPermitted), errors.Is(err, domain.ErrUserDeactivated), errors.Is(err, domain.ErrTaskSharingNotAllowed),
+        errors.Is(err, domain.ErrNoOrganization), errors.Is(err, domain.ErrInviteRoleNotPermitted), errors.Is(err, domain.ErrRegistrationClosed),
+        errors.Is(err, domain.ErrInviteRequired), errors.Is(err, domain.ErrNotProvisioned), errors.Is(err, domain.ErrUserNotManageable),
+        errors.Is(err, domain.ErrOrgRoleNotGrantable):
+        return http.StatusForbidden
+    case errors.Is(err, domain.ErrTaskNotFound), errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrAccessTokenNotFound),
+        errors.Is(err, domain.ErrTwoFactorNotFound), errors.Is(err, domain.ErrRoleNotFound), errors.Is(err, domain.ErrOrganizationNotFound),
+        errors.Is(err, domain.ErrNotOrgMember), errors.Is(err, domain.ErrInviteNotFound), errors.Is(err, domain.ErrOIDCNotConfigured):
+        return http.StatusNotFound
+    case errors.Is(err, domain.ErrTwoFactorAlreadyEnabled), errors.Is(err, domain.ErrUserExists), errors.Is(err, domain.ErrLastAdmin),
+        errors.Is(err, domain.ErrAlreadyBootstrapped), errors.Is(err, domain.ErrRoleExists), errors.Is(err, domain.ErrRoleInUse),
+        errors.Is(err, domain.ErrBuiltInRole), errors.Is(err, domain.ErrAdminRoleChanged), errors.Is(err, domain.ErrAlreadyOrgMember),
+        errors.Is(err, domain.ErrLastOrgAdmin), errors.Is(err, domain.ErrIdentityConflict):
+        return http.StatusConflict
+    case errors.Is(err, domain.ErrAuthBackendUnavailable):
+        return http.StatusServiceUnavailable
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+// respondWithError writes err with the status from statusForError. Validation
+// errors also list the rejected fields.
+func respondWithError(ctx *gin.Context, err error) {
+    var validation *domain.ValidationError
+    if errors.As(err, &validation) {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "fields": validation.Fields})
+        return
+    }
+    ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+}
+
 type UserController struct {
-    useCase domain.UserUseCaseInterface
+    useCase            domain.UserUseCaseInterface
+    authUseCase        domain.AuthUseCaseInterface
+    accessTokenUseCase domain.AccessTokenUseCaseInterface
+    twoFactorUseCase   domain.TwoFactorUseCaseInterface
+    passwordUseCase    domain.PasswordUseCaseInterface
+    bootstrapUseCase   domain.BootstrapUseCaseInterface
+    roleUseCase        domain.RoleUseCaseInterface
+    orgUseCase         domain.OrganizationUseCaseInterface
+    inviteUseCase      domain.InviteUseCaseInterface
+    // oidcUseCase is nil unless single sign-on is configured.
+    oidcUseCase        domain.OIDCUseCaseInterface
+    registrationMode   string
 }
 
-func NewUserController(useCase domain.UserUseCaseInterface) domain.UserControllerInterface {
-    return &UserController{useCase: useCase}
+func NewUserController(useCase domain.UserUseCaseInterface, authUseCase domain.AuthUseCaseInterface, accessTokenUseCase domain.AccessTokenUseCaseInterface, twoFactorUseCase domain.TwoFactorUseCaseInterface, passwordUseCase domain.PasswordUseCaseInterface, bootstrapUseCase domain.BootstrapUseCaseInterface, roleUseCase domain.RoleUseCaseInterface, orgUseCase domain.OrganizationUseCaseInterface, inviteUseCase domain.InviteUseCaseInterface, oidcUseCase domain.OIDCUseCaseInterface, registrationMode string) domain.UserControllerInterface {
+    return &UserController{
+        useCase:            useCase,
+        authUseCase:        authUseCase,
+        accessTokenUseCase: accessTokenUseCase,
+        twoFactorUseCase:   twoFactorUseCase,
+        passwordUseCase:    passwordUseCase,
+        bootstrapUseCase:   bootstrapUseCase,
+        roleUseCase:        roleUseCase,
+        orgUseCase:         orgUseCase,
+        inviteUseCase:      inviteUseCase,
+        oidcUseCase:        oidcUseCase,
+        registrationMode:   registrationMode,
+    }
 }
 
+// CreateUser registers a user as the registration mode allows. An invite
+// token is honoured in every mode but closed, and is required in invite mode.
 func (c *UserController) CreateUser(ctx *gin.Context) {
-    var user domain.User
-    if err := ctx.ShouldBindJSON(&user); err != nil {
+    var input struct {
+        Username    string `json:"username"`
+        Password    string `json:"password"`
+        InviteToken string `json:"invite_token"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
         ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    if err := c.useCase.CreateUser(&user); err != nil {
-        ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+    user := domain.User{Username: input.Username, Password: input.Password}
+    var err error
+    switch {
+    case c.registrationMode == domain.RegistrationClosed:
+        err = domain.ErrRegistrationClosed
+    case input.InviteToken != "":
+        err = c.inviteUseCase.Register(input.InviteToken, &user)
+    case c.registrationMode == domain.RegistrationInvite:
+        err = domain.ErrInviteRequired
+    default:
+        err = c.useCase.CreateUser(&user)
+    }
+    if err != nil {
+        if errors.Is(err, domain.ErrInvalidInvite) {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+            return
+        }
+        respondWithError(ctx, err)
         return
     }
     ctx.JSON(http.StatusCreated, gin.H{"message": "user created"})
 }
 
+// SetupAdmin creates the first admin with the setup token shown at startup.
+// It only works once.
+func (c *UserController) SetupAdmin(ctx *gin.Context) {
+    var input struct {
+        SetupToken string `json:"setup_token" binding:"required"`
+        Username   string `json:"username"`
+        Password   string `json:"password"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if _, err := c.bootstrapUseCase.SetupAdmin(input.SetupToken, input.Username, input.Password); err != nil {
+        if errors.Is(err, domain.ErrInvalidSetupToken) {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+            return
+        }
+        respondWithError(ctx, err)
+        return
+    }
+    ctx.JSON(http.StatusCreated, gin.H{"message": "admin created"})
+}
+
 func (c *UserController) LoginUser(ctx *gin.Context) {
     var input struct {
         Username string `json:"username"`
@@ -106,20 +367,172 @@ func (c *UserController) LoginUser(ctx *gin.Context) {
         return
     }
 
-    user, err := c.useCase.GetUserByUsername(input.Username)
+    token, challenge, err := c.authUseCase.Login(input.Username, input.Password, ctx.ClientIP())
+    if err != nil {
+        if respondWithLockout(ctx, err) {
+            return
+        }
+        if errors.Is(err, domain.ErrInvalidCredentials) {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+            return
+        }
+        if errors.Is(err, domain.ErrAuthBackendUnavailable) {
+            // The cause names directory servers, so it goes to the request
+            // log rather than to the client.
+            ctx.Error(err)
+            ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": domain.ErrAuthBackendUnavailable.Error()})
+            return
+        }
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    if challenge != nil {
+        ctx.JSON(http.StatusOK, challenge)
+        return
+    }
+    ctx.JSON(http.StatusOK, token)
+}
+
+// The OIDC state cookie ties a login at the identity provider to the browser
+// that started it. It lives as long as the login.
+const (
+    oidcStateCookie       = "oidc_state"
+    oidcStateCookiePath   = "/auth/oidc"
+    oidcStateCookieMaxAge = 10 * 60
+)
+
+// OIDCLogin sends the user to sign in at the identity provider.
+func (c *UserController) OIDCLogin(ctx *gin.Context) {
+    if c.oidcUseCase == nil {
+        ctx.JSON(http.StatusNotFound, gin.H{"error": domain.ErrOIDCNotConfigured.Error()})
+        return
+    }
+    authURL, state, err := c.oidcUseCase.BeginLogin()
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.SetSameSite(http.SameSiteLaxMode)
+    ctx.SetCookie(oidcStateCookie, state, oidcStateCookieMaxAge, oidcStateCookiePath, "", ctx.Request.TLS != nil, true)
+    ctx.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback is where the identity provider sends the user back. It answers
+// with tokens or a two-factor challenge, like LoginUser.
+func (c *UserController) OIDCCallback(ctx *gin.Context) {
+    if c.oidcUseCase == nil {
+        ctx.JSON(http.StatusNotFound, gin.H{"error": domain.ErrOIDCNotConfigured.Error()})
+        return
+    }
+    cookie, _ := ctx.Cookie(oidcStateCookie)
+    ctx.SetSameSite(http.SameSiteLaxMode)
+    ctx.SetCookie(oidcStateCookie, "", -1, oidcStateCookiePath, "", ctx.Request.TLS != nil, true)
+
+    if reason := ctx.Query("error"); reason != "" {
+        ctx.JSON(http.StatusUnauthorized, gin.H{"error": domain.ErrOIDCLoginFailed.Error() + ": " + reason})
+        return
+    }
+    state, code := ctx.Query("state"), ctx.Query("code")
+    if state == "" || code == "" {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": "state and code are required"})
+        return
+    }
+    if subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+        ctx.JSON(http.StatusUnauthorized, gin.H{"error": domain.ErrInvalidOIDCState.Error()})
+        return
+    }
+
+    token, challenge, err := c.oidcUseCase.CompleteLogin(state, code)
+    if err != nil {
+        if errors.Is(err, domain.ErrInvalidOIDCState) || errors.Is(err, domain.ErrOIDCLoginFailed) {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+            return
+        }
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    if challenge != nil {
+        ctx.JSON(http.StatusOK, challenge)
+        return
+    }
+    ctx.JSON(http.StatusOK, token)
+}
+
+// CompleteTwoFactorLogin is the second step of a login for users with
+// two-factor authentication.
+func (c *UserController) CompleteTwoFactorLogin(ctx *gin.Context) {
+    var input struct {
+        ChallengeToken string `json:"challenge_token" binding:"required"`
+        Code           string `json:"code" binding:"required"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    token, err := c.authUseCase.CompleteTwoFactorLogin(input.ChallengeToken, input.Code, ctx.ClientIP())
     if err != nil {
-        ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+        if respondWithLockout(ctx, err) {
+            return
+        }
+        if errors.Is(err, domain.ErrInvalidChallenge) || errors.Is(err, domain.ErrInvalidTwoFactorCode) {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+            return
+        }
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
         return
     }
+    ctx.JSON(http.StatusOK, token)
+}
+
+// respondWithLockout answers with 429 and a Retry-After header if err is a
+// domain.LockoutError, and reports whether it did.
+func respondWithLockout(ctx *gin.Context, err error) bool {
+    var lockout *domain.LockoutError
+    if !errors.As(err, &lockout) {
+        return false
+    }
+    ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+    ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+    return true
+}
 
-    if err := user.ComparePassword(input.Password); err != nil {
-        ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+func (c *UserController) RefreshToken(ctx *gin.Context) {
+    var input struct {
+        RefreshToken string `json:"refresh_token" binding:"required"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
 
-    // Generate token here (omitted for brevity)
+    token, err := c.authUseCase.Refresh(input.RefreshToken)
+    if err != nil {
+        if errors.Is(err, domain.ErrInvalidRefreshToken) || errors.Is(err, domain.ErrRefreshTokenReused) {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+            return
+        }
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, token)
+}
 
-    ctx.JSON(http.StatusOK, gin.H{"message": "login successful"})
+func (c *UserController) LogoutUser(ctx *gin.Context) {
+    var input struct {
+        RefreshToken string `json:"refresh_token" binding:"required"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.authUseCase.Logout(input.RefreshToken, principal.TokenID, principal.ExpiresAt); err != nil {
+        ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "logged out"})
 }
 
 func (c *UserController) PromoteUser(ctx *gin.Context) {
@@ -130,3 +543,461 @@ func (c *UserController) PromoteUser(ctx *gin.Context) {
     }
     ctx.JSON(http.StatusOK, gin.H{"message": "user promoted"})
 }
+
+func (c *UserController) UnlockUser(ctx *gin.Context) {
+    username := ctx.Param("username")
+    if err := c.authUseCase.UnlockUser(username); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "user unlocked"})
+}
+
+// ListUsers pages through users in username order, optionally filtered by
+// role and status.
+func (c *UserController) ListUsers(ctx *gin.Context) {
+    query := domain.UserQuery{
+        Role:   ctx.Query("role"),
+        Status: ctx.Query("status"),
+        Cursor: ctx.Query("cursor"),
+    }
+    if v := ctx.Query("limit"); v != "" {
+        var err error
+        if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit < 1 {
+            ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+            return
+        }
+    }
+    page, err := c.useCase.ListUsers(query)
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    respondWithPage(ctx, "users", page.Users, page.NextCursor)
+}
+
+func (c *UserController) SetUserRole(ctx *gin.Context) {
+    var input struct {
+        Role string `json:"role" binding:"required"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if err := c.useCase.SetUserRole(ctx.Param("username"), input.Role); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "user role updated"})
+}
+
+func (c *UserController) DeactivateUser(ctx *gin.Context) {
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.useCase.SetUserDeactivated(principal, ctx.Param("username"), true); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "user deactivated"})
+}
+
+func (c *UserController) ReactivateUser(ctx *gin.Context) {
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.useCase.SetUserDeactivated(principal, ctx.Param("username"), false); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "user reactivated"})
+}
+
+// DeleteUser deletes a user. The tasks query parameter chooses what happens
+// to their tasks: unassign (the default), reassign with reassign_to, or delete.
+func (c *UserController) DeleteUser(ctx *gin.Context) {
+    principal, _ := domain.PrincipalFromContext(ctx)
+    affected, err := c.useCase.DeleteUser(principal, ctx.Param("username"), ctx.Query("tasks"), ctx.Query("reassign_to"))
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "user deleted", "tasks_affected": affected})
+}
+
+func (c *UserController) ListRoles(ctx *gin.Context) {
+    roles, err := c.roleUseCase.ListRoles()
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"roles": roles, "permissions": domain.AllPermissions})
+}
+
+func (c *UserController) CreateRole(ctx *gin.Context) {
+    var role domain.Role
+    if err := ctx.ShouldBindJSON(&role); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if err := c.roleUseCase.CreateRole(&role); err != nil {
+        respondWithError(ctx, err)
+        return
+    }
+    ctx.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole replaces the description and permissions of the role named in
+// the URL.
+func (c *UserController) UpdateRole(ctx *gin.Context) {
+    var role domain.Role
+    if err := ctx.ShouldBindJSON(&role); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    role.Name = ctx.Param("name")
+    if err := c.roleUseCase.UpdateRole(&role); err != nil {
+        respondWithError(ctx, err)
+        return
+    }
+    ctx.JSON(http.StatusOK, role)
+}
+
+func (c *UserController) DeleteRole(ctx *gin.Context) {
+    if err := c.roleUseCase.DeleteRole(ctx.Param("name")); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "role deleted"})
+}
+
+func (c *UserController) CreateOrganization(ctx *gin.Context) {
+    var input struct {
+        Name string `json:"name"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    org, err := c.orgUseCase.CreateOrganization(principal, input.Name)
+    if err != nil {
+        respondWithError(ctx, err)
+        return
+    }
+    ctx.JSON(http.StatusCreated, org)
+}
+
+// ListOrganizations lists the caller's organizations and pending invitations.
+func (c *UserController) ListOrganizations(ctx *gin.Context) {
+    principal, _ := domain.PrincipalFromContext(ctx)
+    orgs, err := c.orgUseCase.ListOrganizations(principal)
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
+
+// SwitchOrganization returns tokens for a new session in the organization.
+func (c *UserController) SwitchOrganization(ctx *gin.Context) {
+    orgID, ok := orgIDParam(ctx)
+    if !ok {
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    token, err := c.authUseCase.SwitchOrganization(principal, orgID)
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, token)
+}
+
+func (c *UserController) AcceptInvitation(ctx *gin.Context) {
+    orgID, ok := orgIDParam(ctx)
+    if !ok {
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.orgUseCase.AcceptInvitation(principal, orgID); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "invitation accepted"})
+}
+
+func (c *UserController) DeclineInvitation(ctx *gin.Context) {
+    orgID, ok := orgIDParam(ctx)
+    if !ok {
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.orgUseCase.DeclineInvitation(principal, orgID); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "invitation declined"})
+}
+
+// orgIDParam parses the :id path parameter, responding with 404 if it is not
+// a valid ID.
+func orgIDParam(ctx *gin.Context) (primitive.ObjectID, bool) {
+    orgID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+    if err != nil {
+        ctx.JSON(http.StatusNotFound, gin.H{"error": domain.ErrOrganizationNotFound.Error()})
+        return orgID, false
+    }
+    return orgID, true
+}
+
+func (c *UserController) ListMembers(ctx *gin.Context) {
+    principal, _ := domain.PrincipalFromContext(ctx)
+    members, err := c.orgUseCase.ListMembers(principal)
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// InviteMember invites a user to the caller's organization with the given
+// role, user if none is given.
+func (c *UserController) InviteMember(ctx *gin.Context) {
+    var input struct {
+        Username string `json:"username" binding:"required"`
+        Role     string `json:"role"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.orgUseCase.InviteMember(principal, input.Username, input.Role); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusCreated, gin.H{"message": "user invited"})
+}
+
+func (c *UserController) SetMemberRole(ctx *gin.Context) {
+    var input struct {
+        Role string `json:"role" binding:"required"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.orgUseCase.SetMemberRole(principal, ctx.Param("username"), input.Role); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "member role updated"})
+}
+
+func (c *UserController) RemoveMember(ctx *gin.Context) {
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.orgUseCase.RemoveMember(principal, ctx.Param("username")); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "member removed"})
+}
+
+// CreateInvite returns the invite with its token, which is only shown here.
+func (c *UserController) CreateInvite(ctx *gin.Context) {
+    var input struct {
+        Username string `json:"username"`
+        Role     string `json:"role"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    raw, invite, err := c.inviteUseCase.CreateInvite(principal, input.Username, input.Role)
+    if err != nil {
+        respondWithError(ctx, err)
+        return
+    }
+    ctx.JSON(http.StatusCreated, struct {
+        Token string `json:"invite_token"`
+        *domain.Invite
+    }{raw, invite})
+}
+
+func (c *UserController) ListInvites(ctx *gin.Context) {
+    invites, err := c.inviteUseCase.ListInvites()
+    if err != nil {
+        ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"invites": invites})
+}
+
+func (c *UserController) RevokeInvite(ctx *gin.Context) {
+    id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+    if err != nil {
+        ctx.JSON(http.StatusNotFound, gin.H{"error": domain.ErrInviteNotFound.Error()})
+        return
+    }
+    if err := c.inviteUseCase.RevokeInvite(id); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "invite revoked"})
+}
+
+func (c *UserController) RevokeUserTokens(ctx *gin.Context) {
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.useCase.RevokeUserTokens(principal, ctx.Param("username")); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "user tokens revoked"})
+}
+
+// CreateAccessToken creates a personal access token for the caller. The token
+// is only included in this response.
+func (c *UserController) CreateAccessToken(ctx *gin.Context) {
+    var input struct {
+        Name          string   `json:"name" binding:"required"`
+        Scopes        []string `json:"scopes" binding:"required"`
+        ExpiresInDays int      `json:"expires_in_days"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    principal, _ := domain.PrincipalFromContext(ctx)
+    lifetime := time.Duration(input.ExpiresInDays) * 24 * time.Hour
+    raw, token, err := c.accessTokenUseCase.CreateAccessToken(principal.UserID, principal.OrgID, input.Name, input.Scopes, lifetime)
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusCreated, struct {
+        Token string `json:"token"`
+        *domain.PersonalAccessToken
+    }{raw, token})
+}
+
+func (c *UserController) ListAccessTokens(ctx *gin.Context) {
+    principal, _ := domain.PrincipalFromContext(ctx)
+    tokens, err := c.accessTokenUseCase.ListAccessTokens(principal.UserID)
+    if err != nil {
+        ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+func (c *UserController) RevokeAccessToken(ctx *gin.Context) {
+    id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+    if err != nil {
+        ctx.JSON(http.StatusNotFound, gin.H{"error": domain.ErrAccessTokenNotFound.Error()})
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.accessTokenUseCase.RevokeAccessToken(principal.UserID, id); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "access token revoked"})
+}
+
+func (c *UserController) EnrollTwoFactor(ctx *gin.Context) {
+    principal, _ := domain.PrincipalFromContext(ctx)
+    enrollment, err := c.twoFactorUseCase.Enroll(principal.UserID)
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, enrollment)
+}
+
+func (c *UserController) EnableTwoFactor(ctx *gin.Context) {
+    var input struct {
+        Code string `json:"code" binding:"required"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    principal, _ := domain.PrincipalFromContext(ctx)
+    codes, err := c.twoFactorUseCase.Enable(principal.UserID, input.Code)
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "two-factor authentication enabled", "recovery_codes": codes})
+}
+
+func (c *UserController) DisableTwoFactor(ctx *gin.Context) {
+    var input struct {
+        Code string `json:"code" binding:"required"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.twoFactorUseCase.Disable(principal.UserID, input.Code); err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+// ChangePassword sets a new password for the caller. All of the caller's
+// sessions, including the current one, are signed out.
+func (c *UserController) ChangePassword(ctx *gin.Context) {
+    var input struct {
+        CurrentPassword string `json:"current_password" binding:"required"`
+        NewPassword     string `json:"new_password" binding:"required"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.passwordUseCase.ChangePassword(principal.UserID, input.CurrentPassword, input.NewPassword); err != nil {
+        respondWithError(ctx, err)
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "password changed"})
+}
+
+// IssuePasswordReset creates a reset token for a user. The token is only in
+// the response when it was not delivered to the user directly.
+func (c *UserController) IssuePasswordReset(ctx *gin.Context) {
+    principal, _ := domain.PrincipalFromContext(ctx)
+    reset, err := c.passwordUseCase.IssuePasswordReset(principal, ctx.Param("username"))
+    if err != nil {
+        ctx.JSON(statusForError(err), gin.H{"error": err.Error()})
+        return
+    }
+    ctx.JSON(http.StatusCreated, reset)
+}
+
+func (c *UserController) ResetPassword(ctx *gin.Context) {
+    var input struct {
+        ResetToken  string `json:"reset_token" binding:"required"`
+        NewPassword string `json:"new_password" binding:"required"`
+    }
+    if err := ctx.ShouldBindJSON(&input); err != nil {
+        ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := c.passwordUseCase.ResetPassword(input.ResetToken, input.NewPassword); err != nil {
+        if errors.Is(err, domain.ErrInvalidPasswordResetToken) {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+            return
+        }
+        respondWithError(ctx, err)
+        return
+    }
+    ctx.JSON(http.StatusOK, gin.H{"message": "password reset"})
+}
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/gin-gonic/gin"
+)
+
+// SCIMController serves the SCIM 2.0 endpoints for provisioning connectors.
+// Responses use the SCIM media type and error format rather than the API's.
+type SCIMController struct {
+    useCase domain.SCIMUseCaseInterface
+}
+
+func NewSCIMController(useCase domain.SCIMUseCaseInterface) domain.SCIMControllerInterface {
+    return &SCIMController{useCase: useCase}
+}
+
+func (c *SCIMController) ServiceProviderConfig(ctx *gin.Context) {
+    respondSCIM(ctx, http.StatusOK, gin.H{
+        "schemas":        []string{domain.SCIMSchemaServiceConfig},
+        "patch":          gin.H{"supported": true},
+        "bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+        "filter":         gin.H{"supported": true, "maxResults": domain.MaxSCIMPageSize},
+        "changePassword": gin.H{"supported": true},
+        "sort":           gin.H{"supported": false},
+        "etag":           gin.H{"supported": false},
+        "authenticationSchemes": []gin.H{{
+            "type":        "oauthbearertoken",
+            "name":        "Personal access token",
+            "description": "A personal access token with the scim scope, sent as a bearer token",
+            "primary":     true,
+        }},
+    })
+}
+
+func (c *SCIMController) ListUsers(ctx *gin.Context) {
+    query, ok := bindSCIMListQuery(ctx)
+    if !ok {
+        return
+    }
+    list, err := c.useCase.ListUsers(query)
+    if err != nil {
+        respondWithSCIMError(ctx, err)
+        return
+    }
+    respondSCIM(ctx, http.StatusOK, list)
+}
+
+func (c *SCIMController) GetUser(ctx *gin.Context) {
+    user, err := c.useCase.GetUser(ctx.Param("id"))
+    if err != nil {
+        respondWithSCIMError(ctx, err)
+        return
+    }
+    respondSCIM(ctx, http.StatusOK, user)
+}
+
+func (c *SCIMController) CreateUser(ctx *gin.Context) {
+    var input domain.SCIMUser
+    if !bindSCIM(ctx, &input) {
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    user, err := c.useCase.CreateUser(principal, &input)
+    if err != nil {
+        respondWithSCIMError(ctx, err)
+        return
+    }
+    ctx.Header("Location", user.Meta.Location)
+    respondSCIM(ctx, http.StatusCreated, user)
+}
+
+func (c *SCIMController) ReplaceUser(ctx *gin.Context) {
+    var input domain.SCIMUser
+    if !bindSCIM(ctx, &input) {
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    user, err := c.useCase.ReplaceUser(principal, ctx.Param("id"), &input)
+    if err != nil {
+        respondWithSCIMError(ctx, err)
+        return
+    }
+    respondSCIM(ctx, http.StatusOK, user)
+}
+
+func (c *SCIMController) PatchUser(ctx *gin.Context) {
+    var patch domain.SCIMPatch
+    if !bindSCIM(ctx, &patch) {
+        return
+    }
+    principal, _ := domain.PrincipalFromContext(ctx)
+    user, err := c.useCase.PatchUser(principal, ctx.Param("id"), &patch)
+    if err != nil {
+        respondWithSCIMError(ctx, err)
+        return
+    }
+    respondSCIM(ctx, http.StatusOK, user)
+}
+
+func (c *SCIMController) DeleteUser(ctx *gin.Context) {
+    principal, _ := domain.PrincipalFromContext(ctx)
+    if err := c.useCase.DeleteUser(principal, ctx.Param("id")); err != nil {
+        respondWithSCIMError(ctx, err)
+        return
+    }
+    ctx.Status(http.StatusNoContent)
+}
+
+func (c *SCIMController) ListGroups(ctx *gin.Context) {
+    query, ok := bindSCIMListQuery(ctx)
+    if !ok {
+        return
+    }
+    list, err := c.useCase.ListGroups(query)
+    if err != nil {
+        respondWithSCIMError(ctx, err)
+        return
+    }
+    respondSCIM(ctx, http.StatusOK, list)
+}
+
+func (c *SCIMController) GetGroup(ctx *gin.Context) {
+    group, err := c.useCase.GetGroup(ctx.Param("id"))
+    if err != nil {
+        respondWithSCIMError(ctx, err)
+        return
+    }
+    respondSCIM(ctx, http.StatusOK, group)
+}
+
+func (c *SCIMController) CreateGroup(ctx *gin.Context) {
+    var input domain.SCIMGroup
+    if !bindSCIM(ctx, &input) {
+        return
+    }
+    group, err := c.useCase.CreateGroup(&input)
+    if err != nil {
+        respondWithSCIMError(ctx, err)
+        return
+    }
+    ctx.Header("Location", group.Meta.Location)
+    respondSCIM(ctx, http.StatusCreated, group)
+}
+
+func (c *SCIMController) PatchGroup(ctx *gin.Context) {
+    var patch domain.SCIMPatch
+    if !bindSCIM(ctx, &patch) {
+        return
+    }
+    group, err := c.useCase.PatchGroup(ctx.Param("id"), &patch)
+    if err != nil {
+        respondWithSCIMError(ctx, err)
+        return
+    }
+    respondSCIM(ctx, http.StatusOK, group)
+}
+
+func (c *SCIMController) DeleteGroup(ctx *gin.Context) {
+    if err := c.useCase.DeleteGroup(ctx.Param("id")); err != nil {
+        respondWithSCIMError(ctx, err)
+        return
+    }
+    ctx.Status(http.StatusNoContent)
+}
+
+// bindSCIMListQuery reads the filter, startIndex and count parameters.
+func bindSCIMListQuery(ctx *gin.Context) (domain.SCIMListQuery, bool) {
+    query := domain.SCIMListQuery{Filter: ctx.Query("filter"), StartIndex: 1, Count: domain.DefaultSCIMPageSize}
+    for name, target := range map[string]*int{"startIndex": &query.StartIndex, "count": &query.Count} {
+        value := ctx.Query(name)
+        if value == "" {
+            continue
+        }
+        n, err := strconv.Atoi(value)
+        if err != nil {
+            respondSCIMError(ctx, http.StatusBadRequest, "invalidValue", name+" must be an integer")
+            return query, false
+        }
+        *target = n
+    }
+    return query, true
+}
+
+func bindSCIM(ctx *gin.Context, target interface{}) bool {
+    if err := ctx.ShouldBindJSON(target); err != nil {
+        respondSCIMError(ctx, http.StatusBadRequest, "invalidSyntax", err.Error())
+        return false
+    }
+    return true
+}
+
+func respondSCIM(ctx *gin.Context, status int, body interface{}) {
+    ctx.Header("Content-Type", "application/scim+json; charset=utf-8")
+    ctx.JSON(status, body)
+}
+
+// respondWithSCIMError writes err as a SCIM error, with the status from
+// statusForError and the scimType clients act on where one applies.
+func respondWithSCIMError(ctx *gin.Context, err error) {
+    scimType := ""
+    var validation *domain.ValidationError
+    switch {
+    case errors.Is(err, domain.ErrInvalidSCIMFilter):
+        scimType = "invalidFilter"
+    case errors.Is(err, domain.ErrSCIMImmutable):
+        scimType = "mutability"
+    case errors.Is(err, domain.ErrInvalidSCIMRequest), errors.As(err, &validation):
+        scimType = "invalidValue"
+    case errors.Is(err, domain.ErrUserExists), errors.Is(err, domain.ErrRoleExists):
+        scimType = "uniqueness"
+    }
+    respondSCIMError(ctx, statusForError(err), scimType, err.Error())
+}
+
+func respondSCIMError(ctx *gin.Context, status int, scimType, detail string) {
+    body := gin.H{
+        "schemas": []string{domain.SCIMSchemaError},
+        "status":  strconv.Itoa(status),
+        "detail":  detail,
+    }
+    if scimType != "" {
+        body["scimType"] = scimType
+    }
+    respondSCIM(ctx, status, body)
+}
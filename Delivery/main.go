@@ -1,12 +1,20 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Hailemari/clean_architecture_task_manager/Delivery/controllers"
 	"github.com/Hailemari/clean_architecture_task_manager/Delivery/routers"
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Infrastructure"
 	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
 	"github.com/Hailemari/clean_architecture_task_manager/Usecases"
 	"github.com/joho/godotenv"
@@ -16,38 +24,259 @@ import (
 )
 
 func main() {
+    createAdmin := flag.String("create-admin", "", "create the first admin with this username and exit; the password is read from ADMIN_PASSWORD or standard input")
+    flag.Parse()
+
     // Load environment variables
     err := godotenv.Load()
     if err != nil {
         log.Printf("Warning: Error loading .env file: %v", err)
     }
 
-    // Initialize the database connection
-    mongoURI := os.Getenv("MONGODB_URI")
-    if mongoURI == "" {
-        log.Fatal("MONGODB_URI environment variable is not set")
-    }
-
-    client, err := connectDB(mongoURI)
+    // Load JWT signing keys and token settings
+    tokenService, err := infrastructure.NewJWTServiceFromEnv()
     if err != nil {
-        log.Fatalf("Could not connect to the database: %v", err)
+        log.Fatalf("Could not configure JWT: %v", err)
     }
-    defer client.Disconnect(context.TODO())
 
     // Initialize repositories
-    taskRepo := repositories.NewMongoTaskRepository(client.Database("taskDB").Collection("tasks"))
-    userRepo := repositories.NewMongoUserRepository(client.Database("taskDB").Collection("users"))
+    var taskRepo domain.TaskRepository
+    var userRepo domain.UserRepository
+    var refreshRepo domain.RefreshTokenRepository
+    var revocationRepo domain.TokenRevocationRepository
+    var accessTokenRepo domain.AccessTokenRepository
+    var twoFactorRepo domain.TwoFactorRepository
+    var loginAttemptRepo domain.LoginAttemptRepository
+    var passwordResetRepo domain.PasswordResetRepository
+    var bootstrapRepo domain.BootstrapRepository
+    var migrationRepo domain.MigrationRepository
+    var roleRepo domain.RoleRepository
+    var orgRepo domain.OrganizationRepository
+    var inviteRepo domain.InviteRepository
+    var oidcLoginRepo domain.OIDCLoginRepository
+    switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+    case "memory":
+        log.Println("Using in-memory storage; all data will be lost on shutdown")
+        taskRepo = repositories.NewInMemoryTaskRepository()
+        userRepo = repositories.NewInMemoryUserRepository()
+        refreshRepo = repositories.NewInMemoryRefreshTokenRepository()
+        revocationRepo = repositories.NewInMemoryTokenRevocationRepository()
+        accessTokenRepo = repositories.NewInMemoryAccessTokenRepository()
+        twoFactorRepo = repositories.NewInMemoryTwoFactorRepository()
+        loginAttemptRepo = repositories.NewInMemoryLoginAttemptRepository()
+        passwordResetRepo = repositories.NewInMemoryPasswordResetRepository()
+        bootstrapRepo = repositories.NewInMemoryBootstrapRepository()
+        migrationRepo = repositories.NewInMemoryMigrationRepository()
+        roleRepo = repositories.NewInMemoryRoleRepository()
+        orgRepo = repositories.NewInMemoryOrganizationRepository()
+        inviteRepo = repositories.NewInMemoryInviteRepository()
+        oidcLoginRepo = repositories.NewInMemoryOIDCLoginRepository()
+    case "", "mongo":
+        mongoURI := os.Getenv("MONGODB_URI")
+        if mongoURI == "" {
+            log.Fatal("MONGODB_URI environment variable is not set")
+        }
+
+        client, err := connectDB(mongoURI)
+        if err != nil {
+            log.Fatalf("Could not connect to the database: %v", err)
+        }
+        defer client.Disconnect(context.TODO())
+        db := client.Database("taskDB")
+        err = errors.Join(
+            repositories.EnsureUserIndexes(db.Collection("users")),
+            repositories.EnsureTokenHashIndex(db.Collection("refresh_tokens")),
+            repositories.EnsureTokenHashIndex(db.Collection("access_tokens")),
+            repositories.EnsureTokenHashIndex(db.Collection("password_reset_tokens")),
+            repositories.EnsureTokenHashIndex(db.Collection("invites")),
+            repositories.EnsureTokenRevocationIndexes(db.Collection("revoked_tokens")),
+        )
+        if err != nil {
+            log.Fatalf("Could not create the database indexes: %v", err)
+        }
+
+        taskRepo = repositories.NewMongoTaskRepository(db.Collection("tasks"))
+        userRepo = repositories.NewMongoUserRepository(db.Collection("users"))
+        refreshRepo = repositories.NewMongoRefreshTokenRepository(db.Collection("refresh_tokens"))
+        revocationRepo = repositories.NewMongoTokenRevocationRepository(db.Collection("revoked_tokens"), db.Collection("user_token_cutoffs"))
+        accessTokenRepo = repositories.NewMongoAccessTokenRepository(db.Collection("access_tokens"))
+        twoFactorRepo = repositories.NewMongoTwoFactorRepository(db.Collection("two_factor"))
+        loginAttemptRepo = repositories.NewMongoLoginAttemptRepository(db.Collection("login_attempts"))
+        passwordResetRepo = repositories.NewMongoPasswordResetRepository(db.Collection("password_reset_tokens"))
+        bootstrapRepo = repositories.NewMongoBootstrapRepository(db.Collection("bootstrap"))
+        migrationRepo = repositories.NewMongoMigrationRepository(db.Collection("migrations"))
+        roleRepo = repositories.NewMongoRoleRepository(db.Collection("roles"))
+        orgRepo = repositories.NewMongoOrganizationRepository(db.Collection("organizations"), db.Collection("org_members"))
+        inviteRepo = repositories.NewMongoInviteRepository(db.Collection("invites"))
+        oidcLoginRepo = repositories.NewMongoOIDCLoginRepository(db.Collection("oidc_logins"))
+    default:
+        log.Fatalf("Unknown STORAGE_BACKEND %q, expected \"mongo\" or \"memory\"", backend)
+    }
 
     // Initialize use cases
-    taskUC := usecases.NewTaskUseCase(taskRepo)
-    userUC := usecases.NewUserUseCase(userRepo)
+    roleUC := usecases.NewRoleUseCase(roleRepo, userRepo, orgRepo)
+    if err := roleUC.Initialize(); err != nil {
+        log.Fatalf("Could not create the default roles: %v", err)
+    }
+    orgUC := usecases.NewOrganizationUseCase(orgRepo, userRepo, roleRepo, taskRepo, migrationRepo)
+    defaultOrg, err := orgUC.Initialize()
+    if err != nil {
+        log.Fatalf("Could not set up organizations: %v", err)
+    }
+    if defaultOrg != nil {
+        log.Printf("Moved the existing users and tasks into the new %q organization", defaultOrg.Name)
+    }
+    taskUC := usecases.NewTaskUseCase(taskRepo, userRepo, orgRepo)
+    passwordPolicy := domain.DefaultPasswordPolicy()
+    passwordPolicy.MinLength = intFromEnv("PASSWORD_MIN_LENGTH", passwordPolicy.MinLength)
+    passwordPolicy.MinCharacterClasses = intFromEnv("PASSWORD_MIN_CHARACTER_CLASSES", passwordPolicy.MinCharacterClasses)
+    passwordPolicy.RejectUsername = boolFromEnv("PASSWORD_REJECT_USERNAME", passwordPolicy.RejectUsername)
+    if boolFromEnv("PASSWORD_REJECT_COMMON", true) {
+        passwordPolicy.CommonPasswords, err = infrastructure.NewCommonPasswordList(os.Getenv("PASSWORD_BLOCKLIST_FILE"))
+        if err != nil {
+            log.Fatalf("Could not load PASSWORD_BLOCKLIST_FILE: %v", err)
+        }
+    }
+    hashConfig := infrastructure.DefaultPasswordHashConfig()
+    if algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm != "" {
+        hashConfig.Algorithm = algorithm
+    }
+    hashConfig.Argon2Memory = uint32(intFromEnv("ARGON2_MEMORY_KIB", int(hashConfig.Argon2Memory)))
+    hashConfig.Argon2Iterations = uint32(intFromEnv("ARGON2_ITERATIONS", int(hashConfig.Argon2Iterations)))
+    parallelism := intFromEnv("ARGON2_PARALLELISM", int(hashConfig.Argon2Parallelism))
+    if parallelism > 255 {
+        log.Fatalf("Invalid ARGON2_PARALLELISM %d: expected at most 255", parallelism)
+    }
+    hashConfig.Argon2Parallelism = uint8(parallelism)
+    hashConfig.BcryptCost = intFromEnv("BCRYPT_COST", hashConfig.BcryptCost)
+    passwordHasher, err := infrastructure.NewPasswordHasher(hashConfig)
+    if err != nil {
+        log.Fatalf("Could not configure password hashing: %v", err)
+    }
+    userUC := usecases.NewUserUseCase(userRepo, taskRepo, refreshRepo, revocationRepo, accessTokenRepo, roleRepo, orgRepo, passwordPolicy, passwordHasher)
+    refreshTTL := durationFromEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+    throttlePolicy := domain.DefaultLoginThrottlePolicy()
+    throttlePolicy.MaxUserFailures = intFromEnv("LOGIN_MAX_FAILURES", throttlePolicy.MaxUserFailures)
+    throttlePolicy.MaxIPFailures = intFromEnv("LOGIN_MAX_FAILURES_PER_IP", throttlePolicy.MaxIPFailures)
+    throttlePolicy.BaseDelay = durationFromEnv("LOGIN_BACKOFF_BASE", throttlePolicy.BaseDelay)
+    throttlePolicy.MaxDelay = durationFromEnv("LOGIN_BACKOFF_MAX", throttlePolicy.MaxDelay)
+    throttlePolicy.LockoutDuration = durationFromEnv("LOGIN_LOCKOUT_DURATION", throttlePolicy.LockoutDuration)
+    // AUTH_BACKEND lists where passwords are checked, in order; "ldap,password"
+    // keeps local accounts working when the directory is down.
+    var authenticators []domain.Authenticator
+    backends := os.Getenv("AUTH_BACKEND")
+    if backends == "" {
+        backends = domain.AuthBackendPassword
+    }
+    for _, backend := range strings.Split(backends, ",") {
+        switch backend = strings.TrimSpace(backend); backend {
+        case domain.AuthBackendPassword:
+            authenticators = append(authenticators, usecases.NewPasswordAuthenticator(userRepo, passwordHasher))
+        case domain.AuthBackendLDAP:
+            directory, err := infrastructure.NewLDAPDirectoryFromEnv()
+            if err != nil {
+                log.Fatalf("Could not configure LDAP: %v", err)
+            }
+            ldapSettings := externalUserSettingsFromEnv("LDAP", roleRepo, true)
+            authenticators = append(authenticators, usecases.NewDirectoryAuthenticator(directory, userRepo, ldapSettings))
+            log.Printf("LDAP authentication enabled with %s", os.Getenv("LDAP_URL"))
+        default:
+            log.Fatalf("Unknown AUTH_BACKEND %q, expected a list of \"password\" and \"ldap\"", backend)
+        }
+    }
+    authUC := usecases.NewAuthUseCase(userRepo, refreshRepo, revocationRepo, twoFactorRepo, orgRepo, loginAttemptRepo, throttlePolicy, usecases.NewChainAuthenticator(authenticators...), tokenService, refreshTTL)
+    accessTokenUC := usecases.NewAccessTokenUseCase(accessTokenRepo, userRepo, roleRepo)
+    totpIssuer := os.Getenv("TOTP_ISSUER")
+    if totpIssuer == "" {
+        totpIssuer = "Task Manager"
+    }
+    twoFactorUC := usecases.NewTwoFactorUseCase(twoFactorRepo, userRepo, totpIssuer)
+    // Without a webhook, reset tokens are returned to the admin who issued them.
+    var resetNotifier domain.PasswordResetNotifier
+    if url := os.Getenv("PASSWORD_RESET_WEBHOOK_URL"); url != "" {
+        resetNotifier = infrastructure.NewWebhookPasswordResetNotifier(url)
+    }
+    resetTTL := durationFromEnv("PASSWORD_RESET_TTL", usecases.DefaultPasswordResetTTL)
+    passwordUC := usecases.NewPasswordUseCase(userRepo, roleRepo, refreshRepo, revocationRepo, accessTokenRepo, passwordResetRepo, resetNotifier, passwordPolicy, passwordHasher, resetTTL)
+    inviteTTL := durationFromEnv("INVITE_TTL", usecases.DefaultInviteTTL)
+    inviteUC := usecases.NewInviteUseCase(inviteRepo, userRepo, roleRepo, orgRepo, passwordPolicy, passwordHasher, inviteTTL)
+    scimUC := usecases.NewSCIMUseCase(userRepo, roleRepo, orgRepo, refreshRepo, revocationRepo, userUC, roleUC, passwordPolicy, passwordHasher)
+    registrationMode := os.Getenv("REGISTRATION_MODE")
+    switch registrationMode {
+    case "":
+        registrationMode = domain.RegistrationOpen
+    case domain.RegistrationOpen, domain.RegistrationInvite, domain.RegistrationClosed:
+    default:
+        log.Fatalf("Unknown REGISTRATION_MODE %q, expected \"open\", \"invite\" or \"closed\"", registrationMode)
+    }
+
+    // Single sign-on at the company identity provider is enabled by setting
+    // OIDC_ISSUER.
+    var oidcUC domain.OIDCUseCaseInterface
+    oidcProvider, err := infrastructure.NewOIDCProviderFromEnv()
+    if err != nil {
+        log.Fatalf("Could not configure OIDC: %v", err)
+    }
+    if oidcProvider != nil {
+        oidcSettings := externalUserSettingsFromEnv("OIDC", roleRepo, false)
+        oidcUC = usecases.NewOIDCUseCase(oidcProvider, oidcLoginRepo, userRepo, authUC, oidcSettings)
+        log.Printf("Single sign-on enabled with %s", os.Getenv("OIDC_ISSUER"))
+    }
+
+    bootstrapUC := usecases.NewBootstrapUseCase(userRepo, bootstrapRepo, orgRepo, passwordPolicy, passwordHasher)
+    if *createAdmin != "" {
+        if os.Getenv("STORAGE_BACKEND") == "memory" {
+            log.Fatal("-create-admin has no effect with in-memory storage; use POST /setup/admin instead")
+        }
+        password := os.Getenv("ADMIN_PASSWORD")
+        if password == "" {
+            line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+            if err != nil && line == "" {
+                log.Fatalf("Could not read the admin password from standard input: %v", err)
+            }
+            password = strings.TrimRight(line, "\r\n")
+        }
+        if _, err := bootstrapUC.CreateAdmin(*createAdmin, password); err != nil {
+            log.Fatalf("Could not create admin: %v", err)
+        }
+        log.Printf("Created admin %q", *createAdmin)
+        return
+    }
+
+    // Until an admin exists, one can be created with the setup token.
+    bootstrapToken := os.Getenv("BOOTSTRAP_TOKEN")
+    if bootstrapToken != "" && len(bootstrapToken) < 16 {
+        log.Fatal("BOOTSTRAP_TOKEN must be at least 16 characters long")
+    }
+    setupToken, err := bootstrapUC.Initialize(bootstrapToken)
+    if err != nil {
+        log.Fatalf("Could not check for an admin: %v", err)
+    }
+    if setupToken != "" && bootstrapToken != "" {
+        log.Println("No admin has been set up yet. Create one with POST /setup/admin and the token in BOOTSTRAP_TOKEN")
+    } else if setupToken != "" {
+        log.Printf("No admin has been set up yet. Create one with POST /setup/admin and setup token %s", setupToken)
+    }
 
     // Initialize controllers
     taskCtrl := controllers.NewTaskController(taskUC)
-    userCtrl := controllers.NewUserController(userUC)
+    userCtrl := controllers.NewUserController(userUC, authUC, accessTokenUC, twoFactorUC, passwordUC, bootstrapUC, roleUC, orgUC, inviteUC, oidcUC, registrationMode)
+    scimCtrl := controllers.NewSCIMController(scimUC)
 
     // Set up router
-    r := routers.SetupRouter(taskCtrl, userCtrl)
+    r := routers.SetupRouter(taskCtrl, userCtrl, scimCtrl, tokenService, revocationRepo, userRepo, orgUC, accessTokenUC)
+
+    // Login throttling is keyed by client IP, so X-Forwarded-For is only
+    // honoured when it comes from a listed proxy.
+    var trustedProxies []string
+    for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+        if proxy = strings.TrimSpace(proxy); proxy != "" {
+            trustedProxies = append(trustedProxies, proxy)
+        }
+    }
+    if err := r.SetTrustedProxies(trustedProxies); err != nil {
+        log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+    }
 
     // Start the server
     port := os.Getenv("PORT")
@@ -60,6 +289,87 @@ func main() {
     }
 }
 
+// durationFromEnv parses a duration such as "15m" or "720h" from the environment.
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback
+    }
+    d, err := time.ParseDuration(value)
+    if err != nil || d <= 0 {
+        log.Fatalf("Invalid %s %q: expected a positive duration such as 30m or 720h", key, value)
+    }
+    return d
+}
+
+// intFromEnv parses a positive integer from the environment.
+func intFromEnv(key string, fallback int) int {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback
+    }
+    n, err := strconv.Atoi(value)
+    if err != nil || n <= 0 {
+        log.Fatalf("Invalid %s %q: expected a positive integer", key, value)
+    }
+    return n
+}
+
+func boolFromEnv(key string, fallback bool) bool {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback
+    }
+    b, err := strconv.ParseBool(value)
+    if err != nil {
+        log.Fatalf("Invalid %s %q: expected true or false", key, value)
+    }
+    return b
+}
+
+// externalUserSettingsFromEnv reads how users of an identity provider or
+// directory are matched and given roles from PREFIX_GROUP_ROLES,
+// PREFIX_DEFAULT_ROLE, PREFIX_LINK_EXISTING and PREFIX_AUTO_PROVISION, and
+// checks that the roles exist.
+func externalUserSettingsFromEnv(prefix string, roleRepo domain.RoleRepository, linkExisting bool) domain.ExternalUserSettings {
+    settings := domain.ExternalUserSettings{
+        GroupRoles:    groupRolesFromEnv(prefix + "_GROUP_ROLES"),
+        DefaultRole:   os.Getenv(prefix + "_DEFAULT_ROLE"),
+        LinkExisting:  boolFromEnv(prefix+"_LINK_EXISTING", linkExisting),
+        AutoProvision: boolFromEnv(prefix+"_AUTO_PROVISION", true),
+    }
+    roles := []string{settings.DefaultRole}
+    for _, mapping := range settings.GroupRoles {
+        roles = append(roles, mapping.Role)
+    }
+    for _, role := range roles {
+        if role == "" {
+            continue
+        }
+        if _, err := roleRepo.GetRole(role); err != nil {
+            log.Fatalf("Invalid %s role %q: %v", prefix, role, err)
+        }
+    }
+    return settings
+}
+
+// groupRolesFromEnv parses a comma-separated list of group=role pairs, such
+// as "task-admins=admin,staff=user", from the environment.
+func groupRolesFromEnv(key string) []domain.GroupRole {
+    var mappings []domain.GroupRole
+    for _, pair := range strings.Split(os.Getenv(key), ",") {
+        if pair = strings.TrimSpace(pair); pair == "" {
+            continue
+        }
+        i := strings.LastIndex(pair, "=")
+        if i <= 0 || i == len(pair)-1 {
+            log.Fatalf("Invalid %s entry %q: expected group=role", key, pair)
+        }
+        mappings = append(mappings, domain.GroupRole{Group: pair[:i], Role: pair[i+1:]})
+    }
+    return mappings
+}
+
 func connectDB(mongoURI string) (*mongo.Client, error) {
     clientOptions := options.Client().ApplyURI(mongoURI)
     client, err := mongo.Connect(context.TODO(), clientOptions)
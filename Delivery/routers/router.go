@@ -7,28 +7,108 @@ import (
 )
 
 // SetupRouter sets up the routes and middleware for the application
-func SetupRouter(taskCtrl domain.TaskControllerInterface, userCtrl domain.UserControllerInterface) *gin.Engine {
+func SetupRouter(taskCtrl domain.TaskControllerInterface, userCtrl domain.UserControllerInterface, scimCtrl domain.SCIMControllerInterface, tokens domain.TokenService, revocations domain.TokenRevocationRepository, users domain.UserRepository, orgs domain.OrganizationUseCaseInterface, accessTokens domain.AccessTokenUseCaseInterface) *gin.Engine {
     r := gin.Default()
 
     // Public routes
     r.POST("/register", userCtrl.CreateUser)
+    r.POST("/setup/admin", userCtrl.SetupAdmin)
     r.POST("/login", userCtrl.LoginUser)
+    r.POST("/login/2fa", userCtrl.CompleteTwoFactorLogin)
+    r.GET("/auth/oidc/login", userCtrl.OIDCLogin)
+    r.GET("/auth/oidc/callback", userCtrl.OIDCCallback)
+    r.POST("/token/refresh", userCtrl.RefreshToken)
+    r.POST("/password/reset", userCtrl.ResetPassword)
+    r.GET("/.well-known/jwks.json", infrastructure.JWKSHandler(tokens))
+    r.GET(domain.SCIMBasePath+"/ServiceProviderConfig", scimCtrl.ServiceProviderConfig)
 
-    // Protected routes
+    // Protected routes. Each route requires one permission from the caller's
+    // role; personal access tokens also need a scope that covers it. The tasks
+    // and org permissions come from the caller's role in their current
+    // organization.
     auth := r.Group("/")
-    auth.Use(infrastructure.AuthMiddleware())
+    auth.Use(infrastructure.AuthMiddleware(tokens, revocations, users, orgs, accessTokens))
     {
-        auth.GET("/tasks", taskCtrl.GetTasks)
-        auth.GET("/tasks/:id", taskCtrl.GetTask)
+        readTasks := infrastructure.RequirePermission(domain.PermissionTasksRead)
+        auth.GET("/tasks", readTasks, taskCtrl.GetTasks)
+        auth.GET("/tasks/mine", readTasks, taskCtrl.GetMyTasks)
+        auth.GET("/tasks/:id", readTasks, taskCtrl.GetTask)
+        auth.POST("/tasks", infrastructure.RequirePermission(domain.PermissionTasksCreate), taskCtrl.AddTask)
+        auth.PUT("/tasks/:id", infrastructure.RequirePermission(domain.PermissionTasksUpdate), taskCtrl.UpdateTask)
+        auth.DELETE("/tasks/:id", infrastructure.RequirePermission(domain.PermissionTasksDelete), taskCtrl.DeleteTask)
+        assignTasks := infrastructure.RequirePermission(domain.PermissionTasksAssign)
+        auth.PUT("/tasks/:id/assignee", assignTasks, taskCtrl.AssignTask)
+        auth.DELETE("/tasks/:id/assignee", assignTasks, taskCtrl.UnassignTask)
+        // Only the creator of a task, or a holder of tasks.read_all, can
+        // change who can see it.
+        shareTasks := infrastructure.RequirePermission(domain.PermissionTasksUpdate)
+        auth.PUT("/tasks/:id/visibility", shareTasks, taskCtrl.SetTaskVisibility)
+        auth.POST("/tasks/:id/shares", shareTasks, taskCtrl.ShareTask)
+        auth.DELETE("/tasks/:id/shares/:username", shareTasks, taskCtrl.UnshareTask)
 
-        // Admin-only routes
-        admin := auth.Group("/")
-        admin.Use(infrastructure.AdminMiddleware())
+        readUsers := infrastructure.RequirePermission(domain.PermissionUsersRead)
+        auth.GET("/users", readUsers, userCtrl.ListUsers)
+        auth.GET("/roles", readUsers, userCtrl.ListRoles)
+
+        manageUsers := infrastructure.RequirePermission(domain.PermissionUsersManage)
+        auth.POST("/users/:username/revoke-tokens", manageUsers, userCtrl.RevokeUserTokens)
+        auth.POST("/users/:username/unlock", manageUsers, userCtrl.UnlockUser)
+        auth.POST("/users/:username/password-reset", manageUsers, userCtrl.IssuePasswordReset)
+        auth.POST("/users/:username/deactivate", manageUsers, userCtrl.DeactivateUser)
+        auth.POST("/users/:username/reactivate", manageUsers, userCtrl.ReactivateUser)
+        auth.DELETE("/users/:username", manageUsers, userCtrl.DeleteUser)
+        auth.POST("/invites", manageUsers, userCtrl.CreateInvite)
+        auth.GET("/invites", manageUsers, userCtrl.ListInvites)
+        auth.DELETE("/invites/:id", manageUsers, userCtrl.RevokeInvite)
+
+        auth.GET("/org/members", readTasks, userCtrl.ListMembers)
+        manageOrg := infrastructure.RequirePermission(domain.PermissionOrgManage)
+        auth.POST("/org/invitations", manageOrg, userCtrl.InviteMember)
+        auth.PUT("/org/members/:username/role", manageOrg, userCtrl.SetMemberRole)
+        auth.DELETE("/org/members/:username", manageOrg, userCtrl.RemoveMember)
+
+        manageRoles := infrastructure.RequirePermission(domain.PermissionRolesManage)
+        auth.POST("/promote/:username", manageRoles, userCtrl.PromoteUser)
+        auth.PUT("/users/:username/role", manageRoles, userCtrl.SetUserRole)
+        auth.POST("/roles", manageRoles, userCtrl.CreateRole)
+        auth.PUT("/roles/:name", manageRoles, userCtrl.UpdateRole)
+        auth.DELETE("/roles/:name", manageRoles, userCtrl.DeleteRole)
+
+        // SCIM provisioning, usually with a personal access token that has
+        // the scim scope. Groups are roles, so changing them needs
+        // roles.manage.
+        scim := auth.Group(domain.SCIMBasePath)
+        {
+            scim.GET("/Users", readUsers, scimCtrl.ListUsers)
+            scim.GET("/Users/:id", readUsers, scimCtrl.GetUser)
+            scim.POST("/Users", manageUsers, scimCtrl.CreateUser)
+            scim.PUT("/Users/:id", manageUsers, scimCtrl.ReplaceUser)
+            scim.PATCH("/Users/:id", manageUsers, scimCtrl.PatchUser)
+            scim.DELETE("/Users/:id", manageUsers, scimCtrl.DeleteUser)
+            scim.GET("/Groups", readUsers, scimCtrl.ListGroups)
+            scim.GET("/Groups/:id", readUsers, scimCtrl.GetGroup)
+            scim.POST("/Groups", manageRoles, scimCtrl.CreateGroup)
+            scim.PATCH("/Groups/:id", manageRoles, scimCtrl.PatchGroup)
+            scim.DELETE("/Groups/:id", manageRoles, scimCtrl.DeleteGroup)
+        }
+
+        // Routes that need an interactive session
+        session := auth.Group("/")
+        session.Use(infrastructure.SessionOnlyMiddleware())
         {
-            admin.POST("/tasks", taskCtrl.AddTask)
-            admin.PUT("/tasks/:id", taskCtrl.UpdateTask)
-            admin.DELETE("/tasks/:id", taskCtrl.DeleteTask)
-            admin.POST("/promote/:username", userCtrl.PromoteUser)
+            session.POST("/logout", userCtrl.LogoutUser)
+            session.POST("/password", userCtrl.ChangePassword)
+            session.POST("/access-tokens", userCtrl.CreateAccessToken)
+            session.GET("/access-tokens", userCtrl.ListAccessTokens)
+            session.DELETE("/access-tokens/:id", userCtrl.RevokeAccessToken)
+            session.POST("/2fa/enroll", userCtrl.EnrollTwoFactor)
+            session.POST("/2fa/enable", userCtrl.EnableTwoFactor)
+            session.POST("/2fa/disable", userCtrl.DisableTwoFactor)
+            session.GET("/orgs", userCtrl.ListOrganizations)
+            session.POST("/orgs", userCtrl.CreateOrganization)
+            session.POST("/orgs/:id/switch", userCtrl.SwitchOrganization)
+            session.POST("/orgs/:id/accept", userCtrl.AcceptInvitation)
+            session.POST("/orgs/:id/decline", userCtrl.DeclineInvitation)
         }
     }
 
@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AccessTokenPrefix marks a bearer token as a personal access token rather
+// than a JWT.
+const AccessTokenPrefix = "tm_pat_"
+
+// Scopes a personal access token can be granted. Each scope lets the token
+// use some of its owner's permissions; see ScopesGrantPermission.
+const (
+    ScopeTasksRead  = "tasks:read"
+    ScopeTasksWrite = "tasks:write"
+    ScopeAdmin      = "admin"
+    // ScopeSCIM is for provisioning connectors calling the SCIM endpoints.
+    ScopeSCIM       = "scim"
+)
+
+var AllowedScopes = []string{ScopeTasksRead, ScopeTasksWrite, ScopeAdmin, ScopeSCIM}
+
+const (
+    DefaultAccessTokenLifetime = 30 * 24 * time.Hour
+    MaxAccessTokenLifetime     = 365 * 24 * time.Hour
+)
+
+// PersonalAccessToken is a long-lived token for scripts and CI. Only a hash
+// of the token is stored; the token itself is shown once, when it is created.
+// The token works in the organization its owner was in when creating it.
+type PersonalAccessToken struct {
+    ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+    UserID    primitive.ObjectID `json:"-" bson:"user_id"`
+    OrgID     primitive.ObjectID `json:"org_id,omitempty" bson:"org_id,omitempty"`
+    Name      string             `json:"name" bson:"name"`
+    TokenHash string             `json:"-" bson:"token_hash"`
+    Scopes    []string           `json:"scopes" bson:"scopes"`
+    CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+    ExpiresAt time.Time          `json:"expires_at" bson:"expires_at"`
+    Revoked   bool               `json:"revoked" bson:"revoked"`
+}
+
+var (
+    ErrAccessTokenNotFound       = errors.New("access token not found")
+    ErrInvalidAccessToken        = errors.New("invalid, expired or revoked access token")
+    ErrInvalidAccessTokenRequest = errors.New("invalid access token request")
+    ErrInvalidScope              = fmt.Errorf("%w: allowed scopes are: tasks:read, tasks:write, admin, scim", ErrInvalidAccessTokenRequest)
+    ErrScopeNotPermitted         = errors.New("the admin and scim scopes require a role that grants every permission of the scope")
+)
+
+// ValidScope reports whether scope is one of AllowedScopes.
+func ValidScope(scope string) bool {
+    for _, allowed := range AllowedScopes {
+        if scope == allowed {
+            return true
+        }
+    }
+    return false
+}
+
+type AccessTokenRepository interface {
+    CreateAccessToken(token *PersonalAccessToken) error
+    GetAccessTokenByHash(hash string) (*PersonalAccessToken, error)
+    ListUserAccessTokens(userID primitive.ObjectID) ([]PersonalAccessToken, error)
+    // RevokeAccessToken revokes one of the user's tokens. It returns
+    // ErrAccessTokenNotFound if the token does not belong to the user.
+    RevokeAccessToken(userID, id primitive.ObjectID) error
+    RevokeUserAccessTokens(userID primitive.ObjectID) error
+}
+
+type AccessTokenUseCaseInterface interface {
+    // CreateAccessToken returns the new token and its record. The token
+    // cannot be retrieved again.
+    CreateAccessToken(userID, orgID primitive.ObjectID, name string, scopes []string, lifetime time.Duration) (string, *PersonalAccessToken, error)
+    ListAccessTokens(userID primitive.ObjectID) ([]PersonalAccessToken, error)
+    RevokeAccessToken(userID, id primitive.ObjectID) error
+    // Authenticate resolves a personal access token to the principal of its owner.
+    Authenticate(token string) (*Principal, error)
+}
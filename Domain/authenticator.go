@@ -0,0 +1,28 @@
+package domain
+
+import "errors"
+
+// Authentication backends selectable for POST /login.
+const (
+    AuthBackendPassword = "password"
+    AuthBackendLDAP     = "ldap"
+)
+
+// Authenticator checks the username and password given to POST /login.
+type Authenticator interface {
+    // Authenticate returns the user the credentials belong to. It returns
+    // ErrInvalidCredentials if they are wrong, including for unknown users,
+    // and an error wrapping ErrAuthBackendUnavailable if they could not be
+    // checked.
+    Authenticate(username, password string) (*User, error)
+}
+
+// Directory checks passwords against an external user directory such as LDAP.
+type Directory interface {
+    // Authenticate returns the directory entry of the user, with the groups
+    // they are a member of. It returns ErrInvalidCredentials if the user is
+    // unknown or the password is wrong.
+    Authenticate(username, password string) (*ExternalIdentity, error)
+}
+
+var ErrAuthBackendUnavailable = errors.New("authentication service unavailable")
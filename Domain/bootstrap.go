@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+    ErrAlreadyBootstrapped = errors.New("the first admin has already been set up")
+    ErrInvalidSetupToken   = errors.New("invalid setup token")
+)
+
+// BootstrapRepository records that the first admin has been created. Claims
+// are atomic, so at most one bootstrap can ever succeed.
+type BootstrapRepository interface {
+    IsBootstrapped() (bool, error)
+    // ClaimBootstrap marks the bootstrap as done. It reports false if it
+    // already was.
+    ClaimBootstrap(at time.Time) (bool, error)
+    // ReleaseBootstrap undoes a claim whose admin could not be created.
+    ReleaseBootstrap() error
+    // ReleaseBootstrapClaimedBefore undoes a claim made before the given
+    // time, leaving newer claims alone.
+    ReleaseBootstrapClaimedBefore(before time.Time) error
+}
+
+type BootstrapUseCaseInterface interface {
+    // Initialize runs at startup. It returns the setup token to show the
+    // operator, or "" if an active admin already exists. setupToken is used as the
+    // token when set; otherwise a random one is generated.
+    Initialize(setupToken string) (string, error)
+    // CreateAdmin creates the first admin and the default organization,
+    // with the admin as its first admin. It is meant for trusted callers,
+    // such as a command run on the server.
+    CreateAdmin(username, password string) (*User, error)
+    // SetupAdmin creates the first admin after checking the setup token.
+    SetupAdmin(setupToken, username, password string) (*User, error)
+}
@@ -4,25 +4,89 @@ import (
     "time"
     "errors"
     "github.com/gin-gonic/gin"
-    "golang.org/x/crypto/bcrypt"
     "go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Task struct {
-    ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-    Title       string             `json:"title"`
-    Description string             `json:"description"`
-    DueDate     time.Time          `json:"due_date"`
-    Status      string             `json:"status"`
+    ID          primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+    // OrgID is the organization the task belongs to. It is set when the task
+    // is created and never changes.
+    OrgID       primitive.ObjectID   `json:"org_id" bson:"org_id,omitempty"`
+    Title       string               `json:"title"`
+    Description string               `json:"description"`
+    DueDate     time.Time            `json:"due_date"`
+    Status      string               `json:"status"`
+    CreatedBy   primitive.ObjectID   `json:"created_by" bson:"created_by,omitempty"`
+    AssignedTo  *primitive.ObjectID  `json:"assigned_to,omitempty" bson:"assigned_to,omitempty"`
+    // Visibility decides who can see the task. Tasks stored without one are
+    // visible to everyone.
+    Visibility  string               `json:"visibility" bson:"visibility,omitempty"`
+    SharedWith  []primitive.ObjectID `json:"shared_with,omitempty" bson:"shared_with,omitempty"`
 }
 
+// Task visibilities. The creator and the assignee can always see a task;
+// shared tasks are also visible to the users in SharedWith.
+const (
+    VisibilityPrivate = "private"
+    VisibilityShared  = "shared"
+    VisibilityOrg     = "org"
+)
+
 type User struct {
-    ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-    Username string             `json:"username"`
-    Password string             `json:"password"`
-    Role     string             `json:"role"`
+    ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+    Username    string             `json:"username"`
+    Password    string             `json:"password"`
+    Role        string             `json:"role"`
+    // Deactivated users cannot log in and their tokens are rejected.
+    Deactivated bool               `json:"-"`
+    // ExternalID is the subject the user signs in as at the identity
+    // provider, if any. Users created by signing in there have no password.
+    ExternalID  string             `json:"-" bson:"external_id,omitempty"`
+}
+
+// Built-in roles. RoleUser is given to every registered user.
+const (
+    RoleAdmin = "admin"
+    RoleUser  = "user"
+)
+
+// Task policies for DeleteUser, deciding what happens to the tasks assigned
+// to the deleted user.
+const (
+    TaskPolicyUnassign = "unassign"
+    TaskPolicyReassign = "reassign"
+    TaskPolicyDelete   = "delete"
+)
+
+// AuthToken is returned to clients after a successful login.
+type AuthToken struct {
+    AccessToken      string              `json:"access_token"`
+    TokenType        string              `json:"token_type"`
+    ExpiresIn        int64               `json:"expires_in"`
+    ExpiresAt        time.Time           `json:"expires_at"`
+    RefreshToken     string              `json:"refresh_token"`
+    RefreshExpiresAt time.Time           `json:"refresh_expires_at"`
+    Role             string              `json:"role"`
+    // OrgID is the organization the tokens work in, if any.
+    OrgID            *primitive.ObjectID `json:"org_id,omitempty"`
 }
 
+var (
+    ErrInvalidCredentials = errors.New("invalid credentials")
+    ErrTaskNotFound = errors.New("task not found")
+    ErrTaskExists   = errors.New("task already exists")
+    ErrInvalidVisibility     = errors.New("invalid visibility, allowed values are: private, shared, org")
+    ErrTaskSharingNotAllowed = errors.New("only the creator of a task can change who can see it")
+    ErrUserNotFound = errors.New("user not found")
+    ErrUserExists   = errors.New("user already exists")
+    ErrAlreadyAdmin = errors.New("user is already an admin")
+    ErrLastAdmin         = errors.New("cannot remove the last active admin")
+    ErrUserDeactivated   = errors.New("account is deactivated")
+    ErrInvalidRole       = errors.New("invalid role: no role with that name exists")
+    ErrInvalidTaskPolicy = errors.New("invalid task policy, allowed policies are: unassign, reassign (with reassign_to), delete")
+    ErrUserNotManageable = errors.New("cannot manage a user whose role grants permissions you do not have")
+)
+
 var AllowedStatuses = []string{"pending", "in-progress", "completed"}
 
 func (t *Task) Validate() error {
@@ -41,9 +105,32 @@ func (t *Task) Validate() error {
     if !t.isValidStatus() {
         return errors.New("invalid task status. Allowed statuses are: pending, in-progress, completed")
     }
+    if t.Visibility != "" && !ValidVisibility(t.Visibility) {
+        return ErrInvalidVisibility
+    }
     return nil
 }
 
+func ValidVisibility(visibility string) bool {
+    return visibility == VisibilityPrivate || visibility == VisibilityShared || visibility == VisibilityOrg
+}
+
+// VisibleTo reports whether the user may see the task.
+func (t *Task) VisibleTo(userID primitive.ObjectID) bool {
+    if t.Visibility == "" || t.Visibility == VisibilityOrg || t.CreatedBy == userID ||
+        (t.AssignedTo != nil && *t.AssignedTo == userID) {
+        return true
+    }
+    if t.Visibility == VisibilityShared {
+        for _, id := range t.SharedWith {
+            if id == userID {
+                return true
+            }
+        }
+    }
+    return false
+}
+
 func (t *Task) isValidStatus() bool {
     for _, allowedStatus := range AllowedStatuses {
         if t.Status == allowedStatus {
@@ -54,54 +141,118 @@ func (t *Task) isValidStatus() bool {
 }
 
 func (u *User) ValidateUser() error {
+    var fields []FieldError
     if u.Username == "" {
-        return errors.New("username cannot be empty")
+        fields = append(fields, FieldError{Field: "username", Code: "required", Message: "username cannot be empty"})
     }
     if u.Password == "" {
-        return errors.New("password cannot be empty")
+        fields = append(fields, FieldError{Field: "password", Code: "required", Message: "password cannot be empty"})
     }
-    return nil
-}
-
-func (u *User) HashPassword() error {
-    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
-    if err != nil {
-        return err
+    if len(fields) > 0 {
+        return &ValidationError{Fields: fields}
     }
-    u.Password = string(hashedPassword)
     return nil
 }
 
-func (u *User) ComparePassword(password string) error {
-    return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-}
-
+// TaskRepository scopes every task to an organization: a task is only found
+// through the organization it belongs to.
 type TaskRepository interface {
-    GetTasks() ([]Task, error)
-    GetTaskByID(id primitive.ObjectID) (Task, bool, error)
+    // GetTasks returns the tasks of query.OrgID.
+    GetTasks(query TaskQuery) (TaskPage, error)
+    GetTaskByID(orgID, id primitive.ObjectID) (Task, bool, error)
     AddTask(task Task) error
-    UpdateTask(id primitive.ObjectID, task Task) error
-    DeleteTask(id primitive.ObjectID) error
+    UpdateTask(orgID, id primitive.ObjectID, task Task) error
+    DeleteTask(orgID, id primitive.ObjectID) error
+    AssignTask(orgID, id primitive.ObjectID, userID primitive.ObjectID) error
+    UnassignTask(orgID, id primitive.ObjectID) error
+    // ReassignUserTasks moves the tasks assigned to from in the given
+    // organizations over to to. It returns the number of tasks changed.
+    ReassignUserTasks(from, to primitive.ObjectID, orgIDs []primitive.ObjectID) (int64, error)
+    // UnassignUserTasks unassigns every task assigned to the user, in every
+    // organization. It returns the number of tasks changed.
+    UnassignUserTasks(userID primitive.ObjectID) (int64, error)
+    // DeleteUserTasks deletes every task assigned to the user, in every
+    // organization.
+    DeleteUserTasks(userID primitive.ObjectID) (int64, error)
+    SetTaskVisibility(orgID, id primitive.ObjectID, visibility string) error
+    // ShareTask and UnshareTask add the user to or remove them from SharedWith.
+    ShareTask(orgID, id primitive.ObjectID, userID primitive.ObjectID) error
+    UnshareTask(orgID, id primitive.ObjectID, userID primitive.ObjectID) error
+    // AdoptOrphanTasks moves the tasks stored before organizations existed
+    // into orgID. It returns the number of tasks moved.
+    AdoptOrphanTasks(orgID primitive.ObjectID) (int64, error)
 }
 
 type UserRepository interface {
     CreateUser(user *User) error
     GetUserByUsername(username string) (*User, error)
+    GetUserByID(id primitive.ObjectID) (*User, error)
+    // GetUserByExternalID returns ErrUserNotFound if no user is linked to the
+    // identity provider subject.
+    GetUserByExternalID(externalID string) (*User, error)
+    // SetExternalID links the user to an identity provider subject. It
+    // returns ErrIdentityConflict if another user is linked to it.
+    SetExternalID(id primitive.ObjectID, externalID string) error
     PromoteUser(username string) error
+    UpdatePassword(id primitive.ObjectID, hashedPassword string) error
+    ListUsers(query UserQuery) (UserPage, error)
+    // SetUserRole, SetUserDeactivated and DeleteUser return ErrLastAdmin
+    // rather than leave no active admin.
+    SetUserRole(username, role string) error
+    SetUserDeactivated(username string, deactivated bool) error
+    DeleteUser(username string) error
 }
 
+// TaskUseCaseInterface only lets the principal see and change tasks of their
+// current organization that are visible to them. Hidden tasks and tasks of
+// other organizations are reported as not found.
 type TaskUseCaseInterface interface {
-    GetTasks() ([]Task, error)
-    GetTask(id primitive.ObjectID) (Task, bool, error)
-    AddTask(task Task) error
-    UpdateTask(id primitive.ObjectID, task Task) error
-    DeleteTask(id primitive.ObjectID) error
+    GetTasks(principal *Principal, query TaskQuery) (TaskPage, error)
+    GetTask(principal *Principal, id primitive.ObjectID) (Task, bool, error)
+    // AddTask adds the task to the principal's current organization.
+    AddTask(principal *Principal, task Task) error
+    UpdateTask(principal *Principal, id primitive.ObjectID, task Task) error
+    DeleteTask(principal *Principal, id primitive.ObjectID) error
+    GetMyTasks(principal *Principal, query TaskQuery) (TaskPage, error)
+    AssignTask(principal *Principal, id primitive.ObjectID, username string) error
+    UnassignTask(principal *Principal, id primitive.ObjectID) error
+    // SetTaskVisibility, ShareTask and UnshareTask are limited to the task's
+    // creator and principals that can read every task.
+    SetTaskVisibility(principal *Principal, id primitive.ObjectID, visibility string) error
+    ShareTask(principal *Principal, id primitive.ObjectID, username string) error
+    UnshareTask(principal *Principal, id primitive.ObjectID, username string) error
 }
 
 type UserUseCaseInterface interface {
     CreateUser(user *User) error
     GetUserByUsername(username string) (*User, error)
     PromoteUser(username string) error
+    // RevokeUserTokens, SetUserDeactivated and DeleteUser return
+    // ErrUserNotManageable if the user's role grants a permission the
+    // principal does not have.
+    RevokeUserTokens(principal *Principal, username string) error
+    ListUsers(query UserQuery) (UserPage, error)
+    SetUserRole(username, role string) error
+    // SetUserDeactivated also signs a deactivated user out of every session.
+    SetUserDeactivated(principal *Principal, username string, deactivated bool) error
+    // DeleteUser removes the user and applies taskPolicy to the tasks
+    // assigned to them. It returns the number of tasks affected.
+    DeleteUser(principal *Principal, username, taskPolicy, reassignTo string) (int64, error)
+}
+
+type AuthUseCaseInterface interface {
+    Login(username, password, ip string) (*AuthToken, *TwoFactorChallenge, error)
+    CompleteTwoFactorLogin(challengeToken, code, ip string) (*AuthToken, error)
+    UnlockUser(username string) error
+    Refresh(refreshToken string) (*AuthToken, error)
+    Logout(refreshToken string, accessTokenID string, accessExpiresAt time.Time) error
+    // LoginExternal issues tokens to a user the identity provider has
+    // authenticated, without checking a password. Like Login, it returns a
+    // challenge instead if the user has two-factor authentication enabled.
+    LoginExternal(user *User) (*AuthToken, *TwoFactorChallenge, error)
+    // SwitchOrganization issues tokens for a new session of the principal in
+    // orgID. It returns ErrNotOrgMember unless they are a member.
+    SwitchOrganization(principal *Principal, orgID primitive.ObjectID) (*AuthToken, error)
 }
 
 type TaskControllerInterface interface {
@@ -110,10 +261,69 @@ type TaskControllerInterface interface {
     AddTask(ctx *gin.Context)
     UpdateTask(ctx *gin.Context)
     DeleteTask(ctx *gin.Context)
+    GetMyTasks(ctx *gin.Context)
+    AssignTask(ctx *gin.Context)
+    UnassignTask(ctx *gin.Context)
+    SetTaskVisibility(ctx *gin.Context)
+    ShareTask(ctx *gin.Context)
+    UnshareTask(ctx *gin.Context)
 }
 
 type UserControllerInterface interface {
     CreateUser(ctx *gin.Context)
+    SetupAdmin(ctx *gin.Context)
     LoginUser(ctx *gin.Context)
+    RefreshToken(ctx *gin.Context)
+    LogoutUser(ctx *gin.Context)
     PromoteUser(ctx *gin.Context)
+    RevokeUserTokens(ctx *gin.Context)
+    CreateAccessToken(ctx *gin.Context)
+    ListAccessTokens(ctx *gin.Context)
+    RevokeAccessToken(ctx *gin.Context)
+    CompleteTwoFactorLogin(ctx *gin.Context)
+    EnrollTwoFactor(ctx *gin.Context)
+    EnableTwoFactor(ctx *gin.Context)
+    DisableTwoFactor(ctx *gin.Context)
+    UnlockUser(ctx *gin.Context)
+    ListUsers(ctx *gin.Context)
+    SetUserRole(ctx *gin.Context)
+    DeactivateUser(ctx *gin.Context)
+    ReactivateUser(ctx *gin.Context)
+    DeleteUser(ctx *gin.Context)
+    ListRoles(ctx *gin.Context)
+    CreateRole(ctx *gin.Context)
+    UpdateRole(ctx *gin.Context)
+    DeleteRole(ctx *gin.Context)
+    CreateOrganization(ctx *gin.Context)
+    ListOrganizations(ctx *gin.Context)
+    SwitchOrganization(ctx *gin.Context)
+    AcceptInvitation(ctx *gin.Context)
+    DeclineInvitation(ctx *gin.Context)
+    ListMembers(ctx *gin.Context)
+    InviteMember(ctx *gin.Context)
+    SetMemberRole(ctx *gin.Context)
+    RemoveMember(ctx *gin.Context)
+    CreateInvite(ctx *gin.Context)
+    ListInvites(ctx *gin.Context)
+    RevokeInvite(ctx *gin.Context)
+    OIDCLogin(ctx *gin.Context)
+    OIDCCallback(ctx *gin.Context)
+    ChangePassword(ctx *gin.Context)
+    IssuePasswordReset(ctx *gin.Context)
+    ResetPassword(ctx *gin.Context)
+}
+
+type SCIMControllerInterface interface {
+    ServiceProviderConfig(ctx *gin.Context)
+    ListUsers(ctx *gin.Context)
+    GetUser(ctx *gin.Context)
+    CreateUser(ctx *gin.Context)
+    ReplaceUser(ctx *gin.Context)
+    PatchUser(ctx *gin.Context)
+    DeleteUser(ctx *gin.Context)
+    ListGroups(ctx *gin.Context)
+    GetGroup(ctx *gin.Context)
+    CreateGroup(ctx *gin.Context)
+    PatchGroup(ctx *gin.Context)
+    DeleteGroup(ctx *gin.Context)
 }
\ No newline at end of file
@@ -0,0 +1,52 @@
+package domain
+
+import "errors"
+
+// ExternalIdentity is a user as an identity provider or directory vouches for
+// them. Subject never changes for a given user; the username may.
+type ExternalIdentity struct {
+    Subject  string
+    Username string
+    Email    string
+    Groups   []string
+}
+
+// GroupRole gives the members of an external group a role.
+type GroupRole struct {
+    Group string
+    Role  string
+}
+
+// ExternalUserSettings decide how external identities are matched to users
+// and which role they get.
+type ExternalUserSettings struct {
+    // GroupRoles are checked in order and the first group the user is in
+    // decides their role, which is updated at every login. Users in none of
+    // the groups get DefaultRole. Without GroupRoles roles are managed here.
+    GroupRoles  []GroupRole
+    DefaultRole string
+    // LinkExisting links an identity signing in for the first time to the
+    // unlinked user with its username. Only safe if users cannot choose
+    // their own username there.
+    LinkExisting bool
+    // AutoProvision creates a user at the first login of an identity that is
+    // not linked to one. Otherwise only linked users can sign in.
+    AutoProvision bool
+}
+
+// RoleFor returns the role for a member of groups.
+func (s ExternalUserSettings) RoleFor(groups []string) string {
+    for _, mapping := range s.GroupRoles {
+        for _, group := range groups {
+            if group == mapping.Group {
+                return mapping.Role
+            }
+        }
+    }
+    return s.DefaultRole
+}
+
+var (
+    ErrIdentityConflict = errors.New("user is already linked to another identity")
+    ErrNotProvisioned   = errors.New("no user is linked to this identity")
+)
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Registration modes decide who may use POST /register.
+const (
+    RegistrationOpen   = "open"
+    RegistrationInvite = "invite"
+    RegistrationClosed = "closed"
+)
+
+// Invite lets one person register with a given username and role, even while
+// registration is invite-only. Only a hash of the token is stored, and it can
+// be used once. If OrgID is set, the new user also joins that organization
+// with the role.
+type Invite struct {
+    ID        primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+    TokenHash string              `json:"-" bson:"token_hash"`
+    Username  string              `json:"username" bson:"username"`
+    Role      string              `json:"role" bson:"role"`
+    OrgID     *primitive.ObjectID `json:"org_id,omitempty" bson:"org_id,omitempty"`
+    CreatedBy primitive.ObjectID  `json:"created_by" bson:"created_by"`
+    CreatedAt time.Time           `json:"created_at" bson:"created_at"`
+    ExpiresAt time.Time           `json:"expires_at" bson:"expires_at"`
+    Used      bool                `json:"-" bson:"used"`
+    Revoked   bool                `json:"-" bson:"revoked"`
+}
+
+var (
+    ErrInviteNotFound         = errors.New("invite not found")
+    ErrInvalidInvite          = errors.New("invalid, expired, revoked or already used invite")
+    ErrInviteRoleNotPermitted = errors.New("only holders of roles.manage can invite users with a role other than user")
+    ErrRegistrationClosed     = errors.New("registration is closed")
+    ErrInviteRequired         = errors.New("registration requires an invite")
+)
+
+type InviteRepository interface {
+    CreateInvite(invite *Invite) error
+    // GetInviteByHash returns ErrInviteNotFound if no invite has the hash.
+    GetInviteByHash(hash string) (*Invite, error)
+    // ListPendingInvites returns the invites that are neither used, revoked
+    // nor expired at now, oldest first.
+    ListPendingInvites(now time.Time) ([]Invite, error)
+    // MarkInviteUsed atomically flags an invite as used. It reports false if
+    // the invite had already been used or was revoked.
+    MarkInviteUsed(id primitive.ObjectID) (bool, error)
+    // RevokeInvite returns ErrInviteNotFound unless the invite exists and is
+    // neither used nor revoked.
+    RevokeInvite(id primitive.ObjectID) error
+    // RevokeUsernameInvites revokes every unused invite for the username.
+    RevokeUsernameInvites(username string) error
+}
+
+type InviteUseCaseInterface interface {
+    // CreateInvite returns the invite and its token, which is not stored.
+    // It replaces earlier invites for the username. The invite joins the
+    // principal's current organization if they can manage it.
+    CreateInvite(principal *Principal, username, role string) (string, *Invite, error)
+    ListInvites() ([]Invite, error)
+    RevokeInvite(id primitive.ObjectID) error
+    // Register creates the user with the invite's role and uses up the
+    // invite. An empty username is taken from the invite.
+    Register(token string, user *User) error
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LoginAttempt counts recent failed logins for one key, either a username or
+// a client IP.
+type LoginAttempt struct {
+    Key         string    `bson:"_id"`
+    Failures    int       `bson:"failures"`
+    LastFailure time.Time `bson:"last_failure"`
+    LockedUntil time.Time `bson:"locked_until"`
+}
+
+// LoginThrottlePolicy controls how failed logins slow down further attempts.
+// Each failure blocks the key for BaseDelay, doubling with every further
+// failure up to MaxDelay. Reaching the failure limit locks the key for
+// LockoutDuration. Failures are forgotten once none occurred for
+// LockoutDuration.
+type LoginThrottlePolicy struct {
+    MaxUserFailures int
+    MaxIPFailures   int
+    BaseDelay       time.Duration
+    MaxDelay        time.Duration
+    LockoutDuration time.Duration
+}
+
+func DefaultLoginThrottlePolicy() LoginThrottlePolicy {
+    return LoginThrottlePolicy{
+        MaxUserFailures: 5,
+        MaxIPFailures:   20,
+        BaseDelay:       time.Second,
+        MaxDelay:        time.Minute,
+        LockoutDuration: 15 * time.Minute,
+    }
+}
+
+var ErrTooManyLoginAttempts = errors.New("too many failed login attempts")
+
+// LockoutError is returned when a login is refused without checking the
+// credentials because of earlier failures.
+type LockoutError struct {
+    RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+    return fmt.Sprintf("%v, try again in %v", ErrTooManyLoginAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockoutError) Is(target error) bool {
+    return target == ErrTooManyLoginAttempts
+}
+
+type LoginAttemptRepository interface {
+    // GetLoginAttempt returns the attempts recorded for key, or a zero
+    // LoginAttempt if there are none.
+    GetLoginAttempt(key string) (*LoginAttempt, error)
+    // RecordLoginFailure adds a failure at the given time and returns the
+    // updated record. Earlier failures are discarded first if the last one
+    // happened before resetBefore. Records of any key whose failures would be
+    // discarded and that is no longer locked at that time are deleted, so
+    // that guesses at many usernames or from many IPs do not pile up.
+    RecordLoginFailure(key string, at time.Time, resetBefore time.Time) (*LoginAttempt, error)
+    // LockLogin blocks key until the given time, unless it is already blocked for longer.
+    LockLogin(key string, until time.Time) error
+    ResetLoginAttempts(key string) error
+}
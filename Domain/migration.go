@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// MigrationRepository records one-time data migrations. Claims are atomic, so
+// each migration runs at most once, even when several servers start together.
+type MigrationRepository interface {
+    // ClaimMigration marks the named migration as done. It reports false if
+    // it already was.
+    ClaimMigration(name string, at time.Time) (bool, error)
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OIDCProvider signs users in at an OpenID Connect identity provider with the
+// authorization code flow and PKCE.
+type OIDCProvider interface {
+    // AuthCodeURL returns the URL to send the user to. codeChallenge is the
+    // S256 PKCE challenge of the verifier later passed to Exchange.
+    AuthCodeURL(state, nonce, codeChallenge string) string
+    // Exchange redeems an authorization code and verifies the ID token the
+    // provider returns for it, including its nonce. Errors caused by the
+    // provider or the token wrap ErrOIDCLoginFailed.
+    Exchange(code, codeVerifier, nonce string) (*ExternalIdentity, error)
+}
+
+// OIDCLogin is a login started at the identity provider and not completed
+// yet. Only a hash of the state is stored, and it can be used once.
+type OIDCLogin struct {
+    ID           primitive.ObjectID `bson:"_id,omitempty"`
+    StateHash    string             `bson:"state_hash"`
+    Nonce        string             `bson:"nonce"`
+    CodeVerifier string             `bson:"code_verifier"`
+    CreatedAt    time.Time          `bson:"created_at"`
+    ExpiresAt    time.Time          `bson:"expires_at"`
+}
+
+var (
+    ErrOIDCNotConfigured = errors.New("single sign-on is not configured")
+    ErrOIDCLoginNotFound = errors.New("login not found")
+    ErrInvalidOIDCState  = errors.New("invalid, expired or already used login state")
+    ErrOIDCLoginFailed   = errors.New("identity provider login failed")
+)
+
+type OIDCLoginRepository interface {
+    CreateOIDCLogin(login *OIDCLogin) error
+    // TakeOIDCLogin atomically removes and returns the login with the state
+    // hash. It returns ErrOIDCLoginNotFound if there is none.
+    TakeOIDCLogin(stateHash string) (*OIDCLogin, error)
+    // DeleteExpiredOIDCLogins removes the logins that expired before now.
+    DeleteExpiredOIDCLogins(now time.Time) error
+}
+
+type OIDCUseCaseInterface interface {
+    // BeginLogin returns the URL to send the user to and the state the
+    // callback has to present.
+    BeginLogin() (string, string, error)
+    // CompleteLogin redeems the code the identity provider sent back with the
+    // state, links or provisions the user and issues tokens to them, or a
+    // challenge if they have two-factor authentication enabled.
+    CompleteLogin(state, code string) (*AuthToken, *TwoFactorChallenge, error)
+}
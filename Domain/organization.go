@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Organization is a workspace with its own members and tasks. Tasks never
+// cross organizations, and a user's permissions over tasks come from their
+// role in the organization they are working in.
+type Organization struct {
+    ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+    Name      string             `json:"name" bson:"name"`
+    CreatedBy primitive.ObjectID `json:"created_by" bson:"created_by"`
+    CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// Membership gives a user a role in an organization. A pending membership is
+// an invitation the user has not accepted yet and grants nothing.
+type Membership struct {
+    OrgID     primitive.ObjectID `json:"org_id" bson:"org_id"`
+    UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+    Role      string             `json:"role" bson:"role"`
+    Pending   bool               `json:"pending" bson:"pending"`
+    InvitedBy primitive.ObjectID `json:"invited_by,omitempty" bson:"invited_by,omitempty"`
+    CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// OrganizationMembership is a membership together with its organization, as
+// listed for the user.
+type OrganizationMembership struct {
+    Organization Organization `json:"organization"`
+    Role         string       `json:"role"`
+    Pending      bool         `json:"pending"`
+    Current      bool         `json:"current"`
+}
+
+// Member is a membership as listed for the other members of an organization.
+type Member struct {
+    Username  string    `json:"username"`
+    Role      string    `json:"role"`
+    Pending   bool      `json:"pending"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// DefaultOrganizationName names the first admin's organization, and the one
+// users and tasks from before organizations are moved into.
+const DefaultOrganizationName = "Default"
+
+var (
+    ErrOrganizationNotFound = errors.New("organization not found")
+    ErrNotOrgMember         = errors.New("user is not a member of the organization")
+    ErrAlreadyOrgMember     = errors.New("user is already a member of or invited to the organization")
+    ErrLastOrgAdmin         = errors.New("cannot remove the last admin of the organization")
+    ErrOrgRoleNotGrantable  = errors.New("cannot give or take away an organization role that grants permissions you do not have")
+    ErrNoOrganization       = errors.New("no organization selected: create one, accept an invitation or switch to one first")
+)
+
+// ValidateOrganizationName trims the name and checks that it is not empty.
+func ValidateOrganizationName(name string) (string, error) {
+    name = strings.TrimSpace(name)
+    if name == "" {
+        return "", &ValidationError{Fields: []FieldError{{Field: "name", Code: "required", Message: "organization name cannot be empty"}}}
+    }
+    if len(name) > 100 {
+        return "", &ValidationError{Fields: []FieldError{{Field: "name", Code: "too_long", Message: "organization name must be at most 100 characters long"}}}
+    }
+    return name, nil
+}
+
+type OrganizationRepository interface {
+    CreateOrganization(org *Organization) error
+    // GetOrganization returns ErrOrganizationNotFound if there is no such organization.
+    GetOrganization(id primitive.ObjectID) (*Organization, error)
+    CountOrganizations() (int64, error)
+    // AddMember returns ErrAlreadyOrgMember if the user is already a member
+    // or has a pending invitation.
+    AddMember(membership *Membership) error
+    // GetMembership returns ErrNotOrgMember if the user is neither a member
+    // nor invited. Pending memberships are returned too.
+    GetMembership(orgID, userID primitive.ObjectID) (*Membership, error)
+    // ListMembers and ListUserMemberships return memberships oldest first,
+    // including pending ones.
+    ListMembers(orgID primitive.ObjectID) ([]Membership, error)
+    ListUserMemberships(userID primitive.ObjectID) ([]Membership, error)
+    // AcceptInvitation returns ErrNotOrgMember if the user has no pending
+    // invitation to the organization.
+    AcceptInvitation(orgID, userID primitive.ObjectID) error
+    // SetMemberRole and RemoveMember return ErrLastOrgAdmin rather than leave
+    // the organization without an admin.
+    SetMemberRole(orgID, userID primitive.ObjectID, role string) error
+    RemoveMember(orgID, userID primitive.ObjectID) error
+    // RemoveUserMemberships removes the user from every organization.
+    RemoveUserMemberships(userID primitive.ObjectID) error
+    // CountMembersWithRole counts memberships, pending or not, with the role
+    // in any organization.
+    CountMembersWithRole(role string) (int64, error)
+}
+
+type OrganizationUseCaseInterface interface {
+    // Initialize runs once, on the first start with organizations. If the
+    // database has users from before organizations, it creates the default
+    // organization and moves every existing user and task into it. It returns
+    // the organization it created, if any.
+    Initialize() (*Organization, error)
+    // CreateOrganization makes the creator the organization's first admin.
+    CreateOrganization(principal *Principal, name string) (*Organization, error)
+    // ListOrganizations returns the organizations the principal belongs to
+    // or is invited to.
+    ListOrganizations(principal *Principal) ([]OrganizationMembership, error)
+    AcceptInvitation(principal *Principal, orgID primitive.ObjectID) error
+    DeclineInvitation(principal *Principal, orgID primitive.ObjectID) error
+    // ListMembers, InviteMember, SetMemberRole and RemoveMember act on the
+    // principal's current organization. InviteMember, SetMemberRole and
+    // RemoveMember return ErrOrgRoleNotGrantable if the role given or the
+    // member's current role grants an organization permission the principal
+    // does not have, and SetMemberRole and RemoveMember return
+    // ErrLastOrgAdmin rather than leave the organization without an admin.
+    ListMembers(principal *Principal) ([]Member, error)
+    InviteMember(principal *Principal, username, role string) error
+    SetMemberRole(principal *Principal, username, role string) error
+    RemoveMember(principal *Principal, username string) error
+    // Authorize sets the principal's permissions: organization permissions
+    // from their role in the current organization and the rest from their
+    // own role. A principal whose organization they no longer belong to is
+    // left without one.
+    Authorize(principal *Principal) error
+}
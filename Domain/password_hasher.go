@@ -0,0 +1,16 @@
+package domain
+
+import "errors"
+
+var ErrUnsupportedPasswordHash = errors.New("unsupported or malformed password hash")
+
+// PasswordHasher hashes passwords for storage. Hashes are self-describing PHC
+// strings such as "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>", so hashes
+// made with older algorithms or parameters can still be verified.
+type PasswordHasher interface {
+    Hash(password string) (string, error)
+    // Verify reports whether password matches hash. needsRehash is set when
+    // the hash was not made with the current algorithm and parameters and
+    // should be replaced by Hash(password) once the password is known.
+    Verify(hash, password string) (match bool, needsRehash bool, err error)
+}
@@ -0,0 +1,154 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrValidation is matched by every ValidationError.
+var ErrValidation = errors.New("validation failed")
+
+// FieldError explains why one field of a request was rejected. Code is stable
+// and meant for clients; Message is meant for people.
+type FieldError struct {
+    Field   string `json:"field"`
+    Code    string `json:"code"`
+    Message string `json:"message"`
+}
+
+// ValidationError lists every problem found in a request, so that clients
+// can show them all at once.
+type ValidationError struct {
+    Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+    messages := make([]string, len(e.Fields))
+    for i, field := range e.Fields {
+        messages[i] = field.Message
+    }
+    return fmt.Sprintf("%v: %s", ErrValidation, strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+    return target == ErrValidation
+}
+
+// CommonPasswordList reports whether a password is known from breaches or
+// password-guessing dictionaries.
+type CommonPasswordList interface {
+    Contains(password string) bool
+}
+
+// PasswordPolicy decides which passwords users may choose.
+type PasswordPolicy struct {
+    MinLength int
+    // MaxLength is in bytes. bcrypt, still supported for hashing, rejects
+    // passwords longer than 72 bytes.
+    MaxLength int
+    // MinCharacterClasses is how many of lower case, upper case, digits and
+    // other characters a password must mix.
+    MinCharacterClasses int
+    RejectUsername      bool
+    // CommonPasswords is consulted when set.
+    CommonPasswords CommonPasswordList
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+    return PasswordPolicy{
+        MinLength:           10,
+        MaxLength:           72,
+        MinCharacterClasses: 2,
+        RejectUsername:      true,
+    }
+}
+
+// Validate checks password against the policy. It returns a ValidationError
+// listing every rule the password breaks, or nil.
+func (p PasswordPolicy) Validate(username, password string) error {
+    var fields []FieldError
+    reject := func(code, message string) {
+        fields = append(fields, FieldError{Field: "password", Code: code, Message: message})
+    }
+
+    if length := len([]rune(password)); length < p.MinLength {
+        reject("too_short", fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+    }
+    if p.MaxLength > 0 && len(password) > p.MaxLength {
+        reject("too_long", fmt.Sprintf("password must be at most %d bytes long", p.MaxLength))
+    }
+    if classes := characterClasses(password); classes < p.MinCharacterClasses {
+        reject("too_few_character_classes", fmt.Sprintf(
+            "password must mix at least %d of lower case letters, upper case letters, digits and symbols", p.MinCharacterClasses))
+    }
+    if p.RejectUsername && resemblesUsername(username, password) {
+        reject("similar_to_username", "password must not contain or resemble the username")
+    }
+    if p.CommonPasswords != nil && isCommonPassword(p.CommonPasswords, password) {
+        reject("common_password", "password is too common and appears in lists of breached passwords")
+    }
+
+    if len(fields) > 0 {
+        return &ValidationError{Fields: fields}
+    }
+    return nil
+}
+
+func characterClasses(password string) int {
+    var lower, upper, digit, other bool
+    for _, r := range password {
+        switch {
+        case unicode.IsLower(r):
+            lower = true
+        case unicode.IsUpper(r):
+            upper = true
+        case unicode.IsDigit(r):
+            digit = true
+        default:
+            other = true
+        }
+    }
+    count := 0
+    for _, present := range []bool{lower, upper, digit, other} {
+        if present {
+            count++
+        }
+    }
+    return count
+}
+
+// resemblesUsername catches passwords built from the username, such as
+// "alice2024!" or the username reversed.
+func resemblesUsername(username, password string) bool {
+    username = strings.ToLower(username)
+    password = strings.ToLower(password)
+    if len(username) < 3 {
+        return username != "" && username == password
+    }
+    return strings.Contains(password, username) ||
+        strings.Contains(password, reverse(username)) ||
+        (len(password) >= 3 && strings.Contains(username, password))
+}
+
+// isCommonPassword also catches common passwords with digits or symbols
+// added at the end, such as "Dragon2024!".
+func isCommonPassword(list CommonPasswordList, password string) bool {
+    password = strings.ToLower(password)
+    if list.Contains(password) {
+        return true
+    }
+    base := strings.TrimRightFunc(password, func(r rune) bool {
+        return !unicode.IsLetter(r)
+    })
+    return len(base) >= 4 && base != password && list.Contains(base)
+}
+
+func reverse(s string) string {
+    runes := []rune(s)
+    for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+        runes[i], runes[j] = runes[j], runes[i]
+    }
+    return string(runes)
+}
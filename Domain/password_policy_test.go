@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type wordList map[string]bool
+
+func (w wordList) Contains(password string) bool {
+    return w[password]
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+    policy := DefaultPasswordPolicy()
+    policy.CommonPasswords = wordList{"dragon": true, "correcthorse": true}
+
+    for _, tc := range []struct {
+        username, password string
+        want               []string
+    }{
+        {"alice", "k7#vQ2!mzP", nil},
+        {"alice", "a", []string{"too_short", "too_few_character_classes"}},
+        {"alice", strings.Repeat("aB3", 25), []string{"too_long"}},
+        {"alice", "onlylowercase", []string{"too_few_character_classes"}},
+        {"alice", "Alice-2024!", []string{"similar_to_username"}},
+        {"alice", "ecila#2024Z", []string{"similar_to_username"}},
+        {"correcthorsebattery", "correcthorse", []string{"similar_to_username", "too_few_character_classes", "common_password"}},
+        {"bob", "CorrectHorse", []string{"common_password"}},
+        {"bob", "Dragon2024!!", []string{"common_password"}},
+    } {
+        err := policy.Validate(tc.username, tc.password)
+        var got []string
+        var validation *ValidationError
+        if errors.As(err, &validation) {
+            for _, field := range validation.Fields {
+                got = append(got, field.Code)
+            }
+        } else if err != nil {
+            t.Fatalf("Validate(%q): unexpected error type %T", tc.password, err)
+        }
+        if !sameCodes(got, tc.want) {
+            t.Errorf("Validate(%q, %q): got %v, want %v", tc.username, tc.password, got, tc.want)
+        }
+        if err != nil && !errors.Is(err, ErrValidation) {
+            t.Errorf("Validate(%q): error does not match ErrValidation", tc.password)
+        }
+    }
+}
+
+func sameCodes(got, want []string) bool {
+    if len(got) != len(want) {
+        return false
+    }
+    seen := map[string]bool{}
+    for _, code := range got {
+        seen[code] = true
+    }
+    for _, code := range want {
+        if !seen[code] {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PasswordResetToken lets a user set a new password without knowing the old
+// one. Only a hash of the token is stored, and it can be used once.
+type PasswordResetToken struct {
+    ID        primitive.ObjectID `bson:"_id,omitempty"`
+    TokenHash string             `bson:"token_hash"`
+    UserID    primitive.ObjectID `bson:"user_id"`
+    CreatedAt time.Time          `bson:"created_at"`
+    ExpiresAt time.Time          `bson:"expires_at"`
+    Used      bool               `bson:"used"`
+}
+
+// PasswordReset is returned to the admin who issued a reset. Token is only
+// set when no PasswordResetNotifier delivered it to the user.
+type PasswordReset struct {
+    Token     string    `json:"reset_token,omitempty"`
+    ExpiresAt time.Time `json:"expires_at"`
+    Delivered bool      `json:"delivered"`
+}
+
+var (
+    ErrPasswordResetTokenNotFound = errors.New("password reset token not found")
+    ErrInvalidPasswordResetToken  = errors.New("invalid, expired or already used password reset token")
+)
+
+type PasswordResetRepository interface {
+    CreatePasswordResetToken(token *PasswordResetToken) error
+    GetPasswordResetTokenByHash(hash string) (*PasswordResetToken, error)
+    // MarkPasswordResetTokenUsed atomically flags an unused token as used. It
+    // reports false if the token had already been used.
+    MarkPasswordResetTokenUsed(id primitive.ObjectID) (bool, error)
+    // InvalidateUserPasswordResetTokens marks all of the user's tokens as used.
+    InvalidateUserPasswordResetTokens(userID primitive.ObjectID) error
+}
+
+// PasswordResetNotifier delivers reset tokens to users, for example by email.
+type PasswordResetNotifier interface {
+    SendPasswordReset(user *User, token string, expiresAt time.Time) error
+}
+
+type PasswordUseCaseInterface interface {
+    // ChangePassword sets a new password for a user who knows the current one.
+    ChangePassword(userID primitive.ObjectID, currentPassword, newPassword string) error
+    // IssuePasswordReset creates a reset token for the user, replacing any
+    // earlier one. It returns ErrUserNotManageable if the user's role grants
+    // a permission the principal does not have.
+    IssuePasswordReset(principal *Principal, username string) (*PasswordReset, error)
+    ResetPassword(token, newPassword string) error
+}
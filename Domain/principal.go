@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PrincipalContextKey is the gin.Context key under which AuthMiddleware
+// stores the authenticated Principal.
+const PrincipalContextKey = "principal"
+
+// Principal is the authenticated caller of a request, taken from the
+// validated access token. OrgID is the organization the caller is working in,
+// and is zero if they have none. Permissions are those of the user's role and
+// of their role in the organization. Scopes is
+// nil for interactive sessions, which are not restricted by scope, and set for
+// personal access tokens.
+type Principal struct {
+    UserID      primitive.ObjectID
+    Username    string
+    Role        string
+    OrgID       primitive.ObjectID
+    TokenID     string
+    IssuedAt    time.Time
+    ExpiresAt   time.Time
+    Scopes      []string
+    Permissions []string
+}
+
+// IsAccessToken reports whether the principal authenticated with a personal
+// access token.
+func (p *Principal) IsAccessToken() bool {
+    return p.Scopes != nil
+}
+
+// HasPermission reports whether the principal's role grants permission and,
+// for personal access tokens, whether one of the token's scopes covers it.
+func (p *Principal) HasPermission(permission string) bool {
+    if !slices.Contains(p.Permissions, permission) {
+        return false
+    }
+    return !p.IsAccessToken() || ScopesGrantPermission(p.Scopes, permission)
+}
+
+// PrincipalFromContext returns the principal set by AuthMiddleware. It reports
+// false on routes that are not behind AuthMiddleware.
+func PrincipalFromContext(ctx *gin.Context) (*Principal, bool) {
+    value, exists := ctx.Get(PrincipalContextKey)
+    if !exists {
+        return nil, false
+    }
+    principal, ok := value.(*Principal)
+    return principal, ok
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is the server-side record of an opaque refresh token. Only a
+// hash of the token is stored. Every token issued by rotating another one
+// belongs to the same family, so a replayed token can revoke all of them.
+// OrgID is the organization of the session, kept when the token is rotated.
+type RefreshToken struct {
+    ID        primitive.ObjectID `bson:"_id,omitempty"`
+    TokenHash string             `bson:"token_hash"`
+    UserID    primitive.ObjectID `bson:"user_id"`
+    FamilyID  primitive.ObjectID `bson:"family_id"`
+    OrgID     primitive.ObjectID `bson:"org_id,omitempty"`
+    CreatedAt time.Time          `bson:"created_at"`
+    ExpiresAt time.Time          `bson:"expires_at"`
+    Used      bool               `bson:"used"`
+    Revoked   bool               `bson:"revoked"`
+}
+
+var (
+    ErrRefreshTokenNotFound = errors.New("refresh token not found")
+    ErrInvalidRefreshToken  = errors.New("invalid or expired refresh token")
+    ErrRefreshTokenReused   = errors.New("refresh token reuse detected, all sessions in this family have been revoked")
+)
+
+type RefreshTokenRepository interface {
+    CreateRefreshToken(token *RefreshToken) error
+    GetRefreshTokenByHash(hash string) (*RefreshToken, error)
+    // MarkRefreshTokenUsed atomically flags an unused token as used. It
+    // reports false if the token had already been used.
+    MarkRefreshTokenUsed(id primitive.ObjectID) (bool, error)
+    RevokeRefreshTokenFamily(familyID primitive.ObjectID) error
+    RevokeUserRefreshTokens(userID primitive.ObjectID) error
+}
@@ -0,0 +1,152 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Permissions a role can grant. Routes require one permission each. The
+// tasks and org permissions apply within an organization and come from the
+// user's role there; see OrgPermission.
+const (
+    PermissionTasksRead    = "tasks.read"
+    // PermissionTasksReadAll lets the holder see and share every task,
+    // whatever its visibility.
+    PermissionTasksReadAll = "tasks.read_all"
+    PermissionTasksCreate  = "tasks.create"
+    PermissionTasksUpdate  = "tasks.update"
+    PermissionTasksDelete  = "tasks.delete"
+    PermissionTasksAssign  = "tasks.assign"
+    PermissionOrgManage    = "org.manage"
+    PermissionUsersRead    = "users.read"
+    PermissionUsersManage  = "users.manage"
+    PermissionRolesManage  = "roles.manage"
+)
+
+var AllPermissions = []string{
+    PermissionTasksRead, PermissionTasksReadAll, PermissionTasksCreate, PermissionTasksUpdate, PermissionTasksDelete, PermissionTasksAssign,
+    PermissionOrgManage, PermissionUsersRead, PermissionUsersManage, PermissionRolesManage,
+}
+
+// scopePermissions lists the permissions each personal access token scope
+// lets the token use, out of those its owner's role grants.
+var scopePermissions = map[string][]string{
+    ScopeTasksRead:  {PermissionTasksRead, PermissionTasksReadAll},
+    ScopeTasksWrite: {PermissionTasksRead, PermissionTasksReadAll, PermissionTasksCreate, PermissionTasksUpdate, PermissionTasksDelete, PermissionTasksAssign},
+    ScopeAdmin:      AllPermissions,
+    ScopeSCIM:       {PermissionUsersRead, PermissionUsersManage, PermissionRolesManage, PermissionOrgManage},
+}
+
+// Role is a named set of permissions. Users have exactly one role of their
+// own and one in each organization they belong to.
+type Role struct {
+    Name        string   `json:"name" bson:"_id"`
+    Description string   `json:"description" bson:"description"`
+    Permissions []string `json:"permissions" bson:"permissions"`
+    // BuiltIn roles cannot be deleted, and the admin role cannot be changed.
+    BuiltIn     bool     `json:"built_in" bson:"built_in"`
+}
+
+var (
+    ErrRoleNotFound     = errors.New("role not found")
+    ErrRoleExists       = errors.New("role already exists")
+    ErrRoleInUse        = errors.New("role is still assigned to users or organization members")
+    ErrBuiltInRole      = errors.New("built-in roles cannot be deleted")
+    ErrAdminRoleChanged = errors.New("the admin role always has every permission and cannot be changed")
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// DefaultRoles are created at startup if they do not exist yet.
+func DefaultRoles() []Role {
+    return []Role{
+        {Name: RoleAdmin, Description: "Full access", Permissions: slices.Clone(AllPermissions), BuiltIn: true},
+        {Name: RoleUser, Description: "Read tasks", Permissions: []string{PermissionTasksRead}, BuiltIn: true},
+    }
+}
+
+// ValidRoleName reports whether name can name a role: a lower case letter
+// followed by up to 31 lower case letters, digits, hyphens or underscores.
+func ValidRoleName(name string) bool {
+    return roleNamePattern.MatchString(name)
+}
+
+// ValidPermission reports whether permission is one of AllPermissions.
+func ValidPermission(permission string) bool {
+    return slices.Contains(AllPermissions, permission)
+}
+
+// OrgPermission reports whether permission is granted by the user's role in
+// their current organization rather than by their own role.
+func OrgPermission(permission string) bool {
+    return strings.HasPrefix(permission, "tasks.") || permission == PermissionOrgManage
+}
+
+// Validate checks the role name and permissions and removes duplicate
+// permissions.
+func (r *Role) Validate() error {
+    var fields []FieldError
+    if r.Name == "" {
+        fields = append(fields, FieldError{Field: "name", Code: "required", Message: "role name cannot be empty"})
+    } else if !ValidRoleName(r.Name) {
+        fields = append(fields, FieldError{Field: "name", Code: "invalid", Message: "role name must start with a lower case letter and contain at most 32 lower case letters, digits, hyphens or underscores"})
+    }
+    permissions := []string{}
+    for _, permission := range r.Permissions {
+        if !ValidPermission(permission) {
+            fields = append(fields, FieldError{Field: "permissions", Code: "unknown", Message: fmt.Sprintf("unknown permission %q, allowed permissions are: %s", permission, strings.Join(AllPermissions, ", "))})
+            continue
+        }
+        if !slices.Contains(permissions, permission) {
+            permissions = append(permissions, permission)
+        }
+    }
+    if len(fields) > 0 {
+        return &ValidationError{Fields: fields}
+    }
+    r.Permissions = permissions
+    return nil
+}
+
+// ScopesGrantPermission reports whether a personal access token with the
+// given scopes may use permission.
+func ScopesGrantPermission(scopes []string, permission string) bool {
+    for _, scope := range scopes {
+        if slices.Contains(scopePermissions[scope], permission) {
+            return true
+        }
+    }
+    return false
+}
+
+// ScopePermissions returns the permissions a personal access token with the
+// scope may use.
+func ScopePermissions(scope string) []string {
+    return slices.Clone(scopePermissions[scope])
+}
+
+type RoleRepository interface {
+    // CreateRole returns ErrRoleExists if a role with the same name exists.
+    CreateRole(role *Role) error
+    GetRole(name string) (*Role, error)
+    // ListRoles returns every role, ordered by name.
+    ListRoles() ([]Role, error)
+    // UpdateRole replaces the description and permissions of the role.
+    UpdateRole(role *Role) error
+    DeleteRole(name string) error
+}
+
+type RoleUseCaseInterface interface {
+    // Initialize creates the default roles that do not exist yet and gives
+    // the admin role every permission.
+    Initialize() error
+    ListRoles() ([]Role, error)
+    CreateRole(role *Role) error
+    UpdateRole(role *Role) error
+    // DeleteRole returns ErrRoleInUse while any user or organization member
+    // has the role.
+    DeleteRole(name string) error
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestPrincipalHasPermission(t *testing.T) {
+    lead := []string{PermissionTasksRead, PermissionTasksCreate, PermissionTasksUpdate}
+
+    for _, tc := range []struct {
+        name        string
+        permissions []string
+        scopes      []string
+        permission  string
+        want        bool
+    }{
+        {"session granted", lead, nil, PermissionTasksUpdate, true},
+        {"session not granted", lead, nil, PermissionTasksDelete, false},
+        {"read scope", lead, []string{ScopeTasksRead}, PermissionTasksRead, true},
+        {"read scope cannot write", lead, []string{ScopeTasksRead}, PermissionTasksUpdate, false},
+        {"write scope", lead, []string{ScopeTasksWrite}, PermissionTasksCreate, true},
+        {"write scope limited by role", lead, []string{ScopeTasksWrite}, PermissionTasksDelete, false},
+        {"admin scope limited by role", lead, []string{ScopeAdmin}, PermissionUsersManage, false},
+        {"admin scope", AllPermissions, []string{ScopeAdmin}, PermissionRolesManage, true},
+        {"write scope cannot manage users", AllPermissions, []string{ScopeTasksWrite}, PermissionUsersManage, false},
+        {"scim scope", AllPermissions, []string{ScopeSCIM}, PermissionUsersManage, true},
+        {"scim scope cannot read tasks", AllPermissions, []string{ScopeSCIM}, PermissionTasksRead, false},
+        {"token without scopes", AllPermissions, []string{}, PermissionTasksRead, false},
+    } {
+        principal := &Principal{Permissions: tc.permissions, Scopes: tc.scopes}
+        if got := principal.HasPermission(tc.permission); got != tc.want {
+            t.Errorf("%s: HasPermission(%q) = %v, want %v", tc.name, tc.permission, got, tc.want)
+        }
+    }
+}
+
+func TestRoleValidate(t *testing.T) {
+    role := &Role{Name: "lead", Permissions: []string{PermissionTasksRead, PermissionTasksUpdate, PermissionTasksRead}}
+    if err := role.Validate(); err != nil {
+        t.Fatalf("Validate: %v", err)
+    }
+    if !slices.Equal(role.Permissions, []string{PermissionTasksRead, PermissionTasksUpdate}) {
+        t.Fatalf("Validate: permissions %v, want duplicates removed", role.Permissions)
+    }
+
+    for _, role := range []Role{
+        {Name: ""},
+        {Name: "Lead"},
+        {Name: "9lives"},
+        {Name: "lead", Permissions: []string{"tasks.write"}},
+    } {
+        if err := role.Validate(); !errors.Is(err, ErrValidation) {
+            t.Errorf("Validate(%+v): got %v, want a validation error", role, err)
+        }
+    }
+}
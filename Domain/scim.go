@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SCIMBasePath is where the SCIM 2.0 endpoints are served.
+const SCIMBasePath = "/scim/v2"
+
+// SCIM schema URNs, from RFC 7643 and RFC 7644.
+const (
+    SCIMSchemaUser          = "urn:ietf:params:scim:schemas:core:2.0:User"
+    SCIMSchemaGroup         = "urn:ietf:params:scim:schemas:core:2.0:Group"
+    SCIMSchemaListResponse  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+    SCIMSchemaPatchOp       = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+    SCIMSchemaError         = "urn:ietf:params:scim:api:messages:2.0:Error"
+    SCIMSchemaServiceConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+const (
+    DefaultSCIMPageSize = 100
+    MaxSCIMPageSize     = 200
+)
+
+// SCIMUser is a user as SCIM represents it. The ID is the user's ID and
+// userName their username. Attributes the task manager does not store, such
+// as names and emails, are accepted and ignored. Password is only read.
+type SCIMUser struct {
+    Schemas  []string     `json:"schemas"`
+    ID       string       `json:"id,omitempty"`
+    UserName string       `json:"userName"`
+    Active   *bool        `json:"active,omitempty"`
+    Password string       `json:"password,omitempty"`
+    // Groups holds the group of the user's role. It is read-only; users are
+    // moved between groups through the groups' members.
+    Groups   []SCIMMember `json:"groups,omitempty"`
+    Meta     *SCIMMeta    `json:"meta,omitempty"`
+}
+
+// SCIMGroup is a role as SCIM represents it: its ID and display name are the
+// role name, and its members the users who have the role.
+type SCIMGroup struct {
+    Schemas     []string     `json:"schemas"`
+    ID          string       `json:"id,omitempty"`
+    DisplayName string       `json:"displayName"`
+    Members     []SCIMMember `json:"members"`
+    Meta        *SCIMMeta    `json:"meta,omitempty"`
+}
+
+// SCIMMember refers to a user from a group, or to a group from a user.
+type SCIMMember struct {
+    Value   string `json:"value"`
+    Display string `json:"display,omitempty"`
+}
+
+type SCIMMeta struct {
+    ResourceType string `json:"resourceType"`
+    Location     string `json:"location"`
+}
+
+// SCIMListResponse is one page of users or groups. StartIndex is 1-based.
+type SCIMListResponse struct {
+    Schemas      []string    `json:"schemas"`
+    TotalResults int         `json:"totalResults"`
+    StartIndex   int         `json:"startIndex"`
+    ItemsPerPage int         `json:"itemsPerPage"`
+    Resources    interface{} `json:"Resources"`
+}
+
+// SCIMPatch is a PATCH request body. The operations are applied in order, and
+// those before a failing one stay applied.
+type SCIMPatch struct {
+    Schemas    []string             `json:"schemas"`
+    Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+// SCIMPatchOperation is an add, remove or replace. Op is case-insensitive, as
+// some clients send "Replace". Without a Path, Value is an object of
+// attributes.
+type SCIMPatchOperation struct {
+    Op    string          `json:"op"`
+    Path  string          `json:"path,omitempty"`
+    Value json.RawMessage `json:"value,omitempty"`
+}
+
+// SCIMListQuery selects users or groups. Filter is the raw filter parameter;
+// a Count of 0 only asks for totalResults.
+type SCIMListQuery struct {
+    Filter     string
+    StartIndex int
+    Count      int
+}
+
+var (
+    ErrInvalidSCIMFilter  = errors.New(`unsupported filter, only attribute eq "value" is supported`)
+    ErrInvalidSCIMRequest = errors.New("invalid SCIM request")
+    ErrSCIMImmutable      = errors.New("attribute cannot be changed")
+)
+
+var scimFilterPattern = regexp.MustCompile(`(?i)^\s*([a-z][a-z0-9._]*)\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// Normalize limits the paging parameters to the ranges RFC 7644 allows.
+func (q *SCIMListQuery) Normalize() {
+    if q.StartIndex < 1 {
+        q.StartIndex = 1
+    }
+    if q.Count < 0 {
+        q.Count = 0
+    }
+    if q.Count > MaxSCIMPageSize {
+        q.Count = MaxSCIMPageSize
+    }
+}
+
+// ParseFilter returns the value an equality filter on attribute asks for. It
+// reports false without a filter. Attribute names are case-insensitive.
+func (q *SCIMListQuery) ParseFilter(attribute string) (string, bool, error) {
+    if strings.TrimSpace(q.Filter) == "" {
+        return "", false, nil
+    }
+    match := scimFilterPattern.FindStringSubmatch(q.Filter)
+    if match == nil || !strings.EqualFold(match[1], attribute) {
+        return "", false, fmt.Errorf("%w: filter by %s", ErrInvalidSCIMFilter, attribute)
+    }
+    var value string
+    if err := json.Unmarshal([]byte(match[2]), &value); err != nil {
+        return "", false, ErrInvalidSCIMFilter
+    }
+    return value, true, nil
+}
+
+// SCIMUseCaseInterface keeps users and roles in sync with an identity
+// provider's SCIM client.
+type SCIMUseCaseInterface interface {
+    ListUsers(query SCIMListQuery) (*SCIMListResponse, error)
+    GetUser(id string) (*SCIMUser, error)
+    // CreateUser creates the user with the user role, and adds them to the
+    // principal's current organization if they can manage it. Users created
+    // without a password can only sign in through single sign-on or LDAP.
+    CreateUser(principal *Principal, user *SCIMUser) (*SCIMUser, error)
+    // ReplaceUser and PatchUser can change whether the user is active and
+    // their password, but not their userName. Like DeleteUser, they return
+    // ErrUserNotManageable for users whose role grants a permission the
+    // principal does not have.
+    ReplaceUser(principal *Principal, id string, user *SCIMUser) (*SCIMUser, error)
+    PatchUser(principal *Principal, id string, patch *SCIMPatch) (*SCIMUser, error)
+    DeleteUser(principal *Principal, id string) error
+    ListGroups(query SCIMListQuery) (*SCIMListResponse, error)
+    GetGroup(id string) (*SCIMGroup, error)
+    // CreateGroup creates a role without permissions and gives it to the
+    // members.
+    CreateGroup(group *SCIMGroup) (*SCIMGroup, error)
+    // PatchGroup adds, removes or replaces members. Users removed from a
+    // group go back to the user role.
+    PatchGroup(id string, patch *SCIMPatch) (*SCIMGroup, error)
+    DeleteGroup(id string) error
+}
@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+    DefaultTaskPageSize = 20
+    MaxTaskPageSize     = 100
+)
+
+// Sort keys accepted by TaskQuery.SortBy. Every sort is tie-broken by task ID
+// so that paging through results is stable.
+const (
+    SortByDueDate = "due_date"
+    SortByTitle   = "title"
+    SortByStatus  = "status"
+    SortByID      = "id"
+)
+
+var (
+    ErrInvalidTaskQuery = errors.New("invalid task query")
+    ErrInvalidCursor    = fmt.Errorf("%w: malformed or mismatched cursor", ErrInvalidTaskQuery)
+)
+
+type TaskQuery struct {
+    // OrgID is the organization whose tasks are returned.
+    OrgID         primitive.ObjectID
+    Status        string
+    DueAfter      time.Time
+    DueBefore     time.Time
+    TitleContains string
+    AssignedTo    *primitive.ObjectID
+    // VisibleTo limits the results to tasks the user may see.
+    VisibleTo     *primitive.ObjectID
+    SortBy        string
+    Descending    bool
+    Limit         int
+    Cursor        string
+}
+
+type TaskPage struct {
+    Tasks      []Task `json:"tasks"`
+    NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// TaskCursor is the decoded form of TaskQuery.Cursor. It records the sort
+// position of the last task on the previous page.
+type TaskCursor struct {
+    SortBy     string             `json:"s"`
+    Descending bool               `json:"d,omitempty"`
+    Value      string             `json:"v,omitempty"`
+    ID         primitive.ObjectID `json:"i"`
+}
+
+// Normalize applies defaults and validates the query.
+func (q *TaskQuery) Normalize() error {
+    if q.SortBy == "" {
+        q.SortBy = SortByDueDate
+    }
+    switch q.SortBy {
+    case SortByDueDate, SortByTitle, SortByStatus, SortByID:
+    default:
+        return fmt.Errorf("%w: allowed sort keys are: due_date, title, status, id", ErrInvalidTaskQuery)
+    }
+    if q.Status != "" {
+        task := Task{Status: q.Status}
+        if !task.isValidStatus() {
+            return fmt.Errorf("%w: allowed statuses are: pending, in-progress, completed", ErrInvalidTaskQuery)
+        }
+    }
+    if !q.DueAfter.IsZero() && !q.DueBefore.IsZero() && q.DueBefore.Before(q.DueAfter) {
+        return fmt.Errorf("%w: due_before must not be earlier than due_after", ErrInvalidTaskQuery)
+    }
+    if q.Limit <= 0 {
+        q.Limit = DefaultTaskPageSize
+    }
+    if q.Limit > MaxTaskPageSize {
+        q.Limit = MaxTaskPageSize
+    }
+    if q.Cursor != "" {
+        if _, err := q.DecodeCursor(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// DecodeCursor returns the position encoded in q.Cursor. A cursor is only
+// valid for the sort order it was produced with.
+func (q *TaskQuery) DecodeCursor() (TaskCursor, error) {
+    var cursor TaskCursor
+    raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+    if err != nil {
+        return cursor, ErrInvalidCursor
+    }
+    if err := json.Unmarshal(raw, &cursor); err != nil {
+        return cursor, ErrInvalidCursor
+    }
+    if cursor.SortBy != q.SortBy || cursor.Descending != q.Descending || cursor.ID.IsZero() {
+        return cursor, ErrInvalidCursor
+    }
+    if cursor.SortBy == SortByDueDate {
+        if _, err := time.Parse(time.RFC3339Nano, cursor.Value); err != nil {
+            return cursor, ErrInvalidCursor
+        }
+    }
+    return cursor, nil
+}
+
+// EncodeCursor returns an opaque cursor pointing just after task.
+func (q *TaskQuery) EncodeCursor(task Task) string {
+    cursor := TaskCursor{SortBy: q.SortBy, Descending: q.Descending, ID: task.ID}
+    switch q.SortBy {
+    case SortByDueDate:
+        cursor.Value = task.DueDate.UTC().Format(time.RFC3339Nano)
+    case SortByTitle:
+        cursor.Value = task.Title
+    case SortByStatus:
+        cursor.Value = task.Status
+    }
+    raw, _ := json.Marshal(cursor)
+    return base64.RawURLEncoding.EncodeToString(raw)
+}
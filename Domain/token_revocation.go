@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TokenRevocationRepository records access tokens that must be rejected before
+// they expire, either individually by their jti claim or for a whole user by
+// a cut-off time.
+type TokenRevocationRepository interface {
+    RevokeToken(tokenID string, expiresAt time.Time) error
+    IsTokenRevoked(tokenID string) (bool, error)
+    // RevokeUserTokens invalidates every token issued to the user before the given time.
+    RevokeUserTokens(userID primitive.ObjectID, before time.Time) error
+    // UserTokensRevokedBefore returns the latest cut-off for the user, or the zero time.
+    UserTokensRevokedBefore(userID primitive.ObjectID) (time.Time, error)
+}
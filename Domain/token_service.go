@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TokenService issues access tokens and turns valid ones back into the
+// principal they were issued to.
+type TokenService interface {
+    // GenerateToken issues an access token for the user working in orgID,
+    // which may be zero.
+    GenerateToken(user *User, orgID primitive.ObjectID) (string, time.Time, error)
+    ValidateToken(token string) (*Principal, error)
+    // GenerateChallengeToken issues a short-lived token showing that the user
+    // passed the password step of a two-step login. It is not accepted by
+    // ValidateToken.
+    GenerateChallengeToken(user *User) (string, time.Time, error)
+    ValidateChallengeToken(token string) (*Principal, error)
+    // JWKS returns the public keys tokens can be verified with. It is empty
+    // when tokens are signed with a shared secret.
+    JWKS() []JSONWebKey
+}
+
+// JSONWebKey is a public verification key in RFC 7517 format.
+type JSONWebKey struct {
+    KeyType   string `json:"kty"`
+    KeyID     string `json:"kid"`
+    Use       string `json:"use"`
+    Algorithm string `json:"alg"`
+    Curve     string `json:"crv,omitempty"`
+    X         string `json:"x,omitempty"`
+    Y         string `json:"y,omitempty"`
+    N         string `json:"n,omitempty"`
+    E         string `json:"e,omitempty"`
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TwoFactor is a user's TOTP enrollment. It is created disabled and only
+// enabled once the user proves their authenticator app produces valid codes.
+// Recovery codes are stored as hashes and each can be used once.
+type TwoFactor struct {
+    UserID        primitive.ObjectID `bson:"_id"`
+    Secret        string             `bson:"secret"`
+    Enabled       bool               `bson:"enabled"`
+    RecoveryCodes []string           `bson:"recovery_codes"`
+    LastUsedStep  int64              `bson:"last_used_step"`
+    CreatedAt     time.Time          `bson:"created_at"`
+}
+
+// TwoFactorEnrollment is returned when a user starts enrolling. OTPAuthURI is
+// meant to be rendered as a QR code for authenticator apps.
+type TwoFactorEnrollment struct {
+    Secret     string `json:"secret"`
+    OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorChallenge is returned by the password step of a login when the
+// user has two-factor authentication enabled. The challenge token must be
+// exchanged together with a TOTP or recovery code for an AuthToken.
+type TwoFactorChallenge struct {
+    TwoFactorRequired bool      `json:"two_factor_required"`
+    ChallengeToken    string    `json:"challenge_token"`
+    ExpiresAt         time.Time `json:"expires_at"`
+}
+
+var (
+    ErrTwoFactorNotFound       = errors.New("two-factor authentication has not been set up")
+    ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+    ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+    ErrInvalidChallenge        = errors.New("invalid or expired login challenge")
+)
+
+type TwoFactorRepository interface {
+    // SaveTwoFactor creates or replaces a pending enrollment. It returns
+    // ErrTwoFactorAlreadyEnabled if the user already has 2FA enabled.
+    SaveTwoFactor(twoFactor *TwoFactor) error
+    GetTwoFactor(userID primitive.ObjectID) (*TwoFactor, error)
+    EnableTwoFactor(userID primitive.ObjectID, recoveryCodes []string) error
+    DeleteTwoFactor(userID primitive.ObjectID) error
+    // UseTOTPStep atomically records a TOTP time step as used. It reports
+    // false if the same or a later step was already used, so codes cannot be
+    // replayed.
+    UseTOTPStep(userID primitive.ObjectID, step int64) (bool, error)
+    // UseRecoveryCode atomically removes a recovery code hash. It reports
+    // false if the code was not found.
+    UseRecoveryCode(userID primitive.ObjectID, codeHash string) (bool, error)
+}
+
+type TwoFactorUseCaseInterface interface {
+    Enroll(userID primitive.ObjectID) (*TwoFactorEnrollment, error)
+    // Enable verifies a code from the pending enrollment, enables 2FA and
+    // returns the recovery codes. They cannot be retrieved again.
+    Enable(userID primitive.ObjectID, code string) ([]string, error)
+    Disable(userID primitive.ObjectID, code string) error
+}
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+    DefaultUserPageSize = 50
+    MaxUserPageSize     = 200
+)
+
+// Values accepted by UserQuery.Status.
+const (
+    UserStatusActive      = "active"
+    UserStatusDeactivated = "deactivated"
+)
+
+var ErrInvalidUserQuery = errors.New("invalid user query")
+
+// UserQuery selects a page of users, ordered by username.
+type UserQuery struct {
+    Role   string
+    Status string
+    Limit  int
+    Cursor string
+}
+
+type UserPage struct {
+    Users      []UserSummary `json:"users"`
+    NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// UserSummary is how users are listed to admins. It leaves out the password hash.
+type UserSummary struct {
+    ID          string `json:"id"`
+    Username    string `json:"username"`
+    Role        string `json:"role"`
+    Deactivated bool   `json:"deactivated"`
+}
+
+func NewUserSummary(user User) UserSummary {
+    return UserSummary{ID: user.ID.Hex(), Username: user.Username, Role: user.Role, Deactivated: user.Deactivated}
+}
+
+type userCursor struct {
+    Username string `json:"u"`
+}
+
+// Normalize applies defaults and validates the query.
+func (q *UserQuery) Normalize() error {
+    if q.Role != "" && !ValidRoleName(q.Role) {
+        return fmt.Errorf("%w: invalid role name", ErrInvalidUserQuery)
+    }
+    switch q.Status {
+    case "", UserStatusActive, UserStatusDeactivated:
+    default:
+        return fmt.Errorf("%w: allowed statuses are: active, deactivated", ErrInvalidUserQuery)
+    }
+    if q.Limit <= 0 {
+        q.Limit = DefaultUserPageSize
+    }
+    if q.Limit > MaxUserPageSize {
+        q.Limit = MaxUserPageSize
+    }
+    if q.Cursor != "" {
+        if _, err := q.After(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// After returns the username the page starts after, or "" for the first page.
+func (q *UserQuery) After() (string, error) {
+    if q.Cursor == "" {
+        return "", nil
+    }
+    var cursor userCursor
+    raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+    if err != nil || json.Unmarshal(raw, &cursor) != nil || cursor.Username == "" {
+        return "", fmt.Errorf("%w: malformed cursor", ErrInvalidUserQuery)
+    }
+    return cursor.Username, nil
+}
+
+// EncodeCursor returns an opaque cursor pointing just after user.
+func (q *UserQuery) EncodeCursor(user User) string {
+    raw, _ := json.Marshal(userCursor{Username: user.Username})
+    return base64.RawURLEncoding.EncodeToString(raw)
+}
@@ -1,14 +1,25 @@
 package infrastructure
 
 import (
+    "errors"
+    "slices"
     "strings"
     "net/http"
+    "time"
 
+    "github.com/Hailemari/clean_architecture_task_manager/Domain"
     "github.com/gin-gonic/gin"
-    "github.com/dgrijalva/jwt-go"
+    "go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware accepts a valid bearer token unless it has been revoked,
+// either by its jti or because it was issued before the user's revocation cut-off,
+// or its user has since been deactivated or deleted.
+// Personal access tokens are recognised by their prefix and are revoked
+// individually instead. The principal is given the permissions of the user's
+// current role and of their current role in the token's organization, so
+// changes to roles and memberships apply to the next request.
+func AuthMiddleware(tokens domain.TokenService, revocations domain.TokenRevocationRepository, users domain.UserRepository, orgs domain.OrganizationUseCaseInterface, accessTokens domain.AccessTokenUseCaseInterface) gin.HandlerFunc {
     return func(ctx *gin.Context) {
         authHeader := ctx.GetHeader("Authorization")
         if authHeader == "" {
@@ -24,48 +35,131 @@ func AuthMiddleware() gin.HandlerFunc {
             return
         }
 
-        token, err := ValidateToken(parts[1])
+        if strings.HasPrefix(parts[1], domain.AccessTokenPrefix) {
+            principal, err := accessTokens.Authenticate(parts[1])
+            if err != nil {
+                if errors.Is(err, domain.ErrInvalidAccessToken) {
+                    ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid, expired or revoked access token"})
+                } else if errors.Is(err, domain.ErrUserDeactivated) {
+                    ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
+                } else {
+                    ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify token"})
+                }
+                ctx.Abort()
+                return
+            }
+            if err := orgs.Authorize(principal); err != nil {
+                ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify token"})
+                ctx.Abort()
+                return
+            }
+            ctx.Set(domain.PrincipalContextKey, principal)
+            ctx.Next()
+            return
+        }
+
+        principal, err := tokens.ValidateToken(parts[1])
         if err != nil {
             ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
             ctx.Abort()
             return
         }
 
-        claims, ok := token.Claims.(jwt.MapClaims)
-        if !ok || !token.Valid {
-            ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+        revoked, err := isRevoked(revocations, principal.TokenID, principal.UserID, principal.IssuedAt)
+        if err != nil {
+            ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify token"})
+            ctx.Abort()
+            return
+        }
+        if revoked {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+            ctx.Abort()
+            return
+        }
+
+        user, err := users.GetUserByID(principal.UserID)
+        if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+            ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify token"})
+            ctx.Abort()
+            return
+        }
+        if err != nil || user.Deactivated {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated or no longer exists"})
+            ctx.Abort()
+            return
+        }
+        principal.Role = user.Role
+        if err := orgs.Authorize(principal); err != nil {
+            ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify token"})
             ctx.Abort()
             return
         }
 
-        ctx.Set("user", claims)
+        ctx.Set(domain.PrincipalContextKey, principal)
         ctx.Next()
     }
 }
 
-func AdminMiddleware() gin.HandlerFunc {
+func isRevoked(revocations domain.TokenRevocationRepository, tokenID string, userID primitive.ObjectID, issuedAt time.Time) (bool, error) {
+    revoked, err := revocations.IsTokenRevoked(tokenID)
+    if err != nil || revoked {
+        return revoked, err
+    }
+    cutoff, err := revocations.UserTokensRevokedBefore(userID)
+    if err != nil {
+        return false, err
+    }
+    // iat and the cut-off are compared to the millisecond, the precision of
+    // both. Tokens issued in the cut-off's millisecond may have been issued
+    // before it, so they are revoked too.
+    return !cutoff.IsZero() && !issuedAt.After(cutoff.Truncate(TokenTimePrecision)), nil
+}
+
+// RequirePermission rejects callers whose role does not grant permission, and
+// personal access tokens without a scope that covers it. Organization
+// permissions also need the caller to be working in an organization.
+func RequirePermission(permission string) gin.HandlerFunc {
     return func(ctx *gin.Context) {
-        user, exists := ctx.Get("user")
+        principal, exists := domain.PrincipalFromContext(ctx)
         if !exists {
             ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
             ctx.Abort()
             return
         }
 
-        claims, ok := user.(jwt.MapClaims)
-        if !ok {
-            ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user claims"})
+        if !principal.HasPermission(permission) {
+            if domain.OrgPermission(permission) && principal.OrgID.IsZero() {
+                ctx.JSON(http.StatusForbidden, gin.H{"error": "No organization selected: create one, accept an invitation or switch to one first"})
+            } else if principal.IsAccessToken() && slices.Contains(principal.Permissions, permission) {
+                ctx.JSON(http.StatusForbidden, gin.H{"error": "Token has no scope that grants the " + permission + " permission"})
+            } else {
+                ctx.JSON(http.StatusForbidden, gin.H{"error": "The " + permission + " permission is required"})
+            }
+            ctx.Abort()
+            return
+        }
+
+        ctx.Next()
+    }
+}
+
+// SessionOnlyMiddleware rejects personal access tokens, so that a leaked token
+// cannot be used to mint further tokens or end the owner's sessions.
+func SessionOnlyMiddleware() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        principal, exists := domain.PrincipalFromContext(ctx)
+        if !exists {
+            ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
             ctx.Abort()
             return
         }
 
-        role, ok := claims["role"].(string)
-        if !ok || role != "admin" {
-            ctx.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+        if principal.IsAccessToken() {
+            ctx.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used with a personal access token"})
             ctx.Abort()
             return
         }
 
         ctx.Next()
     }
-}
\ No newline at end of file
+}
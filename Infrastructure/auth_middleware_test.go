@@ -0,0 +1,52 @@
+package infrastructure
+
+import (
+    "testing"
+    "time"
+
+    "github.com/Hailemari/clean_architecture_task_manager/Domain"
+    "github.com/Hailemari/clean_architecture_task_manager/Repositories"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestIsRevokedAfterUserCutoff(t *testing.T) {
+    revocations := repositories.NewInMemoryTokenRevocationRepository()
+    service := testJWTService(t)
+    user := &domain.User{ID: primitive.NewObjectID(), Username: "alice", Role: domain.RoleUser}
+
+    cutoff := time.Now()
+    if err := revocations.RevokeUserTokens(user.ID, cutoff); err != nil {
+        t.Fatalf("RevokeUserTokens: %v", err)
+    }
+    // Tokens issued in the cut-off's millisecond may predate it.
+    if revoked, _ := isRevoked(revocations, "same", user.ID, cutoff.Truncate(TokenTimePrecision)); !revoked {
+        t.Fatal("isRevoked: a token issued in the millisecond of the cut-off was accepted")
+    }
+    before := cutoff.Add(-TokenTimePrecision)
+    if revoked, _ := isRevoked(revocations, "old", user.ID, before); !revoked {
+        t.Fatal("isRevoked: a token issued the millisecond before the cut-off was accepted")
+    }
+
+    time.Sleep(time.Until(cutoff.Truncate(TokenTimePrecision).Add(TokenTimePrecision)))
+    token, _, err := service.GenerateToken(user, primitive.NilObjectID)
+    if err != nil {
+        t.Fatalf("GenerateToken: %v", err)
+    }
+    principal, err := service.ValidateToken(token)
+    if err != nil {
+        t.Fatalf("ValidateToken: %v", err)
+    }
+    if revoked, err := isRevoked(revocations, principal.TokenID, user.ID, principal.IssuedAt); err != nil || revoked {
+        t.Fatalf("isRevoked for a token issued right after the cut-off: got %v, err=%v, want false", revoked, err)
+    }
+    if revoked, _ := isRevoked(revocations, "other", primitive.NewObjectID(), before); revoked {
+        t.Fatal("isRevoked: the cut-off applied to another user")
+    }
+
+    if err := revocations.RevokeToken(principal.TokenID, principal.ExpiresAt); err != nil {
+        t.Fatalf("RevokeToken: %v", err)
+    }
+    if revoked, _ := isRevoked(revocations, principal.TokenID, user.ID, principal.IssuedAt); !revoked {
+        t.Fatal("isRevoked: a revoked token ID was accepted")
+    }
+}
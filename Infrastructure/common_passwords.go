@@ -0,0 +1,48 @@
+package infrastructure
+
+import (
+    "bufio"
+    _ "embed"
+    "os"
+    "strings"
+
+    "github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+//go:embed common_passwords.txt
+var bundledCommonPasswords string
+
+// CommonPasswordList is a set of lower-case passwords.
+type CommonPasswordList map[string]struct{}
+
+// NewCommonPasswordList returns the bundled list of common and breached
+// passwords, extended with the entries of extraFile when it is not empty.
+// Files hold one password per line; lines starting with # are ignored.
+func NewCommonPasswordList(extraFile string) (domain.CommonPasswordList, error) {
+    list := CommonPasswordList{}
+    list.add(bundledCommonPasswords)
+    if extraFile != "" {
+        data, err := os.ReadFile(extraFile)
+        if err != nil {
+            return nil, err
+        }
+        list.add(string(data))
+    }
+    return list, nil
+}
+
+func (l CommonPasswordList) add(data string) {
+    scanner := bufio.NewScanner(strings.NewReader(data))
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        l[strings.ToLower(line)] = struct{}{}
+    }
+}
+
+func (l CommonPasswordList) Contains(password string) bool {
+    _, found := l[strings.ToLower(password)]
+    return found
+}
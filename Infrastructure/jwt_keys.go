@@ -0,0 +1,285 @@
+package infrastructure
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is a key tokens can be verified with and, when private is set,
+// signed with.
+type signingKey struct {
+    id      string
+    method  jwt.SigningMethod
+    private crypto.Signer
+    public  crypto.PublicKey
+    secret  []byte
+}
+
+// KeySet holds the key new tokens are signed with and every key tokens are
+// still accepted from. To rotate, sign with a new key and keep the previous
+// public key in JWT_VERIFICATION_KEY_FILES until tokens signed with it expire.
+type KeySet struct {
+    signing      *signingKey
+    verification map[string]*signingKey
+}
+
+// LoadKeySetFromEnv loads the key set from the environment:
+//
+//   - JWT_SIGNING_ALG: HS256 (default), RS256 or EdDSA
+//   - JWT_SECRET: the shared secret for HS256
+//   - JWT_SIGNING_KEY_FILE: PEM private key for RS256 or EdDSA
+//   - JWT_SIGNING_KEY_ID: optional kid, defaults to the RFC 7638 thumbprint
+//   - JWT_VERIFICATION_KEY_FILES: comma-separated PEM files of retired keys,
+//     each optionally prefixed with "kid="
+func LoadKeySetFromEnv() (*KeySet, error) {
+    return LoadKeySet(
+        os.Getenv("JWT_SIGNING_ALG"),
+        os.Getenv("JWT_SECRET"),
+        os.Getenv("JWT_SIGNING_KEY_FILE"),
+        os.Getenv("JWT_SIGNING_KEY_ID"),
+        os.Getenv("JWT_VERIFICATION_KEY_FILES"),
+    )
+}
+
+func LoadKeySet(alg, secret, keyFile, keyID, verificationFiles string) (*KeySet, error) {
+    keys := &KeySet{verification: make(map[string]*signingKey)}
+
+    switch alg {
+    case "", "HS256":
+        if secret == "" {
+            return nil, errors.New("JWT_SECRET must be set for HS256 signing")
+        }
+        if keyFile != "" || verificationFiles != "" {
+            return nil, errors.New("signing key files require JWT_SIGNING_ALG=RS256 or EdDSA")
+        }
+        keys.signing = &signingKey{method: jwt.SigningMethodHS256, secret: []byte(secret)}
+        return keys, nil
+    case "RS256", "EdDSA":
+    default:
+        return nil, fmt.Errorf("unsupported JWT_SIGNING_ALG %q", alg)
+    }
+
+    if keyFile == "" {
+        return nil, fmt.Errorf("JWT_SIGNING_KEY_FILE must be set for %s signing", alg)
+    }
+    signing, err := loadKeyFile(keyFile, keyID)
+    if err != nil {
+        return nil, err
+    }
+    if signing.private == nil {
+        return nil, fmt.Errorf("%s does not contain a private key", keyFile)
+    }
+    if signing.method.Alg() != alg {
+        return nil, fmt.Errorf("%s holds an %s key, but JWT_SIGNING_ALG is %s", keyFile, signing.method.Alg(), alg)
+    }
+    keys.signing = signing
+    keys.verification[signing.id] = signing
+
+    for _, entry := range strings.Split(verificationFiles, ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+        id, path := "", entry
+        if i := strings.Index(entry, "="); i >= 0 {
+            id, path = entry[:i], entry[i+1:]
+        }
+        key, err := loadKeyFile(path, id)
+        if err != nil {
+            return nil, err
+        }
+        if _, exists := keys.verification[key.id]; exists {
+            return nil, fmt.Errorf("duplicate key ID %q in %s", key.id, path)
+        }
+        key.private = nil
+        keys.verification[key.id] = key
+    }
+    return keys, nil
+}
+
+// sign signs the claims with the current signing key, adding its kid header.
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+    token := jwt.NewWithClaims(ks.signing.method, claims)
+    if ks.signing.secret != nil {
+        return token.SignedString(ks.signing.secret)
+    }
+    token.Header["kid"] = ks.signing.id
+    return token.SignedString(ks.signing.private)
+}
+
+// keyFunc selects the verification key named by the token's kid and rejects
+// tokens whose algorithm does not match that key.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+    if ks.signing.secret != nil {
+        if token.Method != jwt.SigningMethodHS256 {
+            return nil, errors.New("unexpected signing method")
+        }
+        return ks.signing.secret, nil
+    }
+
+    kid, _ := token.Header["kid"].(string)
+    key, ok := ks.verification[kid]
+    if !ok {
+        return nil, errors.New("unknown signing key")
+    }
+    if token.Method.Alg() != key.method.Alg() {
+        return nil, errors.New("unexpected signing method")
+    }
+    return key.public, nil
+}
+
+// algorithms lists the signing algorithms of all verification keys.
+func (ks *KeySet) algorithms() []string {
+    if ks.signing.secret != nil {
+        return []string{ks.signing.method.Alg()}
+    }
+    seen := map[string]bool{}
+    var algs []string
+    for _, key := range ks.verification {
+        if alg := key.method.Alg(); !seen[alg] {
+            seen[alg] = true
+            algs = append(algs, alg)
+        }
+    }
+    return algs
+}
+
+// JWKS returns the public verification keys, current signing key first. It
+// is empty for HS256.
+func (ks *KeySet) JWKS() []domain.JSONWebKey {
+    jwks := []domain.JSONWebKey{}
+    for _, key := range ks.verification {
+        jwks = append(jwks, key.jwk())
+    }
+    sort.Slice(jwks, func(i, j int) bool {
+        if (jwks[i].KeyID == ks.signing.id) != (jwks[j].KeyID == ks.signing.id) {
+            return jwks[i].KeyID == ks.signing.id
+        }
+        return jwks[i].KeyID < jwks[j].KeyID
+    })
+    return jwks
+}
+
+// JWKSHandler serves the verification keys at /.well-known/jwks.json so other
+// services can validate tokens without sharing a secret.
+func JWKSHandler(tokens domain.TokenService) gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        ctx.Header("Cache-Control", "public, max-age=300")
+        ctx.JSON(http.StatusOK, gin.H{"keys": tokens.JWKS()})
+    }
+}
+
+func (k *signingKey) jwk() domain.JSONWebKey {
+    switch pub := k.public.(type) {
+    case *rsa.PublicKey:
+        return domain.JSONWebKey{
+            KeyType:   "RSA",
+            KeyID:     k.id,
+            Use:       "sig",
+            Algorithm: k.method.Alg(),
+            N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+            E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+        }
+    case ed25519.PublicKey:
+        return domain.JSONWebKey{
+            KeyType:   "OKP",
+            KeyID:     k.id,
+            Use:       "sig",
+            Algorithm: k.method.Alg(),
+            Curve:     "Ed25519",
+            X:         base64.RawURLEncoding.EncodeToString(pub),
+        }
+    }
+    return domain.JSONWebKey{}
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint, used as the default kid.
+func (k *signingKey) thumbprint() string {
+    jwk := k.jwk()
+    var members []byte
+    if jwk.KeyType == "RSA" {
+        members, _ = json.Marshal(struct {
+            E   string `json:"e"`
+            Kty string `json:"kty"`
+            N   string `json:"n"`
+        }{jwk.E, jwk.KeyType, jwk.N})
+    } else {
+        members, _ = json.Marshal(struct {
+            Crv string `json:"crv"`
+            Kty string `json:"kty"`
+            X   string `json:"x"`
+        }{jwk.Curve, jwk.KeyType, jwk.X})
+    }
+    sum := sha256.Sum256(members)
+    return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// loadKeyFile reads an RSA or Ed25519 key from a PEM file. Private keys may be
+// PKCS#1 or PKCS#8, public keys PKCS#1 or PKIX.
+func loadKeyFile(path, id string) (*signingKey, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return nil, fmt.Errorf("%s is not a PEM file", path)
+    }
+
+    var parsed interface{}
+    switch block.Type {
+    case "RSA PRIVATE KEY":
+        parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+    case "PRIVATE KEY":
+        parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+    case "RSA PUBLIC KEY":
+        parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+    case "PUBLIC KEY":
+        parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+    default:
+        return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+
+    key := &signingKey{}
+    switch k := parsed.(type) {
+    case *rsa.PrivateKey:
+        key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+    case *rsa.PublicKey:
+        key.method, key.public = jwt.SigningMethodRS256, k
+    case ed25519.PrivateKey:
+        key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+    case ed25519.PublicKey:
+        key.method, key.public = jwt.SigningMethodEdDSA, k
+    default:
+        return nil, fmt.Errorf("%s: only RSA and Ed25519 keys are supported", path)
+    }
+    if rsaKey, ok := key.public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+        return nil, fmt.Errorf("%s: RSA keys must be at least 2048 bits", path)
+    }
+
+    key.id = id
+    if key.id == "" {
+        key.id = key.thumbprint()
+    }
+    return key, nil
+}
@@ -0,0 +1,260 @@
+package infrastructure
+
+import (
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/pem"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+// writeKeyFiles writes the private and public halves of key as PEM files and
+// returns their paths.
+func writeKeyFiles(t *testing.T, key interface{}) (string, string) {
+    t.Helper()
+    var private, public []byte
+    var err error
+    switch k := key.(type) {
+    case *rsa.PrivateKey:
+        private = x509.MarshalPKCS1PrivateKey(k)
+        public, err = x509.MarshalPKIXPublicKey(&k.PublicKey)
+    case ed25519.PrivateKey:
+        if private, err = x509.MarshalPKCS8PrivateKey(k); err == nil {
+            public, err = x509.MarshalPKIXPublicKey(k.Public())
+        }
+    }
+    if err != nil {
+        t.Fatalf("marshal key: %v", err)
+    }
+    privateType := "PRIVATE KEY"
+    if _, ok := key.(*rsa.PrivateKey); ok {
+        privateType = "RSA PRIVATE KEY"
+    }
+    dir := t.TempDir()
+    privatePath, publicPath := filepath.Join(dir, "private.pem"), filepath.Join(dir, "public.pem")
+    for path, block := range map[string]*pem.Block{
+        privatePath: {Type: privateType, Bytes: private},
+        publicPath:  {Type: "PUBLIC KEY", Bytes: public},
+    } {
+        if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+            t.Fatalf("WriteFile: %v", err)
+        }
+    }
+    return privatePath, publicPath
+}
+
+func testRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+    t.Helper()
+    key, err := rsa.GenerateKey(rand.Reader, bits)
+    if err != nil {
+        t.Fatalf("GenerateKey: %v", err)
+    }
+    return key
+}
+
+func testEd25519Key(t *testing.T) ed25519.PrivateKey {
+    t.Helper()
+    _, key, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        t.Fatalf("GenerateKey: %v", err)
+    }
+    return key
+}
+
+func testClaims() jwt.Claims {
+    return jwt.RegisteredClaims{Subject: "alice", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+// verify parses token with the key set, as the token service does apart from
+// the claim checks.
+func verify(keys *KeySet, token string) error {
+    _, err := jwt.Parse(token, keys.keyFunc)
+    return err
+}
+
+func TestKeySetSignAndVerify(t *testing.T) {
+    for alg, key := range map[string]interface{}{"RS256": testRSAKey(t, 2048), "EdDSA": testEd25519Key(t)} {
+        privatePath, _ := writeKeyFiles(t, key)
+        keys, err := LoadKeySet(alg, "", privatePath, "", "")
+        if err != nil {
+            t.Fatalf("%s: LoadKeySet: %v", alg, err)
+        }
+        token, err := keys.sign(testClaims())
+        if err != nil {
+            t.Fatalf("%s: sign: %v", alg, err)
+        }
+        parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+        if err != nil || parsed.Header["alg"] != alg || parsed.Header["kid"] != keys.signing.id {
+            t.Fatalf("%s: header %v, err=%v, want alg %s and kid %s", alg, parsed.Header, err, alg, keys.signing.id)
+        }
+        if err := verify(keys, token); err != nil {
+            t.Fatalf("%s: verify: %v", alg, err)
+        }
+        if _, err := LoadKeySet(alg, "", "", "", ""); err == nil {
+            t.Fatalf("%s: LoadKeySet without a key file succeeded", alg)
+        }
+    }
+
+    if _, err := LoadKeySet("HS256", "", "", "", ""); err == nil {
+        t.Fatal("LoadKeySet: HS256 without a secret succeeded")
+    }
+    rsaPath, _ := writeKeyFiles(t, testRSAKey(t, 2048))
+    if _, err := LoadKeySet("EdDSA", "", rsaPath, "", ""); err == nil {
+        t.Fatal("LoadKeySet: an RSA key with JWT_SIGNING_ALG=EdDSA was accepted")
+    }
+}
+
+func TestKeySetRotation(t *testing.T) {
+    oldPrivate, oldPublic := writeKeyFiles(t, testRSAKey(t, 2048))
+    newPrivate, _ := writeKeyFiles(t, testEd25519Key(t))
+
+    old, err := LoadKeySet("RS256", "", oldPrivate, "2024-01", "")
+    if err != nil {
+        t.Fatalf("LoadKeySet: %v", err)
+    }
+    token, err := old.sign(testClaims())
+    if err != nil {
+        t.Fatalf("sign: %v", err)
+    }
+
+    rotated, err := LoadKeySet("EdDSA", "", newPrivate, "", "2024-01="+oldPublic)
+    if err != nil {
+        t.Fatalf("LoadKeySet with a retired key: %v", err)
+    }
+    if err := verify(rotated, token); err != nil {
+        t.Fatalf("verify a token signed with the retired key: %v", err)
+    }
+    if rotated.verification["2024-01"].private != nil {
+        t.Fatal("LoadKeySet: retired keys must not be usable for signing")
+    }
+    fresh, err := rotated.sign(testClaims())
+    if err != nil {
+        t.Fatalf("sign: %v", err)
+    }
+    if err := verify(rotated, fresh); err != nil {
+        t.Fatalf("verify a token signed with the new key: %v", err)
+    }
+
+    // Once the retired key is dropped, its tokens are no longer accepted.
+    dropped, err := LoadKeySet("EdDSA", "", newPrivate, "", "")
+    if err != nil {
+        t.Fatalf("LoadKeySet: %v", err)
+    }
+    if err := verify(dropped, token); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+        t.Fatalf("verify with an unknown kid: got %v, want an unknown signing key error", err)
+    }
+
+    if _, err := LoadKeySet("EdDSA", "", newPrivate, "", oldPublic+","+oldPublic); err == nil {
+        t.Fatal("LoadKeySet: a duplicate verification key was accepted")
+    }
+}
+
+func TestKeySetRejectsAlgorithmConfusion(t *testing.T) {
+    key := testRSAKey(t, 2048)
+    privatePath, publicPath := writeKeyFiles(t, key)
+    keys, err := LoadKeySet("RS256", "", privatePath, "", "")
+    if err != nil {
+        t.Fatalf("LoadKeySet: %v", err)
+    }
+
+    // An HS256 token keyed with the public key and naming the RSA key's kid,
+    // as an attacker who knows the JWKS would forge it.
+    publicPEM, err := os.ReadFile(publicPath)
+    if err != nil {
+        t.Fatalf("ReadFile: %v", err)
+    }
+    forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+    forged.Header["kid"] = keys.signing.id
+    signed, err := forged.SignedString(publicPEM)
+    if err != nil {
+        t.Fatalf("SignedString: %v", err)
+    }
+    if err := verify(keys, signed); err == nil || !strings.Contains(err.Error(), "unexpected signing method") {
+        t.Fatalf("verify an HS256 token naming an RSA key: got %v, want an unexpected signing method error", err)
+    }
+
+    // An EdDSA token naming the RSA key's kid.
+    forged = jwt.NewWithClaims(jwt.SigningMethodEdDSA, testClaims())
+    forged.Header["kid"] = keys.signing.id
+    if signed, err = forged.SignedString(testEd25519Key(t)); err != nil {
+        t.Fatalf("SignedString: %v", err)
+    }
+    if err := verify(keys, signed); err == nil || !strings.Contains(err.Error(), "unexpected signing method") {
+        t.Fatalf("verify an EdDSA token naming an RSA key: got %v, want an unexpected signing method error", err)
+    }
+
+    hs256, err := LoadKeySet("HS256", "secret", "", "", "")
+    if err != nil {
+        t.Fatalf("LoadKeySet: %v", err)
+    }
+    rsaToken, err := keys.sign(testClaims())
+    if err != nil {
+        t.Fatalf("sign: %v", err)
+    }
+    if err := verify(hs256, rsaToken); err == nil {
+        t.Fatal("verify: an HS256 key set accepted an RS256 token")
+    }
+}
+
+func TestKeySetRejectsShortRSAKeys(t *testing.T) {
+    privatePath, publicPath := writeKeyFiles(t, testRSAKey(t, 1024))
+    if _, err := LoadKeySet("RS256", "", privatePath, "", ""); err == nil || !strings.Contains(err.Error(), "2048 bits") {
+        t.Fatalf("LoadKeySet with a 1024-bit signing key: got %v, want a key size error", err)
+    }
+    signingPath, _ := writeKeyFiles(t, testRSAKey(t, 2048))
+    if _, err := LoadKeySet("RS256", "", signingPath, "", publicPath); err == nil || !strings.Contains(err.Error(), "2048 bits") {
+        t.Fatalf("LoadKeySet with a 1024-bit verification key: got %v, want a key size error", err)
+    }
+}
+
+func TestKeySetJWKS(t *testing.T) {
+    rsaKey := testRSAKey(t, 2048)
+    edKey := testEd25519Key(t)
+    rsaPrivate, _ := writeKeyFiles(t, rsaKey)
+    _, edPublic := writeKeyFiles(t, edKey)
+
+    keys, err := LoadKeySet("RS256", "", rsaPrivate, "", "a-retired="+edPublic)
+    if err != nil {
+        t.Fatalf("LoadKeySet: %v", err)
+    }
+    jwks := keys.JWKS()
+    if len(jwks) != 2 {
+        t.Fatalf("JWKS: got %d keys, want 2", len(jwks))
+    }
+
+    // The signing key comes first, whatever its kid sorts as.
+    current := jwks[0]
+    if current.KeyType != "RSA" || current.Use != "sig" || current.Algorithm != "RS256" || current.KeyID != keys.signing.thumbprint() {
+        t.Fatalf("JWKS: first key %+v, want the RS256 signing key with its thumbprint as kid", current)
+    }
+    if current.E != "AQAB" || current.N != base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()) {
+        t.Fatalf("JWKS: first key n=%q e=%q do not match the RSA key", current.N, current.E)
+    }
+    if current.Curve != "" || current.X != "" {
+        t.Fatalf("JWKS: RSA key %+v has OKP members", current)
+    }
+
+    retired := jwks[1]
+    if retired.KeyType != "OKP" || retired.Curve != "Ed25519" || retired.Algorithm != "EdDSA" || retired.KeyID != "a-retired" {
+        t.Fatalf("JWKS: second key %+v, want the retired Ed25519 key", retired)
+    }
+    if retired.X != base64.RawURLEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey)) || retired.N != "" {
+        t.Fatalf("JWKS: Ed25519 key %+v does not match", retired)
+    }
+
+    hs256, err := LoadKeySet("HS256", "secret", "", "", "")
+    if err != nil {
+        t.Fatalf("LoadKeySet: %v", err)
+    }
+    if jwks := hs256.JWKS(); jwks == nil || len(jwks) != 0 {
+        t.Fatalf("JWKS for HS256: got %+v, want an empty list", jwks)
+    }
+}
@@ -1,30 +1,227 @@
 package infrastructure
 
 import (
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
     "os"
     "time"
-    "errors"
 
-    "github.com/dgrijalva/jwt-go"
     "github.com/Hailemari/clean_architecture_task_manager/Domain"
+    "github.com/golang-jwt/jwt/v5"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Defaults used when the corresponding environment variables are unset.
+// Access tokens are short-lived; clients renew them with a refresh token.
+const (
+    DefaultAccessTokenTTL = 15 * time.Minute
+    DefaultTokenIssuer    = "task-manager"
+    DefaultTokenAudience  = "task-manager"
+    DefaultClockSkew      = 30 * time.Second
 )
 
-func GenerateToken(user *domain.User) (string, error) {
-    claims := jwt.MapClaims{
-        "id":       user.ID,
-        "username": user.Username,
-        "role":     user.Role,
-        "exp":      time.Now().Add(time.Hour * 24).Unix(),
+// Challenge tokens are only good for completing a two-step login. They get
+// their own audience so they are never accepted as access tokens.
+const (
+    ChallengeTokenTTL       = 5 * time.Minute
+    challengeAudienceSuffix = "/2fa"
+)
+
+// TokenTimePrecision is the precision of the times in issued tokens. Tokens
+// issued in the same second must be told apart from a revocation cut-off, and
+// MongoDB stores the cut-off to the millisecond.
+const TokenTimePrecision = time.Millisecond
+
+func init() {
+    // Claims are encoded and decoded with microseconds, so that millisecond
+    // times survive the float64 they are decoded through and can be rounded
+    // back exactly.
+    jwt.TimePrecision = time.Microsecond
+}
+
+// Claims are the claims carried by access tokens. The subject is the user ID
+// and Org, when set, the ID of the organization the token works in.
+type Claims struct {
+    Username string `json:"username"`
+    Role     string `json:"role"`
+    Org      string `json:"org,omitempty"`
+    jwt.RegisteredClaims
+}
+
+// TokenSettings control the claims of issued tokens and how strictly
+// incoming tokens are checked.
+type TokenSettings struct {
+    Issuer    string
+    Audience  string
+    AccessTTL time.Duration
+    ClockSkew time.Duration
+}
+
+// JWTService is the domain.TokenService backed by signed JWTs.
+type JWTService struct {
+    keys            *KeySet
+    settings        TokenSettings
+    parser          *jwt.Parser
+    challengeParser *jwt.Parser
+}
+
+func NewJWTService(keys *KeySet, settings TokenSettings) domain.TokenService {
+    return &JWTService{
+        keys:            keys,
+        settings:        settings,
+        parser:          newParser(keys, settings, settings.Audience),
+        challengeParser: newParser(keys, settings, settings.Audience+challengeAudienceSuffix),
+    }
+}
+
+func newParser(keys *KeySet, settings TokenSettings, audience string) *jwt.Parser {
+    return jwt.NewParser(
+        jwt.WithValidMethods(keys.algorithms()),
+        jwt.WithIssuer(settings.Issuer),
+        jwt.WithAudience(audience),
+        jwt.WithLeeway(settings.ClockSkew),
+        jwt.WithExpirationRequired(),
+        jwt.WithIssuedAt(),
+    )
+}
+
+// NewJWTServiceFromEnv builds a JWTService from the signing key variables read
+// by LoadKeySetFromEnv and from JWT_ISSUER, JWT_AUDIENCE, ACCESS_TOKEN_TTL and
+// JWT_CLOCK_SKEW.
+func NewJWTServiceFromEnv() (domain.TokenService, error) {
+    keys, err := LoadKeySetFromEnv()
+    if err != nil {
+        return nil, err
+    }
+    settings, err := LoadTokenSettings(
+        os.Getenv("JWT_ISSUER"),
+        os.Getenv("JWT_AUDIENCE"),
+        os.Getenv("ACCESS_TOKEN_TTL"),
+        os.Getenv("JWT_CLOCK_SKEW"),
+    )
+    if err != nil {
+        return nil, err
+    }
+    return NewJWTService(keys, settings), nil
+}
+
+func LoadTokenSettings(issuer, audience, accessTTL, clockSkew string) (TokenSettings, error) {
+    settings := TokenSettings{
+        Issuer:    issuer,
+        Audience:  audience,
+        AccessTTL: DefaultAccessTokenTTL,
+        ClockSkew: DefaultClockSkew,
+    }
+    if settings.Issuer == "" {
+        settings.Issuer = DefaultTokenIssuer
+    }
+    if settings.Audience == "" {
+        settings.Audience = DefaultTokenAudience
+    }
+    if accessTTL != "" {
+        ttl, err := time.ParseDuration(accessTTL)
+        if err != nil || ttl <= 0 {
+            return settings, fmt.Errorf("invalid ACCESS_TOKEN_TTL %q", accessTTL)
+        }
+        settings.AccessTTL = ttl
+    }
+    if clockSkew != "" {
+        skew, err := time.ParseDuration(clockSkew)
+        if err != nil || skew < 0 {
+            return settings, fmt.Errorf("invalid JWT_CLOCK_SKEW %q", clockSkew)
+        }
+        settings.ClockSkew = skew
+    }
+    return settings, nil
+}
+
+func (s *JWTService) GenerateToken(user *domain.User, orgID primitive.ObjectID) (string, time.Time, error) {
+    return s.issue(user, orgID, s.settings.Audience, s.settings.AccessTTL)
+}
+
+// ValidateToken verifies the signature and claims of an access token and
+// returns the principal it was issued to.
+func (s *JWTService) ValidateToken(tokenString string) (*domain.Principal, error) {
+    return s.validate(s.parser, tokenString)
+}
+
+func (s *JWTService) GenerateChallengeToken(user *domain.User) (string, time.Time, error) {
+    return s.issue(user, primitive.NilObjectID, s.settings.Audience+challengeAudienceSuffix, ChallengeTokenTTL)
+}
+
+func (s *JWTService) ValidateChallengeToken(tokenString string) (*domain.Principal, error) {
+    return s.validate(s.challengeParser, tokenString)
+}
+
+func (s *JWTService) issue(user *domain.User, orgID primitive.ObjectID, audience string, ttl time.Duration) (string, time.Time, error) {
+    tokenID, err := newTokenID()
+    if err != nil {
+        return "", time.Time{}, err
     }
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+    issuedAt := time.Now().Truncate(TokenTimePrecision)
+    expiresAt := issuedAt.Add(ttl)
+    claims := &Claims{
+        Username: user.Username,
+        Role:     user.Role,
+        RegisteredClaims: jwt.RegisteredClaims{
+            ID:        tokenID,
+            Subject:   user.ID.Hex(),
+            Issuer:    s.settings.Issuer,
+            Audience:  jwt.ClaimStrings{audience},
+            IssuedAt:  jwt.NewNumericDate(issuedAt),
+            NotBefore: jwt.NewNumericDate(issuedAt),
+            ExpiresAt: jwt.NewNumericDate(expiresAt),
+        },
+    }
+    if !orgID.IsZero() {
+        claims.Org = orgID.Hex()
+    }
+    signed, err := s.keys.sign(claims)
+    if err != nil {
+        return "", time.Time{}, err
+    }
+    return signed, expiresAt, nil
 }
 
-func ValidateToken(tokenString string) (*jwt.Token, error) {
-    return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-        if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-            return nil, errors.New("unexpected signing method")
+func (s *JWTService) validate(parser *jwt.Parser, tokenString string) (*domain.Principal, error) {
+    claims := &Claims{}
+    if _, err := parser.ParseWithClaims(tokenString, claims, s.keys.keyFunc); err != nil {
+        return nil, err
+    }
+    if claims.ID == "" || claims.IssuedAt == nil {
+        return nil, errors.New("token is missing required claims")
+    }
+    userID, err := primitive.ObjectIDFromHex(claims.Subject)
+    if err != nil {
+        return nil, errors.New("invalid subject claim")
+    }
+    var orgID primitive.ObjectID
+    if claims.Org != "" {
+        if orgID, err = primitive.ObjectIDFromHex(claims.Org); err != nil {
+            return nil, errors.New("invalid org claim")
         }
-        return []byte(os.Getenv("JWT_SECRET")), nil
-    })
-}
\ No newline at end of file
+    }
+    return &domain.Principal{
+        UserID:    userID,
+        Username:  claims.Username,
+        Role:      claims.Role,
+        OrgID:     orgID,
+        TokenID:   claims.ID,
+        IssuedAt:  claims.IssuedAt.Time.Round(TokenTimePrecision),
+        ExpiresAt: claims.ExpiresAt.Time.Round(TokenTimePrecision),
+    }, nil
+}
+
+func (s *JWTService) JWKS() []domain.JSONWebKey {
+    return s.keys.JWKS()
+}
+
+func newTokenID() (string, error) {
+    buf := make([]byte, 16)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,190 @@
+package infrastructure
+
+import (
+    "testing"
+    "time"
+
+    "github.com/Hailemari/clean_architecture_task_manager/Domain"
+    "github.com/golang-jwt/jwt/v5"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testJWTService(t *testing.T) *JWTService {
+    t.Helper()
+    keys, err := LoadKeySet("HS256", "test-secret", "", "", "")
+    if err != nil {
+        t.Fatalf("LoadKeySet: %v", err)
+    }
+    settings := TokenSettings{Issuer: "issuer", Audience: "tasks", AccessTTL: time.Minute, ClockSkew: 30 * time.Second}
+    return NewJWTService(keys, settings).(*JWTService)
+}
+
+// testTokenClaims returns the claims of a valid access token, for tests to
+// change one at a time.
+func testTokenClaims() *Claims {
+    now := time.Now()
+    return &Claims{
+        Username: "alice",
+        Role:     domain.RoleUser,
+        RegisteredClaims: jwt.RegisteredClaims{
+            ID:        "jti",
+            Subject:   primitive.NewObjectID().Hex(),
+            Issuer:    "issuer",
+            Audience:  jwt.ClaimStrings{"tasks"},
+            IssuedAt:  jwt.NewNumericDate(now),
+            NotBefore: jwt.NewNumericDate(now),
+            ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+        },
+    }
+}
+
+func TestJWTServiceRoundTrip(t *testing.T) {
+    var service domain.TokenService = testJWTService(t)
+    user := &domain.User{ID: primitive.NewObjectID(), Username: "alice", Role: domain.RoleAdmin}
+    orgID := primitive.NewObjectID()
+
+    token, expiresAt, err := service.GenerateToken(user, orgID)
+    if err != nil {
+        t.Fatalf("GenerateToken: %v", err)
+    }
+    principal, err := service.ValidateToken(token)
+    if err != nil {
+        t.Fatalf("ValidateToken: %v", err)
+    }
+    if principal.UserID != user.ID || principal.Username != "alice" || principal.Role != domain.RoleAdmin || principal.OrgID != orgID {
+        t.Fatalf("ValidateToken: got %+v, want alice in %s", principal, orgID.Hex())
+    }
+    if principal.TokenID == "" || !principal.ExpiresAt.Equal(expiresAt) || principal.IssuedAt.IsZero() {
+        t.Fatalf("ValidateToken: got jti %q, iat %v, exp %v, want exp %v", principal.TokenID, principal.IssuedAt, principal.ExpiresAt, expiresAt)
+    }
+
+    other, _, err := service.GenerateToken(user, primitive.NilObjectID)
+    if err != nil {
+        t.Fatalf("GenerateToken: %v", err)
+    }
+    if principal, err := service.ValidateToken(other); err != nil || !principal.OrgID.IsZero() || principal.TokenID == "" {
+        t.Fatalf("ValidateToken without an organization: got %+v, err=%v", principal, err)
+    }
+}
+
+func TestJWTServiceKeepsMilliseconds(t *testing.T) {
+    service := testJWTService(t)
+    base := time.Now().Truncate(time.Second)
+    for ms := 0; ms < 1000; ms += 7 {
+        issuedAt := base.Add(time.Duration(ms) * time.Millisecond)
+        claims := testTokenClaims()
+        claims.IssuedAt = jwt.NewNumericDate(issuedAt)
+        token, err := service.keys.sign(claims)
+        if err != nil {
+            t.Fatalf("sign: %v", err)
+        }
+        principal, err := service.ValidateToken(token)
+        if err != nil {
+            t.Fatalf("ValidateToken: %v", err)
+        }
+        if !principal.IssuedAt.Equal(issuedAt) {
+            t.Fatalf("ValidateToken: iat %v, want %v", principal.IssuedAt, issuedAt)
+        }
+    }
+}
+
+func TestJWTServiceRequiresClaims(t *testing.T) {
+    service := testJWTService(t)
+    tests := []struct {
+        name   string
+        mutate func(*Claims)
+    }{
+        {"no jti", func(c *Claims) { c.ID = "" }},
+        {"no iat", func(c *Claims) { c.IssuedAt = nil }},
+        {"no subject", func(c *Claims) { c.Subject = "" }},
+        {"subject not an ObjectID", func(c *Claims) { c.Subject = "alice" }},
+        {"org not an ObjectID", func(c *Claims) { c.Org = "acme" }},
+    }
+    for _, test := range tests {
+        claims := testTokenClaims()
+        test.mutate(claims)
+        token, err := service.keys.sign(claims)
+        if err != nil {
+            t.Fatalf("%s: sign: %v", test.name, err)
+        }
+        if principal, err := service.ValidateToken(token); err == nil {
+            t.Errorf("%s: ValidateToken accepted the token as %+v", test.name, principal)
+        }
+    }
+}
+
+func TestJWTServiceRejectsInvalidClaims(t *testing.T) {
+    service := testJWTService(t)
+    now := time.Now()
+    tests := []struct {
+        name   string
+        mutate func(*Claims)
+        valid  bool
+    }{
+        {"valid", func(*Claims) {}, true},
+        {"wrong issuer", func(c *Claims) { c.Issuer = "someone-else" }, false},
+        {"no issuer", func(c *Claims) { c.Issuer = "" }, false},
+        {"wrong audience", func(c *Claims) { c.Audience = jwt.ClaimStrings{"other-service"} }, false},
+        {"not yet valid within the leeway", func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(10 * time.Second)) }, true},
+        {"not yet valid beyond the leeway", func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(2 * time.Minute)) }, false},
+        {"expired within the leeway", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second)) }, true},
+        {"expired beyond the leeway", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Minute)) }, false},
+        {"no expiry", func(c *Claims) { c.ExpiresAt = nil }, false},
+        {"issued in the future", func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(2 * time.Minute)) }, false},
+    }
+    for _, test := range tests {
+        claims := testTokenClaims()
+        test.mutate(claims)
+        token, err := service.keys.sign(claims)
+        if err != nil {
+            t.Fatalf("%s: sign: %v", test.name, err)
+        }
+        _, err = service.ValidateToken(token)
+        if test.valid && err != nil {
+            t.Errorf("%s: ValidateToken: %v", test.name, err)
+        }
+        if !test.valid && err == nil {
+            t.Errorf("%s: ValidateToken accepted the token", test.name)
+        }
+    }
+
+    // A token signed with another secret.
+    other, err := LoadKeySet("HS256", "other-secret", "", "", "")
+    if err != nil {
+        t.Fatalf("LoadKeySet: %v", err)
+    }
+    token, err := other.sign(testTokenClaims())
+    if err != nil {
+        t.Fatalf("sign: %v", err)
+    }
+    if _, err := service.ValidateToken(token); err == nil {
+        t.Error("ValidateToken accepted a token signed with another secret")
+    }
+}
+
+func TestJWTServiceSeparatesChallengeTokens(t *testing.T) {
+    service := testJWTService(t)
+    user := &domain.User{ID: primitive.NewObjectID(), Username: "alice", Role: domain.RoleUser}
+
+    challenge, expiresAt, err := service.GenerateChallengeToken(user)
+    if err != nil {
+        t.Fatalf("GenerateChallengeToken: %v", err)
+    }
+    if ttl := time.Until(expiresAt); ttl > ChallengeTokenTTL || ttl < ChallengeTokenTTL-2*time.Second {
+        t.Fatalf("GenerateChallengeToken: expires in %v, want %v", ttl, ChallengeTokenTTL)
+    }
+    if principal, err := service.ValidateChallengeToken(challenge); err != nil || principal.UserID != user.ID {
+        t.Fatalf("ValidateChallengeToken: got %+v, err=%v", principal, err)
+    }
+    if _, err := service.ValidateToken(challenge); err == nil {
+        t.Fatal("ValidateToken accepted a challenge token as an access token")
+    }
+
+    access, _, err := service.GenerateToken(user, primitive.NilObjectID)
+    if err != nil {
+        t.Fatalf("GenerateToken: %v", err)
+    }
+    if _, err := service.ValidateChallengeToken(access); err == nil {
+        t.Fatal("ValidateChallengeToken accepted an access token at /login/2fa")
+    }
+}
@@ -0,0 +1,253 @@
+package infrastructure
+
+import (
+    "crypto/tls"
+    "crypto/x509"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "os"
+    "strings"
+    "time"
+    "unicode/utf8"
+
+    "github.com/Hailemari/clean_architecture_task_manager/Domain"
+    "github.com/go-ldap/ldap/v3"
+)
+
+// Defaults used when the corresponding environment variables are unset.
+const (
+    DefaultLDAPUserFilter         = "(uid=%s)"
+    DefaultLDAPUsernameAttribute  = "uid"
+    DefaultLDAPGroupFilter        = "(member=%s)"
+    DefaultLDAPGroupNameAttribute = "cn"
+    DefaultLDAPTimeout            = 10 * time.Second
+)
+
+// LDAPSettings configure how users are found and authenticated in an LDAP
+// directory.
+type LDAPSettings struct {
+    // URL is an ldap:// or ldaps:// URL. StartTLS upgrades an ldap://
+    // connection before anything is sent.
+    URL       string
+    StartTLS  bool
+    TLSConfig *tls.Config
+    // BindDN and BindPassword are the service account users are searched
+    // with. The search is anonymous without them.
+    BindDN       string
+    BindPassword string
+    // UserFilter finds a user under UserBaseDN, with %s replaced by the
+    // escaped username.
+    UserBaseDN        string
+    UserFilter        string
+    UsernameAttribute string
+    // IDAttribute holds the user's permanent ID, such as entryUUID or
+    // objectGUID. Without it the DN identifies the user, so moving them in the
+    // directory makes them a new user here.
+    IDAttribute string
+    // GroupFilter finds the user's groups under GroupBaseDN, with %s replaced
+    // by the escaped user DN. Groups are not looked up without GroupBaseDN.
+    GroupBaseDN        string
+    GroupFilter        string
+    GroupNameAttribute string
+    Timeout            time.Duration
+}
+
+// ldapConn is the part of *ldap.Conn the directory uses.
+type ldapConn interface {
+    Bind(username, password string) error
+    UnauthenticatedBind(username string) error
+    Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+    Close() error
+}
+
+// LDAPDirectory is the domain.Directory for an LDAP server. It authenticates a
+// user by searching for their entry and binding as it, on a new connection
+// for each login.
+type LDAPDirectory struct {
+    settings LDAPSettings
+    dial     func() (ldapConn, error)
+}
+
+func NewLDAPDirectory(settings LDAPSettings) (*LDAPDirectory, error) {
+    if settings.URL == "" || settings.UserBaseDN == "" {
+        return nil, errors.New("LDAP needs a URL and a user base DN")
+    }
+    if settings.UserFilter == "" {
+        settings.UserFilter = DefaultLDAPUserFilter
+    }
+    if settings.UsernameAttribute == "" {
+        settings.UsernameAttribute = DefaultLDAPUsernameAttribute
+    }
+    if settings.GroupFilter == "" {
+        settings.GroupFilter = DefaultLDAPGroupFilter
+    }
+    if settings.GroupNameAttribute == "" {
+        settings.GroupNameAttribute = DefaultLDAPGroupNameAttribute
+    }
+    if settings.Timeout <= 0 {
+        settings.Timeout = DefaultLDAPTimeout
+    }
+    if strings.Count(settings.UserFilter, "%s") != 1 || strings.Count(settings.GroupFilter, "%s") != 1 {
+        return nil, errors.New("LDAP user and group filters must contain %s exactly once")
+    }
+    if settings.StartTLS && strings.HasPrefix(settings.URL, "ldaps://") {
+        return nil, errors.New("LDAP StartTLS cannot be used with an ldaps:// URL")
+    }
+
+    d := &LDAPDirectory{settings: settings}
+    d.dial = d.dialServer
+    return d, nil
+}
+
+// NewLDAPDirectoryFromEnv builds an LDAPDirectory from LDAP_URL,
+// LDAP_START_TLS, LDAP_CA_FILE, LDAP_BIND_DN, LDAP_BIND_PASSWORD,
+// LDAP_USER_BASE_DN, LDAP_USER_FILTER, LDAP_USERNAME_ATTRIBUTE,
+// LDAP_ID_ATTRIBUTE, LDAP_GROUP_BASE_DN, LDAP_GROUP_FILTER and
+// LDAP_GROUP_NAME_ATTRIBUTE.
+func NewLDAPDirectoryFromEnv() (*LDAPDirectory, error) {
+    settings := LDAPSettings{
+        URL:                os.Getenv("LDAP_URL"),
+        BindDN:             os.Getenv("LDAP_BIND_DN"),
+        BindPassword:       os.Getenv("LDAP_BIND_PASSWORD"),
+        UserBaseDN:         os.Getenv("LDAP_USER_BASE_DN"),
+        UserFilter:         os.Getenv("LDAP_USER_FILTER"),
+        UsernameAttribute:  os.Getenv("LDAP_USERNAME_ATTRIBUTE"),
+        IDAttribute:        os.Getenv("LDAP_ID_ATTRIBUTE"),
+        GroupBaseDN:        os.Getenv("LDAP_GROUP_BASE_DN"),
+        GroupFilter:        os.Getenv("LDAP_GROUP_FILTER"),
+        GroupNameAttribute: os.Getenv("LDAP_GROUP_NAME_ATTRIBUTE"),
+    }
+    switch value := os.Getenv("LDAP_START_TLS"); value {
+    case "", "false":
+    case "true":
+        settings.StartTLS = true
+    default:
+        return nil, fmt.Errorf("invalid LDAP_START_TLS %q", value)
+    }
+    if path := os.Getenv("LDAP_CA_FILE"); path != "" {
+        pem, err := os.ReadFile(path)
+        if err != nil {
+            return nil, err
+        }
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(pem) {
+            return nil, fmt.Errorf("%s contains no PEM certificates", path)
+        }
+        settings.TLSConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+    }
+    return NewLDAPDirectory(settings)
+}
+
+func (d *LDAPDirectory) dialServer() (ldapConn, error) {
+    tlsConfig := d.settings.TLSConfig
+    if tlsConfig == nil {
+        tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+    }
+    conn, err := ldap.DialURL(d.settings.URL, ldap.DialWithTLSConfig(tlsConfig))
+    if err != nil {
+        return nil, err
+    }
+    conn.SetTimeout(d.settings.Timeout)
+    if d.settings.StartTLS {
+        if err := conn.StartTLS(tlsConfig); err != nil {
+            conn.Close()
+            return nil, err
+        }
+    }
+    return conn, nil
+}
+
+func (d *LDAPDirectory) Authenticate(username, password string) (*domain.ExternalIdentity, error) {
+    // An empty password would make the bind unauthenticated, which servers
+    // report as a success.
+    if username == "" || password == "" {
+        return nil, domain.ErrInvalidCredentials
+    }
+    conn, err := d.dial()
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", domain.ErrAuthBackendUnavailable, err)
+    }
+    defer conn.Close()
+
+    if err := d.bindService(conn); err != nil {
+        return nil, err
+    }
+    attributes := []string{d.settings.UsernameAttribute, "mail"}
+    if d.settings.IDAttribute != "" {
+        attributes = append(attributes, d.settings.IDAttribute)
+    }
+    result, err := conn.Search(ldap.NewSearchRequest(
+        d.settings.UserBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(d.settings.Timeout.Seconds()), false,
+        fmt.Sprintf(d.settings.UserFilter, ldap.EscapeFilter(username)), attributes, nil,
+    ))
+    if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+        return nil, fmt.Errorf("%w: user search failed: %v", domain.ErrAuthBackendUnavailable, err)
+    }
+    if result == nil || len(result.Entries) != 1 {
+        return nil, domain.ErrInvalidCredentials
+    }
+    entry := result.Entries[0]
+
+    if err := conn.Bind(entry.DN, password); err != nil {
+        if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+            return nil, domain.ErrInvalidCredentials
+        }
+        return nil, fmt.Errorf("%w: %v", domain.ErrAuthBackendUnavailable, err)
+    }
+
+    identity := &domain.ExternalIdentity{
+        Subject:  entry.DN,
+        Username: entry.GetAttributeValue(d.settings.UsernameAttribute),
+        Email:    entry.GetAttributeValue("mail"),
+    }
+    if d.settings.IDAttribute != "" {
+        // Binary IDs such as objectGUID are stored hex-encoded.
+        raw := entry.GetRawAttributeValue(d.settings.IDAttribute)
+        if len(raw) == 0 {
+            return nil, fmt.Errorf("%w: %s has no %s", domain.ErrAuthBackendUnavailable, entry.DN, d.settings.IDAttribute)
+        }
+        identity.Subject = string(raw)
+        if !utf8.Valid(raw) {
+            identity.Subject = hex.EncodeToString(raw)
+        }
+    }
+    if identity.Username == "" {
+        identity.Username = username
+    }
+
+    if d.settings.GroupBaseDN != "" {
+        // The user may not be allowed to read groups, so search them as the
+        // service account again.
+        if err := d.bindService(conn); err != nil {
+            return nil, err
+        }
+        groups, err := conn.Search(ldap.NewSearchRequest(
+            d.settings.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(d.settings.Timeout.Seconds()), false,
+            fmt.Sprintf(d.settings.GroupFilter, ldap.EscapeFilter(entry.DN)), []string{d.settings.GroupNameAttribute}, nil,
+        ))
+        if err != nil {
+            return nil, fmt.Errorf("%w: group search failed: %v", domain.ErrAuthBackendUnavailable, err)
+        }
+        for _, group := range groups.Entries {
+            if name := group.GetAttributeValue(d.settings.GroupNameAttribute); name != "" {
+                identity.Groups = append(identity.Groups, name)
+            }
+        }
+    }
+    return identity, nil
+}
+
+// bindService binds as the service account, or anonymously without one.
+func (d *LDAPDirectory) bindService(conn ldapConn) error {
+    var err error
+    if d.settings.BindDN == "" {
+        err = conn.UnauthenticatedBind("")
+    } else {
+        err = conn.Bind(d.settings.BindDN, d.settings.BindPassword)
+    }
+    if err != nil {
+        return fmt.Errorf("%w: service bind failed: %v", domain.ErrAuthBackendUnavailable, err)
+    }
+    return nil
+}
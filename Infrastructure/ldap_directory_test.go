@@ -0,0 +1,131 @@
+package infrastructure
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+
+    "github.com/Hailemari/clean_architecture_task_manager/Domain"
+    "github.com/go-ldap/ldap/v3"
+)
+
+// fakeLDAP is a directory server holding entries under their search filter.
+type fakeLDAP struct {
+    passwords map[string]string
+    results   map[string][]*ldap.Entry
+    down      bool
+
+    boundAs string
+    filters []string
+}
+
+func (f *fakeLDAP) Bind(username, password string) error {
+    if want, ok := f.passwords[username]; !ok || want != password {
+        return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+    }
+    f.boundAs = username
+    return nil
+}
+
+func (f *fakeLDAP) UnauthenticatedBind(username string) error {
+    f.boundAs = ""
+    return nil
+}
+
+func (f *fakeLDAP) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+    f.filters = append(f.filters, f.boundAs+" "+request.Filter)
+    return &ldap.SearchResult{Entries: f.results[request.Filter]}, nil
+}
+
+func (f *fakeLDAP) Close() error {
+    return nil
+}
+
+func testLDAPDirectory(t *testing.T, server *fakeLDAP, settings LDAPSettings) *LDAPDirectory {
+    t.Helper()
+    settings.URL = "ldap://ldap.test"
+    settings.UserBaseDN = "ou=people,dc=example,dc=com"
+    directory, err := NewLDAPDirectory(settings)
+    if err != nil {
+        t.Fatalf("NewLDAPDirectory: %v", err)
+    }
+    directory.dial = func() (ldapConn, error) {
+        if server.down {
+            return nil, errors.New("connection refused")
+        }
+        server.filters = nil
+        return server, nil
+    }
+    return directory
+}
+
+func TestLDAPDirectoryAuthenticate(t *testing.T) {
+    const aliceDN = "uid=alice,ou=people,dc=example,dc=com"
+    server := &fakeLDAP{
+        passwords: map[string]string{"cn=reader,dc=example,dc=com": "reader-pw", aliceDN: "alice-pw"},
+        results: map[string][]*ldap.Entry{
+            "(uid=alice)": {ldap.NewEntry(aliceDN, map[string][]string{
+                "uid": {"alice"}, "mail": {"alice@example.com"}, "entryUUID": {"6f1c-alice"},
+            })},
+            "(uid=twin)": {ldap.NewEntry("uid=twin,ou=a", nil), ldap.NewEntry("uid=twin,ou=b", nil)},
+            `(member=uid=alice,ou=people,dc=example,dc=com)`: {
+                ldap.NewEntry("cn=staff,ou=groups", map[string][]string{"cn": {"staff"}}),
+                ldap.NewEntry("cn=task-admins,ou=groups", map[string][]string{"cn": {"task-admins"}}),
+            },
+        },
+    }
+    directory := testLDAPDirectory(t, server, LDAPSettings{
+        BindDN:       "cn=reader,dc=example,dc=com",
+        BindPassword: "reader-pw",
+        IDAttribute:  "entryUUID",
+        GroupBaseDN:  "ou=groups,dc=example,dc=com",
+    })
+
+    identity, err := directory.Authenticate("alice", "alice-pw")
+    if err != nil {
+        t.Fatalf("Authenticate: %v", err)
+    }
+    want := &domain.ExternalIdentity{Subject: "6f1c-alice", Username: "alice", Email: "alice@example.com", Groups: []string{"staff", "task-admins"}}
+    if !reflect.DeepEqual(identity, want) {
+        t.Fatalf("Authenticate: got %+v, want %+v", identity, want)
+    }
+    // Groups are searched as the service account, not as the user.
+    if last := server.filters[len(server.filters)-1]; last != "cn=reader,dc=example,dc=com (member="+aliceDN+")" {
+        t.Fatalf("Authenticate: group search %q", last)
+    }
+
+    for name, credentials := range map[string][2]string{
+        "a wrong password":  {"alice", "wrong"},
+        "an empty password": {"alice", ""},
+        "an unknown user":   {"mallory", "alice-pw"},
+        "an ambiguous user": {"twin", "alice-pw"},
+    } {
+        if _, err := directory.Authenticate(credentials[0], credentials[1]); !errors.Is(err, domain.ErrInvalidCredentials) {
+            t.Fatalf("Authenticate with %s: got %v, want %v", name, err, domain.ErrInvalidCredentials)
+        }
+    }
+
+    if _, err := directory.Authenticate("*)(uid=alice", "alice-pw"); !errors.Is(err, domain.ErrInvalidCredentials) {
+        t.Fatalf("Authenticate with a filter in the username: got %v, want %v", err, domain.ErrInvalidCredentials)
+    }
+    if got := server.filters[0]; got != `cn=reader,dc=example,dc=com (uid=\2a\29\28uid=alice)` {
+        t.Fatalf("Authenticate with a filter in the username: searched %q", got)
+    }
+
+    server.down = true
+    if _, err := directory.Authenticate("alice", "alice-pw"); !errors.Is(err, domain.ErrAuthBackendUnavailable) {
+        t.Fatalf("Authenticate with the server down: got %v, want %v", err, domain.ErrAuthBackendUnavailable)
+    }
+    server.down = false
+    server.passwords["cn=reader,dc=example,dc=com"] = "rotated"
+    if _, err := directory.Authenticate("alice", "alice-pw"); !errors.Is(err, domain.ErrAuthBackendUnavailable) {
+        t.Fatalf("Authenticate with a rejected service account: got %v, want %v", err, domain.ErrAuthBackendUnavailable)
+    }
+}
+
+func TestNewLDAPDirectoryChecksFilters(t *testing.T) {
+    settings := LDAPSettings{URL: "ldap://ldap.test", UserBaseDN: "dc=example,dc=com", UserFilter: "(uid=alice)"}
+    if _, err := NewLDAPDirectory(settings); err == nil {
+        t.Fatal("NewLDAPDirectory: accepted a user filter without a placeholder")
+    }
+}
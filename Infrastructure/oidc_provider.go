@@ -0,0 +1,408 @@
+package infrastructure
+
+import (
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/elliptic"
+    "crypto/rsa"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "math/big"
+    "net/http"
+    "net/url"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/Hailemari/clean_architecture_task_manager/Domain"
+    "github.com/golang-jwt/jwt/v5"
+)
+
+// Defaults used when the corresponding environment variables are unset.
+const (
+    DefaultOIDCScopes        = "openid profile email"
+    DefaultOIDCUsernameClaim = "preferred_username"
+    DefaultOIDCGroupsClaim   = "groups"
+)
+
+// The provider's keys are fetched again when a token is signed with one we do
+// not know, which is how key rotation shows up, but not more often than this.
+const oidcKeyRefreshInterval = time.Minute
+
+// idTokenAlgorithms are the signature algorithms accepted for ID tokens.
+var idTokenAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "EdDSA"}
+
+// OIDCProviderSettings configure the client registered at the identity
+// provider.
+type OIDCProviderSettings struct {
+    Issuer       string
+    ClientID     string
+    ClientSecret string
+    RedirectURL  string
+    Scopes       []string
+    // UsernameClaim and GroupsClaim name the ID token claims holding the
+    // username and the groups of the user.
+    UsernameClaim string
+    GroupsClaim   string
+    ClockSkew     time.Duration
+}
+
+// OIDCProvider is the domain.OIDCProvider for an identity provider that
+// supports OpenID Connect discovery.
+type OIDCProvider struct {
+    settings      OIDCProviderSettings
+    client        *http.Client
+    authEndpoint  string
+    tokenEndpoint string
+    jwksURI       string
+    parser        *jwt.Parser
+
+    mu          sync.Mutex
+    keys        map[string]crypto.PublicKey
+    keysFetched time.Time
+}
+
+// providerMetadata is the part of the discovery document we use.
+type providerMetadata struct {
+    Issuer                        string   `json:"issuer"`
+    AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+    TokenEndpoint                 string   `json:"token_endpoint"`
+    JWKSURI                       string   `json:"jwks_uri"`
+    CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+// NewOIDCProvider reads the provider's discovery document and keys, so that a
+// misconfigured issuer is noticed at startup rather than at the first login.
+func NewOIDCProvider(settings OIDCProviderSettings, client *http.Client) (*OIDCProvider, error) {
+    if settings.Issuer == "" || settings.ClientID == "" || settings.RedirectURL == "" {
+        return nil, errors.New("OIDC needs an issuer, a client ID and a redirect URL")
+    }
+    if len(settings.Scopes) == 0 {
+        settings.Scopes = strings.Fields(DefaultOIDCScopes)
+    }
+    if settings.UsernameClaim == "" {
+        settings.UsernameClaim = DefaultOIDCUsernameClaim
+    }
+    if settings.GroupsClaim == "" {
+        settings.GroupsClaim = DefaultOIDCGroupsClaim
+    }
+    if client == nil {
+        client = &http.Client{Timeout: 10 * time.Second}
+    }
+
+    var metadata providerMetadata
+    discoveryURL := strings.TrimSuffix(settings.Issuer, "/") + "/.well-known/openid-configuration"
+    if err := getJSON(client, discoveryURL, &metadata); err != nil {
+        return nil, fmt.Errorf("OIDC discovery: %v", err)
+    }
+    if metadata.Issuer != settings.Issuer {
+        return nil, fmt.Errorf("OIDC discovery: issuer is %q, want %q", metadata.Issuer, settings.Issuer)
+    }
+    if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+        return nil, errors.New("OIDC discovery: authorization_endpoint, token_endpoint and jwks_uri are required")
+    }
+    if len(metadata.CodeChallengeMethodsSupported) > 0 && !contains(metadata.CodeChallengeMethodsSupported, "S256") {
+        return nil, errors.New("OIDC discovery: the provider does not support S256 PKCE")
+    }
+
+    p := &OIDCProvider{
+        settings:      settings,
+        client:        client,
+        authEndpoint:  metadata.AuthorizationEndpoint,
+        tokenEndpoint: metadata.TokenEndpoint,
+        jwksURI:       metadata.JWKSURI,
+        parser: jwt.NewParser(
+            jwt.WithValidMethods(idTokenAlgorithms),
+            jwt.WithIssuer(settings.Issuer),
+            jwt.WithAudience(settings.ClientID),
+            jwt.WithLeeway(settings.ClockSkew),
+            jwt.WithExpirationRequired(),
+            jwt.WithIssuedAt(),
+        ),
+    }
+    if err := p.refreshKeys(); err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+// NewOIDCProviderFromEnv builds an OIDCProvider from OIDC_ISSUER,
+// OIDC_CLIENT_ID, OIDC_CLIENT_SECRET, OIDC_REDIRECT_URL, OIDC_SCOPES,
+// OIDC_USERNAME_CLAIM, OIDC_GROUPS_CLAIM and JWT_CLOCK_SKEW. It returns nil
+// if OIDC_ISSUER is unset.
+func NewOIDCProviderFromEnv() (*OIDCProvider, error) {
+    issuer := os.Getenv("OIDC_ISSUER")
+    if issuer == "" {
+        return nil, nil
+    }
+    tokenSettings, err := LoadTokenSettings("", "", "", os.Getenv("JWT_CLOCK_SKEW"))
+    if err != nil {
+        return nil, err
+    }
+    scopes := os.Getenv("OIDC_SCOPES")
+    if scopes == "" {
+        scopes = DefaultOIDCScopes
+    }
+    return NewOIDCProvider(OIDCProviderSettings{
+        Issuer:        issuer,
+        ClientID:      os.Getenv("OIDC_CLIENT_ID"),
+        ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+        RedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+        Scopes:        strings.Fields(strings.ReplaceAll(scopes, ",", " ")),
+        UsernameClaim: os.Getenv("OIDC_USERNAME_CLAIM"),
+        GroupsClaim:   os.Getenv("OIDC_GROUPS_CLAIM"),
+        ClockSkew:     tokenSettings.ClockSkew,
+    }, nil)
+}
+
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeChallenge string) string {
+    query := url.Values{
+        "response_type":         {"code"},
+        "client_id":             {p.settings.ClientID},
+        "redirect_uri":          {p.settings.RedirectURL},
+        "scope":                 {strings.Join(p.settings.Scopes, " ")},
+        "state":                 {state},
+        "nonce":                 {nonce},
+        "code_challenge":        {codeChallenge},
+        "code_challenge_method": {"S256"},
+    }
+    separator := "?"
+    if strings.Contains(p.authEndpoint, "?") {
+        separator = "&"
+    }
+    return p.authEndpoint + separator + query.Encode()
+}
+
+func (p *OIDCProvider) Exchange(code, codeVerifier, nonce string) (*domain.ExternalIdentity, error) {
+    form := url.Values{
+        "grant_type":    {"authorization_code"},
+        "code":          {code},
+        "redirect_uri":  {p.settings.RedirectURL},
+        "code_verifier": {codeVerifier},
+    }
+    if p.settings.ClientSecret == "" {
+        form.Set("client_id", p.settings.ClientID)
+    }
+    req, err := http.NewRequest(http.MethodPost, p.tokenEndpoint, strings.NewReader(form.Encode()))
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("Accept", "application/json")
+    if p.settings.ClientSecret != "" {
+        // RFC 6749 section 2.3.1 has the credentials form-encoded first.
+        req.SetBasicAuth(url.QueryEscape(p.settings.ClientID), url.QueryEscape(p.settings.ClientSecret))
+    }
+
+    resp, err := p.client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", domain.ErrOIDCLoginFailed, err)
+    }
+    defer resp.Body.Close()
+    var body struct {
+        IDToken          string `json:"id_token"`
+        Error            string `json:"error"`
+        ErrorDescription string `json:"error_description"`
+    }
+    if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+        return nil, fmt.Errorf("%w: unreadable token response: %v", domain.ErrOIDCLoginFailed, err)
+    }
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("%w: token endpoint returned %s %s %s", domain.ErrOIDCLoginFailed, resp.Status, body.Error, body.ErrorDescription)
+    }
+    if body.IDToken == "" {
+        return nil, fmt.Errorf("%w: token response has no ID token", domain.ErrOIDCLoginFailed)
+    }
+    return p.verifyIDToken(body.IDToken, nonce)
+}
+
+func (p *OIDCProvider) verifyIDToken(idToken, nonce string) (*domain.ExternalIdentity, error) {
+    claims := jwt.MapClaims{}
+    if _, err := p.parser.ParseWithClaims(idToken, claims, p.keyFor); err != nil {
+        return nil, fmt.Errorf("%w: invalid ID token: %v", domain.ErrOIDCLoginFailed, err)
+    }
+    got, _ := claims["nonce"].(string)
+    if subtle.ConstantTimeCompare([]byte(got), []byte(nonce)) != 1 {
+        return nil, fmt.Errorf("%w: ID token nonce does not match", domain.ErrOIDCLoginFailed)
+    }
+    // A token for several audiences must name us as the party it was issued to.
+    if audience, _ := claims.GetAudience(); len(audience) > 1 {
+        if azp, _ := claims["azp"].(string); azp != p.settings.ClientID {
+            return nil, fmt.Errorf("%w: ID token was issued to %q", domain.ErrOIDCLoginFailed, azp)
+        }
+    }
+
+    identity := &domain.ExternalIdentity{}
+    identity.Subject, _ = claims["sub"].(string)
+    identity.Username, _ = claims[p.settings.UsernameClaim].(string)
+    identity.Email, _ = claims["email"].(string)
+    if identity.Subject == "" {
+        return nil, fmt.Errorf("%w: ID token has no subject", domain.ErrOIDCLoginFailed)
+    }
+    if identity.Username == "" {
+        return nil, fmt.Errorf("%w: ID token has no %s claim", domain.ErrOIDCLoginFailed, p.settings.UsernameClaim)
+    }
+    switch groups := claims[p.settings.GroupsClaim].(type) {
+    case string:
+        identity.Groups = []string{groups}
+    case []interface{}:
+        for _, group := range groups {
+            if name, ok := group.(string); ok {
+                identity.Groups = append(identity.Groups, name)
+            }
+        }
+    }
+    return identity, nil
+}
+
+// keyFor finds the provider key a token was signed with. A token without a
+// kid is accepted only while the provider publishes a single key.
+func (p *OIDCProvider) keyFor(token *jwt.Token) (interface{}, error) {
+    kid, _ := token.Header["kid"].(string)
+
+    p.mu.Lock()
+    key, ok := p.lookupKey(kid)
+    stale := time.Since(p.keysFetched) >= oidcKeyRefreshInterval
+    p.mu.Unlock()
+    if ok {
+        return key, nil
+    }
+    if !stale {
+        return nil, fmt.Errorf("unknown signing key %q", kid)
+    }
+    if err := p.refreshKeys(); err != nil {
+        return nil, err
+    }
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if key, ok := p.lookupKey(kid); ok {
+        return key, nil
+    }
+    return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey must be called with p.mu held.
+func (p *OIDCProvider) lookupKey(kid string) (crypto.PublicKey, bool) {
+    if kid == "" && len(p.keys) == 1 {
+        for _, key := range p.keys {
+            return key, true
+        }
+    }
+    key, ok := p.keys[kid]
+    return key, ok
+}
+
+// refreshKeys fetches the provider's JWKS. Keys that are not for signatures
+// or of an unsupported type are skipped.
+func (p *OIDCProvider) refreshKeys() error {
+    var set struct {
+        Keys []domain.JSONWebKey `json:"keys"`
+    }
+    if err := getJSON(p.client, p.jwksURI, &set); err != nil {
+        return fmt.Errorf("OIDC keys: %v", err)
+    }
+    keys := make(map[string]crypto.PublicKey)
+    for _, jwk := range set.Keys {
+        if jwk.Use != "" && jwk.Use != "sig" {
+            continue
+        }
+        if key, err := publicKeyFromJWK(jwk); err == nil {
+            keys[jwk.KeyID] = key
+        }
+    }
+    if len(keys) == 0 {
+        return errors.New("OIDC keys: the provider publishes no usable signing key")
+    }
+
+    p.mu.Lock()
+    p.keys = keys
+    p.keysFetched = time.Now()
+    p.mu.Unlock()
+    return nil
+}
+
+// publicKeyFromJWK is the inverse of signingKey.jwk, extended to the EC keys
+// identity providers commonly use.
+func publicKeyFromJWK(jwk domain.JSONWebKey) (crypto.PublicKey, error) {
+    decode := base64.RawURLEncoding.DecodeString
+    switch jwk.KeyType {
+    case "RSA":
+        n, err := decode(jwk.N)
+        if err != nil {
+            return nil, err
+        }
+        e, err := decode(jwk.E)
+        if err != nil {
+            return nil, err
+        }
+        exponent := new(big.Int).SetBytes(e)
+        if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+            return nil, errors.New("RSA exponent is too large")
+        }
+        return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+    case "EC":
+        var curve elliptic.Curve
+        switch jwk.Curve {
+        case "P-256":
+            curve = elliptic.P256()
+        case "P-384":
+            curve = elliptic.P384()
+        default:
+            return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+        }
+        x, err := decode(jwk.X)
+        if err != nil {
+            return nil, err
+        }
+        y, err := decode(jwk.Y)
+        if err != nil {
+            return nil, err
+        }
+        key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+        if !curve.IsOnCurve(key.X, key.Y) {
+            return nil, errors.New("EC point is not on the curve")
+        }
+        return key, nil
+    case "OKP":
+        if jwk.Curve != "Ed25519" {
+            return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+        }
+        x, err := decode(jwk.X)
+        if err != nil {
+            return nil, err
+        }
+        if len(x) != ed25519.PublicKeySize {
+            return nil, errors.New("invalid Ed25519 key")
+        }
+        return ed25519.PublicKey(x), nil
+    }
+    return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+}
+
+func getJSON(client *http.Client, url string, v interface{}) error {
+    resp, err := client.Get(url)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("%s returned %s", url, resp.Status)
+    }
+    return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+func contains(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
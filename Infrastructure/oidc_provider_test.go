@@ -0,0 +1,146 @@
+package infrastructure
+
+import (
+    "crypto/sha256"
+    "encoding/base64"
+    "errors"
+    "net/url"
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/Hailemari/clean_architecture_task_manager/Domain"
+    "github.com/Hailemari/clean_architecture_task_manager/Infrastructure/oidctest"
+    "github.com/golang-jwt/jwt/v5"
+)
+
+const testRedirectURL = "http://task-manager.test/auth/oidc/callback"
+
+func testOIDCProvider(t *testing.T, clientSecret string) (*oidctest.Provider, *OIDCProvider) {
+    t.Helper()
+    idp, err := oidctest.NewProvider("task-manager", clientSecret)
+    if err != nil {
+        t.Fatalf("oidctest.NewProvider: %v", err)
+    }
+    t.Cleanup(idp.Close)
+    provider, err := NewOIDCProvider(OIDCProviderSettings{
+        Issuer:       idp.Issuer,
+        ClientID:     idp.ClientID,
+        ClientSecret: clientSecret,
+        RedirectURL:  testRedirectURL,
+    }, nil)
+    if err != nil {
+        t.Fatalf("NewOIDCProvider: %v", err)
+    }
+    return idp, provider
+}
+
+// signIn runs the authorization step for the identity and returns the code.
+func signIn(t *testing.T, idp *oidctest.Provider, provider *OIDCProvider, identity oidctest.Identity, verifier, nonce string) string {
+    t.Helper()
+    idp.SignIn(identity)
+    sum := sha256.Sum256([]byte(verifier))
+    code, state, err := idp.Authorize(provider.AuthCodeURL("state-1", nonce, base64.RawURLEncoding.EncodeToString(sum[:])))
+    if err != nil {
+        t.Fatalf("Authorize: %v", err)
+    }
+    if state != "state-1" {
+        t.Fatalf("Authorize: state = %q, want state-1", state)
+    }
+    return code
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+    for _, secret := range []string{"s3cret&+", ""} {
+        idp, provider := testOIDCProvider(t, secret)
+        alice := oidctest.Identity{Subject: "u-1", Username: "alice", Email: "alice@example.com", Groups: []string{"staff", "leads"}}
+
+        authURL, err := url.Parse(provider.AuthCodeURL("state-1", "nonce-1", "challenge"))
+        if err != nil {
+            t.Fatalf("AuthCodeURL: %v", err)
+        }
+        if query := authURL.Query(); query.Get("code_challenge_method") != "S256" || query.Get("scope") != DefaultOIDCScopes ||
+            query.Get("redirect_uri") != testRedirectURL {
+            t.Fatalf("AuthCodeURL: unexpected query %v", query)
+        }
+
+        code := signIn(t, idp, provider, alice, "verifier-1", "nonce-1")
+        identity, err := provider.Exchange(code, "verifier-1", "nonce-1")
+        if err != nil {
+            t.Fatalf("Exchange (secret %q): %v", secret, err)
+        }
+        want := &domain.ExternalIdentity{Subject: "u-1", Username: "alice", Email: "alice@example.com", Groups: []string{"staff", "leads"}}
+        if !reflect.DeepEqual(identity, want) {
+            t.Fatalf("Exchange: got %+v, want %+v", identity, want)
+        }
+        if _, err := provider.Exchange(code, "verifier-1", "nonce-1"); !errors.Is(err, domain.ErrOIDCLoginFailed) {
+            t.Fatalf("Exchange of a used code: got %v, want %v", err, domain.ErrOIDCLoginFailed)
+        }
+    }
+}
+
+func TestOIDCProviderRejects(t *testing.T) {
+    idp, provider := testOIDCProvider(t, "s3cret")
+    alice := oidctest.Identity{Subject: "u-1", Username: "alice"}
+
+    code := signIn(t, idp, provider, alice, "verifier-1", "nonce-1")
+    if _, err := provider.Exchange(code, "another-verifier", "nonce-1"); !errors.Is(err, domain.ErrOIDCLoginFailed) {
+        t.Fatalf("Exchange with the wrong PKCE verifier: got %v, want %v", err, domain.ErrOIDCLoginFailed)
+    }
+    code = signIn(t, idp, provider, alice, "verifier-1", "nonce-1")
+    if _, err := provider.Exchange(code, "verifier-1", "another-nonce"); !errors.Is(err, domain.ErrOIDCLoginFailed) {
+        t.Fatalf("Exchange with the wrong nonce: got %v, want %v", err, domain.ErrOIDCLoginFailed)
+    }
+
+    for name, change := range map[string]func(jwt.MapClaims){
+        "another audience":  func(c jwt.MapClaims) { c["aud"] = "someone-else" },
+        "several audiences": func(c jwt.MapClaims) { c["aud"] = []string{"task-manager", "someone-else"}; c["azp"] = "someone-else" },
+        "another issuer":    func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+        "expired":           func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+        "no subject":        func(c jwt.MapClaims) { delete(c, "sub") },
+        "no username":       func(c jwt.MapClaims) { delete(c, "preferred_username") },
+    } {
+        idp.Claims = change
+        code := signIn(t, idp, provider, alice, "verifier-1", "nonce-1")
+        if _, err := provider.Exchange(code, "verifier-1", "nonce-1"); !errors.Is(err, domain.ErrOIDCLoginFailed) {
+            t.Fatalf("Exchange of a token with %s: got %v, want %v", name, err, domain.ErrOIDCLoginFailed)
+        }
+    }
+
+    idp.Claims = func(c jwt.MapClaims) { c["aud"] = []string{"task-manager", "someone-else"}; c["azp"] = "task-manager" }
+    code = signIn(t, idp, provider, alice, "verifier-1", "nonce-1")
+    if _, err := provider.Exchange(code, "verifier-1", "nonce-1"); err != nil {
+        t.Fatalf("Exchange of a token with several audiences issued to us: %v", err)
+    }
+}
+
+func TestOIDCProviderKeyRotation(t *testing.T) {
+    idp, provider := testOIDCProvider(t, "s3cret")
+    alice := oidctest.Identity{Subject: "u-1", Username: "alice"}
+    if err := idp.RotateKey(); err != nil {
+        t.Fatalf("RotateKey: %v", err)
+    }
+
+    // Right after fetching the keys an unknown kid does not fetch them again.
+    code := signIn(t, idp, provider, alice, "verifier-1", "nonce-1")
+    if _, err := provider.Exchange(code, "verifier-1", "nonce-1"); !errors.Is(err, domain.ErrOIDCLoginFailed) {
+        t.Fatalf("Exchange right after rotation: got %v, want %v", err, domain.ErrOIDCLoginFailed)
+    }
+    provider.keysFetched = time.Now().Add(-oidcKeyRefreshInterval)
+    code = signIn(t, idp, provider, alice, "verifier-1", "nonce-1")
+    if _, err := provider.Exchange(code, "verifier-1", "nonce-1"); err != nil {
+        t.Fatalf("Exchange after rotation: %v", err)
+    }
+}
+
+func TestNewOIDCProviderChecksIssuer(t *testing.T) {
+    idp, err := oidctest.NewProvider("task-manager", "")
+    if err != nil {
+        t.Fatalf("oidctest.NewProvider: %v", err)
+    }
+    defer idp.Close()
+    settings := OIDCProviderSettings{Issuer: idp.Issuer + "/", ClientID: "task-manager", RedirectURL: testRedirectURL}
+    if _, err := NewOIDCProvider(settings, nil); err == nil {
+        t.Fatal("NewOIDCProvider: accepted a discovery document for another issuer")
+    }
+}
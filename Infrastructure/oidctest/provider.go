@@ -0,0 +1,281 @@
+// Package oidctest runs a minimal OpenID Connect provider for tests. It
+// supports discovery, the authorization code flow with S256 PKCE and key
+// rotation, and signs in whoever SignIn was last called with.
+package oidctest
+
+import (
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "sync"
+    "time"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+// Identity is the user signed in at the provider.
+type Identity struct {
+    Subject  string
+    Username string
+    Email    string
+    Groups   []string
+}
+
+// Provider is a mock identity provider listening on a local port.
+type Provider struct {
+    Issuer       string
+    ClientID     string
+    ClientSecret string
+    // Claims, if set, can change the ID token claims before they are signed.
+    Claims func(claims jwt.MapClaims)
+
+    server *httptest.Server
+
+    mu       sync.Mutex
+    key      *rsa.PrivateKey
+    keyID    string
+    oldKeys  []*rsa.PrivateKey
+    identity *Identity
+    grants   map[string]grant
+}
+
+// grant is an issued authorization code.
+type grant struct {
+    identity      Identity
+    redirectURI   string
+    codeChallenge string
+    nonce         string
+}
+
+// NewProvider starts a provider for a confidential client with the given
+// credentials. An empty secret makes the client public.
+func NewProvider(clientID, clientSecret string) (*Provider, error) {
+    p := &Provider{ClientID: clientID, ClientSecret: clientSecret, grants: make(map[string]grant)}
+    if err := p.RotateKey(); err != nil {
+        return nil, err
+    }
+    mux := http.NewServeMux()
+    mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+    mux.HandleFunc("/jwks", p.jwks)
+    mux.HandleFunc("/authorize", p.authorize)
+    mux.HandleFunc("/token", p.token)
+    p.server = httptest.NewServer(mux)
+    p.Issuer = p.server.URL
+    return p, nil
+}
+
+func (p *Provider) Close() {
+    p.server.Close()
+}
+
+// SignIn makes identity the user who approves the next authorization requests.
+func (p *Provider) SignIn(identity Identity) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.identity = &identity
+}
+
+// RotateKey signs new tokens with a fresh key, published under a new kid.
+func (p *Provider) RotateKey() error {
+    key, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil {
+        return err
+    }
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.key != nil {
+        p.oldKeys = append(p.oldKeys, p.key)
+    }
+    p.key = key
+    p.keyID = fmt.Sprintf("key-%d", len(p.oldKeys)+1)
+    return nil
+}
+
+// Authorize follows authURL as a browser would and returns the code and
+// state the provider redirects back with.
+func (p *Provider) Authorize(authURL string) (string, string, error) {
+    client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+        return http.ErrUseLastResponse
+    }}
+    resp, err := client.Get(authURL)
+    if err != nil {
+        return "", "", err
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusFound {
+        return "", "", fmt.Errorf("authorize returned %s", resp.Status)
+    }
+    location, err := url.Parse(resp.Header.Get("Location"))
+    if err != nil {
+        return "", "", err
+    }
+    query := location.Query()
+    if query.Get("error") != "" {
+        return "", "", fmt.Errorf("authorize failed: %s", query.Get("error"))
+    }
+    return query.Get("code"), query.Get("state"), nil
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "issuer":                                p.Issuer,
+        "authorization_endpoint":                p.Issuer + "/authorize",
+        "token_endpoint":                        p.Issuer + "/token",
+        "jwks_uri":                              p.Issuer + "/jwks",
+        "response_types_supported":              []string{"code"},
+        "subject_types_supported":               []string{"public"},
+        "id_token_signing_alg_values_supported": []string{"RS256"},
+        "code_challenge_methods_supported":      []string{"S256"},
+    })
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    keys := []map[string]string{rsaJWK(p.keyID, &p.key.PublicKey)}
+    for i, key := range p.oldKeys {
+        keys = append(keys, rsaJWK(fmt.Sprintf("key-%d", i+1), &key.PublicKey))
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+// authorize approves the request as the signed-in identity, or sends the user
+// back with access_denied if nobody signed in.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    redirectURI, err := url.Parse(query.Get("redirect_uri"))
+    if err != nil || query.Get("redirect_uri") == "" || query.Get("client_id") != p.ClientID {
+        http.Error(w, "unknown client or redirect URI", http.StatusBadRequest)
+        return
+    }
+    back := redirectURI.Query()
+    back.Set("state", query.Get("state"))
+
+    p.mu.Lock()
+    identity := p.identity
+    p.mu.Unlock()
+    switch {
+    case query.Get("response_type") != "code":
+        back.Set("error", "unsupported_response_type")
+    case query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256":
+        back.Set("error", "invalid_request")
+    case identity == nil:
+        back.Set("error", "access_denied")
+    default:
+        code := randomString()
+        p.mu.Lock()
+        p.grants[code] = grant{
+            identity:      *identity,
+            redirectURI:   query.Get("redirect_uri"),
+            codeChallenge: query.Get("code_challenge"),
+            nonce:         query.Get("nonce"),
+        }
+        p.mu.Unlock()
+        back.Set("code", code)
+    }
+    redirectURI.RawQuery = back.Encode()
+    http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token redeems a code once, checking the client, redirect URI and PKCE
+// verifier.
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost || r.ParseForm() != nil {
+        writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+        return
+    }
+    clientID, secret, ok := r.BasicAuth()
+    if ok {
+        clientID, _ = url.QueryUnescape(clientID)
+        secret, _ = url.QueryUnescape(secret)
+    } else {
+        clientID = r.PostForm.Get("client_id")
+    }
+    if clientID != p.ClientID || subtle.ConstantTimeCompare([]byte(secret), []byte(p.ClientSecret)) != 1 {
+        writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+        return
+    }
+    if r.PostForm.Get("grant_type") != "authorization_code" {
+        writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+        return
+    }
+
+    p.mu.Lock()
+    code := r.PostForm.Get("code")
+    grant, ok := p.grants[code]
+    delete(p.grants, code)
+    p.mu.Unlock()
+    sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+    if !ok || grant.redirectURI != r.PostForm.Get("redirect_uri") ||
+        base64.RawURLEncoding.EncodeToString(sum[:]) != grant.codeChallenge {
+        writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+        return
+    }
+
+    idToken, err := p.signIDToken(grant)
+    if err != nil {
+        writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "access_token": randomString(),
+        "token_type":   "Bearer",
+        "expires_in":   300,
+        "id_token":     idToken,
+    })
+}
+
+func (p *Provider) signIDToken(grant grant) (string, error) {
+    now := time.Now()
+    claims := jwt.MapClaims{
+        "iss":                p.Issuer,
+        "sub":                grant.identity.Subject,
+        "aud":                p.ClientID,
+        "iat":                now.Unix(),
+        "exp":                now.Add(5 * time.Minute).Unix(),
+        "nonce":              grant.nonce,
+        "preferred_username": grant.identity.Username,
+        "email":              grant.identity.Email,
+        "groups":             grant.identity.Groups,
+    }
+    if p.Claims != nil {
+        p.Claims(claims)
+    }
+    p.mu.Lock()
+    key, keyID := p.key, p.keyID
+    p.mu.Unlock()
+    token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+    token.Header["kid"] = keyID
+    return token.SignedString(key)
+}
+
+func rsaJWK(keyID string, key *rsa.PublicKey) map[string]string {
+    return map[string]string{
+        "kty": "RSA",
+        "kid": keyID,
+        "use": "sig",
+        "alg": "RS256",
+        "n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+        "e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+    }
+}
+
+func randomString() string {
+    buf := make([]byte, 16)
+    rand.Read(buf)
+    return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(body)
+}
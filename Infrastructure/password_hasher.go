@@ -0,0 +1,142 @@
+package infrastructure
+
+import (
+    "crypto/rand"
+    "crypto/subtle"
+    "encoding/base64"
+    "fmt"
+    "strings"
+
+    "github.com/Hailemari/clean_architecture_task_manager/Domain"
+    "golang.org/x/crypto/argon2"
+    "golang.org/x/crypto/bcrypt"
+)
+
+const (
+    HashAlgorithmArgon2id = "argon2id"
+    HashAlgorithmBcrypt   = "bcrypt"
+
+    argon2SaltLength = 16
+    argon2KeyLength  = 32
+)
+
+// PasswordHashConfig selects the algorithm and cost of new password hashes.
+type PasswordHashConfig struct {
+    Algorithm string
+    // Argon2 memory is in KiB.
+    Argon2Memory      uint32
+    Argon2Iterations  uint32
+    Argon2Parallelism uint8
+    BcryptCost        int
+}
+
+// DefaultPasswordHashConfig returns argon2id with the second recommended
+// parameter set of RFC 9106: 64 MiB of memory, 3 passes and 4 lanes.
+func DefaultPasswordHashConfig() PasswordHashConfig {
+    return PasswordHashConfig{
+        Algorithm:         HashAlgorithmArgon2id,
+        Argon2Memory:      64 * 1024,
+        Argon2Iterations:  3,
+        Argon2Parallelism: 4,
+        BcryptCost:        bcrypt.DefaultCost,
+    }
+}
+
+// PHCPasswordHasher hashes new passwords with the configured algorithm and
+// verifies both argon2id and bcrypt hashes. bcrypt hashes keep their usual
+// "$2a$10$..." form, which is what the PHC format describes for bcrypt.
+type PHCPasswordHasher struct {
+    config PasswordHashConfig
+}
+
+func NewPasswordHasher(config PasswordHashConfig) (domain.PasswordHasher, error) {
+    switch config.Algorithm {
+    case HashAlgorithmArgon2id:
+        if config.Argon2Memory < 8*uint32(config.Argon2Parallelism) || config.Argon2Iterations < 1 || config.Argon2Parallelism < 1 {
+            return nil, fmt.Errorf("invalid argon2id parameters m=%d,t=%d,p=%d", config.Argon2Memory, config.Argon2Iterations, config.Argon2Parallelism)
+        }
+    case HashAlgorithmBcrypt:
+        if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+            return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+        }
+    default:
+        return nil, fmt.Errorf("unsupported password hash algorithm %q, expected argon2id or bcrypt", config.Algorithm)
+    }
+    return &PHCPasswordHasher{config: config}, nil
+}
+
+func (h *PHCPasswordHasher) Hash(password string) (string, error) {
+    if h.config.Algorithm == HashAlgorithmBcrypt {
+        hash, err := bcrypt.GenerateFromPassword([]byte(password), h.config.BcryptCost)
+        return string(hash), err
+    }
+
+    salt := make([]byte, argon2SaltLength)
+    if _, err := rand.Read(salt); err != nil {
+        return "", err
+    }
+    c := h.config
+    key := argon2.IDKey([]byte(password), salt, c.Argon2Iterations, c.Argon2Memory, c.Argon2Parallelism, argon2KeyLength)
+    return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+        argon2.Version, c.Argon2Memory, c.Argon2Iterations, c.Argon2Parallelism,
+        base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *PHCPasswordHasher) Verify(hash, password string) (bool, bool, error) {
+    if strings.HasPrefix(hash, "$argon2id$") {
+        return h.verifyArgon2id(hash, password)
+    }
+    if strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$") {
+        return h.verifyBcrypt(hash, password)
+    }
+    return false, false, domain.ErrUnsupportedPasswordHash
+}
+
+func (h *PHCPasswordHasher) verifyBcrypt(hash, password string) (bool, bool, error) {
+    cost, err := bcrypt.Cost([]byte(hash))
+    if err != nil {
+        return false, false, domain.ErrUnsupportedPasswordHash
+    }
+    if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+        if err == bcrypt.ErrMismatchedHashAndPassword {
+            return false, false, nil
+        }
+        return false, false, domain.ErrUnsupportedPasswordHash
+    }
+    return true, h.config.Algorithm != HashAlgorithmBcrypt || cost != h.config.BcryptCost, nil
+}
+
+func (h *PHCPasswordHasher) verifyArgon2id(hash, password string) (bool, bool, error) {
+    // "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+    parts := strings.Split(hash, "$")
+    if len(parts) != 6 {
+        return false, false, domain.ErrUnsupportedPasswordHash
+    }
+    var version int
+    if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+        return false, false, domain.ErrUnsupportedPasswordHash
+    }
+    var memory, iterations uint32
+    var parallelism uint8
+    if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil || iterations < 1 || parallelism < 1 {
+        return false, false, domain.ErrUnsupportedPasswordHash
+    }
+    salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+    if err != nil {
+        return false, false, domain.ErrUnsupportedPasswordHash
+    }
+    key, err := base64.RawStdEncoding.DecodeString(parts[5])
+    if err != nil || len(key) == 0 {
+        return false, false, domain.ErrUnsupportedPasswordHash
+    }
+
+    computed := argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, uint32(len(key)))
+    if subtle.ConstantTimeCompare(computed, key) != 1 {
+        return false, false, nil
+    }
+    c := h.config
+    needsRehash := c.Algorithm != HashAlgorithmArgon2id ||
+        memory != c.Argon2Memory || iterations != c.Argon2Iterations || parallelism != c.Argon2Parallelism ||
+        len(salt) != argon2SaltLength || len(key) != argon2KeyLength
+    return true, needsRehash, nil
+}
@@ -0,0 +1,82 @@
+package infrastructure
+
+import (
+    "errors"
+    "strings"
+    "testing"
+
+    "github.com/Hailemari/clean_architecture_task_manager/Domain"
+    "golang.org/x/crypto/bcrypt"
+)
+
+func testHasher(t *testing.T, mutate func(*PasswordHashConfig)) domain.PasswordHasher {
+    t.Helper()
+    config := DefaultPasswordHashConfig()
+    config.Argon2Memory, config.Argon2Iterations, config.Argon2Parallelism = 64, 1, 1
+    config.BcryptCost = bcrypt.MinCost
+    if mutate != nil {
+        mutate(&config)
+    }
+    hasher, err := NewPasswordHasher(config)
+    if err != nil {
+        t.Fatalf("NewPasswordHasher: %v", err)
+    }
+    return hasher
+}
+
+func TestPasswordHasherRoundTrip(t *testing.T) {
+    for _, algorithm := range []string{HashAlgorithmArgon2id, HashAlgorithmBcrypt} {
+        hasher := testHasher(t, func(c *PasswordHashConfig) { c.Algorithm = algorithm })
+        hash, err := hasher.Hash("k7#vQ2!mzP")
+        if err != nil {
+            t.Fatalf("%s: Hash: %v", algorithm, err)
+        }
+        if algorithm == HashAlgorithmArgon2id && !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+            t.Fatalf("argon2id: hash %q is not in PHC format", hash)
+        }
+        if match, rehash, err := hasher.Verify(hash, "k7#vQ2!mzP"); !match || rehash || err != nil {
+            t.Fatalf("%s: Verify = %v, %v, %v; want true, false, nil", algorithm, match, rehash, err)
+        }
+        if match, _, err := hasher.Verify(hash, "wrong"); match || err != nil {
+            t.Fatalf("%s: Verify wrong password = %v, %v; want false, nil", algorithm, match, err)
+        }
+    }
+}
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+    bcryptHash, _ := testHasher(t, func(c *PasswordHashConfig) { c.Algorithm = HashAlgorithmBcrypt }).Hash("secret")
+    argonHash, _ := testHasher(t, nil).Hash("secret")
+
+    for _, tc := range []struct {
+        name   string
+        hash   string
+        hasher domain.PasswordHasher
+    }{
+        {"bcrypt to argon2id", bcryptHash, testHasher(t, nil)},
+        {"bcrypt cost", bcryptHash, testHasher(t, func(c *PasswordHashConfig) { c.Algorithm, c.BcryptCost = HashAlgorithmBcrypt, 5 })},
+        {"argon2id memory", argonHash, testHasher(t, func(c *PasswordHashConfig) { c.Argon2Memory = 128 })},
+        {"argon2id to bcrypt", argonHash, testHasher(t, func(c *PasswordHashConfig) { c.Algorithm = HashAlgorithmBcrypt })},
+    } {
+        match, rehash, err := tc.hasher.Verify(tc.hash, "secret")
+        if !match || !rehash || err != nil {
+            t.Errorf("%s: Verify = %v, %v, %v; want true, true, nil", tc.name, match, rehash, err)
+        }
+    }
+}
+
+func TestPasswordHasherRejectsMalformedHashes(t *testing.T) {
+    hasher := testHasher(t, nil)
+    for _, hash := range []string{
+        "",
+        "plaintext",
+        "$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5",
+        "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5",
+        "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5",
+        "$argon2id$v=19$m=64,t=1,p=1$!!$a2V5",
+        "$2a$10$short",
+    } {
+        if _, _, err := hasher.Verify(hash, "secret"); !errors.Is(err, domain.ErrUnsupportedPasswordHash) {
+            t.Errorf("Verify(%q): got %v, want %v", hash, err, domain.ErrUnsupportedPasswordHash)
+        }
+    }
+}
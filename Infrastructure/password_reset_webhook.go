@@ -0,0 +1,42 @@
+package infrastructure
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "time"
+
+    "github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+// WebhookPasswordResetNotifier delivers reset tokens by POSTing them as JSON
+// to a URL, typically a mail relay that emails the user.
+type WebhookPasswordResetNotifier struct {
+    url    string
+    client *http.Client
+}
+
+func NewWebhookPasswordResetNotifier(url string) domain.PasswordResetNotifier {
+    return &WebhookPasswordResetNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *WebhookPasswordResetNotifier) SendPasswordReset(user *domain.User, token string, expiresAt time.Time) error {
+    body, err := json.Marshal(map[string]interface{}{
+        "username":    user.Username,
+        "reset_token": token,
+        "expires_at":  expiresAt,
+    })
+    if err != nil {
+        return err
+    }
+    resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+    if err != nil {
+        return fmt.Errorf("could not deliver password reset: %w", err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        return fmt.Errorf("could not deliver password reset: webhook returned %s", resp.Status)
+    }
+    return nil
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoAccessTokenRepository struct {
+    collection *mongo.Collection
+}
+
+func NewMongoAccessTokenRepository(collection *mongo.Collection) domain.AccessTokenRepository {
+    return &MongoAccessTokenRepository{collection: collection}
+}
+
+func (r *MongoAccessTokenRepository) CreateAccessToken(token *domain.PersonalAccessToken) error {
+    if token.ID.IsZero() {
+        token.ID = primitive.NewObjectID()
+    }
+    _, err := r.collection.InsertOne(context.TODO(), token)
+    return err
+}
+
+func (r *MongoAccessTokenRepository) GetAccessTokenByHash(hash string) (*domain.PersonalAccessToken, error) {
+    token := &domain.PersonalAccessToken{}
+    err := r.collection.FindOne(context.TODO(), bson.M{"token_hash": hash}).Decode(token)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, domain.ErrAccessTokenNotFound
+        }
+        return nil, err
+    }
+    return token, nil
+}
+
+func (r *MongoAccessTokenRepository) ListUserAccessTokens(userID primitive.ObjectID) ([]domain.PersonalAccessToken, error) {
+    opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+    cursor, err := r.collection.Find(context.TODO(), bson.M{"user_id": userID}, opts)
+    if err != nil {
+        return nil, err
+    }
+    tokens := []domain.PersonalAccessToken{}
+    if err := cursor.All(context.TODO(), &tokens); err != nil {
+        return nil, err
+    }
+    return tokens, nil
+}
+
+func (r *MongoAccessTokenRepository) RevokeAccessToken(userID, id primitive.ObjectID) error {
+    result, err := r.collection.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": id, "user_id": userID},
+        bson.M{"$set": bson.M{"revoked": true}},
+    )
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return domain.ErrAccessTokenNotFound
+    }
+    return nil
+}
+
+func (r *MongoAccessTokenRepository) RevokeUserAccessTokens(userID primitive.ObjectID) error {
+    _, err := r.collection.UpdateMany(
+        context.TODO(),
+        bson.M{"user_id": userID},
+        bson.M{"$set": bson.M{"revoked": true}},
+    )
+    return err
+}
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// bootstrapMarkerID is the _id of the marker document. The unique _id index
+// makes sure only one insert of it can succeed.
+const bootstrapMarkerID = "admin_bootstrap"
+
+type MongoBootstrapRepository struct {
+    collection *mongo.Collection
+}
+
+func NewMongoBootstrapRepository(collection *mongo.Collection) domain.BootstrapRepository {
+    return &MongoBootstrapRepository{collection: collection}
+}
+
+func (r *MongoBootstrapRepository) IsBootstrapped() (bool, error) {
+    count, err := r.collection.CountDocuments(context.TODO(), bson.M{"_id": bootstrapMarkerID})
+    return count > 0, err
+}
+
+func (r *MongoBootstrapRepository) ClaimBootstrap(at time.Time) (bool, error) {
+    _, err := r.collection.InsertOne(context.TODO(), bson.M{"_id": bootstrapMarkerID, "claimed_at": at})
+    if mongo.IsDuplicateKeyError(err) {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+func (r *MongoBootstrapRepository) ReleaseBootstrap() error {
+    _, err := r.collection.DeleteOne(context.TODO(), bson.M{"_id": bootstrapMarkerID})
+    return err
+}
+
+func (r *MongoBootstrapRepository) ReleaseBootstrapClaimedBefore(before time.Time) error {
+    _, err := r.collection.DeleteOne(context.TODO(), bson.M{"_id": bootstrapMarkerID, "claimed_at": bson.M{"$lt": before}})
+    return err
+}
@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureUserIndexes creates the unique indexes on usernames and external IDs
+// that MongoUserRepository relies on to refuse duplicates, including ones
+// created concurrently. Users without an external ID are left out of its
+// index.
+func EnsureUserIndexes(collection *mongo.Collection) error {
+    _, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+        {Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "external_id", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+    })
+    return err
+}
+
+// EnsureTokenHashIndex creates a unique index on token_hash, for the
+// collections of refresh tokens, personal access tokens, password reset
+// tokens and invites, which are all looked up by the hash. Creating an index
+// that already exists does nothing.
+func EnsureTokenHashIndex(collection *mongo.Collection) error {
+    _, err := collection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+        Keys:    bson.D{{Key: "token_hash", Value: 1}},
+        Options: options.Index().SetUnique(true),
+    })
+    return err
+}
+
+// EnsureTokenRevocationIndexes creates a TTL index that removes revoked token
+// IDs once their tokens have expired. Lookups by token ID use the _id index.
+func EnsureTokenRevocationIndexes(tokens *mongo.Collection) error {
+    _, err := tokens.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+        Keys:    bson.D{{Key: "expires_at", Value: 1}},
+        Options: options.Index().SetExpireAfterSeconds(0),
+    })
+    return err
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoInviteRepository struct {
+    collection *mongo.Collection
+}
+
+func NewMongoInviteRepository(collection *mongo.Collection) domain.InviteRepository {
+    return &MongoInviteRepository{collection: collection}
+}
+
+func (r *MongoInviteRepository) CreateInvite(invite *domain.Invite) error {
+    if invite.ID.IsZero() {
+        invite.ID = primitive.NewObjectID()
+    }
+    _, err := r.collection.InsertOne(context.TODO(), invite)
+    return err
+}
+
+func (r *MongoInviteRepository) GetInviteByHash(hash string) (*domain.Invite, error) {
+    invite := &domain.Invite{}
+    err := r.collection.FindOne(context.TODO(), bson.M{"token_hash": hash}).Decode(invite)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, domain.ErrInviteNotFound
+        }
+        return nil, err
+    }
+    return invite, nil
+}
+
+func (r *MongoInviteRepository) ListPendingInvites(now time.Time) ([]domain.Invite, error) {
+    filter := bson.M{"used": false, "revoked": false, "expires_at": bson.M{"$gt": now}}
+    opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+    cursor, err := r.collection.Find(context.TODO(), filter, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(context.TODO())
+
+    invites := []domain.Invite{}
+    if err := cursor.All(context.TODO(), &invites); err != nil {
+        return nil, err
+    }
+    return invites, nil
+}
+
+func (r *MongoInviteRepository) MarkInviteUsed(id primitive.ObjectID) (bool, error) {
+    result, err := r.collection.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": id, "used": false, "revoked": false},
+        bson.M{"$set": bson.M{"used": true}},
+    )
+    if err != nil {
+        return false, err
+    }
+    return result.ModifiedCount == 1, nil
+}
+
+func (r *MongoInviteRepository) RevokeInvite(id primitive.ObjectID) error {
+    result, err := r.collection.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": id, "used": false, "revoked": false},
+        bson.M{"$set": bson.M{"revoked": true}},
+    )
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return domain.ErrInviteNotFound
+    }
+    return nil
+}
+
+func (r *MongoInviteRepository) RevokeUsernameInvites(username string) error {
+    _, err := r.collection.UpdateMany(
+        context.TODO(),
+        bson.M{"username": username, "used": false},
+        bson.M{"$set": bson.M{"revoked": true}},
+    )
+    return err
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoLoginAttemptRepository struct {
+    collection *mongo.Collection
+}
+
+func NewMongoLoginAttemptRepository(collection *mongo.Collection) domain.LoginAttemptRepository {
+    return &MongoLoginAttemptRepository{collection: collection}
+}
+
+func (r *MongoLoginAttemptRepository) GetLoginAttempt(key string) (*domain.LoginAttempt, error) {
+    attempt := &domain.LoginAttempt{}
+    err := r.collection.FindOne(context.TODO(), bson.M{"_id": key}).Decode(attempt)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &domain.LoginAttempt{Key: key}, nil
+        }
+        return nil, err
+    }
+    return attempt, nil
+}
+
+func (r *MongoLoginAttemptRepository) RecordLoginFailure(key string, at time.Time, resetBefore time.Time) (*domain.LoginAttempt, error) {
+    _, err := r.collection.DeleteMany(context.TODO(), bson.M{
+        "last_failure": bson.M{"$lt": resetBefore},
+        "$or": bson.A{
+            bson.M{"locked_until": bson.M{"$lte": at}},
+            bson.M{"locked_until": bson.M{"$exists": false}},
+        },
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    _, err = r.collection.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": key, "last_failure": bson.M{"$lt": resetBefore}},
+        bson.M{"$set": bson.M{"failures": 0}},
+    )
+    if err != nil {
+        return nil, err
+    }
+
+    attempt := &domain.LoginAttempt{}
+    err = r.collection.FindOneAndUpdate(
+        context.TODO(),
+        bson.M{"_id": key},
+        bson.M{"$inc": bson.M{"failures": 1}, "$set": bson.M{"last_failure": at}},
+        options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+    ).Decode(attempt)
+    if err != nil {
+        return nil, err
+    }
+    return attempt, nil
+}
+
+func (r *MongoLoginAttemptRepository) LockLogin(key string, until time.Time) error {
+    _, err := r.collection.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": key},
+        bson.M{"$max": bson.M{"locked_until": until}},
+        options.Update().SetUpsert(true),
+    )
+    return err
+}
+
+func (r *MongoLoginAttemptRepository) ResetLoginAttempts(key string) error {
+    _, err := r.collection.DeleteOne(context.TODO(), bson.M{"_id": key})
+    return err
+}
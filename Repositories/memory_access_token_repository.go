@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type InMemoryAccessTokenRepository struct {
+    mu     sync.Mutex
+    tokens map[primitive.ObjectID]domain.PersonalAccessToken
+}
+
+func NewInMemoryAccessTokenRepository() domain.AccessTokenRepository {
+    return &InMemoryAccessTokenRepository{tokens: make(map[primitive.ObjectID]domain.PersonalAccessToken)}
+}
+
+func (r *InMemoryAccessTokenRepository) CreateAccessToken(token *domain.PersonalAccessToken) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if token.ID.IsZero() {
+        token.ID = primitive.NewObjectID()
+    }
+    stored := *token
+    stored.Scopes = append([]string(nil), token.Scopes...)
+    r.tokens[token.ID] = stored
+    return nil
+}
+
+func (r *InMemoryAccessTokenRepository) GetAccessTokenByHash(hash string) (*domain.PersonalAccessToken, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for _, token := range r.tokens {
+        if token.TokenHash == hash {
+            token.Scopes = append([]string(nil), token.Scopes...)
+            return &token, nil
+        }
+    }
+    return nil, domain.ErrAccessTokenNotFound
+}
+
+func (r *InMemoryAccessTokenRepository) ListUserAccessTokens(userID primitive.ObjectID) ([]domain.PersonalAccessToken, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    tokens := []domain.PersonalAccessToken{}
+    for _, token := range r.tokens {
+        if token.UserID == userID {
+            token.Scopes = append([]string(nil), token.Scopes...)
+            tokens = append(tokens, token)
+        }
+    }
+    sort.Slice(tokens, func(i, j int) bool {
+        if !tokens[i].CreatedAt.Equal(tokens[j].CreatedAt) {
+            return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+        }
+        return tokens[i].ID.Hex() < tokens[j].ID.Hex()
+    })
+    return tokens, nil
+}
+
+func (r *InMemoryAccessTokenRepository) RevokeAccessToken(userID, id primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    token, ok := r.tokens[id]
+    if !ok || token.UserID != userID {
+        return domain.ErrAccessTokenNotFound
+    }
+    token.Revoked = true
+    r.tokens[id] = token
+    return nil
+}
+
+func (r *InMemoryAccessTokenRepository) RevokeUserAccessTokens(userID primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for id, token := range r.tokens {
+        if token.UserID == userID {
+            token.Revoked = true
+            r.tokens[id] = token
+        }
+    }
+    return nil
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+type InMemoryBootstrapRepository struct {
+    mu        sync.Mutex
+    claimed   bool
+    claimedAt time.Time
+}
+
+func NewInMemoryBootstrapRepository() domain.BootstrapRepository {
+    return &InMemoryBootstrapRepository{}
+}
+
+func (r *InMemoryBootstrapRepository) IsBootstrapped() (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return r.claimed, nil
+}
+
+func (r *InMemoryBootstrapRepository) ClaimBootstrap(at time.Time) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if r.claimed {
+        return false, nil
+    }
+    r.claimed = true
+    r.claimedAt = at
+    return true, nil
+}
+
+func (r *InMemoryBootstrapRepository) ReleaseBootstrap() error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.claimed = false
+    return nil
+}
+
+func (r *InMemoryBootstrapRepository) ReleaseBootstrapClaimedBefore(before time.Time) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if r.claimed && r.claimedAt.Before(before) {
+        r.claimed = false
+    }
+    return nil
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type InMemoryInviteRepository struct {
+    mu      sync.Mutex
+    invites map[primitive.ObjectID]domain.Invite
+}
+
+func NewInMemoryInviteRepository() domain.InviteRepository {
+    return &InMemoryInviteRepository{invites: make(map[primitive.ObjectID]domain.Invite)}
+}
+
+func (r *InMemoryInviteRepository) CreateInvite(invite *domain.Invite) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if invite.ID.IsZero() {
+        invite.ID = primitive.NewObjectID()
+    }
+    r.invites[invite.ID] = *invite
+    return nil
+}
+
+func (r *InMemoryInviteRepository) GetInviteByHash(hash string) (*domain.Invite, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for _, invite := range r.invites {
+        if invite.TokenHash == hash {
+            return &invite, nil
+        }
+    }
+    return nil, domain.ErrInviteNotFound
+}
+
+func (r *InMemoryInviteRepository) ListPendingInvites(now time.Time) ([]domain.Invite, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    invites := []domain.Invite{}
+    for _, invite := range r.invites {
+        if !invite.Used && !invite.Revoked && invite.ExpiresAt.After(now) {
+            invites = append(invites, invite)
+        }
+    }
+    sort.Slice(invites, func(i, j int) bool {
+        if !invites[i].CreatedAt.Equal(invites[j].CreatedAt) {
+            return invites[i].CreatedAt.Before(invites[j].CreatedAt)
+        }
+        return invites[i].ID.Hex() < invites[j].ID.Hex()
+    })
+    return invites, nil
+}
+
+func (r *InMemoryInviteRepository) MarkInviteUsed(id primitive.ObjectID) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    invite, ok := r.invites[id]
+    if !ok || invite.Used || invite.Revoked {
+        return false, nil
+    }
+    invite.Used = true
+    r.invites[id] = invite
+    return true, nil
+}
+
+func (r *InMemoryInviteRepository) RevokeInvite(id primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    invite, ok := r.invites[id]
+    if !ok || invite.Used || invite.Revoked {
+        return domain.ErrInviteNotFound
+    }
+    invite.Revoked = true
+    r.invites[id] = invite
+    return nil
+}
+
+func (r *InMemoryInviteRepository) RevokeUsernameInvites(username string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for id, invite := range r.invites {
+        if invite.Username == username && !invite.Used {
+            invite.Revoked = true
+            r.invites[id] = invite
+        }
+    }
+    return nil
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+type InMemoryLoginAttemptRepository struct {
+    mu       sync.Mutex
+    attempts map[string]domain.LoginAttempt
+}
+
+func NewInMemoryLoginAttemptRepository() domain.LoginAttemptRepository {
+    return &InMemoryLoginAttemptRepository{attempts: make(map[string]domain.LoginAttempt)}
+}
+
+func (r *InMemoryLoginAttemptRepository) GetLoginAttempt(key string) (*domain.LoginAttempt, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    attempt, ok := r.attempts[key]
+    if !ok {
+        attempt.Key = key
+    }
+    return &attempt, nil
+}
+
+func (r *InMemoryLoginAttemptRepository) RecordLoginFailure(key string, at time.Time, resetBefore time.Time) (*domain.LoginAttempt, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for k, attempt := range r.attempts {
+        if attempt.LastFailure.Before(resetBefore) && !attempt.LockedUntil.After(at) {
+            delete(r.attempts, k)
+        }
+    }
+    attempt := r.attempts[key]
+    attempt.Key = key
+    if attempt.LastFailure.Before(resetBefore) {
+        attempt.Failures = 0
+    }
+    attempt.Failures++
+    attempt.LastFailure = at
+    r.attempts[key] = attempt
+    return &attempt, nil
+}
+
+func (r *InMemoryLoginAttemptRepository) LockLogin(key string, until time.Time) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    attempt := r.attempts[key]
+    attempt.Key = key
+    if until.After(attempt.LockedUntil) {
+        attempt.LockedUntil = until
+    }
+    r.attempts[key] = attempt
+    return nil
+}
+
+func (r *InMemoryLoginAttemptRepository) ResetLoginAttempts(key string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    delete(r.attempts, key)
+    return nil
+}
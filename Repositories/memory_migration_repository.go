@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+type InMemoryMigrationRepository struct {
+    mu      sync.Mutex
+    claimed map[string]time.Time
+}
+
+func NewInMemoryMigrationRepository() domain.MigrationRepository {
+    return &InMemoryMigrationRepository{claimed: make(map[string]time.Time)}
+}
+
+func (r *InMemoryMigrationRepository) ClaimMigration(name string, at time.Time) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if _, ok := r.claimed[name]; ok {
+        return false, nil
+    }
+    r.claimed[name] = at
+    return true, nil
+}
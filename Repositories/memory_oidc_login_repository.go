@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InMemoryOIDCLoginRepository keeps pending logins in a map keyed by state hash.
+type InMemoryOIDCLoginRepository struct {
+    mu     sync.Mutex
+    logins map[string]domain.OIDCLogin
+}
+
+func NewInMemoryOIDCLoginRepository() domain.OIDCLoginRepository {
+    return &InMemoryOIDCLoginRepository{logins: make(map[string]domain.OIDCLogin)}
+}
+
+func (r *InMemoryOIDCLoginRepository) CreateOIDCLogin(login *domain.OIDCLogin) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if login.ID.IsZero() {
+        login.ID = primitive.NewObjectID()
+    }
+    r.logins[login.StateHash] = *login
+    return nil
+}
+
+func (r *InMemoryOIDCLoginRepository) TakeOIDCLogin(stateHash string) (*domain.OIDCLogin, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    login, ok := r.logins[stateHash]
+    if !ok {
+        return nil, domain.ErrOIDCLoginNotFound
+    }
+    delete(r.logins, stateHash)
+    return &login, nil
+}
+
+func (r *InMemoryOIDCLoginRepository) DeleteExpiredOIDCLogins(now time.Time) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for hash, login := range r.logins {
+        if login.ExpiresAt.Before(now) {
+            delete(r.logins, hash)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,199 @@
+package repositories
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memberKey struct {
+    orgID  primitive.ObjectID
+    userID primitive.ObjectID
+}
+
+type InMemoryOrganizationRepository struct {
+    mu      sync.Mutex
+    orgs    map[primitive.ObjectID]domain.Organization
+    members map[memberKey]domain.Membership
+}
+
+func NewInMemoryOrganizationRepository() domain.OrganizationRepository {
+    return &InMemoryOrganizationRepository{
+        orgs:    make(map[primitive.ObjectID]domain.Organization),
+        members: make(map[memberKey]domain.Membership),
+    }
+}
+
+func (r *InMemoryOrganizationRepository) CreateOrganization(org *domain.Organization) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if org.ID.IsZero() {
+        org.ID = primitive.NewObjectID()
+    }
+    r.orgs[org.ID] = *org
+    return nil
+}
+
+func (r *InMemoryOrganizationRepository) GetOrganization(id primitive.ObjectID) (*domain.Organization, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    org, ok := r.orgs[id]
+    if !ok {
+        return nil, domain.ErrOrganizationNotFound
+    }
+    return &org, nil
+}
+
+func (r *InMemoryOrganizationRepository) CountOrganizations() (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    return int64(len(r.orgs)), nil
+}
+
+func (r *InMemoryOrganizationRepository) AddMember(membership *domain.Membership) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    key := memberKey{membership.OrgID, membership.UserID}
+    if _, exists := r.members[key]; exists {
+        return domain.ErrAlreadyOrgMember
+    }
+    r.members[key] = *membership
+    return nil
+}
+
+func (r *InMemoryOrganizationRepository) GetMembership(orgID, userID primitive.ObjectID) (*domain.Membership, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    membership, ok := r.members[memberKey{orgID, userID}]
+    if !ok {
+        return nil, domain.ErrNotOrgMember
+    }
+    return &membership, nil
+}
+
+func (r *InMemoryOrganizationRepository) ListMembers(orgID primitive.ObjectID) ([]domain.Membership, error) {
+    return r.findMemberships(func(m domain.Membership) bool { return m.OrgID == orgID }), nil
+}
+
+func (r *InMemoryOrganizationRepository) ListUserMemberships(userID primitive.ObjectID) ([]domain.Membership, error) {
+    return r.findMemberships(func(m domain.Membership) bool { return m.UserID == userID }), nil
+}
+
+// findMemberships returns the matching memberships oldest first.
+func (r *InMemoryOrganizationRepository) findMemberships(match func(domain.Membership) bool) []domain.Membership {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    memberships := []domain.Membership{}
+    for _, membership := range r.members {
+        if match(membership) {
+            memberships = append(memberships, membership)
+        }
+    }
+    sort.Slice(memberships, func(i, j int) bool {
+        a, b := memberships[i], memberships[j]
+        if !a.CreatedAt.Equal(b.CreatedAt) {
+            return a.CreatedAt.Before(b.CreatedAt)
+        }
+        if a.OrgID != b.OrgID {
+            return bytes.Compare(a.OrgID[:], b.OrgID[:]) < 0
+        }
+        return bytes.Compare(a.UserID[:], b.UserID[:]) < 0
+    })
+    return memberships
+}
+
+func (r *InMemoryOrganizationRepository) AcceptInvitation(orgID, userID primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    key := memberKey{orgID, userID}
+    membership, ok := r.members[key]
+    if !ok || !membership.Pending {
+        return domain.ErrNotOrgMember
+    }
+    membership.Pending = false
+    r.members[key] = membership
+    return nil
+}
+
+func (r *InMemoryOrganizationRepository) SetMemberRole(orgID, userID primitive.ObjectID, role string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    key := memberKey{orgID, userID}
+    membership, ok := r.members[key]
+    if !ok {
+        return domain.ErrNotOrgMember
+    }
+    if role != domain.RoleAdmin && r.isLastOrgAdmin(membership) {
+        return domain.ErrLastOrgAdmin
+    }
+    membership.Role = role
+    r.members[key] = membership
+    return nil
+}
+
+func (r *InMemoryOrganizationRepository) RemoveMember(orgID, userID primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    key := memberKey{orgID, userID}
+    membership, ok := r.members[key]
+    if !ok {
+        return domain.ErrNotOrgMember
+    }
+    if r.isLastOrgAdmin(membership) {
+        return domain.ErrLastOrgAdmin
+    }
+    delete(r.members, key)
+    return nil
+}
+
+// isLastOrgAdmin reports whether membership is the only accepted admin
+// membership of its organization. The caller must hold the lock.
+func (r *InMemoryOrganizationRepository) isLastOrgAdmin(membership domain.Membership) bool {
+    if membership.Role != domain.RoleAdmin || membership.Pending {
+        return false
+    }
+    for _, other := range r.members {
+        if other.OrgID == membership.OrgID && other.UserID != membership.UserID &&
+            other.Role == domain.RoleAdmin && !other.Pending {
+            return false
+        }
+    }
+    return true
+}
+
+func (r *InMemoryOrganizationRepository) RemoveUserMemberships(userID primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for key := range r.members {
+        if key.userID == userID {
+            delete(r.members, key)
+        }
+    }
+    return nil
+}
+
+func (r *InMemoryOrganizationRepository) CountMembersWithRole(role string) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    var count int64
+    for _, membership := range r.members {
+        if membership.Role == role {
+            count++
+        }
+    }
+    return count, nil
+}
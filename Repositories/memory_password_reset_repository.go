@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type InMemoryPasswordResetRepository struct {
+    mu     sync.Mutex
+    tokens map[primitive.ObjectID]domain.PasswordResetToken
+}
+
+func NewInMemoryPasswordResetRepository() domain.PasswordResetRepository {
+    return &InMemoryPasswordResetRepository{tokens: make(map[primitive.ObjectID]domain.PasswordResetToken)}
+}
+
+func (r *InMemoryPasswordResetRepository) CreatePasswordResetToken(token *domain.PasswordResetToken) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if token.ID.IsZero() {
+        token.ID = primitive.NewObjectID()
+    }
+    r.tokens[token.ID] = *token
+    return nil
+}
+
+func (r *InMemoryPasswordResetRepository) GetPasswordResetTokenByHash(hash string) (*domain.PasswordResetToken, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for _, token := range r.tokens {
+        if token.TokenHash == hash {
+            return &token, nil
+        }
+    }
+    return nil, domain.ErrPasswordResetTokenNotFound
+}
+
+func (r *InMemoryPasswordResetRepository) MarkPasswordResetTokenUsed(id primitive.ObjectID) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    token, ok := r.tokens[id]
+    if !ok || token.Used {
+        return false, nil
+    }
+    token.Used = true
+    r.tokens[id] = token
+    return true, nil
+}
+
+func (r *InMemoryPasswordResetRepository) InvalidateUserPasswordResetTokens(userID primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for id, token := range r.tokens {
+        if token.UserID == userID {
+            token.Used = true
+            r.tokens[id] = token
+        }
+    }
+    return nil
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type InMemoryRefreshTokenRepository struct {
+    mu     sync.Mutex
+    tokens map[primitive.ObjectID]domain.RefreshToken
+}
+
+func NewInMemoryRefreshTokenRepository() domain.RefreshTokenRepository {
+    return &InMemoryRefreshTokenRepository{tokens: make(map[primitive.ObjectID]domain.RefreshToken)}
+}
+
+func (r *InMemoryRefreshTokenRepository) CreateRefreshToken(token *domain.RefreshToken) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if token.ID.IsZero() {
+        token.ID = primitive.NewObjectID()
+    }
+    r.tokens[token.ID] = *token
+    return nil
+}
+
+func (r *InMemoryRefreshTokenRepository) GetRefreshTokenByHash(hash string) (*domain.RefreshToken, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for _, token := range r.tokens {
+        if token.TokenHash == hash {
+            return &token, nil
+        }
+    }
+    return nil, domain.ErrRefreshTokenNotFound
+}
+
+func (r *InMemoryRefreshTokenRepository) MarkRefreshTokenUsed(id primitive.ObjectID) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    token, ok := r.tokens[id]
+    if !ok || token.Used {
+        return false, nil
+    }
+    token.Used = true
+    r.tokens[id] = token
+    return true, nil
+}
+
+func (r *InMemoryRefreshTokenRepository) RevokeRefreshTokenFamily(familyID primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for id, token := range r.tokens {
+        if token.FamilyID == familyID {
+            token.Revoked = true
+            r.tokens[id] = token
+        }
+    }
+    return nil
+}
+
+func (r *InMemoryRefreshTokenRepository) RevokeUserRefreshTokens(userID primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for id, token := range r.tokens {
+        if token.UserID == userID {
+            token.Revoked = true
+            r.tokens[id] = token
+        }
+    }
+    return nil
+}
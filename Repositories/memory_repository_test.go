@@ -0,0 +1,93 @@
+package repositories_test
+
+import (
+	"testing"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories/repotest"
+)
+
+func TestInMemoryTaskRepository(t *testing.T) {
+    repotest.RunTaskRepositoryTests(t, func(t *testing.T) domain.TaskRepository {
+        return repositories.NewInMemoryTaskRepository()
+    })
+}
+
+func TestInMemoryUserRepository(t *testing.T) {
+    repotest.RunUserRepositoryTests(t, func(t *testing.T) domain.UserRepository {
+        return repositories.NewInMemoryUserRepository()
+    })
+}
+
+func TestInMemoryRefreshTokenRepository(t *testing.T) {
+    repotest.RunRefreshTokenRepositoryTests(t, func(t *testing.T) domain.RefreshTokenRepository {
+        return repositories.NewInMemoryRefreshTokenRepository()
+    })
+}
+
+func TestInMemoryTokenRevocationRepository(t *testing.T) {
+    repotest.RunTokenRevocationRepositoryTests(t, func(t *testing.T) domain.TokenRevocationRepository {
+        return repositories.NewInMemoryTokenRevocationRepository()
+    })
+}
+
+func TestInMemoryAccessTokenRepository(t *testing.T) {
+    repotest.RunAccessTokenRepositoryTests(t, func(t *testing.T) domain.AccessTokenRepository {
+        return repositories.NewInMemoryAccessTokenRepository()
+    })
+}
+
+func TestInMemoryTwoFactorRepository(t *testing.T) {
+    repotest.RunTwoFactorRepositoryTests(t, func(t *testing.T) domain.TwoFactorRepository {
+        return repositories.NewInMemoryTwoFactorRepository()
+    })
+}
+
+func TestInMemoryLoginAttemptRepository(t *testing.T) {
+    repotest.RunLoginAttemptRepositoryTests(t, func(t *testing.T) domain.LoginAttemptRepository {
+        return repositories.NewInMemoryLoginAttemptRepository()
+    })
+}
+
+func TestInMemoryPasswordResetRepository(t *testing.T) {
+    repotest.RunPasswordResetRepositoryTests(t, func(t *testing.T) domain.PasswordResetRepository {
+        return repositories.NewInMemoryPasswordResetRepository()
+    })
+}
+
+func TestInMemoryBootstrapRepository(t *testing.T) {
+    repotest.RunBootstrapRepositoryTests(t, func(t *testing.T) domain.BootstrapRepository {
+        return repositories.NewInMemoryBootstrapRepository()
+    })
+}
+
+func TestInMemoryMigrationRepository(t *testing.T) {
+    repotest.RunMigrationRepositoryTests(t, func(t *testing.T) domain.MigrationRepository {
+        return repositories.NewInMemoryMigrationRepository()
+    })
+}
+
+func TestInMemoryRoleRepository(t *testing.T) {
+    repotest.RunRoleRepositoryTests(t, func(t *testing.T) domain.RoleRepository {
+        return repositories.NewInMemoryRoleRepository()
+    })
+}
+
+func TestInMemoryOrganizationRepository(t *testing.T) {
+    repotest.RunOrganizationRepositoryTests(t, func(t *testing.T) domain.OrganizationRepository {
+        return repositories.NewInMemoryOrganizationRepository()
+    })
+}
+
+func TestInMemoryInviteRepository(t *testing.T) {
+    repotest.RunInviteRepositoryTests(t, func(t *testing.T) domain.InviteRepository {
+        return repositories.NewInMemoryInviteRepository()
+    })
+}
+
+func TestInMemoryOIDCLoginRepository(t *testing.T) {
+    repotest.RunOIDCLoginRepositoryTests(t, func(t *testing.T) domain.OIDCLoginRepository {
+        return repositories.NewInMemoryOIDCLoginRepository()
+    })
+}
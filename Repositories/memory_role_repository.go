@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+type InMemoryRoleRepository struct {
+    mu    sync.Mutex
+    roles map[string]domain.Role
+}
+
+func NewInMemoryRoleRepository() domain.RoleRepository {
+    return &InMemoryRoleRepository{roles: make(map[string]domain.Role)}
+}
+
+func (r *InMemoryRoleRepository) CreateRole(role *domain.Role) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if _, exists := r.roles[role.Name]; exists {
+        return domain.ErrRoleExists
+    }
+    stored := *role
+    stored.Permissions = slices.Clone(role.Permissions)
+    r.roles[role.Name] = stored
+    return nil
+}
+
+func (r *InMemoryRoleRepository) GetRole(name string) (*domain.Role, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    role, ok := r.roles[name]
+    if !ok {
+        return nil, domain.ErrRoleNotFound
+    }
+    role.Permissions = slices.Clone(role.Permissions)
+    return &role, nil
+}
+
+func (r *InMemoryRoleRepository) ListRoles() ([]domain.Role, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    roles := []domain.Role{}
+    for _, role := range r.roles {
+        role.Permissions = slices.Clone(role.Permissions)
+        roles = append(roles, role)
+    }
+    sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+    return roles, nil
+}
+
+func (r *InMemoryRoleRepository) UpdateRole(role *domain.Role) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    stored, ok := r.roles[role.Name]
+    if !ok {
+        return domain.ErrRoleNotFound
+    }
+    stored.Description = role.Description
+    stored.Permissions = slices.Clone(role.Permissions)
+    r.roles[role.Name] = stored
+    return nil
+}
+
+func (r *InMemoryRoleRepository) DeleteRole(name string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if _, ok := r.roles[name]; !ok {
+        return domain.ErrRoleNotFound
+    }
+    delete(r.roles, name)
+    return nil
+}
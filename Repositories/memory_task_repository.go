@@ -0,0 +1,335 @@
+package repositories
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// InMemoryTaskRepository keeps tasks in a map. It mirrors the behaviour of
+// MongoTaskRepository and is intended for local development and tests.
+type InMemoryTaskRepository struct {
+    mu    sync.RWMutex
+    tasks map[primitive.ObjectID]domain.Task
+}
+
+func NewInMemoryTaskRepository() domain.TaskRepository {
+    return &InMemoryTaskRepository{tasks: make(map[primitive.ObjectID]domain.Task)}
+}
+
+func (r *InMemoryTaskRepository) GetTasks(query domain.TaskQuery) (domain.TaskPage, error) {
+    if err := query.Normalize(); err != nil {
+        return domain.TaskPage{}, err
+    }
+    var position domain.TaskCursor
+    if query.Cursor != "" {
+        position, _ = query.DecodeCursor()
+    }
+
+    r.mu.RLock()
+    tasks := []domain.Task{}
+    for _, task := range r.tasks {
+        if matchesTaskQuery(task, query) && (query.Cursor == "" || isAfterCursor(task, query, position)) {
+            tasks = append(tasks, copyTask(task))
+        }
+    }
+    r.mu.RUnlock()
+
+    sort.Slice(tasks, func(i, j int) bool {
+        cmp := compareTasks(tasks[i], tasks[j], query.SortBy)
+        if query.Descending {
+            return cmp > 0
+        }
+        return cmp < 0
+    })
+
+    page := domain.TaskPage{Tasks: tasks}
+    if len(tasks) > query.Limit {
+        page.Tasks = tasks[:query.Limit]
+        page.NextCursor = query.EncodeCursor(page.Tasks[query.Limit-1])
+    }
+    return page, nil
+}
+
+func (r *InMemoryTaskRepository) GetTaskByID(orgID, id primitive.ObjectID) (domain.Task, bool, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    task, ok := r.lookup(orgID, id)
+    if !ok {
+        return domain.Task{}, false, nil
+    }
+    return copyTask(task), true, nil
+}
+
+func (r *InMemoryTaskRepository) AddTask(task domain.Task) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if task.ID.IsZero() {
+        task.ID = primitive.NewObjectID()
+    }
+    if _, exists := r.tasks[task.ID]; exists {
+        return domain.ErrTaskExists
+    }
+    r.tasks[task.ID] = normalizeTask(task)
+    return nil
+}
+
+func (r *InMemoryTaskRepository) UpdateTask(orgID, id primitive.ObjectID, task domain.Task) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    existing, ok := r.lookup(orgID, id)
+    if !ok {
+        return domain.ErrTaskNotFound
+    }
+    if !task.ID.IsZero() && task.ID != id {
+        return errors.New("task ID cannot be changed")
+    }
+
+    // Zero-valued omitempty fields are left untouched, as with a Mongo $set.
+    task.ID = id
+    if task.OrgID.IsZero() {
+        task.OrgID = existing.OrgID
+    }
+    if task.CreatedBy.IsZero() {
+        task.CreatedBy = existing.CreatedBy
+    }
+    if task.AssignedTo == nil {
+        task.AssignedTo = existing.AssignedTo
+    }
+    if task.Visibility == "" {
+        task.Visibility = existing.Visibility
+    }
+    if task.SharedWith == nil {
+        task.SharedWith = existing.SharedWith
+    }
+    r.tasks[id] = normalizeTask(task)
+    return nil
+}
+
+func (r *InMemoryTaskRepository) DeleteTask(orgID, id primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if _, ok := r.lookup(orgID, id); !ok {
+        return mongo.ErrNoDocuments
+    }
+    delete(r.tasks, id)
+    return nil
+}
+
+func (r *InMemoryTaskRepository) AssignTask(orgID, id primitive.ObjectID, userID primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    task, ok := r.lookup(orgID, id)
+    if !ok {
+        return domain.ErrTaskNotFound
+    }
+    task.AssignedTo = &userID
+    r.tasks[id] = task
+    return nil
+}
+
+func (r *InMemoryTaskRepository) UnassignTask(orgID, id primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    task, ok := r.lookup(orgID, id)
+    if !ok {
+        return domain.ErrTaskNotFound
+    }
+    task.AssignedTo = nil
+    r.tasks[id] = task
+    return nil
+}
+
+func (r *InMemoryTaskRepository) SetTaskVisibility(orgID, id primitive.ObjectID, visibility string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    task, ok := r.lookup(orgID, id)
+    if !ok {
+        return domain.ErrTaskNotFound
+    }
+    task.Visibility = visibility
+    r.tasks[id] = task
+    return nil
+}
+
+func (r *InMemoryTaskRepository) ShareTask(orgID, id primitive.ObjectID, userID primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    task, ok := r.lookup(orgID, id)
+    if !ok {
+        return domain.ErrTaskNotFound
+    }
+    if !slices.Contains(task.SharedWith, userID) {
+        task.SharedWith = append(slices.Clone(task.SharedWith), userID)
+    }
+    r.tasks[id] = task
+    return nil
+}
+
+func (r *InMemoryTaskRepository) UnshareTask(orgID, id primitive.ObjectID, userID primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    task, ok := r.lookup(orgID, id)
+    if !ok {
+        return domain.ErrTaskNotFound
+    }
+    task.SharedWith = slices.DeleteFunc(slices.Clone(task.SharedWith), func(id primitive.ObjectID) bool { return id == userID })
+    r.tasks[id] = task
+    return nil
+}
+
+// lookup returns the task if it belongs to the organization. The caller must
+// hold the lock.
+func (r *InMemoryTaskRepository) lookup(orgID, id primitive.ObjectID) (domain.Task, bool) {
+    task, ok := r.tasks[id]
+    if !ok || task.OrgID != orgID {
+        return domain.Task{}, false
+    }
+    return task, true
+}
+
+// normalizeTask stores due dates the way MongoDB does: UTC with millisecond precision.
+func normalizeTask(task domain.Task) domain.Task {
+    task.DueDate = task.DueDate.Truncate(time.Millisecond).UTC()
+    return copyTask(task)
+}
+
+func copyTask(task domain.Task) domain.Task {
+    if task.AssignedTo != nil {
+        assignee := *task.AssignedTo
+        task.AssignedTo = &assignee
+    }
+    task.SharedWith = slices.Clone(task.SharedWith)
+    return task
+}
+
+func matchesTaskQuery(task domain.Task, query domain.TaskQuery) bool {
+    if task.OrgID != query.OrgID {
+        return false
+    }
+    if query.Status != "" && task.Status != query.Status {
+        return false
+    }
+    if !query.DueAfter.IsZero() && task.DueDate.Before(query.DueAfter) {
+        return false
+    }
+    if !query.DueBefore.IsZero() && task.DueDate.After(query.DueBefore) {
+        return false
+    }
+    if query.TitleContains != "" && !strings.Contains(strings.ToLower(task.Title), strings.ToLower(query.TitleContains)) {
+        return false
+    }
+    if query.AssignedTo != nil && (task.AssignedTo == nil || *task.AssignedTo != *query.AssignedTo) {
+        return false
+    }
+    if query.VisibleTo != nil && !task.VisibleTo(*query.VisibleTo) {
+        return false
+    }
+    return true
+}
+
+func isAfterCursor(task domain.Task, query domain.TaskQuery, position domain.TaskCursor) bool {
+    last := domain.Task{ID: position.ID, Title: position.Value, Status: position.Value}
+    if query.SortBy == domain.SortByDueDate {
+        last.DueDate, _ = time.Parse(time.RFC3339Nano, position.Value)
+    }
+    cmp := compareTasks(task, last, query.SortBy)
+    if query.Descending {
+        return cmp < 0
+    }
+    return cmp > 0
+}
+
+// compareTasks orders tasks by the given sort key, breaking ties by ID.
+func compareTasks(a, b domain.Task, sortBy string) int {
+    cmp := 0
+    switch sortBy {
+    case domain.SortByDueDate:
+        cmp = a.DueDate.Compare(b.DueDate)
+    case domain.SortByTitle:
+        cmp = strings.Compare(a.Title, b.Title)
+    case domain.SortByStatus:
+        cmp = strings.Compare(a.Status, b.Status)
+    }
+    if cmp != 0 {
+        return cmp
+    }
+    return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+func (r *InMemoryTaskRepository) ReassignUserTasks(from, to primitive.ObjectID, orgIDs []primitive.ObjectID) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    var changed int64
+    for id, task := range r.tasks {
+        if task.AssignedTo != nil && *task.AssignedTo == from && slices.Contains(orgIDs, task.OrgID) {
+            assignee := to
+            task.AssignedTo = &assignee
+            r.tasks[id] = task
+            changed++
+        }
+    }
+    return changed, nil
+}
+
+func (r *InMemoryTaskRepository) UnassignUserTasks(userID primitive.ObjectID) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    var changed int64
+    for id, task := range r.tasks {
+        if task.AssignedTo != nil && *task.AssignedTo == userID {
+            task.AssignedTo = nil
+            r.tasks[id] = task
+            changed++
+        }
+    }
+    return changed, nil
+}
+
+func (r *InMemoryTaskRepository) DeleteUserTasks(userID primitive.ObjectID) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    var deleted int64
+    for id, task := range r.tasks {
+        if task.AssignedTo != nil && *task.AssignedTo == userID {
+            delete(r.tasks, id)
+            deleted++
+        }
+    }
+    return deleted, nil
+}
+
+func (r *InMemoryTaskRepository) AdoptOrphanTasks(orgID primitive.ObjectID) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    var moved int64
+    for id, task := range r.tasks {
+        if task.OrgID.IsZero() {
+            task.OrgID = orgID
+            r.tasks[id] = task
+            moved++
+        }
+    }
+    return moved, nil
+}
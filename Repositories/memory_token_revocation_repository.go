@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type InMemoryTokenRevocationRepository struct {
+    mu      sync.RWMutex
+    tokens  map[string]time.Time
+    cutoffs map[primitive.ObjectID]time.Time
+}
+
+func NewInMemoryTokenRevocationRepository() domain.TokenRevocationRepository {
+    return &InMemoryTokenRevocationRepository{
+        tokens:  make(map[string]time.Time),
+        cutoffs: make(map[primitive.ObjectID]time.Time),
+    }
+}
+
+func (r *InMemoryTokenRevocationRepository) RevokeToken(tokenID string, expiresAt time.Time) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    // Entries are only useful until the token would have expired anyway.
+    now := time.Now()
+    for id, exp := range r.tokens {
+        if !exp.After(now) {
+            delete(r.tokens, id)
+        }
+    }
+    r.tokens[tokenID] = expiresAt
+    return nil
+}
+
+func (r *InMemoryTokenRevocationRepository) IsTokenRevoked(tokenID string) (bool, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    expiresAt, ok := r.tokens[tokenID]
+    return ok && expiresAt.After(time.Now()), nil
+}
+
+func (r *InMemoryTokenRevocationRepository) RevokeUserTokens(userID primitive.ObjectID, before time.Time) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if before.After(r.cutoffs[userID]) {
+        r.cutoffs[userID] = before
+    }
+    return nil
+}
+
+func (r *InMemoryTokenRevocationRepository) UserTokensRevokedBefore(userID primitive.ObjectID) (time.Time, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    return r.cutoffs[userID], nil
+}
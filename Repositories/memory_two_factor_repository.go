@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type InMemoryTwoFactorRepository struct {
+    mu         sync.Mutex
+    twoFactors map[primitive.ObjectID]domain.TwoFactor
+}
+
+func NewInMemoryTwoFactorRepository() domain.TwoFactorRepository {
+    return &InMemoryTwoFactorRepository{twoFactors: make(map[primitive.ObjectID]domain.TwoFactor)}
+}
+
+func (r *InMemoryTwoFactorRepository) SaveTwoFactor(twoFactor *domain.TwoFactor) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if existing, ok := r.twoFactors[twoFactor.UserID]; ok && existing.Enabled {
+        return domain.ErrTwoFactorAlreadyEnabled
+    }
+    stored := *twoFactor
+    stored.RecoveryCodes = append([]string{}, twoFactor.RecoveryCodes...)
+    r.twoFactors[twoFactor.UserID] = stored
+    return nil
+}
+
+func (r *InMemoryTwoFactorRepository) GetTwoFactor(userID primitive.ObjectID) (*domain.TwoFactor, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    twoFactor, ok := r.twoFactors[userID]
+    if !ok {
+        return nil, domain.ErrTwoFactorNotFound
+    }
+    twoFactor.RecoveryCodes = append([]string{}, twoFactor.RecoveryCodes...)
+    return &twoFactor, nil
+}
+
+func (r *InMemoryTwoFactorRepository) EnableTwoFactor(userID primitive.ObjectID, recoveryCodes []string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    twoFactor, ok := r.twoFactors[userID]
+    if !ok || twoFactor.Enabled {
+        return domain.ErrTwoFactorNotFound
+    }
+    twoFactor.Enabled = true
+    twoFactor.RecoveryCodes = append([]string{}, recoveryCodes...)
+    r.twoFactors[userID] = twoFactor
+    return nil
+}
+
+func (r *InMemoryTwoFactorRepository) DeleteTwoFactor(userID primitive.ObjectID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if _, ok := r.twoFactors[userID]; !ok {
+        return domain.ErrTwoFactorNotFound
+    }
+    delete(r.twoFactors, userID)
+    return nil
+}
+
+func (r *InMemoryTwoFactorRepository) UseTOTPStep(userID primitive.ObjectID, step int64) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    twoFactor, ok := r.twoFactors[userID]
+    if !ok || twoFactor.LastUsedStep >= step {
+        return false, nil
+    }
+    twoFactor.LastUsedStep = step
+    r.twoFactors[userID] = twoFactor
+    return true, nil
+}
+
+func (r *InMemoryTwoFactorRepository) UseRecoveryCode(userID primitive.ObjectID, codeHash string) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    twoFactor, ok := r.twoFactors[userID]
+    if !ok {
+        return false, nil
+    }
+    for i, hash := range twoFactor.RecoveryCodes {
+        if hash == codeHash {
+            remaining := append([]string{}, twoFactor.RecoveryCodes[:i]...)
+            twoFactor.RecoveryCodes = append(remaining, twoFactor.RecoveryCodes[i+1:]...)
+            r.twoFactors[userID] = twoFactor
+            return true, nil
+        }
+    }
+    return false, nil
+}
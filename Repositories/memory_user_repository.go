@@ -0,0 +1,224 @@
+package repositories
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InMemoryUserRepository keeps users in a map keyed by username. It mirrors
+// the behaviour of MongoUserRepository and is intended for local development
+// and tests.
+type InMemoryUserRepository struct {
+    mu    sync.RWMutex
+    users map[string]domain.User
+}
+
+func NewInMemoryUserRepository() domain.UserRepository {
+    return &InMemoryUserRepository{users: make(map[string]domain.User)}
+}
+
+func (r *InMemoryUserRepository) CreateUser(user *domain.User) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if _, exists := r.users[user.Username]; exists {
+        return domain.ErrUserExists
+    }
+    if user.Role == "" {
+        user.Role = domain.RoleUser
+    }
+    if user.ID.IsZero() {
+        user.ID = primitive.NewObjectID()
+    }
+    r.users[user.Username] = *user
+    return nil
+}
+
+func (r *InMemoryUserRepository) GetUserByUsername(username string) (*domain.User, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    user, ok := r.users[username]
+    if !ok {
+        return nil, domain.ErrUserNotFound
+    }
+    return &user, nil
+}
+
+func (r *InMemoryUserRepository) GetUserByID(id primitive.ObjectID) (*domain.User, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    for _, user := range r.users {
+        if user.ID == id {
+            return &user, nil
+        }
+    }
+    return nil, domain.ErrUserNotFound
+}
+
+func (r *InMemoryUserRepository) GetUserByExternalID(externalID string) (*domain.User, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    for _, user := range r.users {
+        if user.ExternalID != "" && user.ExternalID == externalID {
+            return &user, nil
+        }
+    }
+    return nil, domain.ErrUserNotFound
+}
+
+func (r *InMemoryUserRepository) SetExternalID(id primitive.ObjectID, externalID string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for _, user := range r.users {
+        if user.ID != id && user.ExternalID != "" && user.ExternalID == externalID {
+            return domain.ErrIdentityConflict
+        }
+    }
+    for username, user := range r.users {
+        if user.ID == id {
+            user.ExternalID = externalID
+            r.users[username] = user
+            return nil
+        }
+    }
+    return domain.ErrUserNotFound
+}
+
+func (r *InMemoryUserRepository) PromoteUser(username string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    user, ok := r.users[username]
+    if !ok {
+        return domain.ErrUserNotFound
+    }
+    if user.Role == "admin" {
+        return domain.ErrAlreadyAdmin
+    }
+    user.Role = "admin"
+    r.users[username] = user
+    return nil
+}
+
+func (r *InMemoryUserRepository) UpdatePassword(id primitive.ObjectID, hashedPassword string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    for username, user := range r.users {
+        if user.ID == id {
+            user.Password = hashedPassword
+            r.users[username] = user
+            return nil
+        }
+    }
+    return domain.ErrUserNotFound
+}
+
+func (r *InMemoryUserRepository) ListUsers(query domain.UserQuery) (domain.UserPage, error) {
+    if err := query.Normalize(); err != nil {
+        return domain.UserPage{}, err
+    }
+    after, _ := query.After()
+
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    var users []domain.User
+    for _, user := range r.users {
+        if user.Username > after && matchesUserQuery(user, query) {
+            users = append(users, user)
+        }
+    }
+    sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+
+    page := domain.UserPage{Users: []domain.UserSummary{}}
+    for i, user := range users {
+        if i == query.Limit {
+            page.NextCursor = query.EncodeCursor(users[i-1])
+            break
+        }
+        page.Users = append(page.Users, domain.NewUserSummary(user))
+    }
+    return page, nil
+}
+
+func matchesUserQuery(user domain.User, query domain.UserQuery) bool {
+    if query.Role != "" && user.Role != query.Role {
+        return false
+    }
+    switch query.Status {
+    case domain.UserStatusActive:
+        return !user.Deactivated
+    case domain.UserStatusDeactivated:
+        return user.Deactivated
+    }
+    return true
+}
+
+func (r *InMemoryUserRepository) SetUserRole(username, role string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    user, ok := r.users[username]
+    if !ok {
+        return domain.ErrUserNotFound
+    }
+    if role != domain.RoleAdmin && r.isLastActiveAdmin(user) {
+        return domain.ErrLastAdmin
+    }
+    user.Role = role
+    r.users[username] = user
+    return nil
+}
+
+func (r *InMemoryUserRepository) SetUserDeactivated(username string, deactivated bool) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    user, ok := r.users[username]
+    if !ok {
+        return domain.ErrUserNotFound
+    }
+    if deactivated && r.isLastActiveAdmin(user) {
+        return domain.ErrLastAdmin
+    }
+    user.Deactivated = deactivated
+    r.users[username] = user
+    return nil
+}
+
+func (r *InMemoryUserRepository) DeleteUser(username string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    user, ok := r.users[username]
+    if !ok {
+        return domain.ErrUserNotFound
+    }
+    if r.isLastActiveAdmin(user) {
+        return domain.ErrLastAdmin
+    }
+    delete(r.users, username)
+    return nil
+}
+
+// isLastActiveAdmin reports whether user is an active admin and no other
+// active admin exists. The caller must hold r.mu.
+func (r *InMemoryUserRepository) isLastActiveAdmin(user domain.User) bool {
+    if user.Role != domain.RoleAdmin || user.Deactivated {
+        return false
+    }
+    for _, other := range r.users {
+        if other.Username != user.Username && other.Role == domain.RoleAdmin && !other.Deactivated {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoMigrationRepository stores a document per migration, with the
+// migration's name as its _id.
+type MongoMigrationRepository struct {
+    collection *mongo.Collection
+}
+
+func NewMongoMigrationRepository(collection *mongo.Collection) domain.MigrationRepository {
+    return &MongoMigrationRepository{collection: collection}
+}
+
+func (r *MongoMigrationRepository) ClaimMigration(name string, at time.Time) (bool, error) {
+    _, err := r.collection.InsertOne(context.TODO(), bson.M{"_id": name, "claimed_at": at})
+    if mongo.IsDuplicateKeyError(err) {
+        return false, nil
+    }
+    return err == nil, err
+}
@@ -0,0 +1,174 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories/repotest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The Mongo suites only run when MONGODB_TEST_URI points at a disposable
+// server. Each subtest gets its own database, dropped when the subtest ends.
+func newTestDatabase(t *testing.T) *mongo.Database {
+    uri := os.Getenv("MONGODB_TEST_URI")
+    if uri == "" {
+        t.Skip("MONGODB_TEST_URI is not set")
+    }
+    client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(uri))
+    if err != nil {
+        t.Fatalf("connect: %v", err)
+    }
+    db := client.Database("taskDB_test_" + primitive.NewObjectID().Hex())
+    t.Cleanup(func() {
+        db.Drop(context.TODO())
+        client.Disconnect(context.TODO())
+    })
+    return db
+}
+
+func TestMongoTaskRepository(t *testing.T) {
+    repotest.RunTaskRepositoryTests(t, func(t *testing.T) domain.TaskRepository {
+        return repositories.NewMongoTaskRepository(newTestDatabase(t).Collection("tasks"))
+    })
+}
+
+func TestMongoUserRepository(t *testing.T) {
+    repotest.RunUserRepositoryTests(t, func(t *testing.T) domain.UserRepository {
+        collection := newTestDatabase(t).Collection("users")
+        if err := repositories.EnsureUserIndexes(collection); err != nil {
+            t.Fatalf("EnsureUserIndexes: %v", err)
+        }
+        return repositories.NewMongoUserRepository(collection)
+    })
+}
+
+func TestMongoRefreshTokenRepository(t *testing.T) {
+    repotest.RunRefreshTokenRepositoryTests(t, func(t *testing.T) domain.RefreshTokenRepository {
+        collection := newTestDatabase(t).Collection("refresh_tokens")
+        if err := repositories.EnsureTokenHashIndex(collection); err != nil {
+            t.Fatalf("EnsureTokenHashIndex: %v", err)
+        }
+        return repositories.NewMongoRefreshTokenRepository(collection)
+    })
+}
+
+func TestMongoTokenRevocationRepository(t *testing.T) {
+    repotest.RunTokenRevocationRepositoryTests(t, func(t *testing.T) domain.TokenRevocationRepository {
+        db := newTestDatabase(t)
+        return repositories.NewMongoTokenRevocationRepository(db.Collection("revoked_tokens"), db.Collection("user_token_cutoffs"))
+    })
+}
+
+func TestMongoTokenRevocationIndexes(t *testing.T) {
+    tokens := newTestDatabase(t).Collection("revoked_tokens")
+    err := repositories.EnsureTokenRevocationIndexes(tokens)
+    var commandErr mongo.CommandError
+    if errors.As(err, &commandErr) && commandErr.Name == "NotImplemented" {
+        t.Skipf("the server has no TTL indexes: %v", err)
+    }
+    if err != nil {
+        t.Fatalf("EnsureTokenRevocationIndexes: %v", err)
+    }
+    // Creating the indexes again, as every start does, is fine.
+    if err := repositories.EnsureTokenRevocationIndexes(tokens); err != nil {
+        t.Fatalf("EnsureTokenRevocationIndexes again: %v", err)
+    }
+    cursor, err := tokens.Indexes().List(context.TODO())
+    if err != nil {
+        t.Fatalf("List indexes: %v", err)
+    }
+    var indexes []struct {
+        Key                bson.D `bson:"key"`
+        ExpireAfterSeconds *int32 `bson:"expireAfterSeconds"`
+    }
+    if err := cursor.All(context.TODO(), &indexes); err != nil {
+        t.Fatalf("List indexes: %v", err)
+    }
+    for _, index := range indexes {
+        if len(index.Key) == 1 && index.Key[0].Key == "expires_at" && index.ExpireAfterSeconds != nil && *index.ExpireAfterSeconds == 0 {
+            return
+        }
+    }
+    t.Fatalf("no TTL index on expires_at in %+v", indexes)
+}
+
+func TestMongoAccessTokenRepository(t *testing.T) {
+    repotest.RunAccessTokenRepositoryTests(t, func(t *testing.T) domain.AccessTokenRepository {
+        collection := newTestDatabase(t).Collection("access_tokens")
+        if err := repositories.EnsureTokenHashIndex(collection); err != nil {
+            t.Fatalf("EnsureTokenHashIndex: %v", err)
+        }
+        return repositories.NewMongoAccessTokenRepository(collection)
+    })
+}
+
+func TestMongoTwoFactorRepository(t *testing.T) {
+    repotest.RunTwoFactorRepositoryTests(t, func(t *testing.T) domain.TwoFactorRepository {
+        return repositories.NewMongoTwoFactorRepository(newTestDatabase(t).Collection("two_factor"))
+    })
+}
+
+func TestMongoLoginAttemptRepository(t *testing.T) {
+    repotest.RunLoginAttemptRepositoryTests(t, func(t *testing.T) domain.LoginAttemptRepository {
+        return repositories.NewMongoLoginAttemptRepository(newTestDatabase(t).Collection("login_attempts"))
+    })
+}
+
+func TestMongoPasswordResetRepository(t *testing.T) {
+    repotest.RunPasswordResetRepositoryTests(t, func(t *testing.T) domain.PasswordResetRepository {
+        collection := newTestDatabase(t).Collection("password_resets")
+        if err := repositories.EnsureTokenHashIndex(collection); err != nil {
+            t.Fatalf("EnsureTokenHashIndex: %v", err)
+        }
+        return repositories.NewMongoPasswordResetRepository(collection)
+    })
+}
+
+func TestMongoBootstrapRepository(t *testing.T) {
+    repotest.RunBootstrapRepositoryTests(t, func(t *testing.T) domain.BootstrapRepository {
+        return repositories.NewMongoBootstrapRepository(newTestDatabase(t).Collection("bootstrap"))
+    })
+}
+
+func TestMongoMigrationRepository(t *testing.T) {
+    repotest.RunMigrationRepositoryTests(t, func(t *testing.T) domain.MigrationRepository {
+        return repositories.NewMongoMigrationRepository(newTestDatabase(t).Collection("migrations"))
+    })
+}
+
+func TestMongoRoleRepository(t *testing.T) {
+    repotest.RunRoleRepositoryTests(t, func(t *testing.T) domain.RoleRepository {
+        return repositories.NewMongoRoleRepository(newTestDatabase(t).Collection("roles"))
+    })
+}
+
+func TestMongoOrganizationRepository(t *testing.T) {
+    repotest.RunOrganizationRepositoryTests(t, func(t *testing.T) domain.OrganizationRepository {
+        db := newTestDatabase(t)
+        return repositories.NewMongoOrganizationRepository(db.Collection("organizations"), db.Collection("org_members"))
+    })
+}
+
+func TestMongoInviteRepository(t *testing.T) {
+    repotest.RunInviteRepositoryTests(t, func(t *testing.T) domain.InviteRepository {
+        collection := newTestDatabase(t).Collection("invites")
+        if err := repositories.EnsureTokenHashIndex(collection); err != nil {
+            t.Fatalf("EnsureTokenHashIndex: %v", err)
+        }
+        return repositories.NewMongoInviteRepository(collection)
+    })
+}
+
+func TestMongoOIDCLoginRepository(t *testing.T) {
+    repotest.RunOIDCLoginRepositoryTests(t, func(t *testing.T) domain.OIDCLoginRepository {
+        return repositories.NewMongoOIDCLoginRepository(newTestDatabase(t).Collection("oidc_logins"))
+    })
+}
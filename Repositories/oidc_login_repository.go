@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoOIDCLoginRepository struct {
+    collection *mongo.Collection
+}
+
+func NewMongoOIDCLoginRepository(collection *mongo.Collection) domain.OIDCLoginRepository {
+    return &MongoOIDCLoginRepository{collection: collection}
+}
+
+func (r *MongoOIDCLoginRepository) CreateOIDCLogin(login *domain.OIDCLogin) error {
+    if login.ID.IsZero() {
+        login.ID = primitive.NewObjectID()
+    }
+    _, err := r.collection.InsertOne(context.TODO(), login)
+    return err
+}
+
+func (r *MongoOIDCLoginRepository) TakeOIDCLogin(stateHash string) (*domain.OIDCLogin, error) {
+    login := &domain.OIDCLogin{}
+    err := r.collection.FindOneAndDelete(context.TODO(), bson.M{"state_hash": stateHash}).Decode(login)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, domain.ErrOIDCLoginNotFound
+        }
+        return nil, err
+    }
+    return login, nil
+}
+
+func (r *MongoOIDCLoginRepository) DeleteExpiredOIDCLogins(now time.Time) error {
+    _, err := r.collection.DeleteMany(context.TODO(), bson.M{"expires_at": bson.M{"$lt": now}})
+    return err
+}
@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoOrganizationRepository stores organizations and memberships in
+// separate collections.
+type MongoOrganizationRepository struct {
+    orgs    *mongo.Collection
+    members *mongo.Collection
+}
+
+func NewMongoOrganizationRepository(orgs, members *mongo.Collection) domain.OrganizationRepository {
+    return &MongoOrganizationRepository{orgs: orgs, members: members}
+}
+
+// mongoMembership stores a membership under an _id made of the organization
+// and user IDs, so that the unique _id index keeps a user from being added to
+// an organization twice.
+type mongoMembership struct {
+    ID                string `bson:"_id"`
+    domain.Membership `bson:",inline"`
+}
+
+func membershipID(orgID, userID primitive.ObjectID) string {
+    return orgID.Hex() + ":" + userID.Hex()
+}
+
+func (r *MongoOrganizationRepository) CreateOrganization(org *domain.Organization) error {
+    if org.ID.IsZero() {
+        org.ID = primitive.NewObjectID()
+    }
+    _, err := r.orgs.InsertOne(context.TODO(), org)
+    return err
+}
+
+func (r *MongoOrganizationRepository) GetOrganization(id primitive.ObjectID) (*domain.Organization, error) {
+    var org domain.Organization
+    if err := r.orgs.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&org); err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, domain.ErrOrganizationNotFound
+        }
+        return nil, err
+    }
+    return &org, nil
+}
+
+func (r *MongoOrganizationRepository) CountOrganizations() (int64, error) {
+    return r.orgs.CountDocuments(context.TODO(), bson.M{})
+}
+
+func (r *MongoOrganizationRepository) AddMember(membership *domain.Membership) error {
+    stored := mongoMembership{ID: membershipID(membership.OrgID, membership.UserID), Membership: *membership}
+    _, err := r.members.InsertOne(context.TODO(), stored)
+    if mongo.IsDuplicateKeyError(err) {
+        return domain.ErrAlreadyOrgMember
+    }
+    return err
+}
+
+func (r *MongoOrganizationRepository) GetMembership(orgID, userID primitive.ObjectID) (*domain.Membership, error) {
+    var membership domain.Membership
+    if err := r.members.FindOne(context.TODO(), bson.M{"_id": membershipID(orgID, userID)}).Decode(&membership); err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, domain.ErrNotOrgMember
+        }
+        return nil, err
+    }
+    return &membership, nil
+}
+
+func (r *MongoOrganizationRepository) ListMembers(orgID primitive.ObjectID) ([]domain.Membership, error) {
+    return r.findMemberships(bson.M{"org_id": orgID})
+}
+
+func (r *MongoOrganizationRepository) ListUserMemberships(userID primitive.ObjectID) ([]domain.Membership, error) {
+    return r.findMemberships(bson.M{"user_id": userID})
+}
+
+func (r *MongoOrganizationRepository) findMemberships(filter bson.M) ([]domain.Membership, error) {
+    opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+    cursor, err := r.members.Find(context.TODO(), filter, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(context.TODO())
+
+    memberships := []domain.Membership{}
+    if err := cursor.All(context.TODO(), &memberships); err != nil {
+        return nil, err
+    }
+    return memberships, nil
+}
+
+func (r *MongoOrganizationRepository) AcceptInvitation(orgID, userID primitive.ObjectID) error {
+    result, err := r.members.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": membershipID(orgID, userID), "pending": true},
+        bson.M{"$set": bson.M{"pending": false}},
+    )
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return domain.ErrNotOrgMember
+    }
+    return nil
+}
+
+func (r *MongoOrganizationRepository) SetMemberRole(orgID, userID primitive.ObjectID, role string) error {
+    membership, err := r.GetMembership(orgID, userID)
+    if err != nil {
+        return err
+    }
+    id := membershipID(orgID, userID)
+    if _, err := r.members.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{"role": role}}); err != nil {
+        return err
+    }
+    if role == domain.RoleAdmin || membership.Role != domain.RoleAdmin || membership.Pending {
+        return nil
+    }
+    return r.keepOrgAdmin(orgID, func() error {
+        _, err := r.members.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{"role": membership.Role}})
+        return err
+    })
+}
+
+func (r *MongoOrganizationRepository) RemoveMember(orgID, userID primitive.ObjectID) error {
+    var stored bson.Raw
+    err := r.members.FindOneAndDelete(context.TODO(), bson.M{"_id": membershipID(orgID, userID)}).Decode(&stored)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return domain.ErrNotOrgMember
+        }
+        return err
+    }
+    membership := &domain.Membership{}
+    if err := bson.Unmarshal(stored, membership); err != nil {
+        return err
+    }
+    if membership.Role != domain.RoleAdmin || membership.Pending {
+        return nil
+    }
+    return r.keepOrgAdmin(orgID, func() error {
+        _, err := r.members.InsertOne(context.TODO(), stored)
+        return err
+    })
+}
+
+// keepOrgAdmin runs undo and returns ErrLastOrgAdmin if a change just left the
+// organization without an admin, as keepActiveAdmin does for users.
+func (r *MongoOrganizationRepository) keepOrgAdmin(orgID primitive.ObjectID, undo func() error) error {
+    count, err := r.members.CountDocuments(context.TODO(), bson.M{"org_id": orgID, "role": domain.RoleAdmin, "pending": false})
+    if err != nil {
+        return err
+    }
+    if count > 0 {
+        return nil
+    }
+    if err := undo(); err != nil {
+        return err
+    }
+    return domain.ErrLastOrgAdmin
+}
+
+func (r *MongoOrganizationRepository) RemoveUserMemberships(userID primitive.ObjectID) error {
+    _, err := r.members.DeleteMany(context.TODO(), bson.M{"user_id": userID})
+    return err
+}
+
+func (r *MongoOrganizationRepository) CountMembersWithRole(role string) (int64, error) {
+    return r.members.CountDocuments(context.TODO(), bson.M{"role": role})
+}
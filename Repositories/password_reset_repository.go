@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoPasswordResetRepository struct {
+    collection *mongo.Collection
+}
+
+func NewMongoPasswordResetRepository(collection *mongo.Collection) domain.PasswordResetRepository {
+    return &MongoPasswordResetRepository{collection: collection}
+}
+
+func (r *MongoPasswordResetRepository) CreatePasswordResetToken(token *domain.PasswordResetToken) error {
+    if token.ID.IsZero() {
+        token.ID = primitive.NewObjectID()
+    }
+    _, err := r.collection.InsertOne(context.TODO(), token)
+    return err
+}
+
+func (r *MongoPasswordResetRepository) GetPasswordResetTokenByHash(hash string) (*domain.PasswordResetToken, error) {
+    token := &domain.PasswordResetToken{}
+    err := r.collection.FindOne(context.TODO(), bson.M{"token_hash": hash}).Decode(token)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, domain.ErrPasswordResetTokenNotFound
+        }
+        return nil, err
+    }
+    return token, nil
+}
+
+func (r *MongoPasswordResetRepository) MarkPasswordResetTokenUsed(id primitive.ObjectID) (bool, error) {
+    result, err := r.collection.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": id, "used": false},
+        bson.M{"$set": bson.M{"used": true}},
+    )
+    if err != nil {
+        return false, err
+    }
+    return result.ModifiedCount == 1, nil
+}
+
+func (r *MongoPasswordResetRepository) InvalidateUserPasswordResetTokens(userID primitive.ObjectID) error {
+    _, err := r.collection.UpdateMany(
+        context.TODO(),
+        bson.M{"user_id": userID},
+        bson.M{"$set": bson.M{"used": true}},
+    )
+    return err
+}
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoRefreshTokenRepository struct {
+    collection *mongo.Collection
+}
+
+func NewMongoRefreshTokenRepository(collection *mongo.Collection) domain.RefreshTokenRepository {
+    return &MongoRefreshTokenRepository{collection: collection}
+}
+
+func (r *MongoRefreshTokenRepository) CreateRefreshToken(token *domain.RefreshToken) error {
+    if token.ID.IsZero() {
+        token.ID = primitive.NewObjectID()
+    }
+    _, err := r.collection.InsertOne(context.TODO(), token)
+    return err
+}
+
+func (r *MongoRefreshTokenRepository) GetRefreshTokenByHash(hash string) (*domain.RefreshToken, error) {
+    token := &domain.RefreshToken{}
+    err := r.collection.FindOne(context.TODO(), bson.M{"token_hash": hash}).Decode(token)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, domain.ErrRefreshTokenNotFound
+        }
+        return nil, err
+    }
+    return token, nil
+}
+
+func (r *MongoRefreshTokenRepository) MarkRefreshTokenUsed(id primitive.ObjectID) (bool, error) {
+    result, err := r.collection.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": id, "used": false},
+        bson.M{"$set": bson.M{"used": true}},
+    )
+    if err != nil {
+        return false, err
+    }
+    return result.ModifiedCount == 1, nil
+}
+
+func (r *MongoRefreshTokenRepository) RevokeRefreshTokenFamily(familyID primitive.ObjectID) error {
+    _, err := r.collection.UpdateMany(
+        context.TODO(),
+        bson.M{"family_id": familyID},
+        bson.M{"$set": bson.M{"revoked": true}},
+    )
+    return err
+}
+
+func (r *MongoRefreshTokenRepository) RevokeUserRefreshTokens(userID primitive.ObjectID) error {
+    _, err := r.collection.UpdateMany(
+        context.TODO(),
+        bson.M{"user_id": userID},
+        bson.M{"$set": bson.M{"revoked": true}},
+    )
+    return err
+}
@@ -0,0 +1,117 @@
+package repotest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AccessTokenRepositoryFactory returns a new, empty repository for each call.
+type AccessTokenRepositoryFactory func(t *testing.T) domain.AccessTokenRepository
+
+// RunAccessTokenRepositoryTests runs the personal access token repository conformance suite.
+func RunAccessTokenRepositoryTests(t *testing.T, newRepo AccessTokenRepositoryFactory) {
+    t.Run("CreateAndGet", func(t *testing.T) {
+        repo := newRepo(t)
+        token := mustCreateAccessToken(t, repo, "hash-1", primitive.NewObjectID(), baseTime)
+
+        got, err := repo.GetAccessTokenByHash("hash-1")
+        if err != nil {
+            t.Fatalf("GetAccessTokenByHash: %v", err)
+        }
+        if got.ID != token.ID || got.UserID != token.UserID || got.Name != token.Name || got.Revoked ||
+            len(got.Scopes) != 1 || got.Scopes[0] != domain.ScopeTasksRead || !got.ExpiresAt.Equal(token.ExpiresAt) {
+            t.Fatalf("GetAccessTokenByHash: got %+v, want %+v", got, token)
+        }
+        if _, err := repo.GetAccessTokenByHash("missing"); !errors.Is(err, domain.ErrAccessTokenNotFound) {
+            t.Fatalf("GetAccessTokenByHash missing: got %v, want %v", err, domain.ErrAccessTokenNotFound)
+        }
+    })
+
+    t.Run("ListUserTokens", func(t *testing.T) {
+        repo := newRepo(t)
+        user := primitive.NewObjectID()
+        second := mustCreateAccessToken(t, repo, "second", user, baseTime.Add(time.Minute))
+        first := mustCreateAccessToken(t, repo, "first", user, baseTime)
+        mustCreateAccessToken(t, repo, "other", primitive.NewObjectID(), baseTime)
+
+        tokens, err := repo.ListUserAccessTokens(user)
+        if err != nil {
+            t.Fatalf("ListUserAccessTokens: %v", err)
+        }
+        if len(tokens) != 2 || tokens[0].ID != first.ID || tokens[1].ID != second.ID {
+            t.Fatalf("ListUserAccessTokens: got %+v, want first and second in creation order", tokens)
+        }
+
+        tokens, err = repo.ListUserAccessTokens(primitive.NewObjectID())
+        if err != nil || tokens == nil || len(tokens) != 0 {
+            t.Fatalf("ListUserAccessTokens unknown user: got %v err=%v, want an empty list", tokens, err)
+        }
+    })
+
+    t.Run("RevokeToken", func(t *testing.T) {
+        repo := newRepo(t)
+        user := primitive.NewObjectID()
+        token := mustCreateAccessToken(t, repo, "first", user, baseTime)
+        mustCreateAccessToken(t, repo, "second", user, baseTime)
+
+        if err := repo.RevokeAccessToken(primitive.NewObjectID(), token.ID); !errors.Is(err, domain.ErrAccessTokenNotFound) {
+            t.Fatalf("RevokeAccessToken by another user: got %v, want %v", err, domain.ErrAccessTokenNotFound)
+        }
+        if err := repo.RevokeAccessToken(user, primitive.NewObjectID()); !errors.Is(err, domain.ErrAccessTokenNotFound) {
+            t.Fatalf("RevokeAccessToken missing: got %v, want %v", err, domain.ErrAccessTokenNotFound)
+        }
+        if err := repo.RevokeAccessToken(user, token.ID); err != nil {
+            t.Fatalf("RevokeAccessToken: %v", err)
+        }
+        assertAccessTokensRevoked(t, repo, map[string]bool{"first": true, "second": false})
+    })
+
+    t.Run("RevokeUserTokens", func(t *testing.T) {
+        repo := newRepo(t)
+        user := primitive.NewObjectID()
+        mustCreateAccessToken(t, repo, "first", user, baseTime)
+        mustCreateAccessToken(t, repo, "second", user, baseTime)
+        mustCreateAccessToken(t, repo, "other", primitive.NewObjectID(), baseTime)
+
+        if err := repo.RevokeUserAccessTokens(user); err != nil {
+            t.Fatalf("RevokeUserAccessTokens: %v", err)
+        }
+        assertAccessTokensRevoked(t, repo, map[string]bool{"first": true, "second": true, "other": false})
+    })
+}
+
+func mustCreateAccessToken(t *testing.T, repo domain.AccessTokenRepository, hash string, userID primitive.ObjectID, createdAt time.Time) *domain.PersonalAccessToken {
+    t.Helper()
+    token := &domain.PersonalAccessToken{
+        UserID:    userID,
+        Name:      hash + " token",
+        TokenHash: hash,
+        Scopes:    []string{domain.ScopeTasksRead},
+        CreatedAt: createdAt,
+        ExpiresAt: createdAt.Add(24 * time.Hour),
+    }
+    if err := repo.CreateAccessToken(token); err != nil {
+        t.Fatalf("CreateAccessToken: %v", err)
+    }
+    if token.ID.IsZero() {
+        t.Fatal("CreateAccessToken: token ID was not set")
+    }
+    return token
+}
+
+func assertAccessTokensRevoked(t *testing.T, repo domain.AccessTokenRepository, want map[string]bool) {
+    t.Helper()
+    for hash, revoked := range want {
+        token, err := repo.GetAccessTokenByHash(hash)
+        if err != nil {
+            t.Fatalf("GetAccessTokenByHash(%q): %v", hash, err)
+        }
+        if token.Revoked != revoked {
+            t.Fatalf("token %q: revoked = %v, want %v", hash, token.Revoked, revoked)
+        }
+    }
+}
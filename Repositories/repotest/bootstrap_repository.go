@@ -0,0 +1,92 @@
+package repotest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+// BootstrapRepositoryFactory returns a new, empty repository for each call.
+type BootstrapRepositoryFactory func(t *testing.T) domain.BootstrapRepository
+
+// RunBootstrapRepositoryTests runs the bootstrap repository conformance suite.
+func RunBootstrapRepositoryTests(t *testing.T, newRepo BootstrapRepositoryFactory) {
+    t.Run("ClaimOnce", func(t *testing.T) {
+        repo := newRepo(t)
+        if done, err := repo.IsBootstrapped(); err != nil || done {
+            t.Fatalf("IsBootstrapped on empty repository: done=%v err=%v, want false", done, err)
+        }
+        if claimed, err := repo.ClaimBootstrap(baseTime); err != nil || !claimed {
+            t.Fatalf("ClaimBootstrap: claimed=%v err=%v, want true", claimed, err)
+        }
+        if claimed, err := repo.ClaimBootstrap(baseTime); err != nil || claimed {
+            t.Fatalf("ClaimBootstrap again: claimed=%v err=%v, want false", claimed, err)
+        }
+        if done, err := repo.IsBootstrapped(); err != nil || !done {
+            t.Fatalf("IsBootstrapped after claim: done=%v err=%v, want true", done, err)
+        }
+    })
+
+    t.Run("Release", func(t *testing.T) {
+        repo := newRepo(t)
+        if _, err := repo.ClaimBootstrap(baseTime); err != nil {
+            t.Fatalf("ClaimBootstrap: %v", err)
+        }
+        if err := repo.ReleaseBootstrap(); err != nil {
+            t.Fatalf("ReleaseBootstrap: %v", err)
+        }
+        if claimed, err := repo.ClaimBootstrap(baseTime); err != nil || !claimed {
+            t.Fatalf("ClaimBootstrap after release: claimed=%v err=%v, want true", claimed, err)
+        }
+    })
+
+    t.Run("ReleaseClaimedBefore", func(t *testing.T) {
+        repo := newRepo(t)
+        if _, err := repo.ClaimBootstrap(baseTime); err != nil {
+            t.Fatalf("ClaimBootstrap: %v", err)
+        }
+        if err := repo.ReleaseBootstrapClaimedBefore(baseTime); err != nil {
+            t.Fatalf("ReleaseBootstrapClaimedBefore: %v", err)
+        }
+        if done, err := repo.IsBootstrapped(); err != nil || !done {
+            t.Fatalf("IsBootstrapped after releasing older claims: done=%v err=%v, want true", done, err)
+        }
+        if err := repo.ReleaseBootstrapClaimedBefore(baseTime.Add(time.Second)); err != nil {
+            t.Fatalf("ReleaseBootstrapClaimedBefore: %v", err)
+        }
+        if claimed, err := repo.ClaimBootstrap(baseTime); err != nil || !claimed {
+            t.Fatalf("ClaimBootstrap after releasing the claim: claimed=%v err=%v, want true", claimed, err)
+        }
+    })
+
+    t.Run("ConcurrentClaims", func(t *testing.T) {
+        repo := newRepo(t)
+        var wg sync.WaitGroup
+        results := make(chan bool, 10)
+        for i := 0; i < 10; i++ {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                claimed, err := repo.ClaimBootstrap(baseTime)
+                if err != nil {
+                    t.Errorf("ClaimBootstrap: %v", err)
+                }
+                results <- claimed
+            }()
+        }
+        wg.Wait()
+        close(results)
+
+        wins := 0
+        for claimed := range results {
+            if claimed {
+                wins++
+            }
+        }
+        if wins != 1 {
+            t.Fatalf("ConcurrentClaims: %d claims succeeded, want 1", wins)
+        }
+    })
+}
@@ -0,0 +1,136 @@
+package repotest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InviteRepositoryFactory returns a new, empty repository for each call.
+type InviteRepositoryFactory func(t *testing.T) domain.InviteRepository
+
+// RunInviteRepositoryTests runs the invite repository conformance suite.
+func RunInviteRepositoryTests(t *testing.T, newRepo InviteRepositoryFactory) {
+    t.Run("CreateAndGet", func(t *testing.T) {
+        repo := newRepo(t)
+        invite := mustCreateInvite(t, repo, "hash-1", "alice", baseTime)
+
+        got, err := repo.GetInviteByHash("hash-1")
+        if err != nil {
+            t.Fatalf("GetInviteByHash: %v", err)
+        }
+        if got.ID != invite.ID || got.Username != "alice" || got.Role != domain.RoleUser || got.OrgID == nil || *got.OrgID != *invite.OrgID ||
+            got.Used || got.Revoked || !got.ExpiresAt.Equal(invite.ExpiresAt) {
+            t.Fatalf("GetInviteByHash: got %+v, want %+v", got, invite)
+        }
+        if _, err := repo.GetInviteByHash("missing"); !errors.Is(err, domain.ErrInviteNotFound) {
+            t.Fatalf("GetInviteByHash missing: got %v, want %v", err, domain.ErrInviteNotFound)
+        }
+    })
+
+    t.Run("MarkUsedOnce", func(t *testing.T) {
+        repo := newRepo(t)
+        invite := mustCreateInvite(t, repo, "hash-1", "alice", baseTime)
+
+        if marked, err := repo.MarkInviteUsed(invite.ID); err != nil || !marked {
+            t.Fatalf("MarkInviteUsed: marked=%v err=%v, want true", marked, err)
+        }
+        if marked, err := repo.MarkInviteUsed(invite.ID); err != nil || marked {
+            t.Fatalf("MarkInviteUsed again: marked=%v err=%v, want false", marked, err)
+        }
+        if err := repo.RevokeInvite(invite.ID); !errors.Is(err, domain.ErrInviteNotFound) {
+            t.Fatalf("RevokeInvite of a used invite: got %v, want %v", err, domain.ErrInviteNotFound)
+        }
+    })
+
+    t.Run("Revoke", func(t *testing.T) {
+        repo := newRepo(t)
+        invite := mustCreateInvite(t, repo, "hash-1", "alice", baseTime)
+
+        if err := repo.RevokeInvite(invite.ID); err != nil {
+            t.Fatalf("RevokeInvite: %v", err)
+        }
+        if err := repo.RevokeInvite(invite.ID); !errors.Is(err, domain.ErrInviteNotFound) {
+            t.Fatalf("RevokeInvite again: got %v, want %v", err, domain.ErrInviteNotFound)
+        }
+        if err := repo.RevokeInvite(primitive.NewObjectID()); !errors.Is(err, domain.ErrInviteNotFound) {
+            t.Fatalf("RevokeInvite missing: got %v, want %v", err, domain.ErrInviteNotFound)
+        }
+        if marked, err := repo.MarkInviteUsed(invite.ID); err != nil || marked {
+            t.Fatalf("MarkInviteUsed of a revoked invite: marked=%v err=%v, want false", marked, err)
+        }
+
+        mustCreateInvite(t, repo, "first", "bob", baseTime)
+        mustCreateInvite(t, repo, "second", "bob", baseTime)
+        other := mustCreateInvite(t, repo, "other", "carol", baseTime)
+        if err := repo.RevokeUsernameInvites("bob"); err != nil {
+            t.Fatalf("RevokeUsernameInvites: %v", err)
+        }
+        for hash, revoked := range map[string]bool{"first": true, "second": true, "other": false} {
+            got, err := repo.GetInviteByHash(hash)
+            if err != nil {
+                t.Fatalf("GetInviteByHash(%q): %v", hash, err)
+            }
+            if got.Revoked != revoked {
+                t.Fatalf("invite %q: revoked = %v, want %v", hash, got.Revoked, revoked)
+            }
+        }
+        if err := repo.RevokeInvite(other.ID); err != nil {
+            t.Fatalf("RevokeInvite after RevokeUsernameInvites: %v", err)
+        }
+    })
+
+    t.Run("ListPending", func(t *testing.T) {
+        repo := newRepo(t)
+        second := mustCreateInvite(t, repo, "second", "bob", baseTime.Add(time.Minute))
+        first := mustCreateInvite(t, repo, "first", "alice", baseTime)
+        used := mustCreateInvite(t, repo, "used", "carol", baseTime)
+        revoked := mustCreateInvite(t, repo, "revoked", "dave", baseTime)
+        if _, err := repo.MarkInviteUsed(used.ID); err != nil {
+            t.Fatalf("MarkInviteUsed: %v", err)
+        }
+        if err := repo.RevokeInvite(revoked.ID); err != nil {
+            t.Fatalf("RevokeInvite: %v", err)
+        }
+
+        got, err := repo.ListPendingInvites(baseTime.Add(time.Hour))
+        if err != nil {
+            t.Fatalf("ListPendingInvites: %v", err)
+        }
+        if len(got) != 2 || got[0].ID != first.ID || got[1].ID != second.ID {
+            t.Fatalf("ListPendingInvites: got %+v, want alice then bob", got)
+        }
+        // first expires at baseTime plus a day, second a minute later.
+        got, err = repo.ListPendingInvites(first.ExpiresAt)
+        if err != nil {
+            t.Fatalf("ListPendingInvites: %v", err)
+        }
+        if len(got) != 1 || got[0].ID != second.ID {
+            t.Fatalf("ListPendingInvites after the first expired: got %+v, want bob only", got)
+        }
+    })
+}
+
+func mustCreateInvite(t *testing.T, repo domain.InviteRepository, hash, username string, createdAt time.Time) *domain.Invite {
+    t.Helper()
+    orgID := primitive.NewObjectID()
+    invite := &domain.Invite{
+        TokenHash: hash,
+        Username:  username,
+        Role:      domain.RoleUser,
+        OrgID:     &orgID,
+        CreatedBy: primitive.NewObjectID(),
+        CreatedAt: createdAt,
+        ExpiresAt: createdAt.Add(24 * time.Hour),
+    }
+    if err := repo.CreateInvite(invite); err != nil {
+        t.Fatalf("CreateInvite: %v", err)
+    }
+    if invite.ID.IsZero() {
+        t.Fatal("CreateInvite: invite ID was not set")
+    }
+    return invite
+}
@@ -0,0 +1,105 @@
+package repotest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+// LoginAttemptRepositoryFactory returns a new, empty repository for each call.
+type LoginAttemptRepositoryFactory func(t *testing.T) domain.LoginAttemptRepository
+
+// RunLoginAttemptRepositoryTests runs the login attempt repository conformance suite.
+func RunLoginAttemptRepositoryTests(t *testing.T, newRepo LoginAttemptRepositoryFactory) {
+    t.Run("GetUnknown", func(t *testing.T) {
+        repo := newRepo(t)
+        attempt, err := repo.GetLoginAttempt("user:alice")
+        if err != nil {
+            t.Fatalf("GetLoginAttempt: %v", err)
+        }
+        if attempt.Key != "user:alice" || attempt.Failures != 0 || !attempt.LockedUntil.IsZero() {
+            t.Fatalf("GetLoginAttempt: got %+v, want an empty record", attempt)
+        }
+    })
+
+    t.Run("RecordFailures", func(t *testing.T) {
+        repo := newRepo(t)
+        for i := 1; i <= 3; i++ {
+            at := baseTime.Add(time.Duration(i) * time.Minute)
+            attempt, err := repo.RecordLoginFailure("user:alice", at, baseTime)
+            if err != nil {
+                t.Fatalf("RecordLoginFailure: %v", err)
+            }
+            if attempt.Failures != i || !attempt.LastFailure.Equal(at) {
+                t.Fatalf("RecordLoginFailure %d: got %+v", i, attempt)
+            }
+        }
+        if attempt, _ := repo.GetLoginAttempt("ip:10.0.0.1"); attempt.Failures != 0 {
+            t.Fatalf("GetLoginAttempt other key: failures = %d, want 0", attempt.Failures)
+        }
+
+        // The last failure is older than resetBefore, so counting starts over.
+        attempt, err := repo.RecordLoginFailure("user:alice", baseTime.Add(time.Hour), baseTime.Add(30*time.Minute))
+        if err != nil {
+            t.Fatalf("RecordLoginFailure after window: %v", err)
+        }
+        if attempt.Failures != 1 {
+            t.Fatalf("RecordLoginFailure after window: failures = %d, want 1", attempt.Failures)
+        }
+    })
+
+    t.Run("LockKeepsLatest", func(t *testing.T) {
+        repo := newRepo(t)
+        if _, err := repo.RecordLoginFailure("user:alice", baseTime, baseTime); err != nil {
+            t.Fatalf("RecordLoginFailure: %v", err)
+        }
+        later := baseTime.Add(time.Hour)
+        if err := repo.LockLogin("user:alice", later); err != nil {
+            t.Fatalf("LockLogin: %v", err)
+        }
+        if err := repo.LockLogin("user:alice", baseTime.Add(time.Minute)); err != nil {
+            t.Fatalf("LockLogin earlier: %v", err)
+        }
+        attempt, _ := repo.GetLoginAttempt("user:alice")
+        if !attempt.LockedUntil.Equal(later) || attempt.Failures != 1 {
+            t.Fatalf("GetLoginAttempt: got %+v, want locked until %v with one failure", attempt, later)
+        }
+    })
+
+    t.Run("PurgeExpired", func(t *testing.T) {
+        repo := newRepo(t)
+        repo.RecordLoginFailure("user:old", baseTime, baseTime)
+        repo.RecordLoginFailure("ip:locked", baseTime, baseTime)
+        repo.LockLogin("ip:locked", baseTime.Add(2*time.Hour))
+        repo.RecordLoginFailure("user:recent", baseTime.Add(50*time.Minute), baseTime)
+        if _, err := repo.RecordLoginFailure("user:alice", baseTime.Add(time.Hour), baseTime.Add(30*time.Minute)); err != nil {
+            t.Fatalf("RecordLoginFailure: %v", err)
+        }
+        if attempt, _ := repo.GetLoginAttempt("user:old"); attempt.Failures != 0 || !attempt.LastFailure.IsZero() {
+            t.Fatalf("GetLoginAttempt of an expired key: got %+v, want it purged", attempt)
+        }
+        if attempt, _ := repo.GetLoginAttempt("ip:locked"); attempt.Failures != 1 || !attempt.LockedUntil.Equal(baseTime.Add(2*time.Hour)) {
+            t.Fatalf("GetLoginAttempt of a locked key: got %+v, want it kept", attempt)
+        }
+        if attempt, _ := repo.GetLoginAttempt("user:recent"); attempt.Failures != 1 {
+            t.Fatalf("GetLoginAttempt of a recent key: got %+v, want it kept", attempt)
+        }
+    })
+
+    t.Run("Reset", func(t *testing.T) {
+        repo := newRepo(t)
+        repo.RecordLoginFailure("user:alice", baseTime, baseTime)
+        repo.LockLogin("user:alice", baseTime.Add(time.Hour))
+        if err := repo.ResetLoginAttempts("user:alice"); err != nil {
+            t.Fatalf("ResetLoginAttempts: %v", err)
+        }
+        if err := repo.ResetLoginAttempts("user:nobody"); err != nil {
+            t.Fatalf("ResetLoginAttempts unknown key: %v", err)
+        }
+        attempt, _ := repo.GetLoginAttempt("user:alice")
+        if attempt.Failures != 0 || !attempt.LockedUntil.IsZero() {
+            t.Fatalf("GetLoginAttempt after reset: got %+v, want an empty record", attempt)
+        }
+    })
+}
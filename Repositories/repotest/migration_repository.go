@@ -0,0 +1,56 @@
+package repotest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+// MigrationRepositoryFactory returns a new, empty repository for each call.
+type MigrationRepositoryFactory func(t *testing.T) domain.MigrationRepository
+
+// RunMigrationRepositoryTests runs the migration repository conformance suite.
+func RunMigrationRepositoryTests(t *testing.T, newRepo MigrationRepositoryFactory) {
+    t.Run("ClaimOnce", func(t *testing.T) {
+        repo := newRepo(t)
+        if claimed, err := repo.ClaimMigration("first", baseTime); err != nil || !claimed {
+            t.Fatalf("ClaimMigration: claimed=%v err=%v, want true", claimed, err)
+        }
+        if claimed, err := repo.ClaimMigration("first", baseTime); err != nil || claimed {
+            t.Fatalf("ClaimMigration again: claimed=%v err=%v, want false", claimed, err)
+        }
+        if claimed, err := repo.ClaimMigration("second", baseTime); err != nil || !claimed {
+            t.Fatalf("ClaimMigration of another migration: claimed=%v err=%v, want true", claimed, err)
+        }
+    })
+
+    t.Run("ConcurrentClaims", func(t *testing.T) {
+        repo := newRepo(t)
+        var wg sync.WaitGroup
+        results := make(chan bool, 10)
+        for i := 0; i < 10; i++ {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                claimed, err := repo.ClaimMigration("first", baseTime)
+                if err != nil {
+                    t.Errorf("ClaimMigration: %v", err)
+                }
+                results <- claimed
+            }()
+        }
+        wg.Wait()
+        close(results)
+
+        wins := 0
+        for claimed := range results {
+            if claimed {
+                wins++
+            }
+        }
+        if wins != 1 {
+            t.Fatalf("ConcurrentClaims: %d claims succeeded, want 1", wins)
+        }
+    })
+}
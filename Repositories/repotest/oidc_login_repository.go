@@ -0,0 +1,69 @@
+package repotest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+// OIDCLoginRepositoryFactory returns a new, empty repository for each call.
+type OIDCLoginRepositoryFactory func(t *testing.T) domain.OIDCLoginRepository
+
+// RunOIDCLoginRepositoryTests runs the OIDC login repository conformance suite.
+func RunOIDCLoginRepositoryTests(t *testing.T, newRepo OIDCLoginRepositoryFactory) {
+    t.Run("TakeOnce", func(t *testing.T) {
+        repo := newRepo(t)
+        login := mustCreateOIDCLogin(t, repo, "hash-1", baseTime)
+
+        got, err := repo.TakeOIDCLogin("hash-1")
+        if err != nil {
+            t.Fatalf("TakeOIDCLogin: %v", err)
+        }
+        if got.ID != login.ID || got.Nonce != login.Nonce || got.CodeVerifier != login.CodeVerifier || !got.ExpiresAt.Equal(login.ExpiresAt) {
+            t.Fatalf("TakeOIDCLogin: got %+v, want %+v", got, login)
+        }
+        if _, err := repo.TakeOIDCLogin("hash-1"); !errors.Is(err, domain.ErrOIDCLoginNotFound) {
+            t.Fatalf("TakeOIDCLogin again: got %v, want %v", err, domain.ErrOIDCLoginNotFound)
+        }
+        if _, err := repo.TakeOIDCLogin("missing"); !errors.Is(err, domain.ErrOIDCLoginNotFound) {
+            t.Fatalf("TakeOIDCLogin missing: got %v, want %v", err, domain.ErrOIDCLoginNotFound)
+        }
+    })
+
+    t.Run("DeleteExpired", func(t *testing.T) {
+        repo := newRepo(t)
+        mustCreateOIDCLogin(t, repo, "old", baseTime)
+        mustCreateOIDCLogin(t, repo, "new", baseTime.Add(time.Hour))
+
+        // old expires ten minutes after baseTime, new ten minutes after that hour.
+        if err := repo.DeleteExpiredOIDCLogins(baseTime.Add(30 * time.Minute)); err != nil {
+            t.Fatalf("DeleteExpiredOIDCLogins: %v", err)
+        }
+        if _, err := repo.TakeOIDCLogin("old"); !errors.Is(err, domain.ErrOIDCLoginNotFound) {
+            t.Fatalf("TakeOIDCLogin of an expired login: got %v, want %v", err, domain.ErrOIDCLoginNotFound)
+        }
+        if _, err := repo.TakeOIDCLogin("new"); err != nil {
+            t.Fatalf("TakeOIDCLogin of a pending login: %v", err)
+        }
+    })
+}
+
+func mustCreateOIDCLogin(t *testing.T, repo domain.OIDCLoginRepository, hash string, createdAt time.Time) *domain.OIDCLogin {
+    t.Helper()
+    login := &domain.OIDCLogin{
+        StateHash:    hash,
+        Nonce:        "nonce-" + hash,
+        CodeVerifier: "verifier-" + hash,
+        CreatedAt:    createdAt,
+        ExpiresAt:    createdAt.Add(10 * time.Minute),
+    }
+    if err := repo.CreateOIDCLogin(login); err != nil {
+        t.Fatalf("CreateOIDCLogin: %v", err)
+    }
+    if login.ID.IsZero() {
+        t.Fatal("CreateOIDCLogin: login ID was not set")
+    }
+    return login
+}
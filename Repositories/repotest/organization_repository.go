@@ -0,0 +1,166 @@
+package repotest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OrganizationRepositoryFactory returns a new, empty repository for each call.
+type OrganizationRepositoryFactory func(t *testing.T) domain.OrganizationRepository
+
+// RunOrganizationRepositoryTests runs the organization repository conformance suite.
+func RunOrganizationRepositoryTests(t *testing.T, newRepo OrganizationRepositoryFactory) {
+    t.Run("CreateAndGet", func(t *testing.T) {
+        repo := newRepo(t)
+        if n, err := repo.CountOrganizations(); err != nil || n != 0 {
+            t.Fatalf("CountOrganizations on empty repository: n=%d err=%v", n, err)
+        }
+        org := &domain.Organization{Name: "Acme", CreatedBy: primitive.NewObjectID(), CreatedAt: baseTime}
+        if err := repo.CreateOrganization(org); err != nil {
+            t.Fatalf("CreateOrganization: %v", err)
+        }
+        if org.ID.IsZero() {
+            t.Fatal("CreateOrganization did not set the ID")
+        }
+        got, err := repo.GetOrganization(org.ID)
+        if err != nil {
+            t.Fatalf("GetOrganization: %v", err)
+        }
+        if got.ID != org.ID || got.Name != org.Name || got.CreatedBy != org.CreatedBy || !got.CreatedAt.Equal(org.CreatedAt) {
+            t.Fatalf("GetOrganization: got %+v, want %+v", got, org)
+        }
+        if n, err := repo.CountOrganizations(); err != nil || n != 1 {
+            t.Fatalf("CountOrganizations: n=%d err=%v, want 1", n, err)
+        }
+        if _, err := repo.GetOrganization(primitive.NewObjectID()); !errors.Is(err, domain.ErrOrganizationNotFound) {
+            t.Fatalf("GetOrganization missing: got %v, want %v", err, domain.ErrOrganizationNotFound)
+        }
+    })
+
+    t.Run("Memberships", func(t *testing.T) {
+        repo := newRepo(t)
+        acme, globex := primitive.NewObjectID(), primitive.NewObjectID()
+        alice, bob := primitive.NewObjectID(), primitive.NewObjectID()
+        mustAddMember(t, repo, acme, alice, domain.RoleAdmin, false, baseTime)
+        mustAddMember(t, repo, globex, alice, domain.RoleUser, false, baseTime.Add(time.Hour))
+        mustAddMember(t, repo, acme, bob, domain.RoleUser, true, baseTime.Add(time.Minute))
+
+        duplicate := &domain.Membership{OrgID: acme, UserID: bob, Role: domain.RoleAdmin}
+        if err := repo.AddMember(duplicate); !errors.Is(err, domain.ErrAlreadyOrgMember) {
+            t.Fatalf("AddMember duplicate: got %v, want %v", err, domain.ErrAlreadyOrgMember)
+        }
+
+        got, err := repo.GetMembership(acme, bob)
+        if err != nil {
+            t.Fatalf("GetMembership: %v", err)
+        }
+        if got.Role != domain.RoleUser || !got.Pending || !got.CreatedAt.Equal(baseTime.Add(time.Minute)) {
+            t.Fatalf("GetMembership: got %+v", got)
+        }
+        if _, err := repo.GetMembership(globex, bob); !errors.Is(err, domain.ErrNotOrgMember) {
+            t.Fatalf("GetMembership missing: got %v, want %v", err, domain.ErrNotOrgMember)
+        }
+
+        assertMemberships(t, "ListMembers", mustList(t, repo.ListMembers, acme), []primitive.ObjectID{alice, bob})
+        assertMemberships(t, "ListUserMemberships", mustList(t, repo.ListUserMemberships, alice), []primitive.ObjectID{acme, globex})
+
+        if err := repo.AcceptInvitation(acme, bob); err != nil {
+            t.Fatalf("AcceptInvitation: %v", err)
+        }
+        if got, _ := repo.GetMembership(acme, bob); got.Pending {
+            t.Fatal("AcceptInvitation: membership still pending")
+        }
+        if err := repo.AcceptInvitation(acme, bob); !errors.Is(err, domain.ErrNotOrgMember) {
+            t.Fatalf("AcceptInvitation twice: got %v, want %v", err, domain.ErrNotOrgMember)
+        }
+
+        if n, err := repo.CountMembersWithRole(domain.RoleUser); err != nil || n != 2 {
+            t.Fatalf("CountMembersWithRole: n=%d err=%v, want 2", n, err)
+        }
+        if err := repo.RemoveUserMemberships(alice); err != nil {
+            t.Fatalf("RemoveUserMemberships: %v", err)
+        }
+        if memberships := mustList(t, repo.ListUserMemberships, alice); len(memberships) != 0 {
+            t.Fatalf("RemoveUserMemberships: %d memberships left", len(memberships))
+        }
+        assertMemberships(t, "ListMembers after RemoveUserMemberships", mustList(t, repo.ListMembers, acme), []primitive.ObjectID{bob})
+    })
+
+    t.Run("KeepsAnOrgAdmin", func(t *testing.T) {
+        repo := newRepo(t)
+        org := primitive.NewObjectID()
+        alice, bob, carol := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+        mustAddMember(t, repo, org, alice, domain.RoleAdmin, false, baseTime)
+        mustAddMember(t, repo, org, bob, domain.RoleUser, false, baseTime)
+        // A pending admin invitation does not count as an admin.
+        mustAddMember(t, repo, org, carol, domain.RoleAdmin, true, baseTime)
+
+        if err := repo.SetMemberRole(org, alice, domain.RoleUser); !errors.Is(err, domain.ErrLastOrgAdmin) {
+            t.Fatalf("SetMemberRole of the last admin: got %v, want %v", err, domain.ErrLastOrgAdmin)
+        }
+        if err := repo.RemoveMember(org, alice); !errors.Is(err, domain.ErrLastOrgAdmin) {
+            t.Fatalf("RemoveMember of the last admin: got %v, want %v", err, domain.ErrLastOrgAdmin)
+        }
+        if got, err := repo.GetMembership(org, alice); err != nil || got.Role != domain.RoleAdmin {
+            t.Fatalf("last admin changed: %+v, %v", got, err)
+        }
+
+        if err := repo.SetMemberRole(org, bob, domain.RoleAdmin); err != nil {
+            t.Fatalf("SetMemberRole: %v", err)
+        }
+        if err := repo.SetMemberRole(org, alice, domain.RoleUser); err != nil {
+            t.Fatalf("SetMemberRole with another admin: %v", err)
+        }
+        if err := repo.RemoveMember(org, carol); err != nil {
+            t.Fatalf("RemoveMember of an invitation: %v", err)
+        }
+        if err := repo.RemoveMember(org, alice); err != nil {
+            t.Fatalf("RemoveMember: %v", err)
+        }
+        if _, err := repo.GetMembership(org, alice); !errors.Is(err, domain.ErrNotOrgMember) {
+            t.Fatalf("GetMembership after RemoveMember: got %v, want %v", err, domain.ErrNotOrgMember)
+        }
+        if err := repo.RemoveMember(org, alice); !errors.Is(err, domain.ErrNotOrgMember) {
+            t.Fatalf("RemoveMember again: got %v, want %v", err, domain.ErrNotOrgMember)
+        }
+        if err := repo.SetMemberRole(org, alice, domain.RoleUser); !errors.Is(err, domain.ErrNotOrgMember) {
+            t.Fatalf("SetMemberRole of a non-member: got %v, want %v", err, domain.ErrNotOrgMember)
+        }
+    })
+}
+
+func mustAddMember(t *testing.T, repo domain.OrganizationRepository, orgID, userID primitive.ObjectID, role string, pending bool, createdAt time.Time) {
+    t.Helper()
+    membership := &domain.Membership{OrgID: orgID, UserID: userID, Role: role, Pending: pending, CreatedAt: createdAt}
+    if err := repo.AddMember(membership); err != nil {
+        t.Fatalf("AddMember: %v", err)
+    }
+}
+
+func mustList(t *testing.T, list func(primitive.ObjectID) ([]domain.Membership, error), id primitive.ObjectID) []domain.Membership {
+    t.Helper()
+    memberships, err := list(id)
+    if err != nil {
+        t.Fatalf("listing memberships: %v", err)
+    }
+    return memberships
+}
+
+// assertMemberships checks the memberships are listed in order. The IDs are
+// the user IDs when listing an organization and the organization IDs when
+// listing a user.
+func assertMemberships(t *testing.T, name string, got []domain.Membership, want []primitive.ObjectID) {
+    t.Helper()
+    if len(got) != len(want) {
+        t.Fatalf("%s: got %d memberships, want %d", name, len(got), len(want))
+    }
+    for i, id := range want {
+        if got[i].UserID != id && got[i].OrgID != id {
+            t.Fatalf("%s: membership %d is %+v, want %v", name, i, got[i], id)
+        }
+    }
+}
@@ -0,0 +1,85 @@
+package repotest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PasswordResetRepositoryFactory returns a new, empty repository for each call.
+type PasswordResetRepositoryFactory func(t *testing.T) domain.PasswordResetRepository
+
+// RunPasswordResetRepositoryTests runs the password reset repository conformance suite.
+func RunPasswordResetRepositoryTests(t *testing.T, newRepo PasswordResetRepositoryFactory) {
+    t.Run("CreateAndGet", func(t *testing.T) {
+        repo := newRepo(t)
+        token := mustCreatePasswordResetToken(t, repo, "hash-1", primitive.NewObjectID())
+
+        got, err := repo.GetPasswordResetTokenByHash("hash-1")
+        if err != nil {
+            t.Fatalf("GetPasswordResetTokenByHash: %v", err)
+        }
+        if got.ID != token.ID || got.UserID != token.UserID || got.Used || !got.ExpiresAt.Equal(token.ExpiresAt) {
+            t.Fatalf("GetPasswordResetTokenByHash: got %+v, want %+v", got, token)
+        }
+        if _, err := repo.GetPasswordResetTokenByHash("missing"); !errors.Is(err, domain.ErrPasswordResetTokenNotFound) {
+            t.Fatalf("GetPasswordResetTokenByHash missing: got %v, want %v", err, domain.ErrPasswordResetTokenNotFound)
+        }
+    })
+
+    t.Run("MarkUsedOnce", func(t *testing.T) {
+        repo := newRepo(t)
+        token := mustCreatePasswordResetToken(t, repo, "hash-1", primitive.NewObjectID())
+
+        if marked, err := repo.MarkPasswordResetTokenUsed(token.ID); err != nil || !marked {
+            t.Fatalf("MarkPasswordResetTokenUsed: marked=%v err=%v, want true", marked, err)
+        }
+        if marked, err := repo.MarkPasswordResetTokenUsed(token.ID); err != nil || marked {
+            t.Fatalf("MarkPasswordResetTokenUsed again: marked=%v err=%v, want false", marked, err)
+        }
+        if got, _ := repo.GetPasswordResetTokenByHash("hash-1"); !got.Used {
+            t.Fatal("MarkPasswordResetTokenUsed: token not flagged as used")
+        }
+    })
+
+    t.Run("InvalidateUserTokens", func(t *testing.T) {
+        repo := newRepo(t)
+        user := primitive.NewObjectID()
+        mustCreatePasswordResetToken(t, repo, "first", user)
+        mustCreatePasswordResetToken(t, repo, "second", user)
+        mustCreatePasswordResetToken(t, repo, "other", primitive.NewObjectID())
+
+        if err := repo.InvalidateUserPasswordResetTokens(user); err != nil {
+            t.Fatalf("InvalidateUserPasswordResetTokens: %v", err)
+        }
+        for hash, used := range map[string]bool{"first": true, "second": true, "other": false} {
+            token, err := repo.GetPasswordResetTokenByHash(hash)
+            if err != nil {
+                t.Fatalf("GetPasswordResetTokenByHash(%q): %v", hash, err)
+            }
+            if token.Used != used {
+                t.Fatalf("token %q: used = %v, want %v", hash, token.Used, used)
+            }
+        }
+    })
+}
+
+func mustCreatePasswordResetToken(t *testing.T, repo domain.PasswordResetRepository, hash string, userID primitive.ObjectID) *domain.PasswordResetToken {
+    t.Helper()
+    token := &domain.PasswordResetToken{
+        TokenHash: hash,
+        UserID:    userID,
+        CreatedAt: baseTime,
+        ExpiresAt: baseTime.Add(time.Hour),
+    }
+    if err := repo.CreatePasswordResetToken(token); err != nil {
+        t.Fatalf("CreatePasswordResetToken: %v", err)
+    }
+    if token.ID.IsZero() {
+        t.Fatal("CreatePasswordResetToken: token ID was not set")
+    }
+    return token
+}
@@ -0,0 +1,105 @@
+package repotest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshTokenRepositoryFactory returns a new, empty repository for each call.
+type RefreshTokenRepositoryFactory func(t *testing.T) domain.RefreshTokenRepository
+
+// RunRefreshTokenRepositoryTests runs the refresh token repository conformance suite.
+func RunRefreshTokenRepositoryTests(t *testing.T, newRepo RefreshTokenRepositoryFactory) {
+    t.Run("CreateAndGet", func(t *testing.T) {
+        repo := newRepo(t)
+        token := mustCreateRefreshToken(t, repo, "hash-1", primitive.NewObjectID(), primitive.NewObjectID())
+
+        got, err := repo.GetRefreshTokenByHash("hash-1")
+        if err != nil {
+            t.Fatalf("GetRefreshTokenByHash: %v", err)
+        }
+        if got.ID != token.ID || got.UserID != token.UserID || got.FamilyID != token.FamilyID ||
+            got.Used || got.Revoked || !got.ExpiresAt.Equal(token.ExpiresAt) {
+            t.Fatalf("GetRefreshTokenByHash: got %+v, want %+v", got, token)
+        }
+        if _, err := repo.GetRefreshTokenByHash("missing"); !errors.Is(err, domain.ErrRefreshTokenNotFound) {
+            t.Fatalf("GetRefreshTokenByHash missing: got %v, want %v", err, domain.ErrRefreshTokenNotFound)
+        }
+    })
+
+    t.Run("MarkUsedOnce", func(t *testing.T) {
+        repo := newRepo(t)
+        token := mustCreateRefreshToken(t, repo, "hash-1", primitive.NewObjectID(), primitive.NewObjectID())
+
+        if marked, err := repo.MarkRefreshTokenUsed(token.ID); err != nil || !marked {
+            t.Fatalf("MarkRefreshTokenUsed: marked=%v err=%v, want true", marked, err)
+        }
+        if marked, err := repo.MarkRefreshTokenUsed(token.ID); err != nil || marked {
+            t.Fatalf("MarkRefreshTokenUsed again: marked=%v err=%v, want false", marked, err)
+        }
+        if got, _ := repo.GetRefreshTokenByHash("hash-1"); !got.Used {
+            t.Fatal("MarkRefreshTokenUsed: token not flagged as used")
+        }
+    })
+
+    t.Run("RevokeFamily", func(t *testing.T) {
+        repo := newRepo(t)
+        user, family := primitive.NewObjectID(), primitive.NewObjectID()
+        mustCreateRefreshToken(t, repo, "first", user, family)
+        mustCreateRefreshToken(t, repo, "second", user, family)
+        mustCreateRefreshToken(t, repo, "other", user, primitive.NewObjectID())
+
+        if err := repo.RevokeRefreshTokenFamily(family); err != nil {
+            t.Fatalf("RevokeRefreshTokenFamily: %v", err)
+        }
+        assertRevoked(t, repo, map[string]bool{"first": true, "second": true, "other": false})
+    })
+
+    t.Run("RevokeUser", func(t *testing.T) {
+        repo := newRepo(t)
+        user := primitive.NewObjectID()
+        mustCreateRefreshToken(t, repo, "first", user, primitive.NewObjectID())
+        mustCreateRefreshToken(t, repo, "second", user, primitive.NewObjectID())
+        mustCreateRefreshToken(t, repo, "other", primitive.NewObjectID(), primitive.NewObjectID())
+
+        if err := repo.RevokeUserRefreshTokens(user); err != nil {
+            t.Fatalf("RevokeUserRefreshTokens: %v", err)
+        }
+        assertRevoked(t, repo, map[string]bool{"first": true, "second": true, "other": false})
+    })
+}
+
+func mustCreateRefreshToken(t *testing.T, repo domain.RefreshTokenRepository, hash string, userID, familyID primitive.ObjectID) *domain.RefreshToken {
+    t.Helper()
+    token := &domain.RefreshToken{
+        TokenHash: hash,
+        UserID:    userID,
+        FamilyID:  familyID,
+        CreatedAt: baseTime,
+        ExpiresAt: baseTime.Add(time.Hour),
+    }
+    if err := repo.CreateRefreshToken(token); err != nil {
+        t.Fatalf("CreateRefreshToken: %v", err)
+    }
+    if token.ID.IsZero() {
+        t.Fatal("CreateRefreshToken: token ID was not set")
+    }
+    return token
+}
+
+func assertRevoked(t *testing.T, repo domain.RefreshTokenRepository, want map[string]bool) {
+    t.Helper()
+    for hash, revoked := range want {
+        token, err := repo.GetRefreshTokenByHash(hash)
+        if err != nil {
+            t.Fatalf("GetRefreshTokenByHash(%q): %v", hash, err)
+        }
+        if token.Revoked != revoked {
+            t.Fatalf("token %q: revoked = %v, want %v", hash, token.Revoked, revoked)
+        }
+    }
+}
@@ -0,0 +1,94 @@
+package repotest
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+// RoleRepositoryFactory returns a new, empty repository for each call.
+type RoleRepositoryFactory func(t *testing.T) domain.RoleRepository
+
+// RunRoleRepositoryTests runs the role repository conformance suite.
+func RunRoleRepositoryTests(t *testing.T, newRepo RoleRepositoryFactory) {
+    t.Run("CreateAndGet", func(t *testing.T) {
+        repo := newRepo(t)
+        role := &domain.Role{Name: "auditor", Description: "Read only", Permissions: []string{domain.PermissionTasksRead, domain.PermissionUsersRead}}
+        if err := repo.CreateRole(role); err != nil {
+            t.Fatalf("CreateRole: %v", err)
+        }
+        if err := repo.CreateRole(&domain.Role{Name: "auditor"}); !errors.Is(err, domain.ErrRoleExists) {
+            t.Fatalf("CreateRole duplicate: got %v, want %v", err, domain.ErrRoleExists)
+        }
+
+        got, err := repo.GetRole("auditor")
+        if err != nil {
+            t.Fatalf("GetRole: %v", err)
+        }
+        if got.Name != role.Name || got.Description != role.Description || got.BuiltIn || !slices.Equal(got.Permissions, role.Permissions) {
+            t.Fatalf("GetRole: got %+v, want %+v", got, role)
+        }
+        if _, err := repo.GetRole("missing"); !errors.Is(err, domain.ErrRoleNotFound) {
+            t.Fatalf("GetRole missing: got %v, want %v", err, domain.ErrRoleNotFound)
+        }
+    })
+
+    t.Run("ListRoles", func(t *testing.T) {
+        repo := newRepo(t)
+        if roles, err := repo.ListRoles(); err != nil || len(roles) != 0 {
+            t.Fatalf("ListRoles on empty repository: got %v, %v", roles, err)
+        }
+        for _, name := range []string{"lead", "admin", "user"} {
+            if err := repo.CreateRole(&domain.Role{Name: name, Permissions: []string{}}); err != nil {
+                t.Fatalf("CreateRole %s: %v", name, err)
+            }
+        }
+        roles, err := repo.ListRoles()
+        if err != nil {
+            t.Fatalf("ListRoles: %v", err)
+        }
+        var names []string
+        for _, role := range roles {
+            names = append(names, role.Name)
+        }
+        if !slices.Equal(names, []string{"admin", "lead", "user"}) {
+            t.Fatalf("ListRoles: got %v, want [admin lead user]", names)
+        }
+    })
+
+    t.Run("UpdateRole", func(t *testing.T) {
+        repo := newRepo(t)
+        if err := repo.CreateRole(&domain.Role{Name: domain.RoleUser, Permissions: []string{domain.PermissionTasksRead}, BuiltIn: true}); err != nil {
+            t.Fatalf("CreateRole: %v", err)
+        }
+        update := &domain.Role{Name: domain.RoleUser, Description: "Edit tasks", Permissions: []string{domain.PermissionTasksRead, domain.PermissionTasksUpdate}}
+        if err := repo.UpdateRole(update); err != nil {
+            t.Fatalf("UpdateRole: %v", err)
+        }
+        got, _ := repo.GetRole(domain.RoleUser)
+        if got.Description != update.Description || !got.BuiltIn || !slices.Equal(got.Permissions, update.Permissions) {
+            t.Fatalf("UpdateRole: got %+v, want the new description and permissions and BuiltIn kept", got)
+        }
+        if err := repo.UpdateRole(&domain.Role{Name: "missing"}); !errors.Is(err, domain.ErrRoleNotFound) {
+            t.Fatalf("UpdateRole missing: got %v, want %v", err, domain.ErrRoleNotFound)
+        }
+    })
+
+    t.Run("DeleteRole", func(t *testing.T) {
+        repo := newRepo(t)
+        if err := repo.CreateRole(&domain.Role{Name: "lead"}); err != nil {
+            t.Fatalf("CreateRole: %v", err)
+        }
+        if err := repo.DeleteRole("lead"); err != nil {
+            t.Fatalf("DeleteRole: %v", err)
+        }
+        if _, err := repo.GetRole("lead"); !errors.Is(err, domain.ErrRoleNotFound) {
+            t.Fatalf("GetRole after delete: got %v, want %v", err, domain.ErrRoleNotFound)
+        }
+        if err := repo.DeleteRole("lead"); !errors.Is(err, domain.ErrRoleNotFound) {
+            t.Fatalf("DeleteRole again: got %v, want %v", err, domain.ErrRoleNotFound)
+        }
+    })
+}
@@ -0,0 +1,492 @@
+// Package repotest provides conformance suites for implementations of the
+// domain repository interfaces. Every backend is expected to pass them so that
+// it can be swapped for MongoTaskRepository and MongoUserRepository without
+// changing behaviour.
+package repotest
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TaskRepositoryFactory returns a new, empty repository for each call.
+type TaskRepositoryFactory func(t *testing.T) domain.TaskRepository
+
+// RunTaskRepositoryTests runs the task repository conformance suite.
+func RunTaskRepositoryTests(t *testing.T, newRepo TaskRepositoryFactory) {
+    t.Run("GetTaskByIDMissing", func(t *testing.T) {
+        repo := newRepo(t)
+        _, found, err := repo.GetTaskByID(testOrg, primitive.NewObjectID())
+        if err != nil {
+            t.Fatalf("GetTaskByID: unexpected error %v", err)
+        }
+        if found {
+            t.Fatal("GetTaskByID: found a task that does not exist")
+        }
+    })
+
+    t.Run("AddAndGet", func(t *testing.T) {
+        repo := newRepo(t)
+        task := newTask("Write report", "pending", baseTime)
+        task.CreatedBy = primitive.NewObjectID()
+        mustAddTask(t, repo, task)
+
+        got, found, err := repo.GetTaskByID(testOrg, task.ID)
+        if err != nil || !found {
+            t.Fatalf("GetTaskByID: found=%v err=%v", found, err)
+        }
+        assertTaskEqual(t, got, task)
+    })
+
+    t.Run("AddDuplicate", func(t *testing.T) {
+        repo := newRepo(t)
+        task := newTask("Write report", "pending", baseTime)
+        mustAddTask(t, repo, task)
+        if err := repo.AddTask(task); !errors.Is(err, domain.ErrTaskExists) {
+            t.Fatalf("AddTask duplicate: got %v, want %v", err, domain.ErrTaskExists)
+        }
+    })
+
+    t.Run("UpdateMissing", func(t *testing.T) {
+        repo := newRepo(t)
+        task := newTask("Write report", "pending", baseTime)
+        if err := repo.UpdateTask(testOrg, task.ID, task); !errors.Is(err, domain.ErrTaskNotFound) {
+            t.Fatalf("UpdateTask: got %v, want %v", err, domain.ErrTaskNotFound)
+        }
+    })
+
+    t.Run("UpdateKeepsOwnership", func(t *testing.T) {
+        repo := newRepo(t)
+        task := newTask("Write report", "pending", baseTime)
+        task.CreatedBy = primitive.NewObjectID()
+        mustAddTask(t, repo, task)
+        assignee, reader := primitive.NewObjectID(), primitive.NewObjectID()
+        if err := repo.AssignTask(testOrg, task.ID, assignee); err != nil {
+            t.Fatalf("AssignTask: %v", err)
+        }
+        if err := repo.SetTaskVisibility(testOrg, task.ID, domain.VisibilityShared); err != nil {
+            t.Fatalf("SetTaskVisibility: %v", err)
+        }
+        if err := repo.ShareTask(testOrg, task.ID, reader); err != nil {
+            t.Fatalf("ShareTask: %v", err)
+        }
+
+        update := newTask("Write final report", "in-progress", baseTime.Add(time.Hour))
+        update.ID = task.ID
+        if err := repo.UpdateTask(testOrg, task.ID, update); err != nil {
+            t.Fatalf("UpdateTask: %v", err)
+        }
+
+        want := update
+        want.CreatedBy = task.CreatedBy
+        want.AssignedTo = &assignee
+        want.Visibility = domain.VisibilityShared
+        want.SharedWith = []primitive.ObjectID{reader}
+        got, _, _ := repo.GetTaskByID(testOrg, task.ID)
+        assertTaskEqual(t, got, want)
+    })
+
+    t.Run("Delete", func(t *testing.T) {
+        repo := newRepo(t)
+        task := newTask("Write report", "pending", baseTime)
+        mustAddTask(t, repo, task)
+        if err := repo.DeleteTask(testOrg, task.ID); err != nil {
+            t.Fatalf("DeleteTask: %v", err)
+        }
+        if _, found, _ := repo.GetTaskByID(testOrg, task.ID); found {
+            t.Fatal("GetTaskByID: task still present after delete")
+        }
+        if err := repo.DeleteTask(testOrg, task.ID); !errors.Is(err, mongo.ErrNoDocuments) {
+            t.Fatalf("DeleteTask missing: got %v, want %v", err, mongo.ErrNoDocuments)
+        }
+    })
+
+    t.Run("AssignAndUnassign", func(t *testing.T) {
+        repo := newRepo(t)
+        task := newTask("Write report", "pending", baseTime)
+        mustAddTask(t, repo, task)
+
+        assignee := primitive.NewObjectID()
+        if err := repo.AssignTask(testOrg, task.ID, assignee); err != nil {
+            t.Fatalf("AssignTask: %v", err)
+        }
+        got, _, _ := repo.GetTaskByID(testOrg, task.ID)
+        if got.AssignedTo == nil || *got.AssignedTo != assignee {
+            t.Fatalf("AssignTask: assigned_to = %v, want %v", got.AssignedTo, assignee)
+        }
+
+        if err := repo.UnassignTask(testOrg, task.ID); err != nil {
+            t.Fatalf("UnassignTask: %v", err)
+        }
+        got, _, _ = repo.GetTaskByID(testOrg, task.ID)
+        if got.AssignedTo != nil {
+            t.Fatalf("UnassignTask: assigned_to = %v, want none", got.AssignedTo)
+        }
+
+        missing := primitive.NewObjectID()
+        if err := repo.AssignTask(testOrg, missing, assignee); !errors.Is(err, domain.ErrTaskNotFound) {
+            t.Fatalf("AssignTask missing: got %v, want %v", err, domain.ErrTaskNotFound)
+        }
+        if err := repo.UnassignTask(testOrg, missing); !errors.Is(err, domain.ErrTaskNotFound) {
+            t.Fatalf("UnassignTask missing: got %v, want %v", err, domain.ErrTaskNotFound)
+        }
+    })
+
+    t.Run("VisibilityAndSharing", func(t *testing.T) {
+        repo := newRepo(t)
+        task := newTask("Write report", "pending", baseTime)
+        task.Visibility = domain.VisibilityPrivate
+        mustAddTask(t, repo, task)
+
+        bob, carol := primitive.NewObjectID(), primitive.NewObjectID()
+        if err := repo.SetTaskVisibility(testOrg, task.ID, domain.VisibilityShared); err != nil {
+            t.Fatalf("SetTaskVisibility: %v", err)
+        }
+        for _, userID := range []primitive.ObjectID{bob, carol, bob} {
+            if err := repo.ShareTask(testOrg, task.ID, userID); err != nil {
+                t.Fatalf("ShareTask: %v", err)
+            }
+        }
+        if err := repo.UnshareTask(testOrg, task.ID, carol); err != nil {
+            t.Fatalf("UnshareTask: %v", err)
+        }
+        want := task
+        want.Visibility = domain.VisibilityShared
+        want.SharedWith = []primitive.ObjectID{bob}
+        got, _, _ := repo.GetTaskByID(testOrg, task.ID)
+        assertTaskEqual(t, got, want)
+
+        missing := primitive.NewObjectID()
+        if err := repo.SetTaskVisibility(testOrg, missing, domain.VisibilityOrg); !errors.Is(err, domain.ErrTaskNotFound) {
+            t.Fatalf("SetTaskVisibility missing: got %v, want %v", err, domain.ErrTaskNotFound)
+        }
+        if err := repo.ShareTask(testOrg, missing, bob); !errors.Is(err, domain.ErrTaskNotFound) {
+            t.Fatalf("ShareTask missing: got %v, want %v", err, domain.ErrTaskNotFound)
+        }
+        if err := repo.UnshareTask(testOrg, missing, bob); !errors.Is(err, domain.ErrTaskNotFound) {
+            t.Fatalf("UnshareTask missing: got %v, want %v", err, domain.ErrTaskNotFound)
+        }
+    })
+
+    t.Run("GetTasksVisibleTo", func(t *testing.T) {
+        repo := newRepo(t)
+        alice, bob := primitive.NewObjectID(), primitive.NewObjectID()
+        legacy := newTask("legacy", "pending", baseTime)
+        public := newTask("public", "pending", baseTime.Add(time.Hour))
+        public.Visibility = domain.VisibilityOrg
+        own := newTask("own", "pending", baseTime.Add(2*time.Hour))
+        own.Visibility, own.CreatedBy = domain.VisibilityPrivate, bob
+        assigned := newTask("assigned", "pending", baseTime.Add(3*time.Hour))
+        assigned.Visibility, assigned.CreatedBy = domain.VisibilityPrivate, alice
+        shared := newTask("shared", "pending", baseTime.Add(4*time.Hour))
+        shared.Visibility, shared.CreatedBy = domain.VisibilityShared, alice
+        hidden := newTask("hidden", "pending", baseTime.Add(5*time.Hour))
+        hidden.Visibility, hidden.CreatedBy = domain.VisibilityPrivate, alice
+        // Sharing only counts while the task is shared.
+        unshared := newTask("unshared", "pending", baseTime.Add(6*time.Hour))
+        unshared.Visibility, unshared.CreatedBy = domain.VisibilityPrivate, alice
+        for _, task := range []domain.Task{legacy, public, own, assigned, shared, hidden, unshared} {
+            mustAddTask(t, repo, task)
+        }
+        if err := repo.AssignTask(testOrg, assigned.ID, bob); err != nil {
+            t.Fatalf("AssignTask: %v", err)
+        }
+        for _, id := range []primitive.ObjectID{shared.ID, unshared.ID} {
+            if err := repo.ShareTask(testOrg, id, bob); err != nil {
+                t.Fatalf("ShareTask: %v", err)
+            }
+        }
+
+        assertTaskIDs(t, "bob", collectPages(t, repo, domain.TaskQuery{OrgID: testOrg, VisibleTo: &bob, Limit: 2}), []domain.Task{legacy, public, own, assigned, shared})
+        assertTaskIDs(t, "alice", collectPages(t, repo, domain.TaskQuery{OrgID: testOrg, VisibleTo: &alice}), []domain.Task{legacy, public, assigned, shared, hidden, unshared})
+    })
+
+    t.Run("ScopedToOrganization", func(t *testing.T) {
+        repo := newRepo(t)
+        task := newTask("Write report", "pending", baseTime)
+        mustAddTask(t, repo, task)
+        other := newTask("Other report", "pending", baseTime)
+        other.OrgID = primitive.NewObjectID()
+        mustAddTask(t, repo, other)
+
+        assertTaskIDs(t, "testOrg", collectPages(t, repo, domain.TaskQuery{OrgID: testOrg}), []domain.Task{task})
+        assertTaskIDs(t, "other org", collectPages(t, repo, domain.TaskQuery{OrgID: other.OrgID}), []domain.Task{other})
+        if _, found, err := repo.GetTaskByID(testOrg, other.ID); err != nil || found {
+            t.Fatalf("GetTaskByID of another organization's task: found=%v err=%v", found, err)
+        }
+
+        userID := primitive.NewObjectID()
+        for name, err := range map[string]error{
+            "UpdateTask":        repo.UpdateTask(testOrg, other.ID, other),
+            "AssignTask":        repo.AssignTask(testOrg, other.ID, userID),
+            "UnassignTask":      repo.UnassignTask(testOrg, other.ID),
+            "SetTaskVisibility": repo.SetTaskVisibility(testOrg, other.ID, domain.VisibilityPrivate),
+            "ShareTask":         repo.ShareTask(testOrg, other.ID, userID),
+            "UnshareTask":       repo.UnshareTask(testOrg, other.ID, userID),
+        } {
+            if !errors.Is(err, domain.ErrTaskNotFound) {
+                t.Fatalf("%s of another organization's task: got %v, want %v", name, err, domain.ErrTaskNotFound)
+            }
+        }
+        if err := repo.DeleteTask(testOrg, other.ID); !errors.Is(err, mongo.ErrNoDocuments) {
+            t.Fatalf("DeleteTask of another organization's task: got %v, want %v", err, mongo.ErrNoDocuments)
+        }
+        got, _, _ := repo.GetTaskByID(other.OrgID, other.ID)
+        assertTaskEqual(t, got, other)
+    })
+
+    t.Run("AdoptOrphanTasks", func(t *testing.T) {
+        repo := newRepo(t)
+        orphan := newTask("Old report", "pending", baseTime)
+        orphan.OrgID = primitive.NilObjectID
+        mustAddTask(t, repo, orphan)
+        owned := newTask("Write report", "pending", baseTime)
+        mustAddTask(t, repo, owned)
+
+        org := primitive.NewObjectID()
+        if n, err := repo.AdoptOrphanTasks(org); err != nil || n != 1 {
+            t.Fatalf("AdoptOrphanTasks: n=%d err=%v, want 1", n, err)
+        }
+        want := orphan
+        want.OrgID = org
+        got, found, _ := repo.GetTaskByID(org, orphan.ID)
+        if !found {
+            t.Fatal("AdoptOrphanTasks: task not found in the organization")
+        }
+        assertTaskEqual(t, got, want)
+        if _, found, _ := repo.GetTaskByID(testOrg, owned.ID); !found {
+            t.Fatal("AdoptOrphanTasks moved a task that already had an organization")
+        }
+    })
+
+    t.Run("ReassignAndDeleteUserTasks", func(t *testing.T) {
+        repo := newRepo(t)
+        leaving, staying, other := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+        otherOrg := primitive.NewObjectID()
+        tasks := make([]domain.Task, 5)
+        for i, assignee := range []primitive.ObjectID{leaving, leaving, other, leaving, leaving} {
+            tasks[i] = newTask("task", "pending", baseTime)
+            if i == 4 {
+                tasks[i].OrgID = otherOrg
+            }
+            mustAddTask(t, repo, tasks[i])
+            if err := repo.AssignTask(tasks[i].OrgID, tasks[i].ID, assignee); err != nil {
+                t.Fatalf("AssignTask: %v", err)
+            }
+        }
+
+        if n, err := repo.ReassignUserTasks(leaving, staying, []primitive.ObjectID{testOrg}); err != nil || n != 3 {
+            t.Fatalf("ReassignUserTasks: n=%d err=%v, want 3", n, err)
+        }
+        if got, _, _ := repo.GetTaskByID(testOrg, tasks[0].ID); got.AssignedTo == nil || *got.AssignedTo != staying {
+            t.Fatalf("ReassignUserTasks: assigned_to = %v, want %v", got.AssignedTo, staying)
+        }
+        if got, _, _ := repo.GetTaskByID(testOrg, tasks[2].ID); got.AssignedTo == nil || *got.AssignedTo != other {
+            t.Fatalf("ReassignUserTasks moved another user's task to %v", got.AssignedTo)
+        }
+        if got, _, _ := repo.GetTaskByID(otherOrg, tasks[4].ID); got.AssignedTo == nil || *got.AssignedTo != leaving {
+            t.Fatalf("ReassignUserTasks moved a task of another organization to %v", got.AssignedTo)
+        }
+        if n, err := repo.ReassignUserTasks(leaving, staying, nil); err != nil || n != 0 {
+            t.Fatalf("ReassignUserTasks without organizations: n=%d err=%v, want 0", n, err)
+        }
+
+        if n, err := repo.UnassignUserTasks(leaving); err != nil || n != 1 {
+            t.Fatalf("UnassignUserTasks: n=%d err=%v, want 1", n, err)
+        }
+        if got, _, _ := repo.GetTaskByID(otherOrg, tasks[4].ID); got.AssignedTo != nil {
+            t.Fatalf("UnassignUserTasks: assigned_to = %v, want none", got.AssignedTo)
+        }
+        if n, err := repo.UnassignUserTasks(staying); err != nil || n != 3 {
+            t.Fatalf("UnassignUserTasks: n=%d err=%v, want 3", n, err)
+        }
+        if got, _, _ := repo.GetTaskByID(testOrg, tasks[1].ID); got.AssignedTo != nil {
+            t.Fatalf("UnassignUserTasks: assigned_to = %v, want none", got.AssignedTo)
+        }
+
+        if n, err := repo.DeleteUserTasks(other); err != nil || n != 1 {
+            t.Fatalf("DeleteUserTasks: n=%d err=%v, want 1", n, err)
+        }
+        if _, found, _ := repo.GetTaskByID(testOrg, tasks[2].ID); found {
+            t.Fatal("DeleteUserTasks: task still exists")
+        }
+        if _, found, _ := repo.GetTaskByID(testOrg, tasks[0].ID); !found {
+            t.Fatal("DeleteUserTasks deleted an unassigned task")
+        }
+    })
+
+    t.Run("GetTasksEmpty", func(t *testing.T) {
+        repo := newRepo(t)
+        page, err := repo.GetTasks(domain.TaskQuery{OrgID: testOrg})
+        if err != nil {
+            t.Fatalf("GetTasks: %v", err)
+        }
+        if len(page.Tasks) != 0 || page.NextCursor != "" {
+            t.Fatalf("GetTasks: got %d tasks and cursor %q from an empty repository", len(page.Tasks), page.NextCursor)
+        }
+    })
+
+    t.Run("GetTasksFilters", func(t *testing.T) {
+        repo := newRepo(t)
+        assignee := primitive.NewObjectID()
+        report := newTask("Quarterly Report", "pending", baseTime)
+        review := newTask("Review report draft", "in-progress", baseTime.Add(24*time.Hour))
+        deploy := newTask("Deploy", "completed", baseTime.Add(48*time.Hour))
+        for _, task := range []domain.Task{report, review, deploy} {
+            mustAddTask(t, repo, task)
+        }
+        if err := repo.AssignTask(testOrg, deploy.ID, assignee); err != nil {
+            t.Fatalf("AssignTask: %v", err)
+        }
+
+        cases := []struct {
+            name  string
+            query domain.TaskQuery
+            want  []domain.Task
+        }{
+            {"status", domain.TaskQuery{OrgID: testOrg, Status: "in-progress"}, []domain.Task{review}},
+            {"due_after", domain.TaskQuery{OrgID: testOrg, DueAfter: baseTime.Add(24 * time.Hour)}, []domain.Task{review, deploy}},
+            {"due_before", domain.TaskQuery{OrgID: testOrg, DueBefore: baseTime.Add(24 * time.Hour)}, []domain.Task{report, review}},
+            {"title", domain.TaskQuery{OrgID: testOrg, TitleContains: "REPORT"}, []domain.Task{report, review}},
+            {"title metacharacters", domain.TaskQuery{OrgID: testOrg, TitleContains: "rep.rt"}, nil},
+            {"assigned_to", domain.TaskQuery{OrgID: testOrg, AssignedTo: &assignee}, []domain.Task{deploy}},
+        }
+        for _, tc := range cases {
+            page, err := repo.GetTasks(tc.query)
+            if err != nil {
+                t.Fatalf("%s: GetTasks: %v", tc.name, err)
+            }
+            assertTaskIDs(t, tc.name, page.Tasks, tc.want)
+        }
+    })
+
+    t.Run("GetTasksSortAndPaginate", func(t *testing.T) {
+        repo := newRepo(t)
+        // Two tasks share each due date so that the ID tie-break is exercised.
+        var byDueDate []domain.Task
+        for i, title := range []string{"e", "d", "c", "b", "a"} {
+            task := newTask(title, "pending", baseTime.Add(time.Duration(i/2)*time.Hour))
+            mustAddTask(t, repo, task)
+            byDueDate = append(byDueDate, task)
+        }
+
+        for _, tc := range []struct {
+            name  string
+            query domain.TaskQuery
+            want  []domain.Task
+        }{
+            {"due_date", domain.TaskQuery{OrgID: testOrg, Limit: 2}, byDueDate},
+            {"due_date descending", domain.TaskQuery{OrgID: testOrg, Limit: 2, Descending: true}, reversed(byDueDate)},
+            {"title", domain.TaskQuery{OrgID: testOrg, Limit: 2, SortBy: domain.SortByTitle}, reversed(byDueDate)},
+            {"status", domain.TaskQuery{OrgID: testOrg, Limit: 3, SortBy: domain.SortByStatus}, byDueDate},
+            {"id descending", domain.TaskQuery{OrgID: testOrg, Limit: 4, SortBy: domain.SortByID, Descending: true}, reversed(byDueDate)},
+        } {
+            assertTaskIDs(t, tc.name, collectPages(t, repo, tc.query), tc.want)
+        }
+    })
+
+    t.Run("GetTasksRejectsBadCursor", func(t *testing.T) {
+        repo := newRepo(t)
+        for i := 0; i < 3; i++ {
+            mustAddTask(t, repo, newTask("task", "pending", baseTime))
+        }
+        page, err := repo.GetTasks(domain.TaskQuery{OrgID: testOrg, Limit: 1})
+        if err != nil || page.NextCursor == "" {
+            t.Fatalf("GetTasks: cursor=%q err=%v", page.NextCursor, err)
+        }
+
+        for _, query := range []domain.TaskQuery{
+            {OrgID: testOrg, Cursor: "not-a-cursor"},
+            {OrgID: testOrg, Cursor: page.NextCursor, SortBy: domain.SortByTitle},
+            {OrgID: testOrg, Cursor: page.NextCursor, Descending: true},
+        } {
+            if _, err := repo.GetTasks(query); !errors.Is(err, domain.ErrInvalidTaskQuery) {
+                t.Fatalf("GetTasks(%+v): got %v, want %v", query, err, domain.ErrInvalidTaskQuery)
+            }
+        }
+    })
+}
+
+var baseTime = time.Date(2024, time.August, 1, 9, 0, 0, 0, time.UTC)
+
+// testOrg is the organization newTask puts tasks in.
+var testOrg = primitive.NewObjectID()
+
+func newTask(title, status string, dueDate time.Time) domain.Task {
+    return domain.Task{
+        ID:          primitive.NewObjectID(),
+        OrgID:       testOrg,
+        Title:       title,
+        Description: title + " description",
+        DueDate:     dueDate,
+        Status:      status,
+    }
+}
+
+func mustAddTask(t *testing.T, repo domain.TaskRepository, task domain.Task) {
+    t.Helper()
+    if err := repo.AddTask(task); err != nil {
+        t.Fatalf("AddTask: %v", err)
+    }
+}
+
+func collectPages(t *testing.T, repo domain.TaskRepository, query domain.TaskQuery) []domain.Task {
+    t.Helper()
+    var tasks []domain.Task
+    for pages := 0; ; pages++ {
+        if pages > 10 {
+            t.Fatal("GetTasks: pagination did not terminate")
+        }
+        page, err := repo.GetTasks(query)
+        if err != nil {
+            t.Fatalf("GetTasks: %v", err)
+        }
+        tasks = append(tasks, page.Tasks...)
+        if page.NextCursor == "" {
+            return tasks
+        }
+        query.Cursor = page.NextCursor
+    }
+}
+
+func reversed(tasks []domain.Task) []domain.Task {
+    out := make([]domain.Task, len(tasks))
+    for i, task := range tasks {
+        out[len(tasks)-1-i] = task
+    }
+    return out
+}
+
+func assertTaskEqual(t *testing.T, got, want domain.Task) {
+    t.Helper()
+    if got.ID != want.ID || got.OrgID != want.OrgID || got.Title != want.Title || got.Description != want.Description ||
+        got.Status != want.Status || got.CreatedBy != want.CreatedBy || !got.DueDate.Equal(want.DueDate) {
+        t.Fatalf("task mismatch:\n got  %+v\n want %+v", got, want)
+    }
+    if (got.AssignedTo == nil) != (want.AssignedTo == nil) ||
+        (got.AssignedTo != nil && *got.AssignedTo != *want.AssignedTo) {
+        t.Fatalf("assigned_to mismatch: got %v, want %v", got.AssignedTo, want.AssignedTo)
+    }
+    if got.Visibility != want.Visibility || len(got.SharedWith) != len(want.SharedWith) ||
+        (len(want.SharedWith) > 0 && !slices.Equal(got.SharedWith, want.SharedWith)) {
+        t.Fatalf("visibility mismatch: got %q %v, want %q %v", got.Visibility, got.SharedWith, want.Visibility, want.SharedWith)
+    }
+}
+
+func assertTaskIDs(t *testing.T, name string, got, want []domain.Task) {
+    t.Helper()
+    if len(got) != len(want) {
+        t.Fatalf("%s: got %d tasks, want %d", name, len(got), len(want))
+    }
+    for i := range want {
+        if got[i].ID != want[i].ID {
+            t.Fatalf("%s: task %d is %q, want %q", name, i, got[i].Title, want[i].Title)
+        }
+    }
+}
@@ -0,0 +1,66 @@
+package repotest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TokenRevocationRepositoryFactory returns a new, empty repository for each call.
+type TokenRevocationRepositoryFactory func(t *testing.T) domain.TokenRevocationRepository
+
+// RunTokenRevocationRepositoryTests runs the token revocation repository conformance suite.
+func RunTokenRevocationRepositoryTests(t *testing.T, newRepo TokenRevocationRepositoryFactory) {
+    t.Run("RevokeToken", func(t *testing.T) {
+        repo := newRepo(t)
+        if revoked, err := repo.IsTokenRevoked("jti-1"); err != nil || revoked {
+            t.Fatalf("IsTokenRevoked before revoke: revoked=%v err=%v", revoked, err)
+        }
+        if err := repo.RevokeToken("jti-1", time.Now().Add(time.Hour)); err != nil {
+            t.Fatalf("RevokeToken: %v", err)
+        }
+        if err := repo.RevokeToken("jti-1", time.Now().Add(time.Hour)); err != nil {
+            t.Fatalf("RevokeToken twice: %v", err)
+        }
+        if revoked, err := repo.IsTokenRevoked("jti-1"); err != nil || !revoked {
+            t.Fatalf("IsTokenRevoked: revoked=%v err=%v, want true", revoked, err)
+        }
+        if revoked, _ := repo.IsTokenRevoked("jti-2"); revoked {
+            t.Fatal("IsTokenRevoked: unrelated token reported as revoked")
+        }
+    })
+
+    t.Run("ExpiredEntriesIgnored", func(t *testing.T) {
+        repo := newRepo(t)
+        if err := repo.RevokeToken("jti-1", time.Now().Add(-time.Minute)); err != nil {
+            t.Fatalf("RevokeToken: %v", err)
+        }
+        if revoked, _ := repo.IsTokenRevoked("jti-1"); revoked {
+            t.Fatal("IsTokenRevoked: entry for an expired token should not matter")
+        }
+    })
+
+    t.Run("UserCutoffOnlyMovesForward", func(t *testing.T) {
+        repo := newRepo(t)
+        user := primitive.NewObjectID()
+        if cutoff, err := repo.UserTokensRevokedBefore(user); err != nil || !cutoff.IsZero() {
+            t.Fatalf("UserTokensRevokedBefore: cutoff=%v err=%v, want zero", cutoff, err)
+        }
+
+        later := baseTime.Add(time.Hour)
+        if err := repo.RevokeUserTokens(user, later); err != nil {
+            t.Fatalf("RevokeUserTokens: %v", err)
+        }
+        if err := repo.RevokeUserTokens(user, baseTime); err != nil {
+            t.Fatalf("RevokeUserTokens earlier: %v", err)
+        }
+        if cutoff, _ := repo.UserTokensRevokedBefore(user); !cutoff.Equal(later) {
+            t.Fatalf("UserTokensRevokedBefore: got %v, want %v", cutoff, later)
+        }
+        if cutoff, _ := repo.UserTokensRevokedBefore(primitive.NewObjectID()); !cutoff.IsZero() {
+            t.Fatalf("UserTokensRevokedBefore other user: got %v, want zero", cutoff)
+        }
+    })
+}
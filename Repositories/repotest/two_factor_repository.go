@@ -0,0 +1,120 @@
+package repotest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TwoFactorRepositoryFactory returns a new, empty repository for each call.
+type TwoFactorRepositoryFactory func(t *testing.T) domain.TwoFactorRepository
+
+// RunTwoFactorRepositoryTests runs the two-factor repository conformance suite.
+func RunTwoFactorRepositoryTests(t *testing.T, newRepo TwoFactorRepositoryFactory) {
+    t.Run("SaveAndGet", func(t *testing.T) {
+        repo := newRepo(t)
+        user := primitive.NewObjectID()
+        if _, err := repo.GetTwoFactor(user); !errors.Is(err, domain.ErrTwoFactorNotFound) {
+            t.Fatalf("GetTwoFactor missing: got %v, want %v", err, domain.ErrTwoFactorNotFound)
+        }
+        mustSaveTwoFactor(t, repo, user, "FIRST")
+        mustSaveTwoFactor(t, repo, user, "SECOND")
+
+        got, err := repo.GetTwoFactor(user)
+        if err != nil {
+            t.Fatalf("GetTwoFactor: %v", err)
+        }
+        if got.UserID != user || got.Secret != "SECOND" || got.Enabled || !got.CreatedAt.Equal(baseTime) {
+            t.Fatalf("GetTwoFactor: got %+v, want the second pending enrollment", got)
+        }
+    })
+
+    t.Run("Enable", func(t *testing.T) {
+        repo := newRepo(t)
+        user := primitive.NewObjectID()
+        if err := repo.EnableTwoFactor(user, []string{"a"}); !errors.Is(err, domain.ErrTwoFactorNotFound) {
+            t.Fatalf("EnableTwoFactor missing: got %v, want %v", err, domain.ErrTwoFactorNotFound)
+        }
+        mustSaveTwoFactor(t, repo, user, "SECRET")
+        if err := repo.EnableTwoFactor(user, []string{"a", "b"}); err != nil {
+            t.Fatalf("EnableTwoFactor: %v", err)
+        }
+
+        got, _ := repo.GetTwoFactor(user)
+        if !got.Enabled || len(got.RecoveryCodes) != 2 {
+            t.Fatalf("EnableTwoFactor: got %+v, want enabled with two recovery codes", got)
+        }
+        err := repo.SaveTwoFactor(&domain.TwoFactor{UserID: user, Secret: "OTHER", CreatedAt: baseTime})
+        if !errors.Is(err, domain.ErrTwoFactorAlreadyEnabled) {
+            t.Fatalf("SaveTwoFactor over enabled: got %v, want %v", err, domain.ErrTwoFactorAlreadyEnabled)
+        }
+        if got, _ := repo.GetTwoFactor(user); got.Secret != "SECRET" {
+            t.Fatalf("SaveTwoFactor over enabled: secret = %q, want SECRET", got.Secret)
+        }
+    })
+
+    t.Run("Delete", func(t *testing.T) {
+        repo := newRepo(t)
+        user := primitive.NewObjectID()
+        mustSaveTwoFactor(t, repo, user, "SECRET")
+        if err := repo.DeleteTwoFactor(user); err != nil {
+            t.Fatalf("DeleteTwoFactor: %v", err)
+        }
+        if _, err := repo.GetTwoFactor(user); !errors.Is(err, domain.ErrTwoFactorNotFound) {
+            t.Fatalf("GetTwoFactor after delete: got %v, want %v", err, domain.ErrTwoFactorNotFound)
+        }
+        if err := repo.DeleteTwoFactor(user); !errors.Is(err, domain.ErrTwoFactorNotFound) {
+            t.Fatalf("DeleteTwoFactor missing: got %v, want %v", err, domain.ErrTwoFactorNotFound)
+        }
+    })
+
+    t.Run("UseTOTPStep", func(t *testing.T) {
+        repo := newRepo(t)
+        user := primitive.NewObjectID()
+        mustSaveTwoFactor(t, repo, user, "SECRET")
+
+        for _, tc := range []struct {
+            step int64
+            want bool
+        }{{100, true}, {100, false}, {99, false}, {101, true}} {
+            if used, err := repo.UseTOTPStep(user, tc.step); err != nil || used != tc.want {
+                t.Fatalf("UseTOTPStep(%d): used=%v err=%v, want %v", tc.step, used, err, tc.want)
+            }
+        }
+        if used, _ := repo.UseTOTPStep(primitive.NewObjectID(), 1); used {
+            t.Fatal("UseTOTPStep: used a step for a user without 2FA")
+        }
+    })
+
+    t.Run("UseRecoveryCode", func(t *testing.T) {
+        repo := newRepo(t)
+        user := primitive.NewObjectID()
+        mustSaveTwoFactor(t, repo, user, "SECRET")
+        if err := repo.EnableTwoFactor(user, []string{"a", "b", "c"}); err != nil {
+            t.Fatalf("EnableTwoFactor: %v", err)
+        }
+
+        for _, tc := range []struct {
+            hash string
+            want bool
+        }{{"b", true}, {"b", false}, {"missing", false}} {
+            if used, err := repo.UseRecoveryCode(user, tc.hash); err != nil || used != tc.want {
+                t.Fatalf("UseRecoveryCode(%q): used=%v err=%v, want %v", tc.hash, used, err, tc.want)
+            }
+        }
+        got, _ := repo.GetTwoFactor(user)
+        if len(got.RecoveryCodes) != 2 || got.RecoveryCodes[0] != "a" || got.RecoveryCodes[1] != "c" {
+            t.Fatalf("UseRecoveryCode: remaining codes %v, want [a c]", got.RecoveryCodes)
+        }
+    })
+}
+
+func mustSaveTwoFactor(t *testing.T, repo domain.TwoFactorRepository, userID primitive.ObjectID, secret string) {
+    t.Helper()
+    twoFactor := &domain.TwoFactor{UserID: userID, Secret: secret, RecoveryCodes: []string{}, CreatedAt: baseTime}
+    if err := repo.SaveTwoFactor(twoFactor); err != nil {
+        t.Fatalf("SaveTwoFactor: %v", err)
+    }
+}
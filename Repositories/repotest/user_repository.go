@@ -0,0 +1,393 @@
+package repotest
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserRepositoryFactory returns a new, empty repository for each call.
+type UserRepositoryFactory func(t *testing.T) domain.UserRepository
+
+// RunUserRepositoryTests runs the user repository conformance suite.
+func RunUserRepositoryTests(t *testing.T, newRepo UserRepositoryFactory) {
+    t.Run("CreateStoresRole", func(t *testing.T) {
+        repo := newRepo(t)
+        first := mustCreateUser(t, repo, "alice")
+        admin := mustCreateAdmin(t, repo, "bob")
+        if first.Role != domain.RoleUser || admin.Role != domain.RoleAdmin {
+            t.Fatalf("roles: first=%q admin=%q, want user and admin", first.Role, admin.Role)
+        }
+
+        stored, err := repo.GetUserByUsername("bob")
+        if err != nil {
+            t.Fatalf("GetUserByUsername: %v", err)
+        }
+        if stored.ID != admin.ID || stored.Role != domain.RoleAdmin || stored.Password != admin.Password {
+            t.Fatalf("GetUserByUsername: got %+v, want %+v", stored, admin)
+        }
+    })
+
+    t.Run("CreateAssignsID", func(t *testing.T) {
+        repo := newRepo(t)
+        if user := mustCreateUser(t, repo, "alice"); user.ID.IsZero() {
+            t.Fatal("CreateUser: user ID was not set")
+        }
+    })
+
+    t.Run("CreateDuplicate", func(t *testing.T) {
+        repo := newRepo(t)
+        mustCreateUser(t, repo, "alice")
+        err := repo.CreateUser(&domain.User{Username: "alice", Password: "other"})
+        if !errors.Is(err, domain.ErrUserExists) {
+            t.Fatalf("CreateUser duplicate: got %v, want %v", err, domain.ErrUserExists)
+        }
+    })
+
+    t.Run("ConcurrentCreate", func(t *testing.T) {
+        repo := newRepo(t)
+        var wg sync.WaitGroup
+        results := make(chan error, 10)
+        for i := 0; i < 10; i++ {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                results <- repo.CreateUser(&domain.User{Username: "alice", Password: "hash"})
+            }()
+        }
+        wg.Wait()
+        close(results)
+
+        created := 0
+        for err := range results {
+            switch {
+            case err == nil:
+                created++
+            case !errors.Is(err, domain.ErrUserExists):
+                t.Fatalf("CreateUser: %v", err)
+            }
+        }
+        if created != 1 {
+            t.Fatalf("ConcurrentCreate: %d users created, want 1", created)
+        }
+    })
+
+    t.Run("GetMissing", func(t *testing.T) {
+        repo := newRepo(t)
+        if _, err := repo.GetUserByUsername("nobody"); !errors.Is(err, domain.ErrUserNotFound) {
+            t.Fatalf("GetUserByUsername: got %v, want %v", err, domain.ErrUserNotFound)
+        }
+    })
+
+    t.Run("GetByID", func(t *testing.T) {
+        repo := newRepo(t)
+        created := mustCreateUser(t, repo, "alice")
+        user, err := repo.GetUserByID(created.ID)
+        if err != nil {
+            t.Fatalf("GetUserByID: %v", err)
+        }
+        if user.Username != "alice" {
+            t.Fatalf("GetUserByID: username = %q, want alice", user.Username)
+        }
+        if _, err := repo.GetUserByID(primitive.NewObjectID()); !errors.Is(err, domain.ErrUserNotFound) {
+            t.Fatalf("GetUserByID missing: got %v, want %v", err, domain.ErrUserNotFound)
+        }
+    })
+
+    t.Run("ExternalID", func(t *testing.T) {
+        repo := newRepo(t)
+        alice := mustCreateUser(t, repo, "alice")
+        bob := mustCreateUser(t, repo, "bob")
+
+        if _, err := repo.GetUserByExternalID("idp|alice"); !errors.Is(err, domain.ErrUserNotFound) {
+            t.Fatalf("GetUserByExternalID before linking: got %v, want %v", err, domain.ErrUserNotFound)
+        }
+        if _, err := repo.GetUserByExternalID(""); !errors.Is(err, domain.ErrUserNotFound) {
+            t.Fatalf("GetUserByExternalID of an empty subject: got %v, want %v", err, domain.ErrUserNotFound)
+        }
+        if err := repo.SetExternalID(alice.ID, "idp|alice"); err != nil {
+            t.Fatalf("SetExternalID: %v", err)
+        }
+        user, err := repo.GetUserByExternalID("idp|alice")
+        if err != nil {
+            t.Fatalf("GetUserByExternalID: %v", err)
+        }
+        if user.ID != alice.ID || user.ExternalID != "idp|alice" {
+            t.Fatalf("GetUserByExternalID: got %+v, want alice", user)
+        }
+        if err := repo.SetExternalID(bob.ID, "idp|alice"); !errors.Is(err, domain.ErrIdentityConflict) {
+            t.Fatalf("SetExternalID of a linked subject: got %v, want %v", err, domain.ErrIdentityConflict)
+        }
+        if err := repo.SetExternalID(alice.ID, "idp|alice"); err != nil {
+            t.Fatalf("SetExternalID again: %v", err)
+        }
+        // Any number of users can be unlinked.
+        for _, user := range []*domain.User{alice, bob} {
+            if err := repo.SetExternalID(user.ID, ""); err != nil {
+                t.Fatalf("SetExternalID to unlink %s: %v", user.Username, err)
+            }
+        }
+        if _, err := repo.GetUserByExternalID("idp|alice"); !errors.Is(err, domain.ErrUserNotFound) {
+            t.Fatalf("GetUserByExternalID after unlinking: got %v, want %v", err, domain.ErrUserNotFound)
+        }
+        if err := repo.SetExternalID(primitive.NewObjectID(), "idp|carol"); !errors.Is(err, domain.ErrUserNotFound) {
+            t.Fatalf("SetExternalID missing: got %v, want %v", err, domain.ErrUserNotFound)
+        }
+
+        linked := &domain.User{Username: "carol", ExternalID: "idp|carol"}
+        if err := repo.CreateUser(linked); err != nil {
+            t.Fatalf("CreateUser with an external ID: %v", err)
+        }
+        if user, err := repo.GetUserByExternalID("idp|carol"); err != nil || user.ID != linked.ID {
+            t.Fatalf("GetUserByExternalID of a created user: got %+v, err=%v", user, err)
+        }
+    })
+
+    t.Run("Promote", func(t *testing.T) {
+        repo := newRepo(t)
+        mustCreateUser(t, repo, "alice")
+        mustCreateUser(t, repo, "bob")
+
+        if err := repo.PromoteUser("bob"); err != nil {
+            t.Fatalf("PromoteUser: %v", err)
+        }
+        if user, _ := repo.GetUserByUsername("bob"); user.Role != "admin" {
+            t.Fatalf("PromoteUser: role = %q, want admin", user.Role)
+        }
+        if err := repo.PromoteUser("bob"); !errors.Is(err, domain.ErrAlreadyAdmin) {
+            t.Fatalf("PromoteUser admin: got %v, want %v", err, domain.ErrAlreadyAdmin)
+        }
+        if err := repo.PromoteUser("nobody"); !errors.Is(err, domain.ErrUserNotFound) {
+            t.Fatalf("PromoteUser missing: got %v, want %v", err, domain.ErrUserNotFound)
+        }
+    })
+
+    t.Run("UpdatePassword", func(t *testing.T) {
+        repo := newRepo(t)
+        alice := mustCreateUser(t, repo, "alice")
+        bob := mustCreateUser(t, repo, "bob")
+
+        if err := repo.UpdatePassword(alice.ID, "new-hash"); err != nil {
+            t.Fatalf("UpdatePassword: %v", err)
+        }
+        if user, _ := repo.GetUserByUsername("alice"); user.Password != "new-hash" || user.Role != alice.Role {
+            t.Fatalf("UpdatePassword: got %+v, want password new-hash and role %q", user, alice.Role)
+        }
+        if user, _ := repo.GetUserByUsername("bob"); user.Password != bob.Password {
+            t.Fatalf("UpdatePassword changed another user's password to %q", user.Password)
+        }
+        if err := repo.UpdatePassword(primitive.NewObjectID(), "hash"); !errors.Is(err, domain.ErrUserNotFound) {
+            t.Fatalf("UpdatePassword missing: got %v, want %v", err, domain.ErrUserNotFound)
+        }
+    })
+
+    t.Run("ListUsers", func(t *testing.T) {
+        repo := newRepo(t)
+        mustCreateAdmin(t, repo, "dave")
+        for _, name := range []string{"alice", "carol", "bob", "erin"} {
+            mustCreateUser(t, repo, name)
+        }
+        if err := repo.SetUserDeactivated("carol", true); err != nil {
+            t.Fatalf("SetUserDeactivated: %v", err)
+        }
+
+        var names []string
+        query := domain.UserQuery{Limit: 2}
+        for pages := 0; ; pages++ {
+            if pages > 5 {
+                t.Fatal("ListUsers: too many pages")
+            }
+            page, err := repo.ListUsers(query)
+            if err != nil {
+                t.Fatalf("ListUsers: %v", err)
+            }
+            for _, user := range page.Users {
+                names = append(names, user.Username)
+            }
+            if page.NextCursor == "" {
+                break
+            }
+            query.Cursor = page.NextCursor
+        }
+        if got := strings.Join(names, ","); got != "alice,bob,carol,dave,erin" {
+            t.Fatalf("ListUsers: got %s, want alice,bob,carol,dave,erin", got)
+        }
+
+        for _, tc := range []struct {
+            query domain.UserQuery
+            want  string
+        }{
+            {domain.UserQuery{Role: domain.RoleAdmin}, "dave"},
+            {domain.UserQuery{Status: domain.UserStatusDeactivated}, "carol"},
+            {domain.UserQuery{Role: domain.RoleUser, Status: domain.UserStatusActive}, "alice,bob,erin"},
+        } {
+            page, err := repo.ListUsers(tc.query)
+            if err != nil {
+                t.Fatalf("ListUsers(%+v): %v", tc.query, err)
+            }
+            var got []string
+            for _, user := range page.Users {
+                got = append(got, user.Username)
+            }
+            if strings.Join(got, ",") != tc.want {
+                t.Fatalf("ListUsers(%+v): got %v, want %s", tc.query, got, tc.want)
+            }
+        }
+
+        if _, err := repo.ListUsers(domain.UserQuery{Cursor: "not-a-cursor"}); !errors.Is(err, domain.ErrInvalidUserQuery) {
+            t.Fatalf("ListUsers bad cursor: got %v, want %v", err, domain.ErrInvalidUserQuery)
+        }
+    })
+
+    t.Run("SetUserRole", func(t *testing.T) {
+        repo := newRepo(t)
+        mustCreateAdmin(t, repo, "alice")
+        mustCreateUser(t, repo, "bob")
+
+        if err := repo.SetUserRole("alice", domain.RoleUser); !errors.Is(err, domain.ErrLastAdmin) {
+            t.Fatalf("SetUserRole last admin: got %v, want %v", err, domain.ErrLastAdmin)
+        }
+        if user, _ := repo.GetUserByUsername("alice"); user.Role != domain.RoleAdmin {
+            t.Fatalf("SetUserRole last admin: role = %q, want admin", user.Role)
+        }
+        if err := repo.SetUserRole("bob", domain.RoleAdmin); err != nil {
+            t.Fatalf("SetUserRole promote: %v", err)
+        }
+        if err := repo.SetUserRole("alice", domain.RoleUser); err != nil {
+            t.Fatalf("SetUserRole demote: %v", err)
+        }
+        if user, _ := repo.GetUserByUsername("alice"); user.Role != domain.RoleUser {
+            t.Fatalf("SetUserRole demote: role = %q, want user", user.Role)
+        }
+        if err := repo.SetUserRole("nobody", domain.RoleUser); !errors.Is(err, domain.ErrUserNotFound) {
+            t.Fatalf("SetUserRole missing: got %v, want %v", err, domain.ErrUserNotFound)
+        }
+    })
+
+    t.Run("SetUserDeactivated", func(t *testing.T) {
+        repo := newRepo(t)
+        mustCreateAdmin(t, repo, "alice")
+        mustCreateUser(t, repo, "bob")
+        if err := repo.PromoteUser("bob"); err != nil {
+            t.Fatalf("PromoteUser: %v", err)
+        }
+
+        if err := repo.SetUserDeactivated("bob", true); err != nil {
+            t.Fatalf("SetUserDeactivated: %v", err)
+        }
+        if user, _ := repo.GetUserByUsername("bob"); !user.Deactivated {
+            t.Fatal("SetUserDeactivated: user is still active")
+        }
+        // bob is deactivated, so alice is the last active admin.
+        if err := repo.SetUserDeactivated("alice", true); !errors.Is(err, domain.ErrLastAdmin) {
+            t.Fatalf("SetUserDeactivated last admin: got %v, want %v", err, domain.ErrLastAdmin)
+        }
+        if err := repo.SetUserRole("alice", domain.RoleUser); !errors.Is(err, domain.ErrLastAdmin) {
+            t.Fatalf("SetUserRole last active admin: got %v, want %v", err, domain.ErrLastAdmin)
+        }
+        if user, _ := repo.GetUserByUsername("alice"); user.Deactivated || user.Role != domain.RoleAdmin {
+            t.Fatalf("rejected changes were applied: %+v", user)
+        }
+
+        if err := repo.SetUserDeactivated("bob", false); err != nil {
+            t.Fatalf("SetUserDeactivated reactivate: %v", err)
+        }
+        if user, _ := repo.GetUserByUsername("bob"); user.Deactivated {
+            t.Fatal("SetUserDeactivated: user was not reactivated")
+        }
+        if err := repo.SetUserDeactivated("nobody", true); !errors.Is(err, domain.ErrUserNotFound) {
+            t.Fatalf("SetUserDeactivated missing: got %v, want %v", err, domain.ErrUserNotFound)
+        }
+    })
+
+    t.Run("DeleteUser", func(t *testing.T) {
+        repo := newRepo(t)
+        alice := mustCreateAdmin(t, repo, "alice")
+        mustCreateUser(t, repo, "bob")
+
+        if err := repo.DeleteUser("alice"); !errors.Is(err, domain.ErrLastAdmin) {
+            t.Fatalf("DeleteUser last admin: got %v, want %v", err, domain.ErrLastAdmin)
+        }
+        if user, err := repo.GetUserByID(alice.ID); err != nil || user.Username != "alice" || user.Password != alice.Password {
+            t.Fatalf("DeleteUser last admin: user not kept intact: %+v, %v", user, err)
+        }
+        if err := repo.DeleteUser("bob"); err != nil {
+            t.Fatalf("DeleteUser: %v", err)
+        }
+        if _, err := repo.GetUserByUsername("bob"); !errors.Is(err, domain.ErrUserNotFound) {
+            t.Fatalf("GetUserByUsername deleted: got %v, want %v", err, domain.ErrUserNotFound)
+        }
+        if err := repo.DeleteUser("bob"); !errors.Is(err, domain.ErrUserNotFound) {
+            t.Fatalf("DeleteUser missing: got %v, want %v", err, domain.ErrUserNotFound)
+        }
+    })
+
+    t.Run("ConcurrentAdminRemoval", func(t *testing.T) {
+        removals := []struct {
+            name   string
+            remove func(repo domain.UserRepository, username string) error
+        }{
+            {"SetUserRole", func(repo domain.UserRepository, username string) error { return repo.SetUserRole(username, domain.RoleUser) }},
+            {"SetUserDeactivated", func(repo domain.UserRepository, username string) error { return repo.SetUserDeactivated(username, true) }},
+            {"DeleteUser", func(repo domain.UserRepository, username string) error { return repo.DeleteUser(username) }},
+        }
+        for _, removal := range removals {
+            repo := newRepo(t)
+            admins := []*domain.User{mustCreateAdmin(t, repo, "alice"), mustCreateAdmin(t, repo, "bob")}
+
+            // Removing both admins at once must leave at least one, and
+            // undo the rejected removal completely.
+            errs := make([]error, len(admins))
+            var wg sync.WaitGroup
+            for i, admin := range admins {
+                wg.Add(1)
+                go func(i int, username string) {
+                    defer wg.Done()
+                    errs[i] = removal.remove(repo, username)
+                }(i, admin.Username)
+            }
+            wg.Wait()
+
+            kept := 0
+            for i, err := range errs {
+                if err == nil {
+                    continue
+                }
+                if !errors.Is(err, domain.ErrLastAdmin) {
+                    t.Fatalf("%s: %v", removal.name, err)
+                }
+                user, err := repo.GetUserByID(admins[i].ID)
+                if err != nil || user.Role != domain.RoleAdmin || user.Deactivated || user.Password != admins[i].Password {
+                    t.Fatalf("%s: rejected removal not undone: %+v, %v", removal.name, user, err)
+                }
+                kept++
+            }
+            if kept == 0 {
+                t.Fatalf("%s: both admins were removed", removal.name)
+            }
+        }
+    })
+}
+
+func mustCreateUser(t *testing.T, repo domain.UserRepository, username string) *domain.User {
+    t.Helper()
+    return mustCreateUserWithRole(t, repo, username, "")
+}
+
+func mustCreateAdmin(t *testing.T, repo domain.UserRepository, username string) *domain.User {
+    t.Helper()
+    return mustCreateUserWithRole(t, repo, username, domain.RoleAdmin)
+}
+
+func mustCreateUserWithRole(t *testing.T, repo domain.UserRepository, username, role string) *domain.User {
+    t.Helper()
+    user := &domain.User{Username: username, Password: "hashed-" + username, Role: role}
+    if err := repo.CreateUser(user); err != nil {
+        t.Fatalf("CreateUser(%q): %v", username, err)
+    }
+    return user
+}
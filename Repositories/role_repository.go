@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Roles are stored with their name as _id, so names are unique.
+type MongoRoleRepository struct {
+    collection *mongo.Collection
+}
+
+func NewMongoRoleRepository(collection *mongo.Collection) domain.RoleRepository {
+    return &MongoRoleRepository{collection: collection}
+}
+
+func (r *MongoRoleRepository) CreateRole(role *domain.Role) error {
+    _, err := r.collection.InsertOne(context.TODO(), role)
+    if mongo.IsDuplicateKeyError(err) {
+        return domain.ErrRoleExists
+    }
+    return err
+}
+
+func (r *MongoRoleRepository) GetRole(name string) (*domain.Role, error) {
+    role := &domain.Role{}
+    err := r.collection.FindOne(context.TODO(), bson.M{"_id": name}).Decode(role)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, domain.ErrRoleNotFound
+        }
+        return nil, err
+    }
+    return role, nil
+}
+
+func (r *MongoRoleRepository) ListRoles() ([]domain.Role, error) {
+    cursor, err := r.collection.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+    if err != nil {
+        return nil, err
+    }
+    roles := []domain.Role{}
+    if err := cursor.All(context.TODO(), &roles); err != nil {
+        return nil, err
+    }
+    return roles, nil
+}
+
+func (r *MongoRoleRepository) UpdateRole(role *domain.Role) error {
+    result, err := r.collection.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": role.Name},
+        bson.M{"$set": bson.M{"description": role.Description, "permissions": role.Permissions}},
+    )
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return domain.ErrRoleNotFound
+    }
+    return nil
+}
+
+func (r *MongoRoleRepository) DeleteRole(name string) error {
+    result, err := r.collection.DeleteOne(context.TODO(), bson.M{"_id": name})
+    if err != nil {
+        return err
+    }
+    if result.DeletedCount == 0 {
+        return domain.ErrRoleNotFound
+    }
+    return nil
+}
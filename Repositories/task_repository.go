@@ -2,12 +2,14 @@ package repositories
 
 import (
 	"context"
-	"errors"
+	"regexp"
+	"time"
 
 	"github.com/Hailemari/clean_architecture_task_manager/Domain"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoTaskRepository struct {
@@ -18,32 +20,117 @@ func NewMongoTaskRepository(collection *mongo.Collection) domain.TaskRepository{
     return &MongoTaskRepository{collection: collection}
 }
 
-func (r *MongoTaskRepository) GetTasks() ([]domain.Task, error) {
-    var tasks []domain.Task
-    cursor, err := r.collection.Find(context.TODO(), bson.D{{}})
+var taskSortFields = map[string]string{
+    domain.SortByDueDate: "duedate",
+    domain.SortByTitle:   "title",
+    domain.SortByStatus:  "status",
+    domain.SortByID:      "_id",
+}
+
+func (r *MongoTaskRepository) GetTasks(query domain.TaskQuery) (domain.TaskPage, error) {
+    if err := query.Normalize(); err != nil {
+        return domain.TaskPage{}, err
+    }
+    filter, err := taskQueryFilter(query)
+    if err != nil {
+        return domain.TaskPage{}, err
+    }
+
+    direction := 1
+    if query.Descending {
+        direction = -1
+    }
+    sortField := taskSortFields[query.SortBy]
+    sort := bson.D{{Key: sortField, Value: direction}}
+    if sortField != "_id" {
+        sort = append(sort, bson.E{Key: "_id", Value: direction})
+    }
+
+    // Fetch one extra document to find out whether there is a next page.
+    opts := options.Find().SetSort(sort).SetLimit(int64(query.Limit) + 1)
+    cursor, err := r.collection.Find(context.TODO(), filter, opts)
     if err != nil {
-        return nil, err
+        return domain.TaskPage{}, err
     }
     defer cursor.Close(context.TODO())
 
-    for cursor.Next(context.TODO()) {
-        var task domain.Task
-        if err := cursor.Decode(&task); err != nil {
+    tasks := []domain.Task{}
+    if err := cursor.All(context.TODO(), &tasks); err != nil {
+        return domain.TaskPage{}, err
+    }
+
+    page := domain.TaskPage{Tasks: tasks}
+    if len(tasks) > query.Limit {
+        page.Tasks = tasks[:query.Limit]
+        page.NextCursor = query.EncodeCursor(page.Tasks[query.Limit-1])
+    }
+    return page, nil
+}
+
+func taskQueryFilter(query domain.TaskQuery) (bson.D, error) {
+    conditions := bson.A{bson.D{{Key: "org_id", Value: query.OrgID}}}
+    if query.Status != "" {
+        conditions = append(conditions, bson.D{{Key: "status", Value: query.Status}})
+    }
+    if !query.DueAfter.IsZero() {
+        conditions = append(conditions, bson.D{{Key: "duedate", Value: bson.D{{Key: "$gte", Value: query.DueAfter}}}})
+    }
+    if !query.DueBefore.IsZero() {
+        conditions = append(conditions, bson.D{{Key: "duedate", Value: bson.D{{Key: "$lte", Value: query.DueBefore}}}})
+    }
+    if query.TitleContains != "" {
+        pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query.TitleContains), Options: "i"}
+        conditions = append(conditions, bson.D{{Key: "title", Value: pattern}})
+    }
+    if query.AssignedTo != nil {
+        conditions = append(conditions, bson.D{{Key: "assigned_to", Value: *query.AssignedTo}})
+    }
+    if query.VisibleTo != nil {
+        // Mirrors Task.VisibleTo.
+        conditions = append(conditions, bson.D{{Key: "$or", Value: bson.A{
+            bson.D{{Key: "visibility", Value: domain.VisibilityOrg}},
+            bson.D{{Key: "visibility", Value: bson.D{{Key: "$exists", Value: false}}}},
+            bson.D{{Key: "created_by", Value: *query.VisibleTo}},
+            bson.D{{Key: "assigned_to", Value: *query.VisibleTo}},
+            bson.D{{Key: "visibility", Value: domain.VisibilityShared}, {Key: "shared_with", Value: *query.VisibleTo}},
+        }}})
+    }
+
+    if query.Cursor != "" {
+        position, err := query.DecodeCursor()
+        if err != nil {
             return nil, err
         }
-        tasks = append(tasks, task)
+        op := "$gt"
+        if query.Descending {
+            op = "$lt"
+        }
+        sortField := taskSortFields[query.SortBy]
+        if sortField == "_id" {
+            conditions = append(conditions, bson.D{{Key: "_id", Value: bson.D{{Key: op, Value: position.ID}}}})
+        } else {
+            var value interface{} = position.Value
+            if query.SortBy == domain.SortByDueDate {
+                value, _ = time.Parse(time.RFC3339Nano, position.Value)
+            }
+            conditions = append(conditions, bson.D{{Key: "$or", Value: bson.A{
+                bson.D{{Key: sortField, Value: bson.D{{Key: op, Value: value}}}},
+                bson.D{{Key: sortField, Value: value}, {Key: "_id", Value: bson.D{{Key: op, Value: position.ID}}}},
+            }}})
+        }
     }
 
-    if err := cursor.Err(); err != nil {
-        return nil, err
-    }
+    return bson.D{{Key: "$and", Value: conditions}}, nil
+}
 
-    return tasks, nil
+// taskFilter matches the task only within its organization.
+func taskFilter(orgID, id primitive.ObjectID) bson.D {
+    return bson.D{{Key: "_id", Value: id}, {Key: "org_id", Value: orgID}}
 }
 
-func (r *MongoTaskRepository) GetTaskByID(id primitive.ObjectID) (domain.Task, bool, error) {
+func (r *MongoTaskRepository) GetTaskByID(orgID, id primitive.ObjectID) (domain.Task, bool, error) {
     var task domain.Task
-    err := r.collection.FindOne(context.TODO(), bson.D{{Key: "_id", Value: id}}).Decode(&task)
+    err := r.collection.FindOne(context.TODO(), taskFilter(orgID, id)).Decode(&task)
     if err != nil {
         if err == mongo.ErrNoDocuments {
             return task, false, nil
@@ -55,26 +142,29 @@ func (r *MongoTaskRepository) GetTaskByID(id primitive.ObjectID) (domain.Task, b
 
 func (r *MongoTaskRepository) AddTask(task domain.Task) error {
     _, err := r.collection.InsertOne(context.TODO(), task)
+    if mongo.IsDuplicateKeyError(err) {
+        return domain.ErrTaskExists
+    }
     return err
 }
 
-func (r *MongoTaskRepository) UpdateTask(id primitive.ObjectID, task domain.Task) error {
+func (r *MongoTaskRepository) UpdateTask(orgID, id primitive.ObjectID, task domain.Task) error {
     result := r.collection.FindOneAndUpdate(
         context.TODO(),
-        bson.D{{Key: "_id", Value: id}},
+        taskFilter(orgID, id),
         bson.D{{Key: "$set", Value: task}},
     )
     if result.Err() != nil {
         if result.Err() == mongo.ErrNoDocuments {
-            return errors.New("task not found")
+            return domain.ErrTaskNotFound
         }
         return result.Err()
     }
     return nil
 }
 
-func (r *MongoTaskRepository) DeleteTask(id primitive.ObjectID) error {
-    result, err := r.collection.DeleteOne(context.TODO(), bson.D{{Key: "_id", Value: id}})
+func (r *MongoTaskRepository) DeleteTask(orgID, id primitive.ObjectID) error {
+    result, err := r.collection.DeleteOne(context.TODO(), taskFilter(orgID, id))
     if err != nil {
         return err
     }
@@ -83,3 +173,81 @@ func (r *MongoTaskRepository) DeleteTask(id primitive.ObjectID) error {
     }
     return nil
 }
+
+func (r *MongoTaskRepository) AssignTask(orgID, id primitive.ObjectID, userID primitive.ObjectID) error {
+    return r.updateByID(orgID, id, bson.D{{Key: "$set", Value: bson.D{{Key: "assigned_to", Value: userID}}}})
+}
+
+func (r *MongoTaskRepository) UnassignTask(orgID, id primitive.ObjectID) error {
+    return r.updateByID(orgID, id, bson.D{{Key: "$unset", Value: bson.D{{Key: "assigned_to", Value: ""}}}})
+}
+
+func (r *MongoTaskRepository) updateByID(orgID, id primitive.ObjectID, update bson.D) error {
+    result, err := r.collection.UpdateOne(context.TODO(), taskFilter(orgID, id), update)
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return domain.ErrTaskNotFound
+    }
+    return nil
+}
+
+func (r *MongoTaskRepository) SetTaskVisibility(orgID, id primitive.ObjectID, visibility string) error {
+    return r.updateByID(orgID, id, bson.D{{Key: "$set", Value: bson.D{{Key: "visibility", Value: visibility}}}})
+}
+
+func (r *MongoTaskRepository) ShareTask(orgID, id primitive.ObjectID, userID primitive.ObjectID) error {
+    return r.updateByID(orgID, id, bson.D{{Key: "$addToSet", Value: bson.D{{Key: "shared_with", Value: userID}}}})
+}
+
+func (r *MongoTaskRepository) UnshareTask(orgID, id primitive.ObjectID, userID primitive.ObjectID) error {
+    return r.updateByID(orgID, id, bson.D{{Key: "$pull", Value: bson.D{{Key: "shared_with", Value: userID}}}})
+}
+
+func (r *MongoTaskRepository) ReassignUserTasks(from, to primitive.ObjectID, orgIDs []primitive.ObjectID) (int64, error) {
+    if len(orgIDs) == 0 {
+        return 0, nil
+    }
+    result, err := r.collection.UpdateMany(
+        context.TODO(),
+        bson.D{{Key: "assigned_to", Value: from}, {Key: "org_id", Value: bson.D{{Key: "$in", Value: orgIDs}}}},
+        bson.D{{Key: "$set", Value: bson.D{{Key: "assigned_to", Value: to}}}},
+    )
+    if err != nil {
+        return 0, err
+    }
+    return result.ModifiedCount, nil
+}
+
+func (r *MongoTaskRepository) UnassignUserTasks(userID primitive.ObjectID) (int64, error) {
+    result, err := r.collection.UpdateMany(
+        context.TODO(),
+        bson.D{{Key: "assigned_to", Value: userID}},
+        bson.D{{Key: "$unset", Value: bson.D{{Key: "assigned_to", Value: ""}}}},
+    )
+    if err != nil {
+        return 0, err
+    }
+    return result.ModifiedCount, nil
+}
+
+func (r *MongoTaskRepository) DeleteUserTasks(userID primitive.ObjectID) (int64, error) {
+    result, err := r.collection.DeleteMany(context.TODO(), bson.D{{Key: "assigned_to", Value: userID}})
+    if err != nil {
+        return 0, err
+    }
+    return result.DeletedCount, nil
+}
+
+func (r *MongoTaskRepository) AdoptOrphanTasks(orgID primitive.ObjectID) (int64, error) {
+    result, err := r.collection.UpdateMany(
+        context.TODO(),
+        bson.D{{Key: "org_id", Value: bson.D{{Key: "$exists", Value: false}}}},
+        bson.D{{Key: "$set", Value: bson.D{{Key: "org_id", Value: orgID}}}},
+    )
+    if err != nil {
+        return 0, err
+    }
+    return result.ModifiedCount, nil
+}
@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoTokenRevocationRepository stores revoked token IDs and per-user
+// cut-offs in separate collections. Revoked token IDs are the documents' _id,
+// and carry their expiry so that the TTL index of
+// EnsureTokenRevocationIndexes can remove them once the tokens could no
+// longer be used anyway.
+type MongoTokenRevocationRepository struct {
+    tokens *mongo.Collection
+    users  *mongo.Collection
+}
+
+func NewMongoTokenRevocationRepository(tokens, users *mongo.Collection) domain.TokenRevocationRepository {
+    return &MongoTokenRevocationRepository{tokens: tokens, users: users}
+}
+
+func (r *MongoTokenRevocationRepository) RevokeToken(tokenID string, expiresAt time.Time) error {
+    _, err := r.tokens.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": tokenID},
+        bson.M{"$set": bson.M{"expires_at": expiresAt}},
+        options.Update().SetUpsert(true),
+    )
+    return err
+}
+
+// IsTokenRevoked ignores expired entries the TTL monitor has not removed yet.
+func (r *MongoTokenRevocationRepository) IsTokenRevoked(tokenID string) (bool, error) {
+    count, err := r.tokens.CountDocuments(
+        context.TODO(),
+        bson.M{"_id": tokenID, "expires_at": bson.M{"$gt": time.Now()}},
+        options.Count().SetLimit(1),
+    )
+    if err != nil {
+        return false, err
+    }
+    return count > 0, nil
+}
+
+func (r *MongoTokenRevocationRepository) RevokeUserTokens(userID primitive.ObjectID, before time.Time) error {
+    _, err := r.users.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": userID},
+        bson.M{"$max": bson.M{"revoked_before": before}},
+        options.Update().SetUpsert(true),
+    )
+    return err
+}
+
+func (r *MongoTokenRevocationRepository) UserTokensRevokedBefore(userID primitive.ObjectID) (time.Time, error) {
+    var result struct {
+        RevokedBefore time.Time `bson:"revoked_before"`
+    }
+    err := r.users.FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&result)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return time.Time{}, nil
+        }
+        return time.Time{}, err
+    }
+    return result.RevokedBefore, nil
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoTwoFactorRepository stores one enrollment per user, keyed by user ID.
+type MongoTwoFactorRepository struct {
+    collection *mongo.Collection
+}
+
+func NewMongoTwoFactorRepository(collection *mongo.Collection) domain.TwoFactorRepository {
+    return &MongoTwoFactorRepository{collection: collection}
+}
+
+func (r *MongoTwoFactorRepository) SaveTwoFactor(twoFactor *domain.TwoFactor) error {
+    // The filter never matches an enabled enrollment, so the upsert fails
+    // with a duplicate key error instead of replacing it.
+    _, err := r.collection.ReplaceOne(
+        context.TODO(),
+        bson.M{"_id": twoFactor.UserID, "enabled": false},
+        twoFactor,
+        options.Replace().SetUpsert(true),
+    )
+    if mongo.IsDuplicateKeyError(err) {
+        return domain.ErrTwoFactorAlreadyEnabled
+    }
+    return err
+}
+
+func (r *MongoTwoFactorRepository) GetTwoFactor(userID primitive.ObjectID) (*domain.TwoFactor, error) {
+    twoFactor := &domain.TwoFactor{}
+    err := r.collection.FindOne(context.TODO(), bson.M{"_id": userID}).Decode(twoFactor)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, domain.ErrTwoFactorNotFound
+        }
+        return nil, err
+    }
+    return twoFactor, nil
+}
+
+func (r *MongoTwoFactorRepository) EnableTwoFactor(userID primitive.ObjectID, recoveryCodes []string) error {
+    result, err := r.collection.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": userID, "enabled": false},
+        bson.M{"$set": bson.M{"enabled": true, "recovery_codes": recoveryCodes, "enabled_at": time.Now()}},
+    )
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return domain.ErrTwoFactorNotFound
+    }
+    return nil
+}
+
+func (r *MongoTwoFactorRepository) DeleteTwoFactor(userID primitive.ObjectID) error {
+    result, err := r.collection.DeleteOne(context.TODO(), bson.M{"_id": userID})
+    if err != nil {
+        return err
+    }
+    if result.DeletedCount == 0 {
+        return domain.ErrTwoFactorNotFound
+    }
+    return nil
+}
+
+func (r *MongoTwoFactorRepository) UseTOTPStep(userID primitive.ObjectID, step int64) (bool, error) {
+    result, err := r.collection.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": userID, "last_used_step": bson.M{"$lt": step}},
+        bson.M{"$set": bson.M{"last_used_step": step}},
+    )
+    if err != nil {
+        return false, err
+    }
+    return result.ModifiedCount == 1, nil
+}
+
+func (r *MongoTwoFactorRepository) UseRecoveryCode(userID primitive.ObjectID, codeHash string) (bool, error) {
+    result, err := r.collection.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": userID, "recovery_codes": codeHash},
+        bson.M{"$pull": bson.M{"recovery_codes": codeHash}},
+    )
+    if err != nil {
+        return false, err
+    }
+    return result.ModifiedCount == 1, nil
+}
@@ -1,10 +1,11 @@
 package repositories
 
 import (
-    "errors"
     "context"
     "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
     "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
     "github.com/Hailemari/clean_architecture_task_manager/Domain"
 )
 
@@ -17,48 +18,102 @@ func NewMongoUserRepository(collection *mongo.Collection) domain.UserRepository
 }
 
 func (r *MongoUserRepository) CreateUser(user *domain.User) error {
-    existingUser := &domain.User{}
-    err := r.collection.FindOne(context.TODO(), bson.M{"username": user.Username}).Decode(existingUser)
-    if err == nil {
-        return errors.New("user already exists")
+    if user.Role == "" {
+        user.Role = domain.RoleUser
     }
 
-    count, err := r.collection.CountDocuments(context.TODO(), bson.M{})
+    result, err := r.collection.InsertOne(context.TODO(), user)
+    if mongo.IsDuplicateKeyError(err) {
+        return domain.ErrUserExists
+    }
     if err != nil {
         return err
     }
-    if count == 0 {
-        user.Role = "admin"
-    } else {
-        user.Role = "user"
+    if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+        user.ID = id
     }
-
-    _, err = r.collection.InsertOne(context.TODO(), user)
-    return err
+    return nil
 }
 
 func (r *MongoUserRepository) GetUserByUsername(username string) (*domain.User, error) {
     user := &domain.User{}
     err := r.collection.FindOne(context.TODO(), bson.M{"username": username}).Decode(user)
     if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, domain.ErrUserNotFound
+        }
         return nil, err
     }
     return user, nil
 }
 
+func (r *MongoUserRepository) GetUserByID(id primitive.ObjectID) (*domain.User, error) {
+    user := &domain.User{}
+    err := r.collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(user)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, domain.ErrUserNotFound
+        }
+        return nil, err
+    }
+    return user, nil
+}
+
+func (r *MongoUserRepository) GetUserByExternalID(externalID string) (*domain.User, error) {
+    if externalID == "" {
+        return nil, domain.ErrUserNotFound
+    }
+    user := &domain.User{}
+    err := r.collection.FindOne(context.TODO(), bson.M{"external_id": externalID}).Decode(user)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil, domain.ErrUserNotFound
+        }
+        return nil, err
+    }
+    return user, nil
+}
+
+// SetExternalID removes the field rather than store an empty external ID, so
+// that unlinked users stay out of the unique index.
+func (r *MongoUserRepository) SetExternalID(id primitive.ObjectID, externalID string) error {
+    update := bson.M{"$unset": bson.M{"external_id": ""}}
+    if externalID != "" {
+        err := r.collection.FindOne(context.TODO(), bson.M{"external_id": externalID, "_id": bson.M{"$ne": id}}).Err()
+        if err == nil {
+            return domain.ErrIdentityConflict
+        }
+        if err != mongo.ErrNoDocuments {
+            return err
+        }
+        update = bson.M{"$set": bson.M{"external_id": externalID}}
+    }
+    result, err := r.collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
+    if mongo.IsDuplicateKeyError(err) {
+        return domain.ErrIdentityConflict
+    }
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return domain.ErrUserNotFound
+    }
+    return nil
+}
+
 func (r *MongoUserRepository) PromoteUser(username string) error {
     var user bson.M
     err := r.collection.FindOne(context.TODO(), bson.M{"username": username}).Decode(&user)
 
     if err != nil {
         if err == mongo.ErrNoDocuments {
-            return errors.New("user not found")
+            return domain.ErrUserNotFound
         }
         return err
     }
 
     if userRole, ok := user["role"].(string); ok && userRole == "admin" {
-        return errors.New("user is already an admin")
+        return domain.ErrAlreadyAdmin
     }
 
     result, err := r.collection.UpdateOne(
@@ -71,7 +126,141 @@ func (r *MongoUserRepository) PromoteUser(username string) error {
         return err
     }
     if result.ModifiedCount == 0 {
-        return errors.New("user not found")
+        return domain.ErrUserNotFound
+    }
+    return nil
+}
+
+func (r *MongoUserRepository) UpdatePassword(id primitive.ObjectID, hashedPassword string) error {
+    result, err := r.collection.UpdateOne(
+        context.TODO(),
+        bson.M{"_id": id},
+        bson.M{"$set": bson.M{"password": hashedPassword}},
+    )
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return domain.ErrUserNotFound
     }
     return nil
 }
+
+func (r *MongoUserRepository) ListUsers(query domain.UserQuery) (domain.UserPage, error) {
+    if err := query.Normalize(); err != nil {
+        return domain.UserPage{}, err
+    }
+    after, _ := query.After()
+
+    filter := bson.M{}
+    if after != "" {
+        filter["username"] = bson.M{"$gt": after}
+    }
+    if query.Role != "" {
+        filter["role"] = query.Role
+    }
+    switch query.Status {
+    case domain.UserStatusActive:
+        filter["deactivated"] = bson.M{"$ne": true}
+    case domain.UserStatusDeactivated:
+        filter["deactivated"] = true
+    }
+
+    opts := options.Find().SetSort(bson.D{{Key: "username", Value: 1}}).SetLimit(int64(query.Limit) + 1)
+    cursor, err := r.collection.Find(context.TODO(), filter, opts)
+    if err != nil {
+        return domain.UserPage{}, err
+    }
+    var users []domain.User
+    if err := cursor.All(context.TODO(), &users); err != nil {
+        return domain.UserPage{}, err
+    }
+
+    page := domain.UserPage{Users: []domain.UserSummary{}}
+    for i, user := range users {
+        if i == query.Limit {
+            page.NextCursor = query.EncodeCursor(users[i-1])
+            break
+        }
+        page.Users = append(page.Users, domain.NewUserSummary(user))
+    }
+    return page, nil
+}
+
+// activeAdminFilter matches admins that have not been deactivated, including
+// users stored before deactivation existed.
+var activeAdminFilter = bson.M{"role": domain.RoleAdmin, "deactivated": bson.M{"$ne": true}}
+
+func (r *MongoUserRepository) SetUserRole(username, role string) error {
+    user, err := r.GetUserByUsername(username)
+    if err != nil {
+        return err
+    }
+    if _, err := r.collection.UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"role": role}}); err != nil {
+        return err
+    }
+    if role == domain.RoleAdmin || user.Role != domain.RoleAdmin || user.Deactivated {
+        return nil
+    }
+    return r.keepActiveAdmin(func() error {
+        _, err := r.collection.UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"role": user.Role}})
+        return err
+    })
+}
+
+func (r *MongoUserRepository) SetUserDeactivated(username string, deactivated bool) error {
+    user, err := r.GetUserByUsername(username)
+    if err != nil {
+        return err
+    }
+    if _, err := r.collection.UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"deactivated": deactivated}}); err != nil {
+        return err
+    }
+    if !deactivated || user.Role != domain.RoleAdmin || user.Deactivated {
+        return nil
+    }
+    return r.keepActiveAdmin(func() error {
+        _, err := r.collection.UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"deactivated": false}})
+        return err
+    })
+}
+
+func (r *MongoUserRepository) DeleteUser(username string) error {
+    var stored bson.Raw
+    err := r.collection.FindOneAndDelete(context.TODO(), bson.M{"username": username}).Decode(&stored)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return domain.ErrUserNotFound
+        }
+        return err
+    }
+    user := &domain.User{}
+    if err := bson.Unmarshal(stored, user); err != nil {
+        return err
+    }
+    if user.Role != domain.RoleAdmin || user.Deactivated {
+        return nil
+    }
+    return r.keepActiveAdmin(func() error {
+        _, err := r.collection.InsertOne(context.TODO(), stored)
+        return err
+    })
+}
+
+// keepActiveAdmin runs undo and returns ErrLastAdmin if a change just left no
+// active admin. Checking after the write rather than before means two admins
+// removed at the same time both see the count drop and both back out, instead
+// of both passing a check made before either write.
+func (r *MongoUserRepository) keepActiveAdmin(undo func() error) error {
+    count, err := r.collection.CountDocuments(context.TODO(), activeAdminFilter)
+    if err != nil {
+        return err
+    }
+    if count > 0 {
+        return nil
+    }
+    if err := undo(); err != nil {
+        return err
+    }
+    return domain.ErrLastAdmin
+}
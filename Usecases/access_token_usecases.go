@@ -0,0 +1,144 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AccessTokenUseCase struct {
+    repo      domain.AccessTokenRepository
+    usersRepo domain.UserRepository
+    roleRepo  domain.RoleRepository
+}
+
+func NewAccessTokenUseCase(repo domain.AccessTokenRepository, usersRepo domain.UserRepository, roleRepo domain.RoleRepository) domain.AccessTokenUseCaseInterface {
+    return &AccessTokenUseCase{repo: repo, usersRepo: usersRepo, roleRepo: roleRepo}
+}
+
+// privilegedScopes can manage users, so they are only given to users whose
+// own role grants every permission of the scope.
+var privilegedScopes = []string{domain.ScopeAdmin, domain.ScopeSCIM}
+
+func (uc *AccessTokenUseCase) CreateAccessToken(userID, orgID primitive.ObjectID, name string, scopes []string, lifetime time.Duration) (string, *domain.PersonalAccessToken, error) {
+    name = strings.TrimSpace(name)
+    if name == "" {
+        return "", nil, fmt.Errorf("%w: name is required", domain.ErrInvalidAccessTokenRequest)
+    }
+    if lifetime == 0 {
+        lifetime = domain.DefaultAccessTokenLifetime
+    }
+    if lifetime < 0 || lifetime > domain.MaxAccessTokenLifetime {
+        return "", nil, fmt.Errorf("%w: lifetime must be between 1 and 365 days", domain.ErrInvalidAccessTokenRequest)
+    }
+    if len(scopes) == 0 {
+        return "", nil, fmt.Errorf("%w: at least one scope is required", domain.ErrInvalidAccessTokenRequest)
+    }
+    granted := []string{}
+    for _, scope := range scopes {
+        if !domain.ValidScope(scope) {
+            return "", nil, domain.ErrInvalidScope
+        }
+        if !slices.Contains(granted, scope) {
+            granted = append(granted, scope)
+        }
+    }
+
+    user, err := uc.usersRepo.GetUserByID(userID)
+    if err != nil {
+        return "", nil, err
+    }
+    if err := uc.checkPrivilegedScopes(user, granted); err != nil {
+        return "", nil, err
+    }
+
+    secret, _, err := newOpaqueToken()
+    if err != nil {
+        return "", nil, err
+    }
+    raw := domain.AccessTokenPrefix + secret
+    now := time.Now()
+    token := &domain.PersonalAccessToken{
+        UserID:    user.ID,
+        OrgID:     orgID,
+        Name:      name,
+        TokenHash: hashToken(raw),
+        Scopes:    granted,
+        CreatedAt: now,
+        ExpiresAt: now.Add(lifetime),
+    }
+    if err := uc.repo.CreateAccessToken(token); err != nil {
+        return "", nil, err
+    }
+    return raw, token, nil
+}
+
+func (uc *AccessTokenUseCase) checkPrivilegedScopes(user *domain.User, scopes []string) error {
+    for _, scope := range privilegedScopes {
+        if !slices.Contains(scopes, scope) {
+            continue
+        }
+        role, err := uc.roleRepo.GetRole(user.Role)
+        if errors.Is(err, domain.ErrRoleNotFound) {
+            return domain.ErrScopeNotPermitted
+        }
+        if err != nil {
+            return err
+        }
+        for _, permission := range domain.ScopePermissions(scope) {
+            if !slices.Contains(role.Permissions, permission) {
+                return domain.ErrScopeNotPermitted
+            }
+        }
+    }
+    return nil
+}
+
+func (uc *AccessTokenUseCase) ListAccessTokens(userID primitive.ObjectID) ([]domain.PersonalAccessToken, error) {
+    return uc.repo.ListUserAccessTokens(userID)
+}
+
+func (uc *AccessTokenUseCase) RevokeAccessToken(userID, id primitive.ObjectID) error {
+    return uc.repo.RevokeAccessToken(userID, id)
+}
+
+// Authenticate looks the token up by its hash. The principal carries the
+// owner's current role, so demoting a user also limits their tokens.
+func (uc *AccessTokenUseCase) Authenticate(raw string) (*domain.Principal, error) {
+    token, err := uc.repo.GetAccessTokenByHash(hashToken(raw))
+    if err != nil {
+        if errors.Is(err, domain.ErrAccessTokenNotFound) {
+            return nil, domain.ErrInvalidAccessToken
+        }
+        return nil, err
+    }
+    if token.Revoked || time.Now().After(token.ExpiresAt) || len(token.Scopes) == 0 {
+        return nil, domain.ErrInvalidAccessToken
+    }
+
+    user, err := uc.usersRepo.GetUserByID(token.UserID)
+    if err != nil {
+        if errors.Is(err, domain.ErrUserNotFound) {
+            return nil, domain.ErrInvalidAccessToken
+        }
+        return nil, err
+    }
+    if user.Deactivated {
+        return nil, domain.ErrUserDeactivated
+    }
+    return &domain.Principal{
+        UserID:    user.ID,
+        Username:  user.Username,
+        Role:      user.Role,
+        OrgID:     token.OrgID,
+        TokenID:   token.ID.Hex(),
+        IssuedAt:  token.CreatedAt,
+        ExpiresAt: token.ExpiresAt,
+        Scopes:    token.Scopes,
+    }, nil
+}
@@ -0,0 +1,50 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCreateAccessTokenScopes(t *testing.T) {
+    roles := repositories.NewInMemoryRoleRepository()
+    provisioner := domain.Role{Name: "provisioner", Permissions: domain.ScopePermissions(domain.ScopeSCIM)}
+    helpdesk := domain.Role{Name: "helpdesk", Permissions: []string{domain.PermissionUsersRead, domain.PermissionUsersManage}}
+    for _, role := range append(domain.DefaultRoles(), provisioner, helpdesk) {
+        if err := roles.CreateRole(&role); err != nil {
+            t.Fatalf("CreateRole: %v", err)
+        }
+    }
+    users := repositories.NewInMemoryUserRepository()
+    uc := NewAccessTokenUseCase(repositories.NewInMemoryAccessTokenRepository(), users, roles)
+
+    for _, tc := range []struct {
+        role    string
+        scope   string
+        allowed bool
+    }{
+        {domain.RoleAdmin, domain.ScopeAdmin, true},
+        {domain.RoleAdmin, domain.ScopeSCIM, true},
+        {"provisioner", domain.ScopeSCIM, true},
+        {"provisioner", domain.ScopeAdmin, false},
+        {"helpdesk", domain.ScopeSCIM, false},
+        {domain.RoleUser, domain.ScopeTasksWrite, true},
+        {domain.RoleUser, domain.ScopeSCIM, false},
+        {"deleted-role", domain.ScopeAdmin, false},
+    } {
+        user := &domain.User{Username: tc.role + "-" + tc.scope, Role: tc.role}
+        if err := users.CreateUser(user); err != nil {
+            t.Fatalf("CreateUser: %v", err)
+        }
+        _, _, err := uc.CreateAccessToken(user.ID, primitive.NilObjectID, "ci", []string{domain.ScopeTasksRead, tc.scope}, 0)
+        if tc.allowed && err != nil {
+            t.Errorf("%s with the %s scope: %v", tc.role, tc.scope, err)
+        }
+        if !tc.allowed && !errors.Is(err, domain.ErrScopeNotPermitted) {
+            t.Errorf("%s with the %s scope: got %v, want %v", tc.role, tc.scope, err, domain.ErrScopeNotPermitted)
+        }
+    }
+}
@@ -0,0 +1,294 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuthUseCase struct {
+    repo          domain.UserRepository
+    refreshRepo   domain.RefreshTokenRepository
+    revocations   domain.TokenRevocationRepository
+    twoFactorRepo domain.TwoFactorRepository
+    orgRepo       domain.OrganizationRepository
+    throttle      *loginThrottle
+    authenticator domain.Authenticator
+    tokens        domain.TokenService
+    refreshTTL    time.Duration
+}
+
+func NewAuthUseCase(repo domain.UserRepository, refreshRepo domain.RefreshTokenRepository, revocations domain.TokenRevocationRepository, twoFactorRepo domain.TwoFactorRepository, orgRepo domain.OrganizationRepository, attempts domain.LoginAttemptRepository, policy domain.LoginThrottlePolicy, authenticator domain.Authenticator, tokens domain.TokenService, refreshTTL time.Duration) domain.AuthUseCaseInterface {
+    return &AuthUseCase{
+        repo:          repo,
+        refreshRepo:   refreshRepo,
+        revocations:   revocations,
+        twoFactorRepo: twoFactorRepo,
+        orgRepo:       orgRepo,
+        throttle:      &loginThrottle{attempts: attempts, policy: policy},
+        authenticator: authenticator,
+        tokens:        tokens,
+        refreshTTL:    refreshTTL,
+    }
+}
+
+// Login checks the password with the authenticator. Users with two-factor
+// authentication enabled get a challenge instead of tokens, to be completed
+// with CompleteTwoFactorLogin. Repeated failures for the username or the
+// client IP make Login return a LockoutError without checking the password.
+func (uc *AuthUseCase) Login(username, password, ip string) (*domain.AuthToken, *domain.TwoFactorChallenge, error) {
+    if err := uc.throttle.check(username, ip); err != nil {
+        return nil, nil, err
+    }
+    user, err := uc.authenticator.Authenticate(username, password)
+    if err != nil {
+        if errors.Is(err, domain.ErrInvalidCredentials) {
+            return nil, nil, uc.loginFailed(username, ip, err)
+        }
+        return nil, nil, err
+    }
+    if user.Deactivated {
+        return nil, nil, domain.ErrUserDeactivated
+    }
+
+    return uc.challengeOrIssue(user, username)
+}
+
+// challengeOrIssue returns a challenge for users with two-factor
+// authentication enabled, and tokens for everyone else, clearing the failed
+// logins counted for username. With 2FA the failures are only cleared once the
+// code is verified, so a known password does not allow unlimited code guesses.
+func (uc *AuthUseCase) challengeOrIssue(user *domain.User, username string) (*domain.AuthToken, *domain.TwoFactorChallenge, error) {
+    twoFactor, err := uc.twoFactorRepo.GetTwoFactor(user.ID)
+    if err != nil && !errors.Is(err, domain.ErrTwoFactorNotFound) {
+        return nil, nil, err
+    }
+    if twoFactor != nil && twoFactor.Enabled {
+        challenge, expiresAt, err := uc.tokens.GenerateChallengeToken(user)
+        if err != nil {
+            return nil, nil, err
+        }
+        return nil, &domain.TwoFactorChallenge{TwoFactorRequired: true, ChallengeToken: challenge, ExpiresAt: expiresAt}, nil
+    }
+
+    if err := uc.throttle.reset(username); err != nil {
+        return nil, nil, err
+    }
+    token, err := uc.issueLoginTokens(user)
+    return token, nil, err
+}
+
+// CompleteTwoFactorLogin exchanges a login challenge and a TOTP or recovery
+// code for tokens. A challenge can only be completed once.
+func (uc *AuthUseCase) CompleteTwoFactorLogin(challengeToken, code, ip string) (*domain.AuthToken, error) {
+    challenge, err := uc.tokens.ValidateChallengeToken(challengeToken)
+    if err != nil {
+        return nil, domain.ErrInvalidChallenge
+    }
+    if err := uc.throttle.check(challenge.Username, ip); err != nil {
+        return nil, err
+    }
+    revoked, err := uc.revocations.IsTokenRevoked(challenge.TokenID)
+    if err != nil {
+        return nil, err
+    }
+    if revoked {
+        return nil, domain.ErrInvalidChallenge
+    }
+
+    twoFactor, err := uc.twoFactorRepo.GetTwoFactor(challenge.UserID)
+    if err != nil {
+        if errors.Is(err, domain.ErrTwoFactorNotFound) {
+            return nil, domain.ErrInvalidChallenge
+        }
+        return nil, err
+    }
+    if !twoFactor.Enabled {
+        return nil, domain.ErrInvalidChallenge
+    }
+    if err := verifySecondFactor(uc.twoFactorRepo, twoFactor, code); err != nil {
+        if errors.Is(err, domain.ErrInvalidTwoFactorCode) {
+            return nil, uc.loginFailed(challenge.Username, ip, err)
+        }
+        return nil, err
+    }
+    if err := uc.revocations.RevokeToken(challenge.TokenID, challenge.ExpiresAt); err != nil {
+        return nil, err
+    }
+    if err := uc.throttle.reset(challenge.Username); err != nil {
+        return nil, err
+    }
+
+    user, err := uc.repo.GetUserByID(challenge.UserID)
+    if err != nil {
+        if errors.Is(err, domain.ErrUserNotFound) {
+            return nil, domain.ErrInvalidChallenge
+        }
+        return nil, err
+    }
+    return uc.issueLoginTokens(user)
+}
+
+// LoginExternal does not trust the identity provider to check a second
+// factor: users who enabled two-factor authentication here get a challenge,
+// as with Login. Signing in there also clears failed password logins.
+func (uc *AuthUseCase) LoginExternal(user *domain.User) (*domain.AuthToken, *domain.TwoFactorChallenge, error) {
+    return uc.challengeOrIssue(user, user.Username)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. Presenting a token that was already exchanged is treated as theft and
+// revokes every token descended from the same login.
+func (uc *AuthUseCase) Refresh(refreshToken string) (*domain.AuthToken, error) {
+    stored, err := uc.refreshRepo.GetRefreshTokenByHash(hashToken(refreshToken))
+    if err != nil {
+        if errors.Is(err, domain.ErrRefreshTokenNotFound) {
+            return nil, domain.ErrInvalidRefreshToken
+        }
+        return nil, err
+    }
+    if stored.Revoked || time.Now().After(stored.ExpiresAt) {
+        return nil, domain.ErrInvalidRefreshToken
+    }
+
+    marked, err := uc.refreshRepo.MarkRefreshTokenUsed(stored.ID)
+    if err != nil {
+        return nil, err
+    }
+    if stored.Used || !marked {
+        if err := uc.refreshRepo.RevokeRefreshTokenFamily(stored.FamilyID); err != nil {
+            return nil, err
+        }
+        return nil, domain.ErrRefreshTokenReused
+    }
+
+    user, err := uc.repo.GetUserByID(stored.UserID)
+    if err != nil {
+        if errors.Is(err, domain.ErrUserNotFound) {
+            return nil, domain.ErrInvalidRefreshToken
+        }
+        return nil, err
+    }
+    return uc.issueTokens(user, stored.FamilyID, stored.OrgID)
+}
+
+// Logout revokes the access token used for the request, the refresh token and
+// every token rotated from the same login. Unknown refresh tokens are ignored
+// so that logging out twice is harmless.
+func (uc *AuthUseCase) Logout(refreshToken string, accessTokenID string, accessExpiresAt time.Time) error {
+    if err := uc.revocations.RevokeToken(accessTokenID, accessExpiresAt); err != nil {
+        return err
+    }
+    stored, err := uc.refreshRepo.GetRefreshTokenByHash(hashToken(refreshToken))
+    if err != nil {
+        if errors.Is(err, domain.ErrRefreshTokenNotFound) {
+            return nil
+        }
+        return err
+    }
+    return uc.refreshRepo.RevokeRefreshTokenFamily(stored.FamilyID)
+}
+
+// SwitchOrganization starts a new session rather than changing the current
+// one, so the tokens already issued keep working in their organization.
+func (uc *AuthUseCase) SwitchOrganization(principal *domain.Principal, orgID primitive.ObjectID) (*domain.AuthToken, error) {
+    membership, err := uc.orgRepo.GetMembership(orgID, principal.UserID)
+    if err != nil {
+        return nil, err
+    }
+    if membership.Pending {
+        return nil, domain.ErrNotOrgMember
+    }
+    user, err := uc.repo.GetUserByID(principal.UserID)
+    if err != nil {
+        return nil, err
+    }
+    return uc.issueTokens(user, primitive.NewObjectID(), orgID)
+}
+
+// UnlockUser clears the failed logins and any lockout of the user.
+func (uc *AuthUseCase) UnlockUser(username string) error {
+    if _, err := uc.repo.GetUserByUsername(username); err != nil {
+        return err
+    }
+    return uc.throttle.reset(username)
+}
+
+// loginFailed records the failure and returns err.
+func (uc *AuthUseCase) loginFailed(username, ip string, err error) error {
+    if failErr := uc.throttle.fail(username, ip); failErr != nil {
+        return failErr
+    }
+    return err
+}
+
+// issueLoginTokens starts a session in the organization the user joined first.
+func (uc *AuthUseCase) issueLoginTokens(user *domain.User) (*domain.AuthToken, error) {
+    orgID, err := defaultOrganization(uc.orgRepo, user.ID)
+    if err != nil {
+        return nil, err
+    }
+    return uc.issueTokens(user, primitive.NewObjectID(), orgID)
+}
+
+func (uc *AuthUseCase) issueTokens(user *domain.User, familyID, orgID primitive.ObjectID) (*domain.AuthToken, error) {
+    if user.Deactivated {
+        return nil, domain.ErrUserDeactivated
+    }
+    accessToken, expiresAt, err := uc.tokens.GenerateToken(user, orgID)
+    if err != nil {
+        return nil, err
+    }
+
+    rawRefresh, refreshHash, err := newOpaqueToken()
+    if err != nil {
+        return nil, err
+    }
+    now := time.Now()
+    refresh := &domain.RefreshToken{
+        TokenHash: refreshHash,
+        UserID:    user.ID,
+        FamilyID:  familyID,
+        OrgID:     orgID,
+        CreatedAt: now,
+        ExpiresAt: now.Add(uc.refreshTTL),
+    }
+    if err := uc.refreshRepo.CreateRefreshToken(refresh); err != nil {
+        return nil, err
+    }
+
+    token := &domain.AuthToken{
+        AccessToken:      accessToken,
+        TokenType:        "Bearer",
+        ExpiresIn:        int64(time.Until(expiresAt).Seconds()),
+        ExpiresAt:        expiresAt,
+        RefreshToken:     rawRefresh,
+        RefreshExpiresAt: refresh.ExpiresAt,
+        Role:             user.Role,
+    }
+    if !orgID.IsZero() {
+        token.OrgID = &orgID
+    }
+    return token, nil
+}
+
+// newOpaqueToken returns a random URL-safe token and the hash under which it is stored.
+func newOpaqueToken() (string, string, error) {
+    buf := make([]byte, 32)
+    if _, err := rand.Read(buf); err != nil {
+        return "", "", err
+    }
+    token := base64.RawURLEncoding.EncodeToString(buf)
+    return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
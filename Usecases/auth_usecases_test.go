@@ -0,0 +1,159 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// plainHasher stores passwords in the clear, so tests can skip the cost of
+// real hashing.
+type plainHasher struct{}
+
+func (plainHasher) Hash(password string) (string, error) {
+    return "plain:" + password, nil
+}
+
+func (plainHasher) Verify(hash, password string) (bool, bool, error) {
+    return hash == "plain:"+password, false, nil
+}
+
+// fakeTokens issues access tokens that name the user; signing is tested in
+// Infrastructure.
+type fakeTokens struct{}
+
+func (fakeTokens) GenerateToken(user *domain.User, orgID primitive.ObjectID) (string, time.Time, error) {
+    return "access:" + user.Username, time.Now().Add(time.Minute), nil
+}
+
+func (fakeTokens) ValidateToken(token string) (*domain.Principal, error) {
+    return nil, errors.New("not implemented")
+}
+
+func (fakeTokens) GenerateChallengeToken(user *domain.User) (string, time.Time, error) {
+    return "challenge:" + user.Username, time.Now().Add(time.Minute), nil
+}
+
+func (fakeTokens) ValidateChallengeToken(token string) (*domain.Principal, error) {
+    return nil, errors.New("not implemented")
+}
+
+func (fakeTokens) JWKS() []domain.JSONWebKey {
+    return nil
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+    users := repositories.NewInMemoryUserRepository()
+    // Deactivating alice below must not remove the last admin.
+    if err := users.CreateUser(&domain.User{Username: "root", Password: "plain:root", Role: domain.RoleAdmin}); err != nil {
+        t.Fatalf("CreateUser: %v", err)
+    }
+    if err := users.CreateUser(&domain.User{Username: "alice", Password: "plain:pw"}); err != nil {
+        t.Fatalf("CreateUser: %v", err)
+    }
+    revocations := repositories.NewInMemoryTokenRevocationRepository()
+    uc := NewAuthUseCase(users, repositories.NewInMemoryRefreshTokenRepository(), revocations, repositories.NewInMemoryTwoFactorRepository(),
+        repositories.NewInMemoryOrganizationRepository(), repositories.NewInMemoryLoginAttemptRepository(), domain.DefaultLoginThrottlePolicy(),
+        NewPasswordAuthenticator(users, plainHasher{}), fakeTokens{}, time.Hour)
+
+    login := func() *domain.AuthToken {
+        t.Helper()
+        token, challenge, err := uc.Login("alice", "pw", "")
+        if err != nil || challenge != nil {
+            t.Fatalf("Login: got %+v, challenge %+v, err=%v", token, challenge, err)
+        }
+        return token
+    }
+    refresh := func(refreshToken string) *domain.AuthToken {
+        t.Helper()
+        token, err := uc.Refresh(refreshToken)
+        if err != nil {
+            t.Fatalf("Refresh: %v", err)
+        }
+        return token
+    }
+
+    // Each refresh rotates the refresh token.
+    first := login()
+    second := refresh(first.RefreshToken)
+    if second.RefreshToken == first.RefreshToken || second.AccessToken != "access:alice" {
+        t.Fatalf("Refresh: got %+v, want a new refresh token and an access token for alice", second)
+    }
+    third := refresh(second.RefreshToken)
+
+    // Replaying a used token revokes the whole family, including the newest.
+    other := login()
+    if _, err := uc.Refresh(first.RefreshToken); !errors.Is(err, domain.ErrRefreshTokenReused) {
+        t.Fatalf("Refresh with a used token: got %v, want %v", err, domain.ErrRefreshTokenReused)
+    }
+    if _, err := uc.Refresh(third.RefreshToken); !errors.Is(err, domain.ErrInvalidRefreshToken) {
+        t.Fatalf("Refresh with the newest token of a revoked family: got %v, want %v", err, domain.ErrInvalidRefreshToken)
+    }
+    // Sessions from other logins are not affected.
+    other = refresh(other.RefreshToken)
+
+    // Logout revokes the access token and the refresh token's family.
+    rotated := refresh(other.RefreshToken)
+    expiresAt := time.Now().Add(time.Minute)
+    if err := uc.Logout(other.RefreshToken, "jti-1", expiresAt); err != nil {
+        t.Fatalf("Logout: %v", err)
+    }
+    if _, err := uc.Refresh(rotated.RefreshToken); !errors.Is(err, domain.ErrInvalidRefreshToken) {
+        t.Fatalf("Refresh after Logout with an earlier token of the family: got %v, want %v", err, domain.ErrInvalidRefreshToken)
+    }
+    if revoked, err := revocations.IsTokenRevoked("jti-1"); err != nil || !revoked {
+        t.Fatalf("IsTokenRevoked after Logout: got %v, err=%v, want true", revoked, err)
+    }
+    if err := uc.Logout(other.RefreshToken, "jti-1", expiresAt); err != nil {
+        t.Fatalf("Logout twice: %v", err)
+    }
+    if err := uc.Logout("unknown", "jti-2", expiresAt); err != nil {
+        t.Fatalf("Logout with an unknown refresh token: %v", err)
+    }
+
+    if _, err := uc.Refresh("unknown"); !errors.Is(err, domain.ErrInvalidRefreshToken) {
+        t.Fatalf("Refresh with an unknown token: got %v, want %v", err, domain.ErrInvalidRefreshToken)
+    }
+    deactivated := login()
+    if err := users.SetUserDeactivated("alice", true); err != nil {
+        t.Fatalf("SetUserDeactivated: %v", err)
+    }
+    if _, err := uc.Refresh(deactivated.RefreshToken); !errors.Is(err, domain.ErrUserDeactivated) {
+        t.Fatalf("Refresh of a deactivated user: got %v, want %v", err, domain.ErrUserDeactivated)
+    }
+}
+
+func TestLoginExternalChecksSecondFactor(t *testing.T) {
+    users := repositories.NewInMemoryUserRepository()
+    for _, username := range []string{"alice", "bob"} {
+        if err := users.CreateUser(&domain.User{Username: username, ExternalID: "idp|" + username}); err != nil {
+            t.Fatalf("CreateUser: %v", err)
+        }
+    }
+    alice, _ := users.GetUserByUsername("alice")
+    bob, _ := users.GetUserByUsername("bob")
+    twoFactor := repositories.NewInMemoryTwoFactorRepository()
+    if err := twoFactor.SaveTwoFactor(&domain.TwoFactor{UserID: alice.ID, Secret: "secret"}); err != nil {
+        t.Fatalf("SaveTwoFactor: %v", err)
+    }
+    if err := twoFactor.EnableTwoFactor(alice.ID, nil); err != nil {
+        t.Fatalf("EnableTwoFactor: %v", err)
+    }
+    uc := NewAuthUseCase(users, repositories.NewInMemoryRefreshTokenRepository(), repositories.NewInMemoryTokenRevocationRepository(), twoFactor,
+        repositories.NewInMemoryOrganizationRepository(), repositories.NewInMemoryLoginAttemptRepository(), domain.DefaultLoginThrottlePolicy(),
+        NewPasswordAuthenticator(users, plainHasher{}), fakeTokens{}, time.Hour)
+
+    // Signing in at the identity provider does not skip the second factor.
+    token, challenge, err := uc.LoginExternal(alice)
+    if err != nil || token != nil || challenge == nil || challenge.ChallengeToken != "challenge:alice" {
+        t.Fatalf("LoginExternal with 2FA: got %+v, challenge %+v, err=%v, want a challenge", token, challenge, err)
+    }
+    token, challenge, err = uc.LoginExternal(bob)
+    if err != nil || challenge != nil || token == nil || token.AccessToken != "access:bob" {
+        t.Fatalf("LoginExternal without 2FA: got %+v, challenge %+v, err=%v, want tokens", token, challenge, err)
+    }
+}
@@ -0,0 +1,117 @@
+package usecases
+
+import (
+	"errors"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+// PasswordAuthenticator checks passwords against the hashes stored with users.
+type PasswordAuthenticator struct {
+    repo   domain.UserRepository
+    hasher domain.PasswordHasher
+    // dummyHash is checked instead when there is no user or no password to
+    // check, so that those logins take as long as a wrong password and do
+    // not reveal which usernames exist.
+    dummyHash string
+}
+
+func NewPasswordAuthenticator(repo domain.UserRepository, hasher domain.PasswordHasher) domain.Authenticator {
+    authenticator := &PasswordAuthenticator{repo: repo, hasher: hasher}
+    // A random password no login can match. Without it, logins still work
+    // but take less time for unknown usernames.
+    if password, _, err := newOpaqueToken(); err == nil {
+        authenticator.dummyHash, _ = hasher.Hash(password)
+    }
+    return authenticator
+}
+
+// Authenticate replaces a hash made with an outdated algorithm or cost once
+// the password is verified.
+func (a *PasswordAuthenticator) Authenticate(username, password string) (*domain.User, error) {
+    user, err := a.repo.GetUserByUsername(username)
+    if err != nil {
+        if errors.Is(err, domain.ErrUserNotFound) {
+            a.verifyDummy(password)
+            return nil, domain.ErrInvalidCredentials
+        }
+        return nil, err
+    }
+    // Users provisioned by an identity provider or directory have no
+    // password to log in with.
+    if user.Password == "" {
+        a.verifyDummy(password)
+        return nil, domain.ErrInvalidCredentials
+    }
+    match, needsRehash, err := a.hasher.Verify(user.Password, password)
+    if err != nil {
+        return nil, err
+    }
+    if !match {
+        return nil, domain.ErrInvalidCredentials
+    }
+    if needsRehash {
+        // The password is known only now, so this is the one chance to move
+        // the hash to the current algorithm. A failed update is retried at
+        // the next login rather than failing this one.
+        if hash, err := a.hasher.Hash(password); err == nil {
+            _ = a.repo.UpdatePassword(user.ID, hash)
+        }
+    }
+    return user, nil
+}
+
+// verifyDummy spends the time a password check takes.
+func (a *PasswordAuthenticator) verifyDummy(password string) {
+    if a.dummyHash != "" {
+        _, _, _ = a.hasher.Verify(a.dummyHash, password)
+    }
+}
+
+// DirectoryAuthenticator checks passwords against a directory. Users are
+// linked or provisioned at their first login, and their role follows their
+// directory groups, as with single sign-on.
+type DirectoryAuthenticator struct {
+    directory domain.Directory
+    users     *externalUsers
+}
+
+func NewDirectoryAuthenticator(directory domain.Directory, repo domain.UserRepository, settings domain.ExternalUserSettings) domain.Authenticator {
+    return &DirectoryAuthenticator{directory: directory, users: newExternalUsers(repo, settings)}
+}
+
+func (a *DirectoryAuthenticator) Authenticate(username, password string) (*domain.User, error) {
+    identity, err := a.directory.Authenticate(username, password)
+    if err != nil {
+        return nil, err
+    }
+    return a.users.resolve(identity)
+}
+
+// ChainAuthenticator tries authenticators in order until one accepts the
+// credentials. Unavailable ones are skipped, so that local accounts can still
+// log in while the directory is down.
+type ChainAuthenticator []domain.Authenticator
+
+func NewChainAuthenticator(authenticators ...domain.Authenticator) domain.Authenticator {
+    if len(authenticators) == 1 {
+        return authenticators[0]
+    }
+    return ChainAuthenticator(authenticators)
+}
+
+func (c ChainAuthenticator) Authenticate(username, password string) (*domain.User, error) {
+    result := domain.ErrInvalidCredentials
+    for _, authenticator := range c {
+        user, err := authenticator.Authenticate(username, password)
+        switch {
+        case err == nil:
+            return user, nil
+        case errors.Is(err, domain.ErrAuthBackendUnavailable):
+            result = err
+        case !errors.Is(err, domain.ErrInvalidCredentials):
+            return nil, err
+        }
+    }
+    return nil, result
+}
@@ -0,0 +1,125 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+)
+
+// fakeDirectory accepts password "directory-pw" for the identities it holds.
+type fakeDirectory struct {
+    identities map[string]domain.ExternalIdentity
+    down       bool
+}
+
+func (d *fakeDirectory) Authenticate(username, password string) (*domain.ExternalIdentity, error) {
+    if d.down {
+        return nil, domain.ErrAuthBackendUnavailable
+    }
+    identity, ok := d.identities[username]
+    if !ok || password != "directory-pw" {
+        return nil, domain.ErrInvalidCredentials
+    }
+    return &identity, nil
+}
+
+// countingHasher counts password checks.
+type countingHasher struct {
+    plainHasher
+    verified int
+}
+
+func (h *countingHasher) Verify(hash, password string) (bool, bool, error) {
+    h.verified++
+    return h.plainHasher.Verify(hash, password)
+}
+
+func TestPasswordAuthenticatorChecksUnknownUsers(t *testing.T) {
+    users := repositories.NewInMemoryUserRepository()
+    for _, user := range []*domain.User{
+        {Username: "alice", Password: "plain:local-pw"},
+        {Username: "sso", ExternalID: "uid=sso"},
+    } {
+        if err := users.CreateUser(user); err != nil {
+            t.Fatalf("CreateUser: %v", err)
+        }
+    }
+    hasher := &countingHasher{}
+    passwords := NewPasswordAuthenticator(users, hasher)
+
+    // Every failed login checks one hash, whether or not the user exists or
+    // has a password, so they all take as long.
+    for _, username := range []string{"alice", "nobody", "sso"} {
+        hasher.verified = 0
+        if _, err := passwords.Authenticate(username, "wrong"); !errors.Is(err, domain.ErrInvalidCredentials) {
+            t.Fatalf("Authenticate %s: got %v, want %v", username, err, domain.ErrInvalidCredentials)
+        }
+        if hasher.verified != 1 {
+            t.Fatalf("Authenticate %s: checked %d hashes, want 1", username, hasher.verified)
+        }
+    }
+    // The dummy hash cannot be matched, even by an empty password.
+    if _, err := passwords.Authenticate("sso", ""); !errors.Is(err, domain.ErrInvalidCredentials) {
+        t.Fatalf("Authenticate without a password: got %v, want %v", err, domain.ErrInvalidCredentials)
+    }
+}
+
+func TestAuthenticators(t *testing.T) {
+    users := repositories.NewInMemoryUserRepository()
+    for _, user := range []*domain.User{
+        {Username: "root", Password: "plain:local-pw", Role: domain.RoleAdmin},
+        {Username: "bob", Password: "plain:local-pw"},
+    } {
+        if err := users.CreateUser(user); err != nil {
+            t.Fatalf("CreateUser: %v", err)
+        }
+    }
+    directory := &fakeDirectory{identities: map[string]domain.ExternalIdentity{
+        "alice": {Subject: "uid=alice", Username: "alice", Groups: []string{"task-admins"}},
+        "bob":   {Subject: "uid=bob", Username: "bob", Groups: []string{"staff"}},
+    }}
+    settings := domain.ExternalUserSettings{
+        GroupRoles:    []domain.GroupRole{{Group: "task-admins", Role: domain.RoleAdmin}},
+        LinkExisting:  true,
+        AutoProvision: true,
+    }
+    passwords := NewPasswordAuthenticator(users, plainHasher{})
+    ldap := NewDirectoryAuthenticator(directory, users, settings)
+    chain := NewChainAuthenticator(ldap, passwords)
+
+    alice, err := chain.Authenticate("alice", "directory-pw")
+    if err != nil {
+        t.Fatalf("Authenticate a directory user: %v", err)
+    }
+    if alice.Role != domain.RoleAdmin || alice.ExternalID != "uid=alice" || alice.Password != "" {
+        t.Fatalf("Authenticate a directory user: provisioned %+v, want alice as an admin without a password", alice)
+    }
+    if _, err := passwords.Authenticate("alice", ""); !errors.Is(err, domain.ErrInvalidCredentials) {
+        t.Fatalf("password login of a provisioned user: got %v, want %v", err, domain.ErrInvalidCredentials)
+    }
+
+    bob, err := chain.Authenticate("bob", "directory-pw")
+    if err != nil {
+        t.Fatalf("Authenticate a local user in the directory: %v", err)
+    }
+    if stored, _ := users.GetUserByExternalID("uid=bob"); stored == nil || stored.ID != bob.ID {
+        t.Fatalf("Authenticate a local user in the directory: not linked, got %+v", stored)
+    }
+    // The local password still works, through the next authenticator.
+    if _, err := chain.Authenticate("bob", "local-pw"); err != nil {
+        t.Fatalf("Authenticate with a local password: %v", err)
+    }
+    if _, err := chain.Authenticate("bob", "wrong"); !errors.Is(err, domain.ErrInvalidCredentials) {
+        t.Fatalf("Authenticate with a wrong password: got %v, want %v", err, domain.ErrInvalidCredentials)
+    }
+
+    directory.down = true
+    if _, err := chain.Authenticate("root", "local-pw"); err != nil {
+        t.Fatalf("Authenticate a local user with the directory down: %v", err)
+    }
+    if _, err := chain.Authenticate("alice", "directory-pw"); !errors.Is(err, domain.ErrAuthBackendUnavailable) {
+        t.Fatalf("Authenticate a directory user with the directory down: got %v, want %v", err, domain.ErrAuthBackendUnavailable)
+    }
+}
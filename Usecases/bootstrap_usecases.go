@@ -0,0 +1,135 @@
+package usecases
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+// BootstrapClaimTimeout is how long a bootstrap claim may go without an admin
+// before it is taken to be abandoned by a process that died between claiming
+// and creating the admin.
+const BootstrapClaimTimeout = time.Minute
+
+// BootstrapUseCase creates the first admin. Until it does, nobody has admin
+// rights; registering always creates a plain user.
+type BootstrapUseCase struct {
+    repo          domain.UserRepository
+    bootstrapRepo domain.BootstrapRepository
+    orgRepo       domain.OrganizationRepository
+    policy        domain.PasswordPolicy
+    hasher        domain.PasswordHasher
+    tokenHash     string
+}
+
+func NewBootstrapUseCase(repo domain.UserRepository, bootstrapRepo domain.BootstrapRepository, orgRepo domain.OrganizationRepository, policy domain.PasswordPolicy, hasher domain.PasswordHasher) domain.BootstrapUseCaseInterface {
+    return &BootstrapUseCase{repo: repo, bootstrapRepo: bootstrapRepo, orgRepo: orgRepo, policy: policy, hasher: hasher}
+}
+
+// Initialize hands out a setup token whenever no active admin exists, even if
+// the bootstrap was claimed: SetupAdmin releases the claim once it has been
+// abandoned for BootstrapClaimTimeout.
+func (uc *BootstrapUseCase) Initialize(setupToken string) (string, error) {
+    hasAdmin, err := uc.hasActiveAdmin()
+    if err != nil {
+        return "", err
+    }
+    if hasAdmin {
+        // Databases from before the bootstrap existed have an admin but no
+        // claim.
+        _, err := uc.bootstrapRepo.ClaimBootstrap(time.Now())
+        return "", err
+    }
+
+    if setupToken == "" {
+        if setupToken, _, err = newOpaqueToken(); err != nil {
+            return "", err
+        }
+    }
+    uc.tokenHash = hashToken(setupToken)
+    return setupToken, nil
+}
+
+func (uc *BootstrapUseCase) SetupAdmin(setupToken, username, password string) (*domain.User, error) {
+    if err := uc.releaseAbandonedClaim(); err != nil {
+        return nil, err
+    }
+    done, err := uc.bootstrapRepo.IsBootstrapped()
+    if err != nil {
+        return nil, err
+    }
+    if done {
+        return nil, domain.ErrAlreadyBootstrapped
+    }
+    if uc.tokenHash == "" || subtle.ConstantTimeCompare([]byte(hashToken(setupToken)), []byte(uc.tokenHash)) != 1 {
+        return nil, domain.ErrInvalidSetupToken
+    }
+    return uc.CreateAdmin(username, password)
+}
+
+// CreateAdmin claims the bootstrap before creating the admin, so concurrent
+// calls cannot both succeed. The claim is released if the admin cannot be
+// created. The admin is then made the first admin of the default
+// organization.
+func (uc *BootstrapUseCase) CreateAdmin(username, password string) (*domain.User, error) {
+    user := &domain.User{Username: username, Password: password}
+    if err := user.ValidateUser(); err != nil {
+        return nil, err
+    }
+    if err := uc.policy.Validate(username, password); err != nil {
+        return nil, err
+    }
+    if _, err := uc.repo.GetUserByUsername(username); err == nil {
+        return nil, domain.ErrUserExists
+    } else if !errors.Is(err, domain.ErrUserNotFound) {
+        return nil, err
+    }
+    hash, err := uc.hasher.Hash(password)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := uc.releaseAbandonedClaim(); err != nil {
+        return nil, err
+    }
+    claimed, err := uc.bootstrapRepo.ClaimBootstrap(time.Now())
+    if err != nil {
+        return nil, err
+    }
+    if !claimed {
+        return nil, domain.ErrAlreadyBootstrapped
+    }
+    user.Password = hash
+    user.Role = domain.RoleAdmin
+    if err := uc.repo.CreateUser(user); err != nil {
+        if releaseErr := uc.bootstrapRepo.ReleaseBootstrap(); releaseErr != nil {
+            return nil, releaseErr
+        }
+        return nil, err
+    }
+    if _, err := createOrganization(uc.orgRepo, domain.DefaultOrganizationName, user.ID); err != nil {
+        return nil, err
+    }
+    return user, nil
+}
+
+func (uc *BootstrapUseCase) hasActiveAdmin() (bool, error) {
+    admins, err := uc.repo.ListUsers(domain.UserQuery{Role: domain.RoleAdmin, Status: domain.UserStatusActive, Limit: 1})
+    if err != nil {
+        return false, err
+    }
+    return len(admins.Users) > 0, nil
+}
+
+// releaseAbandonedClaim releases a claim older than BootstrapClaimTimeout if
+// no active admin exists. Newer claims may belong to a CreateAdmin call that
+// is still running, so they are left alone.
+func (uc *BootstrapUseCase) releaseAbandonedClaim() error {
+    hasAdmin, err := uc.hasActiveAdmin()
+    if err != nil || hasAdmin {
+        return err
+    }
+    return uc.bootstrapRepo.ReleaseBootstrapClaimedBefore(time.Now().Add(-BootstrapClaimTimeout))
+}
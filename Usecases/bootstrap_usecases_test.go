@@ -0,0 +1,148 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+)
+
+// failingUserRepository fails the next CreateUser call when fail is set.
+type failingUserRepository struct {
+    domain.UserRepository
+    fail bool
+}
+
+func (r *failingUserRepository) CreateUser(user *domain.User) error {
+    if r.fail {
+        r.fail = false
+        return errors.New("write failed")
+    }
+    return r.UserRepository.CreateUser(user)
+}
+
+func TestBootstrapSetupToken(t *testing.T) {
+    users := &failingUserRepository{UserRepository: repositories.NewInMemoryUserRepository()}
+    claims := repositories.NewInMemoryBootstrapRepository()
+    orgs := repositories.NewInMemoryOrganizationRepository()
+    uc := NewBootstrapUseCase(users, claims, orgs, domain.DefaultPasswordPolicy(), plainHasher{})
+
+    if _, err := uc.SetupAdmin("", "alice", "Admin-password-1"); !errors.Is(err, domain.ErrInvalidSetupToken) {
+        t.Fatalf("SetupAdmin before Initialize: got %v, want %v", err, domain.ErrInvalidSetupToken)
+    }
+    token, err := uc.Initialize("")
+    if err != nil || token == "" {
+        t.Fatalf("Initialize: got %q, err=%v, want a token", token, err)
+    }
+    if _, err := uc.SetupAdmin("wrong", "alice", "Admin-password-1"); !errors.Is(err, domain.ErrInvalidSetupToken) {
+        t.Fatalf("SetupAdmin with a wrong token: got %v, want %v", err, domain.ErrInvalidSetupToken)
+    }
+    // Neither a rejected password nor a failed write uses up the bootstrap.
+    if _, err := uc.SetupAdmin(token, "alice", "short"); !errors.Is(err, domain.ErrValidation) {
+        t.Fatalf("SetupAdmin with a weak password: got %v, want %v", err, domain.ErrValidation)
+    }
+    users.fail = true
+    if _, err := uc.SetupAdmin(token, "alice", "Admin-password-1"); err == nil {
+        t.Fatal("SetupAdmin: the failed write was not reported")
+    }
+    admin, err := uc.SetupAdmin(token, "alice", "Admin-password-1")
+    if err != nil || admin.Role != domain.RoleAdmin || admin.Password != "plain:Admin-password-1" {
+        t.Fatalf("SetupAdmin: got %+v, err=%v, want an admin", admin, err)
+    }
+    if memberships, err := orgs.ListUserMemberships(admin.ID); err != nil || len(memberships) != 1 || memberships[0].Role != domain.RoleAdmin {
+        t.Fatalf("ListUserMemberships of the admin: got %+v, err=%v, want the admin of one organization", memberships, err)
+    }
+    if _, err := uc.SetupAdmin(token, "bob", "Admin-password-1"); !errors.Is(err, domain.ErrAlreadyBootstrapped) {
+        t.Fatalf("SetupAdmin twice: got %v, want %v", err, domain.ErrAlreadyBootstrapped)
+    }
+    if _, err := uc.CreateAdmin("bob", "Admin-password-1"); !errors.Is(err, domain.ErrAlreadyBootstrapped) {
+        t.Fatalf("CreateAdmin after SetupAdmin: got %v, want %v", err, domain.ErrAlreadyBootstrapped)
+    }
+    if token, err := uc.Initialize("Operator-token-123"); err != nil || token != "" {
+        t.Fatalf("Initialize after the setup: got %q, err=%v, want none", token, err)
+    }
+
+    // Databases with an admin from before the bootstrap get the claim.
+    legacy := repositories.NewInMemoryBootstrapRepository()
+    if token, err := NewBootstrapUseCase(users, legacy, repositories.NewInMemoryOrganizationRepository(), domain.DefaultPasswordPolicy(), plainHasher{}).Initialize(""); err != nil || token != "" {
+        t.Fatalf("Initialize with an existing admin: got %q, err=%v, want none", token, err)
+    }
+    if done, _ := legacy.IsBootstrapped(); !done {
+        t.Fatal("Initialize with an existing admin: the bootstrap was not claimed")
+    }
+}
+
+func TestBootstrapClaims(t *testing.T) {
+    newUseCase := func() (domain.BootstrapUseCaseInterface, domain.UserRepository, domain.BootstrapRepository) {
+        users := repositories.NewInMemoryUserRepository()
+        claims := repositories.NewInMemoryBootstrapRepository()
+        return NewBootstrapUseCase(users, claims, repositories.NewInMemoryOrganizationRepository(), domain.DefaultPasswordPolicy(), plainHasher{}), users, claims
+    }
+
+    // Of concurrent setups, exactly one creates an admin.
+    uc, users, _ := newUseCase()
+    token, err := uc.Initialize("Operator-token-123")
+    if err != nil || token != "Operator-token-123" {
+        t.Fatalf("Initialize with a token: got %q, err=%v", token, err)
+    }
+    var wg sync.WaitGroup
+    results := make(chan error, 10)
+    for i := 0; i < 10; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            _, err := uc.SetupAdmin(token, fmt.Sprintf("admin-%d", i), "Admin-password-1")
+            results <- err
+        }(i)
+    }
+    wg.Wait()
+    close(results)
+    created := 0
+    for err := range results {
+        switch {
+        case err == nil:
+            created++
+        case !errors.Is(err, domain.ErrAlreadyBootstrapped):
+            t.Fatalf("concurrent SetupAdmin: %v", err)
+        }
+    }
+    admins, _ := users.ListUsers(domain.UserQuery{Role: domain.RoleAdmin})
+    if created != 1 || len(admins.Users) != 1 {
+        t.Fatalf("concurrent SetupAdmin: %d succeeded and %d admins exist, want 1", created, len(admins.Users))
+    }
+
+    // A claim left behind without an admin blocks the setup while it may
+    // still be in progress, and is released once it is abandoned.
+    uc, _, claims := newUseCase()
+    if _, err := claims.ClaimBootstrap(time.Now()); err != nil {
+        t.Fatalf("ClaimBootstrap: %v", err)
+    }
+    token, err = uc.Initialize("")
+    if err != nil || token == "" {
+        t.Fatalf("Initialize with a claim but no admin: got %q, err=%v, want a token", token, err)
+    }
+    if _, err := uc.SetupAdmin(token, "alice", "Admin-password-1"); !errors.Is(err, domain.ErrAlreadyBootstrapped) {
+        t.Fatalf("SetupAdmin during another claim: got %v, want %v", err, domain.ErrAlreadyBootstrapped)
+    }
+    if err := claims.ReleaseBootstrap(); err != nil {
+        t.Fatalf("ReleaseBootstrap: %v", err)
+    }
+    if _, err := claims.ClaimBootstrap(time.Now().Add(-BootstrapClaimTimeout - time.Second)); err != nil {
+        t.Fatalf("ClaimBootstrap: %v", err)
+    }
+    if _, err := uc.SetupAdmin(token, "alice", "Admin-password-1"); err != nil {
+        t.Fatalf("SetupAdmin after an abandoned claim: %v", err)
+    }
+
+    uc, _, claims = newUseCase()
+    if _, err := claims.ClaimBootstrap(time.Now().Add(-BootstrapClaimTimeout - time.Second)); err != nil {
+        t.Fatalf("ClaimBootstrap: %v", err)
+    }
+    if _, err := uc.CreateAdmin("alice", "Admin-password-1"); err != nil {
+        t.Fatalf("CreateAdmin after an abandoned claim: %v", err)
+    }
+}
@@ -0,0 +1,95 @@
+package usecases
+
+import (
+	"errors"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+// externalUsers finds the users that identities from an identity provider or
+// directory belong to.
+type externalUsers struct {
+    repo     domain.UserRepository
+    settings domain.ExternalUserSettings
+}
+
+func newExternalUsers(repo domain.UserRepository, settings domain.ExternalUserSettings) *externalUsers {
+    if settings.DefaultRole == "" {
+        settings.DefaultRole = domain.RoleUser
+    }
+    return &externalUsers{repo: repo, settings: settings}
+}
+
+// resolve returns the user linked to the identity, linking or provisioning
+// one if the settings allow it, with the role their groups map to.
+func (e *externalUsers) resolve(identity *domain.ExternalIdentity) (*domain.User, error) {
+    user, err := e.find(identity)
+    if err != nil {
+        return nil, err
+    }
+    if err := e.syncRole(user, identity.Groups); err != nil {
+        return nil, err
+    }
+    return user, nil
+}
+
+func (e *externalUsers) find(identity *domain.ExternalIdentity) (*domain.User, error) {
+    user, err := e.repo.GetUserByExternalID(identity.Subject)
+    if err == nil {
+        return user, nil
+    }
+    if !errors.Is(err, domain.ErrUserNotFound) {
+        return nil, err
+    }
+
+    user, err = e.repo.GetUserByUsername(identity.Username)
+    if err == nil {
+        if !e.settings.LinkExisting {
+            return nil, domain.ErrUserExists
+        }
+        if user.ExternalID != "" {
+            return nil, domain.ErrIdentityConflict
+        }
+        if err := e.repo.SetExternalID(user.ID, identity.Subject); err != nil {
+            return nil, err
+        }
+        user.ExternalID = identity.Subject
+        return user, nil
+    }
+    if !errors.Is(err, domain.ErrUserNotFound) {
+        return nil, err
+    }
+
+    if !e.settings.AutoProvision {
+        return nil, domain.ErrNotProvisioned
+    }
+    user = &domain.User{
+        Username:   identity.Username,
+        Role:       e.settings.RoleFor(identity.Groups),
+        ExternalID: identity.Subject,
+    }
+    if err := e.repo.CreateUser(user); err != nil {
+        return nil, err
+    }
+    return user, nil
+}
+
+// syncRole gives the user the role their groups map to. The last active admin
+// keeps their role rather than lock everyone out.
+func (e *externalUsers) syncRole(user *domain.User, groups []string) error {
+    if len(e.settings.GroupRoles) == 0 {
+        return nil
+    }
+    role := e.settings.RoleFor(groups)
+    if role == user.Role {
+        return nil
+    }
+    if err := e.repo.SetUserRole(user.Username, role); err != nil {
+        if errors.Is(err, domain.ErrLastAdmin) {
+            return nil
+        }
+        return err
+    }
+    user.Role = role
+    return nil
+}
@@ -0,0 +1,158 @@
+package usecases
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultInviteTTL is how long an invite stays valid.
+const DefaultInviteTTL = 7 * 24 * time.Hour
+
+type InviteUseCase struct {
+    repo     domain.InviteRepository
+    users    domain.UserRepository
+    roleRepo domain.RoleRepository
+    orgRepo  domain.OrganizationRepository
+    policy   domain.PasswordPolicy
+    hasher   domain.PasswordHasher
+    ttl      time.Duration
+}
+
+func NewInviteUseCase(repo domain.InviteRepository, users domain.UserRepository, roleRepo domain.RoleRepository, orgRepo domain.OrganizationRepository, policy domain.PasswordPolicy, hasher domain.PasswordHasher, ttl time.Duration) domain.InviteUseCaseInterface {
+    if ttl <= 0 {
+        ttl = DefaultInviteTTL
+    }
+    return &InviteUseCase{
+        repo:     repo,
+        users:    users,
+        roleRepo: roleRepo,
+        orgRepo:  orgRepo,
+        policy:   policy,
+        hasher:   hasher,
+        ttl:      ttl,
+    }
+}
+
+// CreateInvite only lets holders of roles.manage invite users with a role
+// other than user, as they are the only ones who could give it later.
+func (uc *InviteUseCase) CreateInvite(principal *domain.Principal, username, role string) (string, *domain.Invite, error) {
+    if username == "" {
+        return "", nil, &domain.ValidationError{Fields: []domain.FieldError{
+            {Field: "username", Code: "required", Message: "username cannot be empty"},
+        }}
+    }
+    if role == "" {
+        role = domain.RoleUser
+    }
+    if _, err := uc.roleRepo.GetRole(role); err != nil {
+        if errors.Is(err, domain.ErrRoleNotFound) {
+            return "", nil, domain.ErrInvalidRole
+        }
+        return "", nil, err
+    }
+    if role != domain.RoleUser && !principal.HasPermission(domain.PermissionRolesManage) {
+        return "", nil, domain.ErrInviteRoleNotPermitted
+    }
+    if _, err := uc.users.GetUserByUsername(username); err == nil {
+        return "", nil, domain.ErrUserExists
+    } else if !errors.Is(err, domain.ErrUserNotFound) {
+        return "", nil, err
+    }
+    if err := uc.repo.RevokeUsernameInvites(username); err != nil {
+        return "", nil, err
+    }
+
+    raw, hash, err := newOpaqueToken()
+    if err != nil {
+        return "", nil, err
+    }
+    now := time.Now()
+    invite := &domain.Invite{
+        TokenHash: hash,
+        Username:  username,
+        Role:      role,
+        CreatedBy: principal.UserID,
+        CreatedAt: now,
+        ExpiresAt: now.Add(uc.ttl),
+    }
+    if !principal.OrgID.IsZero() && principal.HasPermission(domain.PermissionOrgManage) {
+        orgID := principal.OrgID
+        invite.OrgID = &orgID
+    }
+    if err := uc.repo.CreateInvite(invite); err != nil {
+        return "", nil, err
+    }
+    return raw, invite, nil
+}
+
+func (uc *InviteUseCase) ListInvites() ([]domain.Invite, error) {
+    return uc.repo.ListPendingInvites(time.Now())
+}
+
+func (uc *InviteUseCase) RevokeInvite(id primitive.ObjectID) error {
+    return uc.repo.RevokeInvite(id)
+}
+
+// Register checks the new user before using up the invite, so that a rejected
+// password does not cost the invite.
+func (uc *InviteUseCase) Register(token string, user *domain.User) error {
+    invite, err := uc.repo.GetInviteByHash(hashToken(token))
+    if err != nil {
+        if errors.Is(err, domain.ErrInviteNotFound) {
+            return domain.ErrInvalidInvite
+        }
+        return err
+    }
+    if invite.Used || invite.Revoked || !time.Now().Before(invite.ExpiresAt) {
+        return domain.ErrInvalidInvite
+    }
+    if user.Username == "" {
+        user.Username = invite.Username
+    }
+    if user.Username != invite.Username {
+        return &domain.ValidationError{Fields: []domain.FieldError{
+            {Field: "username", Code: "invite_mismatch", Message: "username must be the one the invite was issued for"},
+        }}
+    }
+    if err := user.ValidateUser(); err != nil {
+        return err
+    }
+    if err := uc.policy.Validate(user.Username, user.Password); err != nil {
+        return err
+    }
+    if _, err := uc.users.GetUserByUsername(user.Username); err == nil {
+        return domain.ErrUserExists
+    } else if !errors.Is(err, domain.ErrUserNotFound) {
+        return err
+    }
+    hash, err := uc.hasher.Hash(user.Password)
+    if err != nil {
+        return err
+    }
+
+    marked, err := uc.repo.MarkInviteUsed(invite.ID)
+    if err != nil {
+        return err
+    }
+    if !marked {
+        return domain.ErrInvalidInvite
+    }
+    user.Password = hash
+    user.Role = invite.Role
+    if err := uc.users.CreateUser(user); err != nil {
+        return err
+    }
+    if invite.OrgID == nil {
+        return nil
+    }
+    return uc.orgRepo.AddMember(&domain.Membership{
+        OrgID:     *invite.OrgID,
+        UserID:    user.ID,
+        Role:      invite.Role,
+        InvitedBy: invite.CreatedBy,
+        CreatedAt: time.Now(),
+    })
+}
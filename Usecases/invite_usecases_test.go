@@ -0,0 +1,88 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestInviteRegister(t *testing.T) {
+    roles := repositories.NewInMemoryRoleRepository()
+    for _, role := range domain.DefaultRoles() {
+        if err := roles.CreateRole(&role); err != nil {
+            t.Fatalf("CreateRole: %v", err)
+        }
+    }
+    users := repositories.NewInMemoryUserRepository()
+    orgs := repositories.NewInMemoryOrganizationRepository()
+    uc := NewInviteUseCase(repositories.NewInMemoryInviteRepository(), users, roles, orgs, domain.DefaultPasswordPolicy(), plainHasher{}, 0)
+
+    orgID := primitive.NewObjectID()
+    admin := &domain.Principal{UserID: primitive.NewObjectID(), OrgID: orgID, Permissions: domain.AllPermissions}
+    manager := &domain.Principal{UserID: primitive.NewObjectID(), OrgID: orgID, Permissions: []string{domain.PermissionUsersManage}}
+    const password = "k7#vQ2!mzP"
+
+    if _, _, err := uc.CreateInvite(manager, "bob", domain.RoleAdmin); !errors.Is(err, domain.ErrInviteRoleNotPermitted) {
+        t.Fatalf("CreateInvite of an admin without roles.manage: got %v, want %v", err, domain.ErrInviteRoleNotPermitted)
+    }
+    if _, _, err := uc.CreateInvite(admin, "bob", "wizard"); !errors.Is(err, domain.ErrInvalidRole) {
+        t.Fatalf("CreateInvite with an unknown role: got %v, want %v", err, domain.ErrInvalidRole)
+    }
+    token, invite, err := uc.CreateInvite(admin, "bob", domain.RoleAdmin)
+    if err != nil {
+        t.Fatalf("CreateInvite: %v", err)
+    }
+    if invite.OrgID == nil || *invite.OrgID != orgID {
+        t.Fatalf("CreateInvite: org = %v, want %v", invite.OrgID, orgID)
+    }
+
+    if err := uc.Register(token, &domain.User{Username: "eve", Password: password}); !errors.Is(err, domain.ErrValidation) {
+        t.Fatalf("Register with another username: got %v, want a validation error", err)
+    }
+    if err := uc.Register(token, &domain.User{Password: "short"}); !errors.Is(err, domain.ErrValidation) {
+        t.Fatalf("Register with a weak password: got %v, want a validation error", err)
+    }
+    user := &domain.User{Password: password}
+    if err := uc.Register(token, user); err != nil {
+        t.Fatalf("Register: %v", err)
+    }
+    if user.Username != "bob" || user.Role != domain.RoleAdmin {
+        t.Fatalf("Register: got %+v, want bob with the admin role", user)
+    }
+    if membership, err := orgs.GetMembership(orgID, user.ID); err != nil || membership.Role != domain.RoleAdmin || membership.Pending {
+        t.Fatalf("Register: membership %+v, err=%v", membership, err)
+    }
+    if err := uc.Register(token, &domain.User{Password: password}); !errors.Is(err, domain.ErrInvalidInvite) {
+        t.Fatalf("Register twice: got %v, want %v", err, domain.ErrInvalidInvite)
+    }
+    if _, _, err := uc.CreateInvite(admin, "bob", ""); !errors.Is(err, domain.ErrUserExists) {
+        t.Fatalf("CreateInvite for an existing user: got %v, want %v", err, domain.ErrUserExists)
+    }
+
+    // A new invite replaces the earlier one, and neither outlives revocation.
+    first, _, err := uc.CreateInvite(manager, "carol", "")
+    if err != nil {
+        t.Fatalf("CreateInvite: %v", err)
+    }
+    second, invite, err := uc.CreateInvite(manager, "carol", "")
+    if err != nil {
+        t.Fatalf("CreateInvite again: %v", err)
+    }
+    if invite.OrgID != nil {
+        t.Fatal("CreateInvite: invite joins an organization the inviter cannot manage")
+    }
+    if pending, err := uc.ListInvites(); err != nil || len(pending) != 1 || pending[0].ID != invite.ID {
+        t.Fatalf("ListInvites: got %+v, err=%v, want the second invite only", pending, err)
+    }
+    if err := uc.RevokeInvite(invite.ID); err != nil {
+        t.Fatalf("RevokeInvite: %v", err)
+    }
+    for _, token := range []string{first, second} {
+        if err := uc.Register(token, &domain.User{Password: password}); !errors.Is(err, domain.ErrInvalidInvite) {
+            t.Fatalf("Register with a replaced or revoked invite: got %v, want %v", err, domain.ErrInvalidInvite)
+        }
+    }
+}
@@ -0,0 +1,87 @@
+package usecases
+
+import (
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+// loginThrottle slows down password and 2FA code guessing. Failures are
+// counted per username and per client IP, so neither spreading guesses over
+// many accounts nor over many IPs gets around it.
+type loginThrottle struct {
+    attempts domain.LoginAttemptRepository
+    policy   domain.LoginThrottlePolicy
+}
+
+type throttleKey struct {
+    key         string
+    maxFailures int
+}
+
+func (t *loginThrottle) keys(username, ip string) []throttleKey {
+    keys := []throttleKey{{"user:" + username, t.policy.MaxUserFailures}}
+    if ip != "" {
+        keys = append(keys, throttleKey{"ip:" + ip, t.policy.MaxIPFailures})
+    }
+    return keys
+}
+
+// check returns a LockoutError if the username or IP is currently blocked.
+func (t *loginThrottle) check(username, ip string) error {
+    now := time.Now()
+    var retryAfter time.Duration
+    for _, k := range t.keys(username, ip) {
+        attempt, err := t.attempts.GetLoginAttempt(k.key)
+        if err != nil {
+            return err
+        }
+        if wait := attempt.LockedUntil.Sub(now); wait > retryAfter {
+            retryAfter = wait
+        }
+    }
+    if retryAfter > 0 {
+        return &domain.LockoutError{RetryAfter: retryAfter}
+    }
+    return nil
+}
+
+// fail records a failed attempt and blocks the username and IP for an
+// exponentially growing delay, or for the lockout duration once the limit
+// is reached. Unknown usernames are throttled like real ones, so lockouts do
+// not reveal which exist; their records are purged with the others once the
+// failures are forgotten.
+func (t *loginThrottle) fail(username, ip string) error {
+    now := time.Now()
+    for _, k := range t.keys(username, ip) {
+        attempt, err := t.attempts.RecordLoginFailure(k.key, now, now.Add(-t.policy.LockoutDuration))
+        if err != nil {
+            return err
+        }
+        if err := t.attempts.LockLogin(k.key, now.Add(t.delay(attempt.Failures, k.maxFailures))); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (t *loginThrottle) delay(failures, maxFailures int) time.Duration {
+    if failures >= maxFailures {
+        return t.policy.LockoutDuration
+    }
+    delay := t.policy.BaseDelay
+    for i := 1; i < failures && delay < t.policy.MaxDelay; i++ {
+        delay *= 2
+    }
+    if delay > t.policy.MaxDelay {
+        delay = t.policy.MaxDelay
+    }
+    return delay
+}
+
+// reset clears the failures and lockout of the username. The IP's failures
+// are kept, so an attacker cannot clear them by logging in to their own
+// account; they expire LockoutDuration after the last failure.
+func (t *loginThrottle) reset(username string) error {
+    return t.attempts.ResetLoginAttempts("user:" + username)
+}
@@ -0,0 +1,102 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+)
+
+func TestLoginThrottle(t *testing.T) {
+    attempts := repositories.NewInMemoryLoginAttemptRepository()
+    policy := domain.LoginThrottlePolicy{
+        MaxUserFailures: 5,
+        MaxIPFailures:   8,
+        BaseDelay:       time.Second,
+        MaxDelay:        5 * time.Second,
+        LockoutDuration: 15 * time.Minute,
+    }
+    throttle := &loginThrottle{attempts: attempts, policy: policy}
+
+    // lockedFor returns how much longer key is locked.
+    lockedFor := func(key string) time.Duration {
+        t.Helper()
+        attempt, err := attempts.GetLoginAttempt(key)
+        if err != nil {
+            t.Fatalf("GetLoginAttempt: %v", err)
+        }
+        return time.Until(attempt.LockedUntil).Round(time.Second)
+    }
+
+    if err := throttle.check("alice", "10.0.0.1"); err != nil {
+        t.Fatalf("check before any failure: %v", err)
+    }
+    // The delay doubles up to MaxDelay, and the limit locks the username out.
+    for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 15 * time.Minute} {
+        if err := throttle.fail("alice", "10.0.0.1"); err != nil {
+            t.Fatalf("fail: %v", err)
+        }
+        if got := lockedFor("user:alice"); got != want {
+            t.Fatalf("failure %d: alice locked for %v, want %v", i+1, got, want)
+        }
+    }
+    var lockout *domain.LockoutError
+    if err := throttle.check("alice", ""); !errors.As(err, &lockout) || lockout.RetryAfter.Round(time.Second) != 15*time.Minute {
+        t.Fatalf("check after the limit: got %v, want a 15m lockout", err)
+    }
+    if !errors.Is(lockout, domain.ErrTooManyLoginAttempts) {
+        t.Fatalf("LockoutError %v does not match %v", lockout, domain.ErrTooManyLoginAttempts)
+    }
+
+    // Other usernames from the same IP are slowed down by the IP's failures,
+    // and locked out once the IP reaches its own limit.
+    if err := throttle.check("bob", "10.0.0.1"); !errors.Is(err, domain.ErrTooManyLoginAttempts) {
+        t.Fatalf("check of another user from the IP: got %v, want a delay", err)
+    }
+    if err := throttle.check("bob", "10.0.0.2"); err != nil {
+        t.Fatalf("check of another user from another IP: %v", err)
+    }
+    for _, username := range []string{"bob", "carol", "dave"} {
+        if err := throttle.fail(username, "10.0.0.1"); err != nil {
+            t.Fatalf("fail: %v", err)
+        }
+    }
+    if got := lockedFor("ip:10.0.0.1"); got != 15*time.Minute {
+        t.Fatalf("after %d failures, the IP is locked for %v, want 15m", policy.MaxIPFailures, got)
+    }
+    if got := lockedFor("user:bob"); got != time.Second {
+        t.Fatalf("after one failure, bob is locked for %v, want 1s", got)
+    }
+
+    // Unlocking clears the username's lockout, but not the IP's.
+    users := repositories.NewInMemoryUserRepository()
+    if err := users.CreateUser(&domain.User{Username: "alice", Password: "plain:x"}); err != nil {
+        t.Fatalf("CreateUser: %v", err)
+    }
+    auth := NewAuthUseCase(users, nil, nil, nil, nil, attempts, policy, NewPasswordAuthenticator(users, plainHasher{}), nil, 0)
+    if err := auth.UnlockUser("nobody"); !errors.Is(err, domain.ErrUserNotFound) {
+        t.Fatalf("UnlockUser of an unknown user: got %v, want %v", err, domain.ErrUserNotFound)
+    }
+    if err := auth.UnlockUser("alice"); err != nil {
+        t.Fatalf("UnlockUser: %v", err)
+    }
+    if err := throttle.check("alice", "10.0.0.2"); err != nil {
+        t.Fatalf("check after UnlockUser: %v", err)
+    }
+    if err := throttle.check("alice", "10.0.0.1"); !errors.Is(err, domain.ErrTooManyLoginAttempts) {
+        t.Fatalf("check after UnlockUser from the locked IP: got %v, want a lockout", err)
+    }
+    if _, _, err := auth.Login("alice", "x", "10.0.0.1"); !errors.Is(err, domain.ErrTooManyLoginAttempts) {
+        t.Fatalf("Login from the locked IP: got %v, want a lockout", err)
+    }
+
+    // Failures for unknown usernames count like any other.
+    if _, _, err := auth.Login("nobody", "x", "10.0.0.3"); !errors.Is(err, domain.ErrInvalidCredentials) {
+        t.Fatalf("Login of an unknown user: got %v, want %v", err, domain.ErrInvalidCredentials)
+    }
+    if got := lockedFor("user:nobody"); got != time.Second {
+        t.Fatalf("after a failed login, nobody is locked for %v, want 1s", got)
+    }
+}
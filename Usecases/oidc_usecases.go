@@ -0,0 +1,90 @@
+package usecases
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+// OIDCLoginTTL is how long the user has to sign in at the identity provider.
+const OIDCLoginTTL = 10 * time.Minute
+
+type OIDCUseCase struct {
+    provider domain.OIDCProvider
+    logins   domain.OIDCLoginRepository
+    users    *externalUsers
+    auth     domain.AuthUseCaseInterface
+}
+
+func NewOIDCUseCase(provider domain.OIDCProvider, logins domain.OIDCLoginRepository, users domain.UserRepository, auth domain.AuthUseCaseInterface, settings domain.ExternalUserSettings) domain.OIDCUseCaseInterface {
+    return &OIDCUseCase{
+        provider: provider,
+        logins:   logins,
+        users:    newExternalUsers(users, settings),
+        auth:     auth,
+    }
+}
+
+// BeginLogin also clears out the logins that were never completed.
+func (uc *OIDCUseCase) BeginLogin() (string, string, error) {
+    now := time.Now()
+    if err := uc.logins.DeleteExpiredOIDCLogins(now); err != nil {
+        return "", "", err
+    }
+    state, stateHash, err := newOpaqueToken()
+    if err != nil {
+        return "", "", err
+    }
+    nonce, _, err := newOpaqueToken()
+    if err != nil {
+        return "", "", err
+    }
+    verifier, _, err := newOpaqueToken()
+    if err != nil {
+        return "", "", err
+    }
+    login := &domain.OIDCLogin{
+        StateHash:    stateHash,
+        Nonce:        nonce,
+        CodeVerifier: verifier,
+        CreatedAt:    now,
+        ExpiresAt:    now.Add(OIDCLoginTTL),
+    }
+    if err := uc.logins.CreateOIDCLogin(login); err != nil {
+        return "", "", err
+    }
+    return uc.provider.AuthCodeURL(state, nonce, pkceChallenge(verifier)), state, nil
+}
+
+// CompleteLogin uses up the login before redeeming the code, so a state
+// cannot be replayed even if the exchange fails.
+func (uc *OIDCUseCase) CompleteLogin(state, code string) (*domain.AuthToken, *domain.TwoFactorChallenge, error) {
+    login, err := uc.logins.TakeOIDCLogin(hashToken(state))
+    if err != nil {
+        if errors.Is(err, domain.ErrOIDCLoginNotFound) {
+            return nil, nil, domain.ErrInvalidOIDCState
+        }
+        return nil, nil, err
+    }
+    if !time.Now().Before(login.ExpiresAt) {
+        return nil, nil, domain.ErrInvalidOIDCState
+    }
+    identity, err := uc.provider.Exchange(code, login.CodeVerifier, login.Nonce)
+    if err != nil {
+        return nil, nil, err
+    }
+    user, err := uc.users.resolve(identity)
+    if err != nil {
+        return nil, nil, err
+    }
+    return uc.auth.LoginExternal(user)
+}
+
+// pkceChallenge returns the RFC 7636 S256 challenge for a code verifier.
+func pkceChallenge(verifier string) string {
+    sum := sha256.Sum256([]byte(verifier))
+    return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,127 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Infrastructure"
+	"github.com/Hailemari/clean_architecture_task_manager/Infrastructure/oidctest"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+)
+
+// externalLogins stands in for AuthUseCase and records who was let in.
+type externalLogins struct {
+    domain.AuthUseCaseInterface
+    last *domain.User
+}
+
+func (a *externalLogins) LoginExternal(user *domain.User) (*domain.AuthToken, *domain.TwoFactorChallenge, error) {
+    a.last = user
+    return &domain.AuthToken{Role: user.Role}, nil, nil
+}
+
+func TestOIDCLogin(t *testing.T) {
+    idp, err := oidctest.NewProvider("task-manager", "s3cret")
+    if err != nil {
+        t.Fatalf("oidctest.NewProvider: %v", err)
+    }
+    defer idp.Close()
+    provider, err := infrastructure.NewOIDCProvider(infrastructure.OIDCProviderSettings{
+        Issuer:       idp.Issuer,
+        ClientID:     idp.ClientID,
+        ClientSecret: idp.ClientSecret,
+        RedirectURL:  "http://task-manager.test/auth/oidc/callback",
+    }, nil)
+    if err != nil {
+        t.Fatalf("NewOIDCProvider: %v", err)
+    }
+
+    users := repositories.NewInMemoryUserRepository()
+    for _, user := range []*domain.User{
+        {Username: "root", Password: "plain:x", Role: domain.RoleAdmin},
+        {Username: "bob", Password: "plain:x"},
+        {Username: "carol", Password: "plain:x", ExternalID: "idp|someone-else"},
+    } {
+        if err := users.CreateUser(user); err != nil {
+            t.Fatalf("CreateUser: %v", err)
+        }
+    }
+    auth := &externalLogins{}
+    settings := domain.ExternalUserSettings{
+        GroupRoles:    []domain.GroupRole{{Group: "task-admins", Role: domain.RoleAdmin}},
+        LinkExisting:  true,
+        AutoProvision: true,
+    }
+    uc := NewOIDCUseCase(provider, repositories.NewInMemoryOIDCLoginRepository(), users, auth, settings)
+
+    login := func(uc domain.OIDCUseCaseInterface, identity oidctest.Identity) (*domain.User, error) {
+        t.Helper()
+        authURL, state, err := uc.BeginLogin()
+        if err != nil {
+            t.Fatalf("BeginLogin: %v", err)
+        }
+        idp.SignIn(identity)
+        code, returned, err := idp.Authorize(authURL)
+        if err != nil {
+            t.Fatalf("Authorize: %v", err)
+        }
+        if returned != state {
+            t.Fatalf("Authorize: state = %q, want %q", returned, state)
+        }
+        auth.last = nil
+        if _, _, err := uc.CompleteLogin(state, code); err != nil {
+            return nil, err
+        }
+        if _, _, err := uc.CompleteLogin(state, code); !errors.Is(err, domain.ErrInvalidOIDCState) {
+            t.Fatalf("CompleteLogin twice: got %v, want %v", err, domain.ErrInvalidOIDCState)
+        }
+        return auth.last, nil
+    }
+
+    alice, err := login(uc, oidctest.Identity{Subject: "idp|alice", Username: "alice", Groups: []string{"staff", "task-admins"}})
+    if err != nil {
+        t.Fatalf("first login: %v", err)
+    }
+    if alice.Username != "alice" || alice.Role != domain.RoleAdmin || alice.ExternalID != "idp|alice" || alice.Password != "" {
+        t.Fatalf("first login: provisioned %+v, want alice as an admin without a password", alice)
+    }
+    // The subject, not the username, identifies the user; leaving the group
+    // takes the role away.
+    again, err := login(uc, oidctest.Identity{Subject: "idp|alice", Username: "alice.smith", Groups: []string{"staff"}})
+    if err != nil {
+        t.Fatalf("second login: %v", err)
+    }
+    if again.ID != alice.ID || again.Role != domain.RoleUser {
+        t.Fatalf("second login: got %+v, want alice demoted to user", again)
+    }
+    if stored, _ := users.GetUserByUsername("alice"); stored.Role != domain.RoleUser {
+        t.Fatalf("second login: stored role = %q, want user", stored.Role)
+    }
+
+    bob, err := login(uc, oidctest.Identity{Subject: "idp|bob", Username: "bob"})
+    if err != nil {
+        t.Fatalf("login of an existing user: %v", err)
+    }
+    if stored, _ := users.GetUserByExternalID("idp|bob"); stored == nil || stored.ID != bob.ID || stored.Password != "plain:x" {
+        t.Fatalf("login of an existing user: not linked, got %+v", stored)
+    }
+    if _, err := login(uc, oidctest.Identity{Subject: "idp|carol", Username: "carol"}); !errors.Is(err, domain.ErrIdentityConflict) {
+        t.Fatalf("login as a user linked to another identity: got %v, want %v", err, domain.ErrIdentityConflict)
+    }
+
+    strict := NewOIDCUseCase(provider, repositories.NewInMemoryOIDCLoginRepository(), users, auth, domain.ExternalUserSettings{})
+    if _, err := login(strict, oidctest.Identity{Subject: "idp|dave", Username: "dave"}); !errors.Is(err, domain.ErrNotProvisioned) {
+        t.Fatalf("login without provisioning: got %v, want %v", err, domain.ErrNotProvisioned)
+    }
+    if _, err := login(strict, oidctest.Identity{Subject: "idp|root", Username: "root"}); !errors.Is(err, domain.ErrUserExists) {
+        t.Fatalf("login as an unlinked user without linking: got %v, want %v", err, domain.ErrUserExists)
+    }
+    if user, err := login(strict, oidctest.Identity{Subject: "idp|bob", Username: "bob", Groups: []string{"task-admins"}}); err != nil || user.Role != domain.RoleUser {
+        t.Fatalf("login of a linked user without group roles: got %+v, err=%v, want bob keeping the user role", user, err)
+    }
+
+    if _, _, err := uc.CompleteLogin("made-up-state", "code"); !errors.Is(err, domain.ErrInvalidOIDCState) {
+        t.Fatalf("CompleteLogin with an unknown state: got %v, want %v", err, domain.ErrInvalidOIDCState)
+    }
+}
@@ -0,0 +1,327 @@
+package usecases
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// organizationsMigration names the migration that moves data from before
+// organizations into the default organization.
+const organizationsMigration = "organizations"
+
+type OrganizationUseCase struct {
+    repo       domain.OrganizationRepository
+    users      domain.UserRepository
+    roleRepo   domain.RoleRepository
+    taskRepo   domain.TaskRepository
+    migrations domain.MigrationRepository
+}
+
+func NewOrganizationUseCase(repo domain.OrganizationRepository, users domain.UserRepository, roleRepo domain.RoleRepository, taskRepo domain.TaskRepository, migrations domain.MigrationRepository) domain.OrganizationUseCaseInterface {
+    return &OrganizationUseCase{repo: repo, users: users, roleRepo: roleRepo, taskRepo: taskRepo, migrations: migrations}
+}
+
+// Initialize claims the migration on the first start, whatever it finds, so
+// that it never runs again: a new database has no users yet, and one created
+// with organizations already has some. Only a database from before
+// organizations has users but no organization, and then every user joins the
+// default organization with the role they already have, so that nobody loses
+// access to the existing tasks.
+func (uc *OrganizationUseCase) Initialize() (*domain.Organization, error) {
+    claimed, err := uc.migrations.ClaimMigration(organizationsMigration, time.Now())
+    if err != nil || !claimed {
+        return nil, err
+    }
+    count, err := uc.repo.CountOrganizations()
+    if err != nil || count > 0 {
+        return nil, err
+    }
+    var users []domain.UserSummary
+    query := domain.UserQuery{Limit: domain.MaxUserPageSize}
+    for {
+        page, err := uc.users.ListUsers(query)
+        if err != nil {
+            return nil, err
+        }
+        users = append(users, page.Users...)
+        if page.NextCursor == "" {
+            break
+        }
+        query.Cursor = page.NextCursor
+    }
+    if len(users) == 0 {
+        return nil, nil
+    }
+
+    now := time.Now()
+    org := &domain.Organization{Name: domain.DefaultOrganizationName, CreatedAt: now}
+    if err := uc.repo.CreateOrganization(org); err != nil {
+        return nil, err
+    }
+    for _, user := range users {
+        userID, err := primitive.ObjectIDFromHex(user.ID)
+        if err != nil {
+            return nil, err
+        }
+        membership := &domain.Membership{OrgID: org.ID, UserID: userID, Role: user.Role, CreatedAt: now}
+        if err := uc.repo.AddMember(membership); err != nil && !errors.Is(err, domain.ErrAlreadyOrgMember) {
+            return nil, err
+        }
+    }
+    if _, err := uc.taskRepo.AdoptOrphanTasks(org.ID); err != nil {
+        return nil, err
+    }
+    return org, nil
+}
+
+func (uc *OrganizationUseCase) CreateOrganization(principal *domain.Principal, name string) (*domain.Organization, error) {
+    name, err := domain.ValidateOrganizationName(name)
+    if err != nil {
+        return nil, err
+    }
+    return createOrganization(uc.repo, name, principal.UserID)
+}
+
+func (uc *OrganizationUseCase) ListOrganizations(principal *domain.Principal) ([]domain.OrganizationMembership, error) {
+    memberships, err := uc.repo.ListUserMemberships(principal.UserID)
+    if err != nil {
+        return nil, err
+    }
+    orgs := []domain.OrganizationMembership{}
+    for _, membership := range memberships {
+        org, err := uc.repo.GetOrganization(membership.OrgID)
+        if err != nil {
+            if errors.Is(err, domain.ErrOrganizationNotFound) {
+                continue
+            }
+            return nil, err
+        }
+        orgs = append(orgs, domain.OrganizationMembership{
+            Organization: *org,
+            Role:         membership.Role,
+            Pending:      membership.Pending,
+            Current:      org.ID == principal.OrgID,
+        })
+    }
+    return orgs, nil
+}
+
+func (uc *OrganizationUseCase) AcceptInvitation(principal *domain.Principal, orgID primitive.ObjectID) error {
+    return uc.repo.AcceptInvitation(orgID, principal.UserID)
+}
+
+// DeclineInvitation only removes pending memberships; members leave through
+// RemoveMember.
+func (uc *OrganizationUseCase) DeclineInvitation(principal *domain.Principal, orgID primitive.ObjectID) error {
+    membership, err := uc.repo.GetMembership(orgID, principal.UserID)
+    if err != nil {
+        return err
+    }
+    if !membership.Pending {
+        return domain.ErrNotOrgMember
+    }
+    return uc.repo.RemoveMember(orgID, principal.UserID)
+}
+
+func (uc *OrganizationUseCase) ListMembers(principal *domain.Principal) ([]domain.Member, error) {
+    if principal.OrgID.IsZero() {
+        return nil, domain.ErrNoOrganization
+    }
+    memberships, err := uc.repo.ListMembers(principal.OrgID)
+    if err != nil {
+        return nil, err
+    }
+    members := []domain.Member{}
+    for _, membership := range memberships {
+        user, err := uc.users.GetUserByID(membership.UserID)
+        if err != nil {
+            if errors.Is(err, domain.ErrUserNotFound) {
+                continue
+            }
+            return nil, err
+        }
+        members = append(members, domain.Member{
+            Username:  user.Username,
+            Role:      membership.Role,
+            Pending:   membership.Pending,
+            CreatedAt: membership.CreatedAt,
+        })
+    }
+    return members, nil
+}
+
+func (uc *OrganizationUseCase) InviteMember(principal *domain.Principal, username, role string) error {
+    if principal.OrgID.IsZero() {
+        return domain.ErrNoOrganization
+    }
+    if role == "" {
+        role = domain.RoleUser
+    }
+    if err := uc.checkRole(role); err != nil {
+        return err
+    }
+    if err := uc.checkGrantable(principal, role); err != nil {
+        return err
+    }
+    user, err := uc.users.GetUserByUsername(username)
+    if err != nil {
+        return err
+    }
+    return uc.repo.AddMember(&domain.Membership{
+        OrgID:     principal.OrgID,
+        UserID:    user.ID,
+        Role:      role,
+        Pending:   true,
+        InvitedBy: principal.UserID,
+        CreatedAt: time.Now(),
+    })
+}
+
+func (uc *OrganizationUseCase) SetMemberRole(principal *domain.Principal, username, role string) error {
+    if principal.OrgID.IsZero() {
+        return domain.ErrNoOrganization
+    }
+    if err := uc.checkRole(role); err != nil {
+        return err
+    }
+    if err := uc.checkGrantable(principal, role); err != nil {
+        return err
+    }
+    user, err := uc.users.GetUserByUsername(username)
+    if err != nil {
+        return err
+    }
+    if err := uc.checkMemberGrantable(principal, user.ID); err != nil {
+        return err
+    }
+    return uc.repo.SetMemberRole(principal.OrgID, user.ID, role)
+}
+
+func (uc *OrganizationUseCase) RemoveMember(principal *domain.Principal, username string) error {
+    if principal.OrgID.IsZero() {
+        return domain.ErrNoOrganization
+    }
+    user, err := uc.users.GetUserByUsername(username)
+    if err != nil {
+        return err
+    }
+    if err := uc.checkMemberGrantable(principal, user.ID); err != nil {
+        return err
+    }
+    return uc.repo.RemoveMember(principal.OrgID, user.ID)
+}
+
+// Authorize is called for every request, so that changes to roles and
+// memberships apply at once.
+func (uc *OrganizationUseCase) Authorize(principal *domain.Principal) error {
+    own, err := uc.rolePermissions(principal.Role)
+    if err != nil {
+        return err
+    }
+    permissions := slices.DeleteFunc(own, domain.OrgPermission)
+
+    if !principal.OrgID.IsZero() {
+        membership, err := uc.repo.GetMembership(principal.OrgID, principal.UserID)
+        if err != nil && !errors.Is(err, domain.ErrNotOrgMember) {
+            return err
+        }
+        if err != nil || membership.Pending {
+            principal.OrgID = primitive.NilObjectID
+        } else {
+            inOrg, err := uc.rolePermissions(membership.Role)
+            if err != nil {
+                return err
+            }
+            for _, permission := range inOrg {
+                if domain.OrgPermission(permission) {
+                    permissions = append(permissions, permission)
+                }
+            }
+        }
+    }
+    principal.Permissions = permissions
+    return nil
+}
+
+// rolePermissions returns a copy of the role's permissions. A role that does
+// not exist grants none.
+func (uc *OrganizationUseCase) rolePermissions(name string) ([]string, error) {
+    role, err := uc.roleRepo.GetRole(name)
+    if err != nil {
+        if errors.Is(err, domain.ErrRoleNotFound) {
+            return []string{}, nil
+        }
+        return nil, err
+    }
+    return slices.Clone(role.Permissions), nil
+}
+
+// checkGrantable returns ErrOrgRoleNotGrantable if the role grants an
+// organization permission the principal does not have, so that org.manage
+// alone cannot make anyone a more powerful member than its holder.
+func (uc *OrganizationUseCase) checkGrantable(principal *domain.Principal, role string) error {
+    permissions, err := uc.rolePermissions(role)
+    if err != nil {
+        return err
+    }
+    for _, permission := range permissions {
+        if domain.OrgPermission(permission) && !principal.HasPermission(permission) {
+            return domain.ErrOrgRoleNotGrantable
+        }
+    }
+    return nil
+}
+
+// checkMemberGrantable applies checkGrantable to the role the user has, or
+// was invited with, in the principal's current organization.
+func (uc *OrganizationUseCase) checkMemberGrantable(principal *domain.Principal, userID primitive.ObjectID) error {
+    membership, err := uc.repo.GetMembership(principal.OrgID, userID)
+    if err != nil {
+        return err
+    }
+    return uc.checkGrantable(principal, membership.Role)
+}
+
+func (uc *OrganizationUseCase) checkRole(role string) error {
+    if _, err := uc.roleRepo.GetRole(role); err != nil {
+        if errors.Is(err, domain.ErrRoleNotFound) {
+            return domain.ErrInvalidRole
+        }
+        return err
+    }
+    return nil
+}
+
+// defaultOrganization returns the organization the user joined first, or the
+// zero ID if they belong to none.
+func defaultOrganization(repo domain.OrganizationRepository, userID primitive.ObjectID) (primitive.ObjectID, error) {
+    memberships, err := repo.ListUserMemberships(userID)
+    if err != nil {
+        return primitive.NilObjectID, err
+    }
+    for _, membership := range memberships {
+        if !membership.Pending {
+            return membership.OrgID, nil
+        }
+    }
+    return primitive.NilObjectID, nil
+}
+
+// createOrganization creates an organization with the creator as its first
+// admin.
+func createOrganization(repo domain.OrganizationRepository, name string, creator primitive.ObjectID) (*domain.Organization, error) {
+    now := time.Now()
+    org := &domain.Organization{Name: name, CreatedBy: creator, CreatedAt: now}
+    if err := repo.CreateOrganization(org); err != nil {
+        return nil, err
+    }
+    membership := &domain.Membership{OrgID: org.ID, UserID: creator, Role: domain.RoleAdmin, CreatedAt: now}
+    if err := repo.AddMember(membership); err != nil {
+        return nil, err
+    }
+    return org, nil
+}
@@ -0,0 +1,188 @@
+package usecases
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestOrganizationAuthorize(t *testing.T) {
+    roles := repositories.NewInMemoryRoleRepository()
+    for _, role := range domain.DefaultRoles() {
+        if err := roles.CreateRole(&role); err != nil {
+            t.Fatalf("CreateRole: %v", err)
+        }
+    }
+    orgs := repositories.NewInMemoryOrganizationRepository()
+    uc := NewOrganizationUseCase(orgs, repositories.NewInMemoryUserRepository(), roles, repositories.NewInMemoryTaskRepository(), repositories.NewInMemoryMigrationRepository())
+
+    orgID := primitive.NewObjectID()
+    owner, staff, invited := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+    for _, membership := range []domain.Membership{
+        {OrgID: orgID, UserID: owner, Role: domain.RoleAdmin},
+        {OrgID: orgID, UserID: staff, Role: domain.RoleUser},
+        {OrgID: orgID, UserID: invited, Role: domain.RoleAdmin, Pending: true},
+    } {
+        if err := orgs.AddMember(&membership); err != nil {
+            t.Fatalf("AddMember: %v", err)
+        }
+    }
+
+    for _, tc := range []struct {
+        name    string
+        user    primitive.ObjectID
+        role    string
+        inOrg   bool
+        granted []string
+        denied  []string
+    }{
+        {"org admin", owner, domain.RoleUser, true, []string{domain.PermissionTasksDelete, domain.PermissionOrgManage}, []string{domain.PermissionUsersRead}},
+        {"instance admin", staff, domain.RoleAdmin, true, []string{domain.PermissionTasksRead, domain.PermissionUsersManage}, []string{domain.PermissionTasksCreate, domain.PermissionOrgManage}},
+        {"pending invitation", invited, domain.RoleUser, false, nil, []string{domain.PermissionTasksRead}},
+        {"not a member", primitive.NewObjectID(), domain.RoleAdmin, false, []string{domain.PermissionRolesManage}, []string{domain.PermissionTasksRead}},
+    } {
+        principal := &domain.Principal{UserID: tc.user, Role: tc.role, OrgID: orgID}
+        if err := uc.Authorize(principal); err != nil {
+            t.Fatalf("%s: Authorize: %v", tc.name, err)
+        }
+        if inOrg := principal.OrgID == orgID; inOrg != tc.inOrg {
+            t.Fatalf("%s: in organization = %v, want %v", tc.name, inOrg, tc.inOrg)
+        }
+        for _, permission := range tc.granted {
+            if !slices.Contains(principal.Permissions, permission) {
+                t.Fatalf("%s: missing %s in %v", tc.name, permission, principal.Permissions)
+            }
+        }
+        for _, permission := range tc.denied {
+            if slices.Contains(principal.Permissions, permission) {
+                t.Fatalf("%s: unexpected %s in %v", tc.name, permission, principal.Permissions)
+            }
+        }
+    }
+}
+
+func TestOrganizationInitialize(t *testing.T) {
+    // A new database is marked as migrated on the first start, so users who
+    // register later are never moved into the default organization.
+    users := repositories.NewInMemoryUserRepository()
+    orgs := repositories.NewInMemoryOrganizationRepository()
+    migrations := repositories.NewInMemoryMigrationRepository()
+    uc := NewOrganizationUseCase(orgs, users, repositories.NewInMemoryRoleRepository(), repositories.NewInMemoryTaskRepository(), migrations)
+    if org, err := uc.Initialize(); err != nil || org != nil {
+        t.Fatalf("Initialize on a new database: got %+v, err=%v, want none", org, err)
+    }
+    alice := &domain.User{Username: "alice", Role: domain.RoleUser}
+    if err := users.CreateUser(alice); err != nil {
+        t.Fatalf("CreateUser: %v", err)
+    }
+    if org, err := uc.Initialize(); err != nil || org != nil {
+        t.Fatalf("Initialize after a restart: got %+v, err=%v, want none", org, err)
+    }
+    if memberships, err := orgs.ListUserMemberships(alice.ID); err != nil || len(memberships) != 0 {
+        t.Fatalf("ListUserMemberships: got %+v, err=%v, want none", memberships, err)
+    }
+
+    // A database from before organizations is moved into the default
+    // organization, once.
+    tasks := repositories.NewInMemoryTaskRepository()
+    if err := tasks.AddTask(domain.Task{ID: primitive.NewObjectID(), Title: "legacy", CreatedBy: alice.ID}); err != nil {
+        t.Fatalf("AddTask: %v", err)
+    }
+    orgs = repositories.NewInMemoryOrganizationRepository()
+    uc = NewOrganizationUseCase(orgs, users, repositories.NewInMemoryRoleRepository(), tasks, repositories.NewInMemoryMigrationRepository())
+    org, err := uc.Initialize()
+    if err != nil || org == nil || org.Name != domain.DefaultOrganizationName {
+        t.Fatalf("Initialize on a database from before organizations: got %+v, err=%v", org, err)
+    }
+    if membership, err := orgs.GetMembership(org.ID, alice.ID); err != nil || membership.Role != domain.RoleUser {
+        t.Fatalf("GetMembership: got %+v, err=%v, want a user membership", membership, err)
+    }
+    if page, err := tasks.GetTasks(domain.TaskQuery{OrgID: org.ID}); err != nil || len(page.Tasks) != 1 {
+        t.Fatalf("GetTasks in the default organization: got %+v, err=%v, want the legacy task", page, err)
+    }
+    if again, err := uc.Initialize(); err != nil || again != nil {
+        t.Fatalf("Initialize after the migration: got %+v, err=%v, want none", again, err)
+    }
+}
+
+func TestOrganizationMemberRoles(t *testing.T) {
+    roles := repositories.NewInMemoryRoleRepository()
+    manager := domain.Role{Name: "manager", Permissions: []string{domain.PermissionOrgManage, domain.PermissionTasksRead, domain.PermissionTasksCreate}}
+    for _, role := range append(domain.DefaultRoles(), manager) {
+        if err := roles.CreateRole(&role); err != nil {
+            t.Fatalf("CreateRole: %v", err)
+        }
+    }
+    users := repositories.NewInMemoryUserRepository()
+    orgs := repositories.NewInMemoryOrganizationRepository()
+    uc := NewOrganizationUseCase(orgs, users, roles, repositories.NewInMemoryTaskRepository(), repositories.NewInMemoryMigrationRepository())
+
+    principals := map[string]*domain.Principal{}
+    for _, name := range []string{"owner", "lead", "staff", "newcomer"} {
+        user := &domain.User{Username: name, Role: domain.RoleUser}
+        if err := users.CreateUser(user); err != nil {
+            t.Fatalf("CreateUser: %v", err)
+        }
+        principals[name] = &domain.Principal{UserID: user.ID, Username: name, Role: domain.RoleUser}
+    }
+    org, err := uc.CreateOrganization(principals["owner"], "Acme")
+    if err != nil {
+        t.Fatalf("CreateOrganization: %v", err)
+    }
+    for name, role := range map[string]string{"lead": "manager", "staff": domain.RoleUser} {
+        if err := orgs.AddMember(&domain.Membership{OrgID: org.ID, UserID: principals[name].UserID, Role: role}); err != nil {
+            t.Fatalf("AddMember: %v", err)
+        }
+    }
+    authorize := func(name string) *domain.Principal {
+        t.Helper()
+        principal := *principals[name]
+        principal.OrgID = org.ID
+        if err := uc.Authorize(&principal); err != nil {
+            t.Fatalf("Authorize: %v", err)
+        }
+        return &principal
+    }
+    owner, lead := authorize("owner"), authorize("lead")
+
+    // A manager can only give and take away roles within their own.
+    if err := uc.InviteMember(lead, "newcomer", domain.RoleAdmin); !errors.Is(err, domain.ErrOrgRoleNotGrantable) {
+        t.Fatalf("InviteMember as admin by a manager: got %v, want %v", err, domain.ErrOrgRoleNotGrantable)
+    }
+    if err := uc.InviteMember(lead, "newcomer", domain.RoleUser); err != nil {
+        t.Fatalf("InviteMember as user by a manager: %v", err)
+    }
+    if err := uc.SetMemberRole(lead, "staff", domain.RoleAdmin); !errors.Is(err, domain.ErrOrgRoleNotGrantable) {
+        t.Fatalf("SetMemberRole to admin by a manager: got %v, want %v", err, domain.ErrOrgRoleNotGrantable)
+    }
+    if err := uc.SetMemberRole(lead, "staff", "manager"); err != nil {
+        t.Fatalf("SetMemberRole to manager by a manager: %v", err)
+    }
+    if err := uc.SetMemberRole(lead, "owner", domain.RoleUser); !errors.Is(err, domain.ErrOrgRoleNotGrantable) {
+        t.Fatalf("SetMemberRole of an admin by a manager: got %v, want %v", err, domain.ErrOrgRoleNotGrantable)
+    }
+    if err := uc.RemoveMember(lead, "owner"); !errors.Is(err, domain.ErrOrgRoleNotGrantable) {
+        t.Fatalf("RemoveMember of an admin by a manager: got %v, want %v", err, domain.ErrOrgRoleNotGrantable)
+    }
+    if err := uc.RemoveMember(lead, "staff"); err != nil {
+        t.Fatalf("RemoveMember of a manager by a manager: %v", err)
+    }
+
+    // The last admin can be neither demoted nor removed.
+    if err := uc.SetMemberRole(owner, "owner", domain.RoleUser); !errors.Is(err, domain.ErrLastOrgAdmin) {
+        t.Fatalf("SetMemberRole of the last admin: got %v, want %v", err, domain.ErrLastOrgAdmin)
+    }
+    if err := uc.RemoveMember(owner, "owner"); !errors.Is(err, domain.ErrLastOrgAdmin) {
+        t.Fatalf("RemoveMember of the last admin: got %v, want %v", err, domain.ErrLastOrgAdmin)
+    }
+    if err := uc.SetMemberRole(owner, "lead", domain.RoleAdmin); err != nil {
+        t.Fatalf("SetMemberRole to admin by an admin: %v", err)
+    }
+    if err := uc.RemoveMember(owner, "owner"); err != nil {
+        t.Fatalf("RemoveMember of an admin who is not the last: %v", err)
+    }
+}
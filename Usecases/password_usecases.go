@@ -0,0 +1,188 @@
+package usecases
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultPasswordResetTTL is how long a reset token stays valid.
+const DefaultPasswordResetTTL = time.Hour
+
+type PasswordUseCase struct {
+    repo        domain.UserRepository
+    roleRepo    domain.RoleRepository
+    refreshRepo domain.RefreshTokenRepository
+    revocations domain.TokenRevocationRepository
+    accessRepo  domain.AccessTokenRepository
+    resetRepo   domain.PasswordResetRepository
+    notifier    domain.PasswordResetNotifier
+    policy      domain.PasswordPolicy
+    hasher      domain.PasswordHasher
+    resetTTL    time.Duration
+}
+
+// NewPasswordUseCase returns the password change and reset use case. notifier
+// may be nil, in which case reset tokens are returned to the issuing admin.
+func NewPasswordUseCase(repo domain.UserRepository, roleRepo domain.RoleRepository, refreshRepo domain.RefreshTokenRepository, revocations domain.TokenRevocationRepository, accessRepo domain.AccessTokenRepository, resetRepo domain.PasswordResetRepository, notifier domain.PasswordResetNotifier, policy domain.PasswordPolicy, hasher domain.PasswordHasher, resetTTL time.Duration) domain.PasswordUseCaseInterface {
+    if resetTTL <= 0 {
+        resetTTL = DefaultPasswordResetTTL
+    }
+    return &PasswordUseCase{
+        repo:        repo,
+        roleRepo:    roleRepo,
+        refreshRepo: refreshRepo,
+        revocations: revocations,
+        accessRepo:  accessRepo,
+        resetRepo:   resetRepo,
+        notifier:    notifier,
+        policy:      policy,
+        hasher:      hasher,
+        resetTTL:    resetTTL,
+    }
+}
+
+// ChangePassword signs the user out of every session, including the one that
+// made the change, and revokes their personal access tokens, which may have
+// been created by whoever knew the old password.
+func (uc *PasswordUseCase) ChangePassword(userID primitive.ObjectID, currentPassword, newPassword string) error {
+    user, err := uc.repo.GetUserByID(userID)
+    if err != nil {
+        return err
+    }
+    match := false
+    if user.Password != "" {
+        match, _, err = uc.hasher.Verify(user.Password, currentPassword)
+        if err != nil {
+            return err
+        }
+    }
+    if !match {
+        return &domain.ValidationError{Fields: []domain.FieldError{
+            {Field: "current_password", Code: "incorrect", Message: "current password is incorrect"},
+        }}
+    }
+    if newPassword == currentPassword {
+        return &domain.ValidationError{Fields: []domain.FieldError{
+            {Field: "new_password", Code: "same_as_current", Message: "new password must differ from the current one"},
+        }}
+    }
+    if err := uc.setPassword(user, newPassword); err != nil {
+        return err
+    }
+    return uc.resetRepo.InvalidateUserPasswordResetTokens(user.ID)
+}
+
+func (uc *PasswordUseCase) IssuePasswordReset(principal *domain.Principal, username string) (*domain.PasswordReset, error) {
+    user, err := uc.repo.GetUserByUsername(username)
+    if err != nil {
+        return nil, err
+    }
+    if err := checkManageable(uc.roleRepo, principal, user); err != nil {
+        return nil, err
+    }
+    if err := uc.resetRepo.InvalidateUserPasswordResetTokens(user.ID); err != nil {
+        return nil, err
+    }
+
+    raw, hash, err := newOpaqueToken()
+    if err != nil {
+        return nil, err
+    }
+    now := time.Now()
+    token := &domain.PasswordResetToken{
+        TokenHash: hash,
+        UserID:    user.ID,
+        CreatedAt: now,
+        ExpiresAt: now.Add(uc.resetTTL),
+    }
+    if err := uc.resetRepo.CreatePasswordResetToken(token); err != nil {
+        return nil, err
+    }
+
+    if uc.notifier != nil {
+        if err := uc.notifier.SendPasswordReset(user, raw, token.ExpiresAt); err != nil {
+            return nil, err
+        }
+        return &domain.PasswordReset{ExpiresAt: token.ExpiresAt, Delivered: true}, nil
+    }
+    return &domain.PasswordReset{Token: raw, ExpiresAt: token.ExpiresAt}, nil
+}
+
+// ResetPassword sets a new password with a reset token, and like
+// ChangePassword signs the user out of every session and revokes their
+// personal access tokens. A password the policy rejects does not use up the
+// token.
+func (uc *PasswordUseCase) ResetPassword(rawToken, newPassword string) error {
+    token, err := uc.resetRepo.GetPasswordResetTokenByHash(hashToken(rawToken))
+    if err != nil {
+        if errors.Is(err, domain.ErrPasswordResetTokenNotFound) {
+            return domain.ErrInvalidPasswordResetToken
+        }
+        return err
+    }
+    if token.Used || time.Now().After(token.ExpiresAt) {
+        return domain.ErrInvalidPasswordResetToken
+    }
+    user, err := uc.repo.GetUserByID(token.UserID)
+    if err != nil {
+        if errors.Is(err, domain.ErrUserNotFound) {
+            return domain.ErrInvalidPasswordResetToken
+        }
+        return err
+    }
+    if err := uc.validateNewPassword(user, newPassword); err != nil {
+        return err
+    }
+
+    // Claiming the token before changing the password stops two concurrent
+    // requests from both using it.
+    marked, err := uc.resetRepo.MarkPasswordResetTokenUsed(token.ID)
+    if err != nil {
+        return err
+    }
+    if !marked {
+        return domain.ErrInvalidPasswordResetToken
+    }
+    if err := uc.setPassword(user, newPassword); err != nil {
+        return err
+    }
+    return uc.resetRepo.InvalidateUserPasswordResetTokens(user.ID)
+}
+
+// validateNewPassword applies the password policy, reporting problems against
+// the new_password field.
+func (uc *PasswordUseCase) validateNewPassword(user *domain.User, password string) error {
+    err := uc.policy.Validate(user.Username, password)
+    var validation *domain.ValidationError
+    if errors.As(err, &validation) {
+        for i := range validation.Fields {
+            validation.Fields[i].Field = "new_password"
+        }
+    }
+    return err
+}
+
+// setPassword stores the new password and revokes the user's access, refresh
+// and personal access tokens.
+func (uc *PasswordUseCase) setPassword(user *domain.User, password string) error {
+    if err := uc.validateNewPassword(user, password); err != nil {
+        return err
+    }
+    hash, err := uc.hasher.Hash(password)
+    if err != nil {
+        return err
+    }
+    if err := uc.repo.UpdatePassword(user.ID, hash); err != nil {
+        return err
+    }
+    if err := uc.revocations.RevokeUserTokens(user.ID, time.Now()); err != nil {
+        return err
+    }
+    if err := uc.refreshRepo.RevokeUserRefreshTokens(user.ID); err != nil {
+        return err
+    }
+    return uc.accessRepo.RevokeUserAccessTokens(user.ID)
+}
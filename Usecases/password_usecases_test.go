@@ -0,0 +1,155 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recordingNotifier keeps the last reset token it was asked to deliver.
+type recordingNotifier struct {
+    token string
+}
+
+func (n *recordingNotifier) SendPasswordReset(user *domain.User, token string, expiresAt time.Time) error {
+    n.token = token
+    return nil
+}
+
+func TestPasswordChangeAndReset(t *testing.T) {
+    users := repositories.NewInMemoryUserRepository()
+    alice := &domain.User{Username: "alice", Password: "plain:Old-password-1", Role: domain.RoleUser}
+    if err := users.CreateUser(alice); err != nil {
+        t.Fatalf("CreateUser: %v", err)
+    }
+    refresh := repositories.NewInMemoryRefreshTokenRepository()
+    revocations := repositories.NewInMemoryTokenRevocationRepository()
+    access := repositories.NewInMemoryAccessTokenRepository()
+    resets := repositories.NewInMemoryPasswordResetRepository()
+    uc := NewPasswordUseCase(users, repositories.NewInMemoryRoleRepository(), refresh, revocations, access, resets, nil, domain.DefaultPasswordPolicy(), plainHasher{}, 0)
+    admin := &domain.Principal{UserID: primitive.NewObjectID(), Permissions: domain.AllPermissions}
+
+    // signIn gives alice a refresh token and a personal access token.
+    signIn := func() (*domain.RefreshToken, *domain.PersonalAccessToken) {
+        t.Helper()
+        session := &domain.RefreshToken{TokenHash: primitive.NewObjectID().Hex(), UserID: alice.ID, FamilyID: primitive.NewObjectID(), ExpiresAt: time.Now().Add(time.Hour)}
+        if err := refresh.CreateRefreshToken(session); err != nil {
+            t.Fatalf("CreateRefreshToken: %v", err)
+        }
+        pat := &domain.PersonalAccessToken{UserID: alice.ID, TokenHash: primitive.NewObjectID().Hex(), Scopes: []string{domain.ScopeTasksRead}, ExpiresAt: time.Now().Add(time.Hour)}
+        if err := access.CreateAccessToken(pat); err != nil {
+            t.Fatalf("CreateAccessToken: %v", err)
+        }
+        return session, pat
+    }
+    fieldCode := func(err error) string {
+        var validation *domain.ValidationError
+        if !errors.As(err, &validation) || len(validation.Fields) == 0 {
+            return ""
+        }
+        return validation.Fields[0].Field + ":" + validation.Fields[0].Code
+    }
+    issue := func() string {
+        t.Helper()
+        reset, err := uc.IssuePasswordReset(admin, "alice")
+        if err != nil || reset.Token == "" || reset.Delivered {
+            t.Fatalf("IssuePasswordReset: got %+v, err=%v", reset, err)
+        }
+        return reset.Token
+    }
+
+    // ChangePassword checks the current password, signs every session out and
+    // revokes personal access tokens.
+    if err := uc.ChangePassword(alice.ID, "wrong", "New-password-2"); fieldCode(err) != "current_password:incorrect" {
+        t.Fatalf("ChangePassword with a wrong password: got %v", err)
+    }
+    if err := uc.ChangePassword(alice.ID, "Old-password-1", "Old-password-1"); fieldCode(err) != "new_password:same_as_current" {
+        t.Fatalf("ChangePassword to the same password: got %v", err)
+    }
+    if err := uc.ChangePassword(alice.ID, "Old-password-1", "short"); fieldCode(err) == "" || !errors.Is(err, domain.ErrValidation) {
+        t.Fatalf("ChangePassword to a weak password: got %v, want a validation error", err)
+    }
+    pending := issue()
+    session, pat := signIn()
+    if err := uc.ChangePassword(alice.ID, "Old-password-1", "New-password-2"); err != nil {
+        t.Fatalf("ChangePassword: %v", err)
+    }
+    if user, _ := users.GetUserByID(alice.ID); user.Password != "plain:New-password-2" {
+        t.Fatalf("ChangePassword: stored %q", user.Password)
+    }
+    if cutoff, _ := revocations.UserTokensRevokedBefore(alice.ID); cutoff.IsZero() {
+        t.Fatal("ChangePassword: access tokens were not revoked")
+    }
+    if stored, _ := refresh.GetRefreshTokenByHash(session.TokenHash); !stored.Revoked {
+        t.Fatal("ChangePassword: the refresh token was not revoked")
+    }
+    if stored, _ := access.GetAccessTokenByHash(pat.TokenHash); !stored.Revoked {
+        t.Fatal("ChangePassword: the personal access token was not revoked")
+    }
+    if err := uc.ResetPassword(pending, "Reset-password-3"); !errors.Is(err, domain.ErrInvalidPasswordResetToken) {
+        t.Fatalf("ResetPassword with a token issued before ChangePassword: got %v, want %v", err, domain.ErrInvalidPasswordResetToken)
+    }
+
+    // Issuing a reset replaces the earlier token.
+    replaced := issue()
+    token := issue()
+    if err := uc.ResetPassword(replaced, "Reset-password-3"); !errors.Is(err, domain.ErrInvalidPasswordResetToken) {
+        t.Fatalf("ResetPassword with a replaced token: got %v, want %v", err, domain.ErrInvalidPasswordResetToken)
+    }
+    if _, err := uc.IssuePasswordReset(admin, "nobody"); !errors.Is(err, domain.ErrUserNotFound) {
+        t.Fatalf("IssuePasswordReset for an unknown user: got %v, want %v", err, domain.ErrUserNotFound)
+    }
+
+    // A rejected password does not use up the token, which then works once
+    // and revokes personal access tokens too.
+    if err := uc.ResetPassword(token, "alice-alice"); fieldCode(err) == "" {
+        t.Fatalf("ResetPassword to a password containing the username: got %v, want a validation error", err)
+    }
+    session, pat = signIn()
+    if err := uc.ResetPassword(token, "Reset-password-3"); err != nil {
+        t.Fatalf("ResetPassword after a rejected password: %v", err)
+    }
+    if user, _ := users.GetUserByID(alice.ID); user.Password != "plain:Reset-password-3" {
+        t.Fatalf("ResetPassword: stored %q", user.Password)
+    }
+    if stored, _ := refresh.GetRefreshTokenByHash(session.TokenHash); !stored.Revoked {
+        t.Fatal("ResetPassword: the refresh token was not revoked")
+    }
+    if stored, _ := access.GetAccessTokenByHash(pat.TokenHash); !stored.Revoked {
+        t.Fatal("ResetPassword: the personal access token was not revoked")
+    }
+    if err := uc.ResetPassword(token, "Another-password-4"); !errors.Is(err, domain.ErrInvalidPasswordResetToken) {
+        t.Fatalf("ResetPassword with a used token: got %v, want %v", err, domain.ErrInvalidPasswordResetToken)
+    }
+    if err := uc.ResetPassword("unknown", "Another-password-4"); !errors.Is(err, domain.ErrInvalidPasswordResetToken) {
+        t.Fatalf("ResetPassword with an unknown token: got %v, want %v", err, domain.ErrInvalidPasswordResetToken)
+    }
+
+    // An expired token is refused.
+    raw, hash, err := newOpaqueToken()
+    if err != nil {
+        t.Fatalf("newOpaqueToken: %v", err)
+    }
+    expired := &domain.PasswordResetToken{TokenHash: hash, UserID: alice.ID, CreatedAt: time.Now().Add(-2 * time.Hour), ExpiresAt: time.Now().Add(-time.Hour)}
+    if err := resets.CreatePasswordResetToken(expired); err != nil {
+        t.Fatalf("CreatePasswordResetToken: %v", err)
+    }
+    if err := uc.ResetPassword(raw, "Another-password-4"); !errors.Is(err, domain.ErrInvalidPasswordResetToken) {
+        t.Fatalf("ResetPassword with an expired token: got %v, want %v", err, domain.ErrInvalidPasswordResetToken)
+    }
+
+    // With a notifier, the token goes to the user and not to the admin.
+    notifier := &recordingNotifier{}
+    notified := NewPasswordUseCase(users, repositories.NewInMemoryRoleRepository(), refresh, revocations, access, resets, notifier, domain.DefaultPasswordPolicy(), plainHasher{}, 0)
+    reset, err := notified.IssuePasswordReset(admin, "alice")
+    if err != nil || reset.Token != "" || !reset.Delivered || notifier.token == "" {
+        t.Fatalf("IssuePasswordReset with a notifier: got %+v, err=%v, delivered %q", reset, err, notifier.token)
+    }
+    if err := notified.ResetPassword(notifier.token, "Notified-password-5"); err != nil {
+        t.Fatalf("ResetPassword with a delivered token: %v", err)
+    }
+}
@@ -0,0 +1,93 @@
+package usecases
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+)
+
+type RoleUseCase struct {
+    repo  domain.RoleRepository
+    users domain.UserRepository
+    orgs  domain.OrganizationRepository
+}
+
+func NewRoleUseCase(repo domain.RoleRepository, users domain.UserRepository, orgs domain.OrganizationRepository) domain.RoleUseCaseInterface {
+    return &RoleUseCase{repo: repo, users: users, orgs: orgs}
+}
+
+// Initialize also brings the admin role up to date when permissions are
+// added in a new version.
+func (uc *RoleUseCase) Initialize() error {
+    for _, role := range domain.DefaultRoles() {
+        if err := uc.repo.CreateRole(&role); err != nil && !errors.Is(err, domain.ErrRoleExists) {
+            return err
+        }
+    }
+    admin, err := uc.repo.GetRole(domain.RoleAdmin)
+    if err != nil {
+        return err
+    }
+    if slices.Equal(admin.Permissions, domain.AllPermissions) {
+        return nil
+    }
+    admin.Permissions = slices.Clone(domain.AllPermissions)
+    return uc.repo.UpdateRole(admin)
+}
+
+func (uc *RoleUseCase) ListRoles() ([]domain.Role, error) {
+    return uc.repo.ListRoles()
+}
+
+func (uc *RoleUseCase) CreateRole(role *domain.Role) error {
+    if err := role.Validate(); err != nil {
+        return err
+    }
+    role.BuiltIn = false
+    return uc.repo.CreateRole(role)
+}
+
+func (uc *RoleUseCase) UpdateRole(role *domain.Role) error {
+    if err := role.Validate(); err != nil {
+        return err
+    }
+    if role.Name == domain.RoleAdmin {
+        return domain.ErrAdminRoleChanged
+    }
+    if err := uc.repo.UpdateRole(role); err != nil {
+        return err
+    }
+    stored, err := uc.repo.GetRole(role.Name)
+    if err != nil {
+        return err
+    }
+    *role = *stored
+    return nil
+}
+
+func (uc *RoleUseCase) DeleteRole(name string) error {
+    role, err := uc.repo.GetRole(name)
+    if err != nil {
+        return err
+    }
+    if role.BuiltIn {
+        return domain.ErrBuiltInRole
+    }
+    users, err := uc.users.ListUsers(domain.UserQuery{Role: name, Limit: 1})
+    if err != nil {
+        return err
+    }
+    if len(users.Users) > 0 {
+        return domain.ErrRoleInUse
+    }
+    members, err := uc.orgs.CountMembersWithRole(name)
+    if err != nil {
+        return err
+    }
+    if members > 0 {
+        return domain.ErrRoleInUse
+    }
+    return uc.repo.DeleteRole(name)
+}
+
@@ -0,0 +1,519 @@
+package usecases
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// scimMemberPath matches a PATCH path selecting one group member, as in
+// members[value eq "66b0..."].
+var scimMemberPath = regexp.MustCompile(`(?i)^members\[(.*)\]$`)
+
+// SCIMUseCase maps SCIM users onto users and SCIM groups onto roles. As users
+// have one role, they are in exactly one group.
+type SCIMUseCase struct {
+    users       domain.UserRepository
+    roles       domain.RoleRepository
+    orgs        domain.OrganizationRepository
+    refreshRepo domain.RefreshTokenRepository
+    revocations domain.TokenRevocationRepository
+    userUC      domain.UserUseCaseInterface
+    roleUC      domain.RoleUseCaseInterface
+    policy      domain.PasswordPolicy
+    hasher      domain.PasswordHasher
+}
+
+func NewSCIMUseCase(users domain.UserRepository, roles domain.RoleRepository, orgs domain.OrganizationRepository, refreshRepo domain.RefreshTokenRepository, revocations domain.TokenRevocationRepository, userUC domain.UserUseCaseInterface, roleUC domain.RoleUseCaseInterface, policy domain.PasswordPolicy, hasher domain.PasswordHasher) domain.SCIMUseCaseInterface {
+    return &SCIMUseCase{
+        users:       users,
+        roles:       roles,
+        orgs:        orgs,
+        refreshRepo: refreshRepo,
+        revocations: revocations,
+        userUC:      userUC,
+        roleUC:      roleUC,
+        policy:      policy,
+        hasher:      hasher,
+    }
+}
+
+func (uc *SCIMUseCase) ListUsers(query domain.SCIMListQuery) (*domain.SCIMListResponse, error) {
+    query.Normalize()
+    username, filtered, err := query.ParseFilter("userName")
+    if err != nil {
+        return nil, err
+    }
+    var users []domain.UserSummary
+    if filtered {
+        user, err := uc.users.GetUserByUsername(username)
+        if err == nil {
+            users = append(users, domain.NewUserSummary(*user))
+        } else if !errors.Is(err, domain.ErrUserNotFound) {
+            return nil, err
+        }
+    } else if users, err = uc.allUsers(""); err != nil {
+        return nil, err
+    }
+
+    start, end := scimPage(len(users), query)
+    resources := []*domain.SCIMUser{}
+    for _, user := range users[start:end] {
+        resources = append(resources, newSCIMUser(user))
+    }
+    return newSCIMListResponse(len(users), query, resources, len(resources)), nil
+}
+
+func (uc *SCIMUseCase) GetUser(id string) (*domain.SCIMUser, error) {
+    user, err := uc.findUser(id)
+    if err != nil {
+        return nil, err
+    }
+    return newSCIMUser(domain.NewUserSummary(*user)), nil
+}
+
+func (uc *SCIMUseCase) CreateUser(principal *domain.Principal, in *domain.SCIMUser) (*domain.SCIMUser, error) {
+    if in.UserName == "" {
+        return nil, &domain.ValidationError{Fields: []domain.FieldError{
+            {Field: "userName", Code: "required", Message: "userName cannot be empty"},
+        }}
+    }
+    user := &domain.User{
+        Username:    in.UserName,
+        Role:        domain.RoleUser,
+        Deactivated: in.Active != nil && !*in.Active,
+    }
+    if in.Password != "" {
+        hash, err := uc.hashPassword(user.Username, in.Password)
+        if err != nil {
+            return nil, err
+        }
+        user.Password = hash
+    }
+    if err := uc.users.CreateUser(user); err != nil {
+        return nil, err
+    }
+    if !principal.OrgID.IsZero() && principal.HasPermission(domain.PermissionOrgManage) {
+        err := uc.orgs.AddMember(&domain.Membership{
+            OrgID:     principal.OrgID,
+            UserID:    user.ID,
+            Role:      domain.RoleUser,
+            InvitedBy: principal.UserID,
+            CreatedAt: time.Now(),
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+    return newSCIMUser(domain.NewUserSummary(*user)), nil
+}
+
+func (uc *SCIMUseCase) ReplaceUser(principal *domain.Principal, id string, in *domain.SCIMUser) (*domain.SCIMUser, error) {
+    user, err := uc.findUser(id)
+    if err != nil {
+        return nil, err
+    }
+    if in.UserName != user.Username {
+        return nil, fmt.Errorf("%w: userName", domain.ErrSCIMImmutable)
+    }
+    if in.Active != nil {
+        if err := uc.setActive(principal, user, *in.Active); err != nil {
+            return nil, err
+        }
+    }
+    if in.Password != "" {
+        if err := uc.setPassword(principal, user, in.Password); err != nil {
+            return nil, err
+        }
+    }
+    return uc.GetUser(id)
+}
+
+// PatchUser ignores the attributes that are not stored, so that clients
+// syncing names or emails are not refused.
+func (uc *SCIMUseCase) PatchUser(principal *domain.Principal, id string, patch *domain.SCIMPatch) (*domain.SCIMUser, error) {
+    user, err := uc.findUser(id)
+    if err != nil {
+        return nil, err
+    }
+    for _, operation := range patch.Operations {
+        op := strings.ToLower(operation.Op)
+        if op != "add" && op != "replace" && op != "remove" {
+            return nil, fmt.Errorf("%w: unknown op %q", domain.ErrInvalidSCIMRequest, operation.Op)
+        }
+        if operation.Path != "" {
+            if err := uc.patchUserAttribute(principal, user, op, operation.Path, operation.Value); err != nil {
+                return nil, err
+            }
+            continue
+        }
+        var attributes map[string]json.RawMessage
+        if op == "remove" || json.Unmarshal(operation.Value, &attributes) != nil {
+            return nil, fmt.Errorf("%w: %s without a path needs an object value", domain.ErrInvalidSCIMRequest, operation.Op)
+        }
+        for name, value := range attributes {
+            if err := uc.patchUserAttribute(principal, user, op, name, value); err != nil {
+                return nil, err
+            }
+        }
+    }
+    return uc.GetUser(id)
+}
+
+func (uc *SCIMUseCase) patchUserAttribute(principal *domain.Principal, user *domain.User, op, path string, value json.RawMessage) error {
+    switch strings.ToLower(path) {
+    case "active":
+        active, err := scimBool(value)
+        if op == "remove" || err != nil {
+            return fmt.Errorf("%w: active must be true or false", domain.ErrInvalidSCIMRequest)
+        }
+        return uc.setActive(principal, user, active)
+    case "username":
+        var username string
+        if op == "remove" || json.Unmarshal(value, &username) != nil || username != user.Username {
+            return fmt.Errorf("%w: userName", domain.ErrSCIMImmutable)
+        }
+        return nil
+    case "password":
+        var password string
+        if op == "remove" || json.Unmarshal(value, &password) != nil || password == "" {
+            return fmt.Errorf("%w: password must be a non-empty string", domain.ErrInvalidSCIMRequest)
+        }
+        return uc.setPassword(principal, user, password)
+    default:
+        return nil
+    }
+}
+
+// DeleteUser deletes the user and unassigns their tasks.
+func (uc *SCIMUseCase) DeleteUser(principal *domain.Principal, id string) error {
+    user, err := uc.findUser(id)
+    if err != nil {
+        return err
+    }
+    _, err = uc.userUC.DeleteUser(principal, user.Username, domain.TaskPolicyUnassign, "")
+    return err
+}
+
+func (uc *SCIMUseCase) ListGroups(query domain.SCIMListQuery) (*domain.SCIMListResponse, error) {
+    query.Normalize()
+    name, filtered, err := query.ParseFilter("displayName")
+    if err != nil {
+        return nil, err
+    }
+    var roles []domain.Role
+    if filtered {
+        role, err := uc.roles.GetRole(name)
+        if err == nil {
+            roles = append(roles, *role)
+        } else if !errors.Is(err, domain.ErrRoleNotFound) {
+            return nil, err
+        }
+    } else if roles, err = uc.roles.ListRoles(); err != nil {
+        return nil, err
+    }
+
+    start, end := scimPage(len(roles), query)
+    resources := []*domain.SCIMGroup{}
+    for _, role := range roles[start:end] {
+        group, err := uc.group(role.Name)
+        if err != nil {
+            return nil, err
+        }
+        resources = append(resources, group)
+    }
+    return newSCIMListResponse(len(roles), query, resources, len(resources)), nil
+}
+
+func (uc *SCIMUseCase) GetGroup(id string) (*domain.SCIMGroup, error) {
+    if _, err := uc.roles.GetRole(id); err != nil {
+        return nil, err
+    }
+    return uc.group(id)
+}
+
+// CreateGroup leaves giving the new role permissions to an admin.
+func (uc *SCIMUseCase) CreateGroup(in *domain.SCIMGroup) (*domain.SCIMGroup, error) {
+    role := &domain.Role{Name: in.DisplayName, Description: "Created by SCIM provisioning"}
+    if err := uc.roleUC.CreateRole(role); err != nil {
+        var validation *domain.ValidationError
+        if errors.As(err, &validation) {
+            for i := range validation.Fields {
+                validation.Fields[i].Field = "displayName"
+            }
+        }
+        return nil, err
+    }
+    for _, member := range in.Members {
+        if err := uc.addMember(role.Name, member.Value); err != nil {
+            return nil, err
+        }
+    }
+    return uc.group(role.Name)
+}
+
+func (uc *SCIMUseCase) PatchGroup(id string, patch *domain.SCIMPatch) (*domain.SCIMGroup, error) {
+    if _, err := uc.roles.GetRole(id); err != nil {
+        return nil, err
+    }
+    for _, operation := range patch.Operations {
+        op := strings.ToLower(operation.Op)
+        if op != "add" && op != "replace" && op != "remove" {
+            return nil, fmt.Errorf("%w: unknown op %q", domain.ErrInvalidSCIMRequest, operation.Op)
+        }
+        if operation.Path != "" {
+            if err := uc.patchGroupAttribute(id, op, operation.Path, operation.Value); err != nil {
+                return nil, err
+            }
+            continue
+        }
+        var attributes map[string]json.RawMessage
+        if op == "remove" || json.Unmarshal(operation.Value, &attributes) != nil {
+            return nil, fmt.Errorf("%w: %s without a path needs an object value", domain.ErrInvalidSCIMRequest, operation.Op)
+        }
+        for name, value := range attributes {
+            if err := uc.patchGroupAttribute(id, op, name, value); err != nil {
+                return nil, err
+            }
+        }
+    }
+    return uc.group(id)
+}
+
+func (uc *SCIMUseCase) patchGroupAttribute(role, op, path string, value json.RawMessage) error {
+    if match := scimMemberPath.FindStringSubmatch(path); match != nil {
+        filter := domain.SCIMListQuery{Filter: match[1]}
+        id, ok, err := filter.ParseFilter("value")
+        if op != "remove" || !ok || err != nil {
+            return fmt.Errorf("%w: unsupported path %q", domain.ErrInvalidSCIMRequest, path)
+        }
+        return uc.removeMember(role, id)
+    }
+
+    switch strings.ToLower(path) {
+    case "displayname":
+        var name string
+        if op == "remove" || json.Unmarshal(value, &name) != nil || name != role {
+            return fmt.Errorf("%w: displayName", domain.ErrSCIMImmutable)
+        }
+        return nil
+    case "members":
+    default:
+        return fmt.Errorf("%w: unsupported path %q", domain.ErrInvalidSCIMRequest, path)
+    }
+
+    var members []domain.SCIMMember
+    if len(value) > 0 && json.Unmarshal(value, &members) != nil {
+        return fmt.Errorf("%w: members must be a list of {\"value\": id}", domain.ErrInvalidSCIMRequest)
+    }
+    switch op {
+    case "add":
+        for _, member := range members {
+            if err := uc.addMember(role, member.Value); err != nil {
+                return err
+            }
+        }
+        return nil
+    case "remove":
+        if len(value) > 0 {
+            for _, member := range members {
+                if err := uc.removeMember(role, member.Value); err != nil {
+                    return err
+                }
+            }
+            return nil
+        }
+    }
+
+    // Replacing the members, or removing them all.
+    keep := make(map[string]bool)
+    for _, member := range members {
+        keep[member.Value] = true
+    }
+    current, err := uc.allUsers(role)
+    if err != nil {
+        return err
+    }
+    for _, user := range current {
+        if !keep[user.ID] {
+            if err := uc.removeMember(role, user.ID); err != nil {
+                return err
+            }
+        }
+    }
+    for _, member := range members {
+        if err := uc.addMember(role, member.Value); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// DeleteGroup fails with ErrRoleInUse while the group has members.
+func (uc *SCIMUseCase) DeleteGroup(id string) error {
+    return uc.roleUC.DeleteRole(id)
+}
+
+// addMember gives the user the role, taking them out of their previous group.
+func (uc *SCIMUseCase) addMember(role, userID string) error {
+    user, err := uc.findMember(userID)
+    if err != nil {
+        return err
+    }
+    return uc.userUC.SetUserRole(user.Username, role)
+}
+
+// removeMember puts the user back on the user role, if they have the role.
+func (uc *SCIMUseCase) removeMember(role, userID string) error {
+    user, err := uc.findMember(userID)
+    if err != nil {
+        return err
+    }
+    if user.Role != role {
+        return nil
+    }
+    return uc.userUC.SetUserRole(user.Username, domain.RoleUser)
+}
+
+func (uc *SCIMUseCase) findMember(userID string) (*domain.User, error) {
+    user, err := uc.findUser(userID)
+    if errors.Is(err, domain.ErrUserNotFound) {
+        return nil, fmt.Errorf("%w: no user has the member id %q", domain.ErrInvalidSCIMRequest, userID)
+    }
+    return user, err
+}
+
+func (uc *SCIMUseCase) findUser(id string) (*domain.User, error) {
+    objectID, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return nil, domain.ErrUserNotFound
+    }
+    return uc.users.GetUserByID(objectID)
+}
+
+func (uc *SCIMUseCase) group(role string) (*domain.SCIMGroup, error) {
+    members, err := uc.allUsers(role)
+    if err != nil {
+        return nil, err
+    }
+    group := &domain.SCIMGroup{
+        Schemas:     []string{domain.SCIMSchemaGroup},
+        ID:          role,
+        DisplayName: role,
+        Members:     []domain.SCIMMember{},
+        Meta:        &domain.SCIMMeta{ResourceType: "Group", Location: domain.SCIMBasePath + "/Groups/" + role},
+    }
+    for _, member := range members {
+        group.Members = append(group.Members, domain.SCIMMember{Value: member.ID, Display: member.Username})
+    }
+    return group, nil
+}
+
+// allUsers returns every user, or every user with the role, page by page.
+func (uc *SCIMUseCase) allUsers(role string) ([]domain.UserSummary, error) {
+    var users []domain.UserSummary
+    query := domain.UserQuery{Role: role, Limit: domain.MaxUserPageSize}
+    for {
+        page, err := uc.users.ListUsers(query)
+        if err != nil {
+            return nil, err
+        }
+        users = append(users, page.Users...)
+        if page.NextCursor == "" {
+            return users, nil
+        }
+        query.Cursor = page.NextCursor
+    }
+}
+
+func (uc *SCIMUseCase) setActive(principal *domain.Principal, user *domain.User, active bool) error {
+    if user.Deactivated != active {
+        return nil
+    }
+    if err := uc.userUC.SetUserDeactivated(principal, user.Username, !active); err != nil {
+        return err
+    }
+    user.Deactivated = !active
+    return nil
+}
+
+// setPassword applies the password policy and, like a password reset, signs
+// the user out. Setting the password of a user with more permissions than the
+// principal would let the connector sign in as them, so it is refused.
+func (uc *SCIMUseCase) setPassword(principal *domain.Principal, user *domain.User, password string) error {
+    if err := checkManageable(uc.roles, principal, user); err != nil {
+        return err
+    }
+    hash, err := uc.hashPassword(user.Username, password)
+    if err != nil {
+        return err
+    }
+    if err := uc.users.UpdatePassword(user.ID, hash); err != nil {
+        return err
+    }
+    if err := uc.revocations.RevokeUserTokens(user.ID, time.Now()); err != nil {
+        return err
+    }
+    return uc.refreshRepo.RevokeUserRefreshTokens(user.ID)
+}
+
+func (uc *SCIMUseCase) hashPassword(username, password string) (string, error) {
+    if err := uc.policy.Validate(username, password); err != nil {
+        return "", err
+    }
+    return uc.hasher.Hash(password)
+}
+
+func newSCIMUser(user domain.UserSummary) *domain.SCIMUser {
+    active := !user.Deactivated
+    return &domain.SCIMUser{
+        Schemas:  []string{domain.SCIMSchemaUser},
+        ID:       user.ID,
+        UserName: user.Username,
+        Active:   &active,
+        Groups:   []domain.SCIMMember{{Value: user.Role, Display: user.Role}},
+        Meta:     &domain.SCIMMeta{ResourceType: "User", Location: domain.SCIMBasePath + "/Users/" + user.ID},
+    }
+}
+
+func newSCIMListResponse(total int, query domain.SCIMListQuery, resources interface{}, count int) *domain.SCIMListResponse {
+    return &domain.SCIMListResponse{
+        Schemas:      []string{domain.SCIMSchemaListResponse},
+        TotalResults: total,
+        StartIndex:   query.StartIndex,
+        ItemsPerPage: count,
+        Resources:    resources,
+    }
+}
+
+// scimPage returns the bounds of the requested page within total results.
+func scimPage(total int, query domain.SCIMListQuery) (int, int) {
+    start := min(query.StartIndex-1, total)
+    return start, min(start+query.Count, total)
+}
+
+// scimBool reads a boolean, also accepting the strings "True" and "False"
+// that some clients send.
+func scimBool(value json.RawMessage) (bool, error) {
+    var b bool
+    if err := json.Unmarshal(value, &b); err == nil {
+        return b, nil
+    }
+    var s string
+    if err := json.Unmarshal(value, &s); err != nil {
+        return false, err
+    }
+    switch strings.ToLower(s) {
+    case "true":
+        return true, nil
+    case "false":
+        return false, nil
+    }
+    return false, fmt.Errorf("not a boolean: %q", s)
+}
@@ -0,0 +1,155 @@
+package usecases
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func patchOp(op, path string, value interface{}) domain.SCIMPatchOperation {
+    raw, _ := json.Marshal(value)
+    if value == nil {
+        raw = nil
+    }
+    return domain.SCIMPatchOperation{Op: op, Path: path, Value: raw}
+}
+
+func TestSCIMProvisioning(t *testing.T) {
+    users := repositories.NewInMemoryUserRepository()
+    roles := repositories.NewInMemoryRoleRepository()
+    orgs := repositories.NewInMemoryOrganizationRepository()
+    refresh := repositories.NewInMemoryRefreshTokenRepository()
+    revocations := repositories.NewInMemoryTokenRevocationRepository()
+    roleUC := NewRoleUseCase(roles, users, orgs)
+    if err := roleUC.Initialize(); err != nil {
+        t.Fatalf("Initialize: %v", err)
+    }
+    userUC := NewUserUseCase(users, repositories.NewInMemoryTaskRepository(), refresh, revocations, repositories.NewInMemoryAccessTokenRepository(), roles, orgs, domain.DefaultPasswordPolicy(), plainHasher{})
+    uc := NewSCIMUseCase(users, roles, orgs, refresh, revocations, userUC, roleUC, domain.DefaultPasswordPolicy(), plainHasher{})
+
+    root := &domain.User{Username: "root", Password: "plain:x", Role: domain.RoleAdmin}
+    if err := users.CreateUser(root); err != nil {
+        t.Fatalf("CreateUser: %v", err)
+    }
+    orgID := primitive.NewObjectID()
+    connector := &domain.Principal{UserID: root.ID, OrgID: orgID, Permissions: domain.AllPermissions, Scopes: []string{domain.ScopeSCIM}}
+
+    alice, err := uc.CreateUser(connector, &domain.SCIMUser{UserName: "alice"})
+    if err != nil {
+        t.Fatalf("CreateUser: %v", err)
+    }
+    if !*alice.Active || alice.Groups[0].Value != domain.RoleUser {
+        t.Fatalf("CreateUser: got %+v, want an active user in the user group", alice)
+    }
+    if membership, err := orgs.GetMembership(orgID, mustObjectID(t, alice.ID)); err != nil || membership.Role != domain.RoleUser {
+        t.Fatalf("CreateUser: membership %+v, err=%v, want alice in the connector's organization", membership, err)
+    }
+    if _, err := uc.CreateUser(connector, &domain.SCIMUser{UserName: "alice"}); !errors.Is(err, domain.ErrUserExists) {
+        t.Fatalf("CreateUser twice: got %v, want %v", err, domain.ErrUserExists)
+    }
+    if _, err := uc.CreateUser(connector, &domain.SCIMUser{UserName: "bob", Password: "short"}); !errors.Is(err, domain.ErrValidation) {
+        t.Fatalf("CreateUser with a weak password: got %v, want %v", err, domain.ErrValidation)
+    }
+
+    list, err := uc.ListUsers(domain.SCIMListQuery{Filter: `userName Eq "alice"`, Count: 10})
+    if err != nil {
+        t.Fatalf("ListUsers: %v", err)
+    }
+    if found := list.Resources.([]*domain.SCIMUser); list.TotalResults != 1 || len(found) != 1 || found[0].ID != alice.ID {
+        t.Fatalf("ListUsers by userName: got %+v", list)
+    }
+    if list, _ := uc.ListUsers(domain.SCIMListQuery{StartIndex: 2, Count: 10}); list.TotalResults != 2 || list.ItemsPerPage != 1 {
+        t.Fatalf("ListUsers from the second: got %+v, want 1 of 2 users", list)
+    }
+    if _, err := uc.ListUsers(domain.SCIMListQuery{Filter: `userName sw "a"`}); !errors.Is(err, domain.ErrInvalidSCIMFilter) {
+        t.Fatalf("ListUsers with an unsupported filter: got %v, want %v", err, domain.ErrInvalidSCIMFilter)
+    }
+
+    // Offboarding deactivates first; names and emails are ignored.
+    patch := &domain.SCIMPatch{Operations: []domain.SCIMPatchOperation{
+        patchOp("Replace", "", map[string]interface{}{"active": "False", "name": map[string]string{"givenName": "Alice"}}),
+    }}
+    if alice, err = uc.PatchUser(connector, alice.ID, patch); err != nil || *alice.Active {
+        t.Fatalf("PatchUser: got %+v, err=%v, want alice deactivated", alice, err)
+    }
+    patch = &domain.SCIMPatch{Operations: []domain.SCIMPatchOperation{patchOp("replace", "userName", "alicia")}}
+    if _, err := uc.PatchUser(connector, alice.ID, patch); !errors.Is(err, domain.ErrSCIMImmutable) {
+        t.Fatalf("PatchUser renaming: got %v, want %v", err, domain.ErrSCIMImmutable)
+    }
+    if alice, err = uc.ReplaceUser(connector, alice.ID, &domain.SCIMUser{UserName: "alice", Active: new(bool)}); err != nil || *alice.Active {
+        t.Fatalf("ReplaceUser: got %+v, err=%v", alice, err)
+    }
+
+    // Groups are roles, and a user is in the group of their role only.
+    leads, err := uc.CreateGroup(&domain.SCIMGroup{DisplayName: "leads", Members: []domain.SCIMMember{{Value: alice.ID}}})
+    if err != nil {
+        t.Fatalf("CreateGroup: %v", err)
+    }
+    if len(leads.Members) != 1 || leads.Members[0].Display != "alice" {
+        t.Fatalf("CreateGroup: members %+v, want alice", leads.Members)
+    }
+    if user, _ := users.GetUserByUsername("alice"); user.Role != "leads" {
+        t.Fatalf("CreateGroup: alice has role %q, want leads", user.Role)
+    }
+    if _, err := uc.CreateGroup(&domain.SCIMGroup{DisplayName: "Task Leads"}); !errors.Is(err, domain.ErrValidation) {
+        t.Fatalf("CreateGroup with an invalid role name: got %v, want %v", err, domain.ErrValidation)
+    }
+    if err := uc.DeleteGroup("leads"); !errors.Is(err, domain.ErrRoleInUse) {
+        t.Fatalf("DeleteGroup with members: got %v, want %v", err, domain.ErrRoleInUse)
+    }
+    patch = &domain.SCIMPatch{Operations: []domain.SCIMPatchOperation{patchOp("remove", `members[value eq "`+alice.ID+`"]`, nil)}}
+    if leads, err = uc.PatchGroup("leads", patch); err != nil || len(leads.Members) != 0 {
+        t.Fatalf("PatchGroup removing alice: got %+v, err=%v", leads, err)
+    }
+    if user, _ := users.GetUserByUsername("alice"); user.Role != domain.RoleUser {
+        t.Fatalf("PatchGroup removing alice: alice has role %q, want user", user.Role)
+    }
+
+    patch = &domain.SCIMPatch{Operations: []domain.SCIMPatchOperation{patchOp("replace", "members", []domain.SCIMMember{{Value: alice.ID}})}}
+    if _, err := uc.PatchGroup(domain.RoleAdmin, patch); !errors.Is(err, domain.ErrLastAdmin) {
+        t.Fatalf("PatchGroup replacing the last admin: got %v, want %v", err, domain.ErrLastAdmin)
+    }
+    patch = &domain.SCIMPatch{Operations: []domain.SCIMPatchOperation{patchOp("add", "members", []domain.SCIMMember{{Value: primitive.NewObjectID().Hex()}})}}
+    if _, err := uc.PatchGroup("leads", patch); !errors.Is(err, domain.ErrInvalidSCIMRequest) {
+        t.Fatalf("PatchGroup adding an unknown user: got %v, want %v", err, domain.ErrInvalidSCIMRequest)
+    }
+    if err := uc.DeleteGroup("leads"); err != nil {
+        t.Fatalf("DeleteGroup: %v", err)
+    }
+
+    // users.manage alone cannot take over an admin through their password.
+    manager := &domain.Principal{UserID: mustObjectID(t, alice.ID), Permissions: []string{domain.PermissionUsersRead, domain.PermissionUsersManage}}
+    patch = &domain.SCIMPatch{Operations: []domain.SCIMPatchOperation{patchOp("replace", "password", "k7#vQ2!mzP")}}
+    if _, err := uc.PatchUser(manager, root.ID.Hex(), patch); !errors.Is(err, domain.ErrUserNotManageable) {
+        t.Fatalf("PatchUser setting an admin's password without roles.manage: got %v, want %v", err, domain.ErrUserNotManageable)
+    }
+    if _, err := uc.ReplaceUser(manager, root.ID.Hex(), &domain.SCIMUser{UserName: "root", Password: "k7#vQ2!mzP"}); !errors.Is(err, domain.ErrUserNotManageable) {
+        t.Fatalf("ReplaceUser setting an admin's password without roles.manage: got %v, want %v", err, domain.ErrUserNotManageable)
+    }
+    if stored, _ := users.GetUserByUsername("root"); stored.Password != "plain:x" {
+        t.Fatalf("PatchUser: the admin's password was changed to %q", stored.Password)
+    }
+    if _, err := uc.PatchUser(manager, alice.ID, patch); err != nil {
+        t.Fatalf("PatchUser setting a user's password: %v", err)
+    }
+
+    if err := uc.DeleteUser(connector, alice.ID); err != nil {
+        t.Fatalf("DeleteUser: %v", err)
+    }
+    if _, err := uc.GetUser(alice.ID); !errors.Is(err, domain.ErrUserNotFound) {
+        t.Fatalf("GetUser after DeleteUser: got %v, want %v", err, domain.ErrUserNotFound)
+    }
+}
+
+func mustObjectID(t *testing.T, hex string) primitive.ObjectID {
+    t.Helper()
+    id, err := primitive.ObjectIDFromHex(hex)
+    if err != nil {
+        t.Fatalf("ObjectIDFromHex(%q): %v", hex, err)
+    }
+    return id
+}
@@ -6,35 +6,179 @@ import (
 )
 
 type TaskUseCase struct {
-    repo domain.TaskRepository
+    repo     domain.TaskRepository
+    userRepo domain.UserRepository
+    orgRepo  domain.OrganizationRepository
 }
 
-func NewTaskUseCase(repo domain.TaskRepository) domain.TaskUseCaseInterface {
-    return &TaskUseCase{repo: repo}
+func NewTaskUseCase(repo domain.TaskRepository, userRepo domain.UserRepository, orgRepo domain.OrganizationRepository) domain.TaskUseCaseInterface {
+    return &TaskUseCase{repo: repo, userRepo: userRepo, orgRepo: orgRepo}
 }
 
-func (uc *TaskUseCase) GetTasks() ([]domain.Task, error) {
-    return uc.repo.GetTasks()
+func (uc *TaskUseCase) GetTasks(principal *domain.Principal, query domain.TaskQuery) (domain.TaskPage, error) {
+    if principal.OrgID.IsZero() {
+        return domain.TaskPage{}, domain.ErrNoOrganization
+    }
+    if err := query.Normalize(); err != nil {
+        return domain.TaskPage{}, err
+    }
+    query.OrgID = principal.OrgID
+    if !principal.HasPermission(domain.PermissionTasksReadAll) {
+        query.VisibleTo = &principal.UserID
+    }
+    return uc.repo.GetTasks(query)
 }
 
-func (uc *TaskUseCase) GetTask(id primitive.ObjectID) (domain.Task, bool, error) {
-    return uc.repo.GetTaskByID(id)
+// GetTask reports tasks hidden from the principal as not found, so that
+// their existence is not revealed.
+func (uc *TaskUseCase) GetTask(principal *domain.Principal, id primitive.ObjectID) (domain.Task, bool, error) {
+    if principal.OrgID.IsZero() {
+        return domain.Task{}, false, domain.ErrNoOrganization
+    }
+    task, found, err := uc.repo.GetTaskByID(principal.OrgID, id)
+    if err != nil || !found || !canSee(principal, task) {
+        return domain.Task{}, false, err
+    }
+    return task, true, nil
 }
 
-func (uc *TaskUseCase) AddTask(task domain.Task) error {
+func (uc *TaskUseCase) AddTask(principal *domain.Principal, task domain.Task) error {
+    if principal.OrgID.IsZero() {
+        return domain.ErrNoOrganization
+    }
     if err := task.Validate(); err != nil {
         return err
     }
+    task.OrgID = principal.OrgID
+    if task.Visibility == "" {
+        task.Visibility = domain.VisibilityOrg
+    }
+    // Users are added to SharedWith through ShareTask only.
+    task.SharedWith = nil
     return uc.repo.AddTask(task)
 }
 
-func (uc *TaskUseCase) UpdateTask(id primitive.ObjectID, task domain.Task) error {
+func (uc *TaskUseCase) UpdateTask(principal *domain.Principal, id primitive.ObjectID, task domain.Task) error {
     if err := task.Validate(); err != nil {
         return err
     }
-    return uc.repo.UpdateTask(id, task)
+    if _, err := uc.visibleTask(principal, id); err != nil {
+        return err
+    }
+    // Ownership is only changed through the assignment endpoints, never by a plain update.
+    task.OrgID = primitive.NilObjectID
+    task.CreatedBy = primitive.NilObjectID
+    task.AssignedTo = nil
+    // Likewise, who can see the task is only changed through the sharing endpoints.
+    task.Visibility = ""
+    task.SharedWith = nil
+    return uc.repo.UpdateTask(principal.OrgID, id, task)
+}
+
+func (uc *TaskUseCase) DeleteTask(principal *domain.Principal, id primitive.ObjectID) error {
+    if _, err := uc.visibleTask(principal, id); err != nil {
+        return err
+    }
+    return uc.repo.DeleteTask(principal.OrgID, id)
+}
+
+func (uc *TaskUseCase) GetMyTasks(principal *domain.Principal, query domain.TaskQuery) (domain.TaskPage, error) {
+    query.AssignedTo = &principal.UserID
+    return uc.GetTasks(principal, query)
+}
+
+func (uc *TaskUseCase) AssignTask(principal *domain.Principal, id primitive.ObjectID, username string) error {
+    if _, err := uc.visibleTask(principal, id); err != nil {
+        return err
+    }
+    user, err := uc.member(principal, username)
+    if err != nil {
+        return err
+    }
+    return uc.repo.AssignTask(principal.OrgID, id, user.ID)
+}
+
+func (uc *TaskUseCase) UnassignTask(principal *domain.Principal, id primitive.ObjectID) error {
+    if _, err := uc.visibleTask(principal, id); err != nil {
+        return err
+    }
+    return uc.repo.UnassignTask(principal.OrgID, id)
+}
+
+func (uc *TaskUseCase) SetTaskVisibility(principal *domain.Principal, id primitive.ObjectID, visibility string) error {
+    if !domain.ValidVisibility(visibility) {
+        return domain.ErrInvalidVisibility
+    }
+    if _, err := uc.sharableTask(principal, id); err != nil {
+        return err
+    }
+    return uc.repo.SetTaskVisibility(principal.OrgID, id, visibility)
+}
+
+func (uc *TaskUseCase) ShareTask(principal *domain.Principal, id primitive.ObjectID, username string) error {
+    if _, err := uc.sharableTask(principal, id); err != nil {
+        return err
+    }
+    user, err := uc.member(principal, username)
+    if err != nil {
+        return err
+    }
+    return uc.repo.ShareTask(principal.OrgID, id, user.ID)
+}
+
+func (uc *TaskUseCase) UnshareTask(principal *domain.Principal, id primitive.ObjectID, username string) error {
+    if _, err := uc.sharableTask(principal, id); err != nil {
+        return err
+    }
+    user, err := uc.userRepo.GetUserByUsername(username)
+    if err != nil {
+        return err
+    }
+    return uc.repo.UnshareTask(principal.OrgID, id, user.ID)
+}
+
+// visibleTask returns the task, or ErrTaskNotFound if it is hidden from the
+// principal.
+func (uc *TaskUseCase) visibleTask(principal *domain.Principal, id primitive.ObjectID) (domain.Task, error) {
+    task, found, err := uc.GetTask(principal, id)
+    if err != nil {
+        return task, err
+    }
+    if !found {
+        return task, domain.ErrTaskNotFound
+    }
+    return task, nil
+}
+
+// sharableTask is visibleTask limited to tasks the principal may share.
+func (uc *TaskUseCase) sharableTask(principal *domain.Principal, id primitive.ObjectID) (domain.Task, error) {
+    task, err := uc.visibleTask(principal, id)
+    if err != nil {
+        return task, err
+    }
+    if task.CreatedBy != principal.UserID && !principal.HasPermission(domain.PermissionTasksReadAll) {
+        return task, domain.ErrTaskSharingNotAllowed
+    }
+    return task, nil
+}
+
+// member returns the user if they are a member of the principal's
+// organization. Tasks can only be assigned to and shared with members.
+func (uc *TaskUseCase) member(principal *domain.Principal, username string) (*domain.User, error) {
+    user, err := uc.userRepo.GetUserByUsername(username)
+    if err != nil {
+        return nil, err
+    }
+    membership, err := uc.orgRepo.GetMembership(principal.OrgID, user.ID)
+    if err != nil {
+        return nil, err
+    }
+    if membership.Pending {
+        return nil, domain.ErrNotOrgMember
+    }
+    return user, nil
 }
 
-func (uc *TaskUseCase) DeleteTask(id primitive.ObjectID) error {
-    return uc.repo.DeleteTask(id)
+func canSee(principal *domain.Principal, task domain.Task) bool {
+    return principal.HasPermission(domain.PermissionTasksReadAll) || task.VisibleTo(principal.UserID)
 }
@@ -0,0 +1,141 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTaskVisibility(t *testing.T) {
+    users := repositories.NewInMemoryUserRepository()
+    orgs := repositories.NewInMemoryOrganizationRepository()
+    orgID := primitive.NewObjectID()
+    principals := map[string]*domain.Principal{}
+    for _, name := range []string{"alice", "bob", "carol"} {
+        principals[name] = newMember(t, users, orgs, orgID, name)
+    }
+    alice, bob, carol := principals["alice"], principals["bob"], principals["carol"]
+    admin := &domain.Principal{UserID: primitive.NewObjectID(), OrgID: orgID, Permissions: domain.AllPermissions}
+
+    uc := NewTaskUseCase(repositories.NewInMemoryTaskRepository(), users, orgs)
+    task := domain.Task{ID: primitive.NewObjectID(), Title: "Plan", DueDate: time.Now(), Status: "pending", CreatedBy: alice.UserID, Visibility: domain.VisibilityPrivate}
+    if err := uc.AddTask(alice, task); err != nil {
+        t.Fatalf("AddTask: %v", err)
+    }
+
+    visible := func(principal *domain.Principal) bool {
+        _, found, err := uc.GetTask(principal, task.ID)
+        if err != nil {
+            t.Fatalf("GetTask: %v", err)
+        }
+        page, err := uc.GetTasks(principal, domain.TaskQuery{})
+        if err != nil {
+            t.Fatalf("GetTasks: %v", err)
+        }
+        if listed := len(page.Tasks) == 1; listed != found {
+            t.Fatalf("GetTask found=%v but GetTasks listed=%v", found, listed)
+        }
+        return found
+    }
+
+    if !visible(alice) || visible(bob) || !visible(admin) {
+        t.Fatal("private task: want it visible to its creator and to admins only")
+    }
+    if err := uc.UpdateTask(bob, task.ID, task); !errors.Is(err, domain.ErrTaskNotFound) {
+        t.Fatalf("UpdateTask of a hidden task: got %v, want %v", err, domain.ErrTaskNotFound)
+    }
+    if err := uc.ShareTask(bob, task.ID, "bob"); !errors.Is(err, domain.ErrTaskNotFound) {
+        t.Fatalf("ShareTask of a hidden task: got %v, want %v", err, domain.ErrTaskNotFound)
+    }
+
+    if err := uc.SetTaskVisibility(alice, task.ID, domain.VisibilityShared); err != nil {
+        t.Fatalf("SetTaskVisibility: %v", err)
+    }
+    if err := uc.ShareTask(alice, task.ID, "bob"); err != nil {
+        t.Fatalf("ShareTask: %v", err)
+    }
+    if !visible(bob) || visible(carol) {
+        t.Fatal("shared task: want it visible to bob and hidden from carol")
+    }
+    if err := uc.ShareTask(bob, task.ID, "carol"); !errors.Is(err, domain.ErrTaskSharingNotAllowed) {
+        t.Fatalf("ShareTask by a non-creator: got %v, want %v", err, domain.ErrTaskSharingNotAllowed)
+    }
+
+    // A plain update leaves the visibility alone.
+    update := task
+    update.Title, update.Visibility = "Plan v2", domain.VisibilityOrg
+    if err := uc.UpdateTask(alice, task.ID, update); err != nil {
+        t.Fatalf("UpdateTask: %v", err)
+    }
+    if visible(carol) {
+        t.Fatal("UpdateTask changed the visibility")
+    }
+
+    if err := uc.SetTaskVisibility(admin, task.ID, domain.VisibilityOrg); err != nil {
+        t.Fatalf("SetTaskVisibility by admin: %v", err)
+    }
+    if !visible(carol) {
+        t.Fatal("org task: want it visible to everyone")
+    }
+    if err := uc.SetTaskVisibility(alice, task.ID, "everyone"); !errors.Is(err, domain.ErrInvalidVisibility) {
+        t.Fatalf("SetTaskVisibility: got %v, want %v", err, domain.ErrInvalidVisibility)
+    }
+}
+
+func TestTaskOrganizationScope(t *testing.T) {
+    users := repositories.NewInMemoryUserRepository()
+    orgs := repositories.NewInMemoryOrganizationRepository()
+    acme, globex := primitive.NewObjectID(), primitive.NewObjectID()
+    alice := newMember(t, users, orgs, acme, "alice")
+    bob := newMember(t, users, orgs, globex, "bob")
+
+    uc := NewTaskUseCase(repositories.NewInMemoryTaskRepository(), users, orgs)
+    task := domain.Task{ID: primitive.NewObjectID(), Title: "Plan", DueDate: time.Now(), Status: "pending", CreatedBy: alice.UserID}
+    if err := uc.AddTask(alice, task); err != nil {
+        t.Fatalf("AddTask: %v", err)
+    }
+
+    if _, found, err := uc.GetTask(bob, task.ID); err != nil || found {
+        t.Fatalf("GetTask from another organization: found=%v err=%v", found, err)
+    }
+    if page, err := uc.GetTasks(bob, domain.TaskQuery{}); err != nil || len(page.Tasks) != 0 {
+        t.Fatalf("GetTasks from another organization: %d tasks, err=%v", len(page.Tasks), err)
+    }
+    if err := uc.DeleteTask(bob, task.ID); !errors.Is(err, domain.ErrTaskNotFound) {
+        t.Fatalf("DeleteTask from another organization: got %v, want %v", err, domain.ErrTaskNotFound)
+    }
+    if err := uc.AssignTask(alice, task.ID, "bob"); !errors.Is(err, domain.ErrNotOrgMember) {
+        t.Fatalf("AssignTask to a non-member: got %v, want %v", err, domain.ErrNotOrgMember)
+    }
+    if err := uc.AddTask(&domain.Principal{UserID: alice.UserID}, task); !errors.Is(err, domain.ErrNoOrganization) {
+        t.Fatalf("AddTask without an organization: got %v, want %v", err, domain.ErrNoOrganization)
+    }
+
+    // The organization cannot be changed by an update.
+    moved := task
+    moved.OrgID = globex
+    if err := uc.UpdateTask(alice, task.ID, moved); err != nil {
+        t.Fatalf("UpdateTask: %v", err)
+    }
+    if _, found, _ := uc.GetTask(alice, task.ID); !found {
+        t.Fatal("UpdateTask moved the task to another organization")
+    }
+}
+
+// newMember creates a user with the user role and adds them to the
+// organization.
+func newMember(t *testing.T, users domain.UserRepository, orgs domain.OrganizationRepository, orgID primitive.ObjectID, name string) *domain.Principal {
+    t.Helper()
+    user := &domain.User{Username: name, Password: "x", Role: domain.RoleUser}
+    if err := users.CreateUser(user); err != nil {
+        t.Fatalf("CreateUser: %v", err)
+    }
+    if err := orgs.AddMember(&domain.Membership{OrgID: orgID, UserID: user.ID, Role: domain.RoleUser}); err != nil {
+        t.Fatalf("AddMember: %v", err)
+    }
+    return &domain.Principal{UserID: user.ID, Username: name, OrgID: orgID, Permissions: []string{domain.PermissionTasksRead}}
+}
@@ -0,0 +1,75 @@
+package usecases
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238). These are the defaults every common
+// authenticator app supports.
+const (
+    totpDigits = 6
+    totpPeriod = 30
+    // totpSkew is the number of periods before and after the current one in
+    // which a code is still accepted, to allow for clock drift.
+    totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newTOTPSecret returns a random 160-bit secret in base32, as recommended by RFC 4226.
+func newTOTPSecret() (string, error) {
+    buf := make([]byte, 20)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return totpEncoding.EncodeToString(buf), nil
+}
+
+// totpCode computes the HOTP value (RFC 4226) of key for the given time step.
+func totpCode(key []byte, step int64) string {
+    var counter [8]byte
+    binary.BigEndian.PutUint64(counter[:], uint64(step))
+    mac := hmac.New(sha1.New, key)
+    mac.Write(counter[:])
+    sum := mac.Sum(nil)
+
+    offset := sum[len(sum)-1] & 0x0f
+    value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+    return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// matchTOTP reports the time step for which code is valid at now.
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+    key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+    if err != nil || len(code) != totpDigits {
+        return 0, false
+    }
+    current := now.Unix() / totpPeriod
+    for step := current - totpSkew; step <= current+totpSkew; step++ {
+        if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+            return step, true
+        }
+    }
+    return 0, false
+}
+
+// totpURI builds the otpauth:// URI understood by authenticator apps.
+func totpURI(issuer, account, secret string) string {
+    params := url.Values{}
+    params.Set("secret", secret)
+    params.Set("issuer", issuer)
+    params.Set("algorithm", "SHA1")
+    params.Set("digits", fmt.Sprint(totpDigits))
+    params.Set("period", fmt.Sprint(totpPeriod))
+    label := url.PathEscape(issuer + ":" + account)
+    return "otpauth://totp/" + label + "?" + params.Encode()
+}
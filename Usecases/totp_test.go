@@ -0,0 +1,51 @@
+package usecases
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA-1 test vectors from RFC 6238, Appendix B, truncated to six digits.
+func TestTOTPCode(t *testing.T) {
+    key := []byte("12345678901234567890")
+    for _, tc := range []struct {
+        unix int64
+        want string
+    }{
+        {59, "287082"},
+        {1111111109, "081804"},
+        {1111111111, "050471"},
+        {1234567890, "005924"},
+        {2000000000, "279037"},
+        {20000000000, "353130"},
+    } {
+        if got := totpCode(key, tc.unix/totpPeriod); got != tc.want {
+            t.Errorf("totpCode at %d: got %s, want %s", tc.unix, got, tc.want)
+        }
+    }
+}
+
+func TestMatchTOTP(t *testing.T) {
+    secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+    now := time.Unix(1111111111, 0)
+    key := []byte("12345678901234567890")
+
+    step, ok := matchTOTP(strings.ToLower(secret), "050471", now)
+    if !ok || step != now.Unix()/totpPeriod {
+        t.Fatalf("matchTOTP current code: step=%d ok=%v", step, ok)
+    }
+    previous := totpCode(key, now.Unix()/totpPeriod-1)
+    if _, ok := matchTOTP(secret, previous, now); !ok {
+        t.Fatal("matchTOTP: code from the previous period was rejected")
+    }
+    stale := totpCode(key, now.Unix()/totpPeriod-2)
+    if _, ok := matchTOTP(secret, stale, now); ok {
+        t.Fatal("matchTOTP: code from two periods ago was accepted")
+    }
+    for _, code := range []string{"", "05047", "0504711", "abcdef"} {
+        if _, ok := matchTOTP(secret, code, now); ok {
+            t.Fatalf("matchTOTP(%q) was accepted", code)
+        }
+    }
+}
@@ -0,0 +1,140 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const recoveryCodeCount = 10
+
+type TwoFactorUseCase struct {
+    repo      domain.TwoFactorRepository
+    usersRepo domain.UserRepository
+    issuer    string
+}
+
+// NewTwoFactorUseCase returns the TOTP enrollment use case. issuer is the
+// name authenticator apps show next to the account.
+func NewTwoFactorUseCase(repo domain.TwoFactorRepository, usersRepo domain.UserRepository, issuer string) domain.TwoFactorUseCaseInterface {
+    return &TwoFactorUseCase{repo: repo, usersRepo: usersRepo, issuer: issuer}
+}
+
+// Enroll starts a new enrollment, replacing any enrollment that was never enabled.
+func (uc *TwoFactorUseCase) Enroll(userID primitive.ObjectID) (*domain.TwoFactorEnrollment, error) {
+    user, err := uc.usersRepo.GetUserByID(userID)
+    if err != nil {
+        return nil, err
+    }
+    secret, err := newTOTPSecret()
+    if err != nil {
+        return nil, err
+    }
+    twoFactor := &domain.TwoFactor{
+        UserID:        user.ID,
+        Secret:        secret,
+        RecoveryCodes: []string{},
+        CreatedAt:     time.Now(),
+    }
+    if err := uc.repo.SaveTwoFactor(twoFactor); err != nil {
+        return nil, err
+    }
+    return &domain.TwoFactorEnrollment{
+        Secret:     secret,
+        OTPAuthURI: totpURI(uc.issuer, user.Username, secret),
+    }, nil
+}
+
+func (uc *TwoFactorUseCase) Enable(userID primitive.ObjectID, code string) ([]string, error) {
+    twoFactor, err := uc.repo.GetTwoFactor(userID)
+    if err != nil {
+        return nil, err
+    }
+    if twoFactor.Enabled {
+        return nil, domain.ErrTwoFactorAlreadyEnabled
+    }
+    step, ok := matchTOTP(twoFactor.Secret, code, time.Now())
+    if !ok {
+        return nil, domain.ErrInvalidTwoFactorCode
+    }
+    if used, err := uc.repo.UseTOTPStep(userID, step); err != nil || !used {
+        if err != nil {
+            return nil, err
+        }
+        return nil, domain.ErrInvalidTwoFactorCode
+    }
+
+    codes, hashes, err := newRecoveryCodes()
+    if err != nil {
+        return nil, err
+    }
+    if err := uc.repo.EnableTwoFactor(userID, hashes); err != nil {
+        return nil, err
+    }
+    return codes, nil
+}
+
+// Disable turns 2FA off. It needs a current TOTP or recovery code so that a
+// stolen session alone cannot remove the second factor.
+func (uc *TwoFactorUseCase) Disable(userID primitive.ObjectID, code string) error {
+    twoFactor, err := uc.repo.GetTwoFactor(userID)
+    if err != nil {
+        return err
+    }
+    if !twoFactor.Enabled {
+        return domain.ErrTwoFactorNotFound
+    }
+    if err := verifySecondFactor(uc.repo, twoFactor, code); err != nil {
+        return err
+    }
+    return uc.repo.DeleteTwoFactor(userID)
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code
+// and consumes it, so that it cannot be used a second time.
+func verifySecondFactor(repo domain.TwoFactorRepository, twoFactor *domain.TwoFactor, code string) error {
+    code = strings.TrimSpace(code)
+    if step, ok := matchTOTP(twoFactor.Secret, code, time.Now()); ok {
+        used, err := repo.UseTOTPStep(twoFactor.UserID, step)
+        if err != nil {
+            return err
+        }
+        if !used {
+            return domain.ErrInvalidTwoFactorCode
+        }
+        return nil
+    }
+
+    used, err := repo.UseRecoveryCode(twoFactor.UserID, hashToken(normalizeRecoveryCode(code)))
+    if err != nil {
+        return err
+    }
+    if !used {
+        return domain.ErrInvalidTwoFactorCode
+    }
+    return nil
+}
+
+// newRecoveryCodes returns recovery codes formatted as xxxxx-xxxxx and their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+    codes := make([]string, recoveryCodeCount)
+    hashes := make([]string, recoveryCodeCount)
+    for i := range codes {
+        buf := make([]byte, 7)
+        if _, err := rand.Read(buf); err != nil {
+            return nil, nil, err
+        }
+        raw := strings.ToLower(totpEncoding.EncodeToString(buf))[:10]
+        codes[i] = raw[:5] + "-" + raw[5:]
+        hashes[i] = hashToken(raw)
+    }
+    return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+    code = strings.ToLower(code)
+    return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
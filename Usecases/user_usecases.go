@@ -2,24 +2,53 @@ package usecases
 
 import (
     "errors"
+    "fmt"
+    "time"
+
     "github.com/Hailemari/clean_architecture_task_manager/Domain"
+    "go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type UserUseCase struct {
-    repo domain.UserRepository
+    repo        domain.UserRepository
+    taskRepo    domain.TaskRepository
+    refreshRepo domain.RefreshTokenRepository
+    revocations domain.TokenRevocationRepository
+    accessRepo  domain.AccessTokenRepository
+    roleRepo    domain.RoleRepository
+    orgRepo     domain.OrganizationRepository
+    policy      domain.PasswordPolicy
+    hasher      domain.PasswordHasher
 }
 
-func NewUserUseCase(repo domain.UserRepository) domain.UserUseCaseInterface {
-    return &UserUseCase{repo: repo}
+func NewUserUseCase(repo domain.UserRepository, taskRepo domain.TaskRepository, refreshRepo domain.RefreshTokenRepository, revocations domain.TokenRevocationRepository, accessRepo domain.AccessTokenRepository, roleRepo domain.RoleRepository, orgRepo domain.OrganizationRepository, policy domain.PasswordPolicy, hasher domain.PasswordHasher) domain.UserUseCaseInterface {
+    return &UserUseCase{
+        repo:        repo,
+        taskRepo:    taskRepo,
+        refreshRepo: refreshRepo,
+        revocations: revocations,
+        accessRepo:  accessRepo,
+        roleRepo:    roleRepo,
+        orgRepo:     orgRepo,
+        policy:      policy,
+        hasher:      hasher,
+    }
 }
 
 func (uc *UserUseCase) CreateUser(user *domain.User) error {
     if err := user.ValidateUser(); err != nil {
         return err
     }
-    if err := user.HashPassword(); err != nil {
+    if err := uc.policy.Validate(user.Username, user.Password); err != nil {
+        return err
+    }
+    hash, err := uc.hasher.Hash(user.Password)
+    if err != nil {
         return err
     }
+    user.Password = hash
+    // Admins are only made by the bootstrap or by another admin.
+    user.Role = domain.RoleUser
     return uc.repo.CreateUser(user)
 }
 
@@ -33,7 +62,179 @@ func (uc *UserUseCase) PromoteUser(username string) error {
         return err
     }
     if user.Role == "admin" {
-        return errors.New("user is already an admin")
+        return domain.ErrAlreadyAdmin
     }
+    // AuthMiddleware loads the role on every request, so tokens the user
+    // already holds get the new role without being revoked.
     return uc.repo.PromoteUser(username)
 }
+
+// RevokeUserTokens signs the user out everywhere: all access tokens issued so
+// far are rejected and all refresh tokens and personal access tokens are revoked.
+func (uc *UserUseCase) RevokeUserTokens(principal *domain.Principal, username string) error {
+    user, err := uc.repo.GetUserByUsername(username)
+    if err != nil {
+        return err
+    }
+    if err := checkManageable(uc.roleRepo, principal, user); err != nil {
+        return err
+    }
+    if err := uc.revocations.RevokeUserTokens(user.ID, time.Now()); err != nil {
+        return err
+    }
+    if err := uc.refreshRepo.RevokeUserRefreshTokens(user.ID); err != nil {
+        return err
+    }
+    return uc.accessRepo.RevokeUserAccessTokens(user.ID)
+}
+
+func (uc *UserUseCase) ListUsers(query domain.UserQuery) (domain.UserPage, error) {
+    return uc.repo.ListUsers(query)
+}
+
+// SetUserRole gives the user any existing role. Like PromoteUser, it leaves
+// the user's tokens alone; the new role applies from their next request.
+func (uc *UserUseCase) SetUserRole(username, role string) error {
+    if _, err := uc.roleRepo.GetRole(role); err != nil {
+        if errors.Is(err, domain.ErrRoleNotFound) {
+            return domain.ErrInvalidRole
+        }
+        return err
+    }
+    user, err := uc.repo.GetUserByUsername(username)
+    if err != nil {
+        return err
+    }
+    if user.Role == role {
+        return nil
+    }
+    return uc.repo.SetUserRole(username, role)
+}
+
+// SetUserDeactivated deactivates or reactivates the user. Personal access
+// tokens are kept but rejected while the user is deactivated.
+func (uc *UserUseCase) SetUserDeactivated(principal *domain.Principal, username string, deactivated bool) error {
+    user, err := uc.repo.GetUserByUsername(username)
+    if err != nil {
+        return err
+    }
+    if err := checkManageable(uc.roleRepo, principal, user); err != nil {
+        return err
+    }
+    if err := uc.repo.SetUserDeactivated(username, deactivated); err != nil {
+        return err
+    }
+    if !deactivated {
+        return nil
+    }
+    if err := uc.revocations.RevokeUserTokens(user.ID, time.Now()); err != nil {
+        return err
+    }
+    return uc.refreshRepo.RevokeUserRefreshTokens(user.ID)
+}
+
+// DeleteUser removes the user. Tasks assigned to them are unassigned,
+// reassigned to reassignTo or deleted, depending on taskPolicy; tasks they
+// created are kept. Tasks are only reassigned in organizations reassignTo is
+// a member of, and unassigned elsewhere.
+func (uc *UserUseCase) DeleteUser(principal *domain.Principal, username, taskPolicy, reassignTo string) (int64, error) {
+    if taskPolicy == "" {
+        taskPolicy = domain.TaskPolicyUnassign
+    }
+    user, err := uc.repo.GetUserByUsername(username)
+    if err != nil {
+        return 0, err
+    }
+    if err := checkManageable(uc.roleRepo, principal, user); err != nil {
+        return 0, err
+    }
+
+    var target *domain.User
+    switch taskPolicy {
+    case domain.TaskPolicyUnassign, domain.TaskPolicyDelete:
+    case domain.TaskPolicyReassign:
+        if reassignTo == "" || reassignTo == username {
+            return 0, domain.ErrInvalidTaskPolicy
+        }
+        target, err = uc.repo.GetUserByUsername(reassignTo)
+        if err != nil {
+            if errors.Is(err, domain.ErrUserNotFound) {
+                return 0, fmt.Errorf("%w: user %q does not exist", domain.ErrInvalidTaskPolicy, reassignTo)
+            }
+            return 0, err
+        }
+    default:
+        return 0, domain.ErrInvalidTaskPolicy
+    }
+
+    // Deactivating the user first refuses to delete the last active admin
+    // before anything has changed, and signs the user out while the rest
+    // runs. The user is deleted last, so a deletion that fails halfway can be
+    // retried.
+    if err := uc.repo.SetUserDeactivated(username, true); err != nil {
+        return 0, err
+    }
+    if err := uc.refreshRepo.RevokeUserRefreshTokens(user.ID); err != nil {
+        return 0, err
+    }
+    if err := uc.accessRepo.RevokeUserAccessTokens(user.ID); err != nil {
+        return 0, err
+    }
+
+    var affected int64
+    if taskPolicy == domain.TaskPolicyDelete {
+        affected, err = uc.taskRepo.DeleteUserTasks(user.ID)
+    } else {
+        affected, err = uc.applyUnassignPolicy(user, target)
+    }
+    if err != nil {
+        return 0, err
+    }
+    if err := uc.orgRepo.RemoveUserMemberships(user.ID); err != nil {
+        return 0, err
+    }
+    return affected, uc.repo.DeleteUser(username)
+}
+
+// applyUnassignPolicy reassigns the user's tasks to target in the
+// organizations target is a member of, and unassigns the rest.
+func (uc *UserUseCase) applyUnassignPolicy(user, target *domain.User) (int64, error) {
+    var reassigned int64
+    if target != nil {
+        memberships, err := uc.orgRepo.ListUserMemberships(target.ID)
+        if err != nil {
+            return 0, err
+        }
+        var orgIDs []primitive.ObjectID
+        for _, membership := range memberships {
+            if !membership.Pending {
+                orgIDs = append(orgIDs, membership.OrgID)
+            }
+        }
+        if reassigned, err = uc.taskRepo.ReassignUserTasks(user.ID, target.ID, orgIDs); err != nil {
+            return 0, err
+        }
+    }
+    unassigned, err := uc.taskRepo.UnassignUserTasks(user.ID)
+    return reassigned + unassigned, err
+}
+
+// checkManageable returns ErrUserNotManageable if the user's role grants a
+// permission the principal does not have. Without it, users.manage would be
+// enough to reset an admin's password and sign in as them. Only the
+// permissions the role grants outside organizations are compared.
+func checkManageable(roles domain.RoleRepository, principal *domain.Principal, user *domain.User) error {
+    role, err := roles.GetRole(user.Role)
+    if errors.Is(err, domain.ErrRoleNotFound) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    for _, permission := range role.Permissions {
+        if !domain.OrgPermission(permission) && !principal.HasPermission(permission) {
+            return domain.ErrUserNotManageable
+        }
+    }
+    return nil
+}
@@ -0,0 +1,263 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hailemari/clean_architecture_task_manager/Domain"
+	"github.com/Hailemari/clean_architecture_task_manager/Repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUserManagementLimitedByTargetRole(t *testing.T) {
+    roles := repositories.NewInMemoryRoleRepository()
+    for _, role := range domain.DefaultRoles() {
+        if err := roles.CreateRole(&role); err != nil {
+            t.Fatalf("CreateRole: %v", err)
+        }
+    }
+    users := repositories.NewInMemoryUserRepository()
+    for _, user := range []*domain.User{
+        {Username: "root", Password: "plain:x", Role: domain.RoleAdmin},
+        {Username: "root2", Password: "plain:x", Role: domain.RoleAdmin},
+        {Username: "bob", Password: "plain:x", Role: domain.RoleUser},
+    } {
+        if err := users.CreateUser(user); err != nil {
+            t.Fatalf("CreateUser: %v", err)
+        }
+    }
+    refresh := repositories.NewInMemoryRefreshTokenRepository()
+    revocations := repositories.NewInMemoryTokenRevocationRepository()
+    userUC := NewUserUseCase(users, repositories.NewInMemoryTaskRepository(), refresh, revocations, repositories.NewInMemoryAccessTokenRepository(), roles, repositories.NewInMemoryOrganizationRepository(), domain.DefaultPasswordPolicy(), plainHasher{})
+    passwordUC := NewPasswordUseCase(users, roles, refresh, revocations, repositories.NewInMemoryAccessTokenRepository(), repositories.NewInMemoryPasswordResetRepository(), nil, domain.DefaultPasswordPolicy(), plainHasher{}, 0)
+
+    // A support role that manages users, but cannot give roles.
+    manager := &domain.Principal{UserID: primitive.NewObjectID(), Permissions: []string{domain.PermissionTasksRead, domain.PermissionUsersRead, domain.PermissionUsersManage}}
+    admin := &domain.Principal{UserID: primitive.NewObjectID(), Permissions: domain.AllPermissions}
+
+    if _, err := passwordUC.IssuePasswordReset(manager, "root"); !errors.Is(err, domain.ErrUserNotManageable) {
+        t.Fatalf("IssuePasswordReset for an admin without roles.manage: got %v, want %v", err, domain.ErrUserNotManageable)
+    }
+    if err := userUC.RevokeUserTokens(manager, "root"); !errors.Is(err, domain.ErrUserNotManageable) {
+        t.Fatalf("RevokeUserTokens for an admin without roles.manage: got %v, want %v", err, domain.ErrUserNotManageable)
+    }
+    if err := userUC.SetUserDeactivated(manager, "root", true); !errors.Is(err, domain.ErrUserNotManageable) {
+        t.Fatalf("SetUserDeactivated for an admin without roles.manage: got %v, want %v", err, domain.ErrUserNotManageable)
+    }
+    if _, err := userUC.DeleteUser(manager, "root", "", ""); !errors.Is(err, domain.ErrUserNotManageable) {
+        t.Fatalf("DeleteUser of an admin without roles.manage: got %v, want %v", err, domain.ErrUserNotManageable)
+    }
+    if user, _ := users.GetUserByUsername("root"); user.Deactivated {
+        t.Fatal("SetUserDeactivated: root was deactivated anyway")
+    }
+
+    // A personal access token is limited to what its scopes allow.
+    token := &domain.Principal{UserID: admin.UserID, Permissions: domain.AllPermissions, Scopes: []string{domain.ScopeTasksWrite}}
+    if err := userUC.RevokeUserTokens(token, "root"); !errors.Is(err, domain.ErrUserNotManageable) {
+        t.Fatalf("RevokeUserTokens with a tasks:write token: got %v, want %v", err, domain.ErrUserNotManageable)
+    }
+
+    if reset, err := passwordUC.IssuePasswordReset(manager, "bob"); err != nil || reset.Token == "" {
+        t.Fatalf("IssuePasswordReset for a user: got %+v, err=%v", reset, err)
+    }
+    if err := userUC.RevokeUserTokens(manager, "bob"); err != nil {
+        t.Fatalf("RevokeUserTokens for a user: %v", err)
+    }
+    if err := userUC.SetUserDeactivated(manager, "bob", true); err != nil {
+        t.Fatalf("SetUserDeactivated for a user: %v", err)
+    }
+    if _, err := userUC.DeleteUser(manager, "bob", "", ""); err != nil {
+        t.Fatalf("DeleteUser of a user: %v", err)
+    }
+    if _, err := passwordUC.IssuePasswordReset(admin, "root"); err != nil {
+        t.Fatalf("IssuePasswordReset for an admin by an admin: %v", err)
+    }
+    if _, err := userUC.DeleteUser(admin, "root2", "", ""); err != nil {
+        t.Fatalf("DeleteUser of an admin by an admin: %v", err)
+    }
+}
+
+func TestDeleteUserReassignsWithinTargetOrganizations(t *testing.T) {
+    users := repositories.NewInMemoryUserRepository()
+    tasks := repositories.NewInMemoryTaskRepository()
+    orgs := repositories.NewInMemoryOrganizationRepository()
+    roles := repositories.NewInMemoryRoleRepository()
+    uc := NewUserUseCase(users, tasks, repositories.NewInMemoryRefreshTokenRepository(), repositories.NewInMemoryTokenRevocationRepository(), repositories.NewInMemoryAccessTokenRepository(), roles, orgs, domain.DefaultPasswordPolicy(), plainHasher{})
+    admin := &domain.Principal{UserID: primitive.NewObjectID(), Permissions: domain.AllPermissions}
+
+    alice := &domain.User{Username: "alice", Password: "plain:x", Role: domain.RoleUser}
+    bob := &domain.User{Username: "bob", Password: "plain:x", Role: domain.RoleUser}
+    for _, user := range []*domain.User{alice, bob} {
+        if err := users.CreateUser(user); err != nil {
+            t.Fatalf("CreateUser: %v", err)
+        }
+    }
+    // Bob is a member of the first organization and only invited to the second.
+    shared, invited, private := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+    for _, membership := range []*domain.Membership{
+        {OrgID: shared, UserID: bob.ID, Role: domain.RoleUser},
+        {OrgID: invited, UserID: bob.ID, Role: domain.RoleUser, Pending: true},
+    } {
+        if err := orgs.AddMember(membership); err != nil {
+            t.Fatalf("AddMember: %v", err)
+        }
+    }
+    var ids []primitive.ObjectID
+    for _, orgID := range []primitive.ObjectID{shared, invited, private} {
+        task := domain.Task{ID: primitive.NewObjectID(), OrgID: orgID, Title: "task", Status: "pending"}
+        if err := tasks.AddTask(task); err != nil {
+            t.Fatalf("AddTask: %v", err)
+        }
+        if err := tasks.AssignTask(orgID, task.ID, alice.ID); err != nil {
+            t.Fatalf("AssignTask: %v", err)
+        }
+        ids = append(ids, task.ID)
+    }
+
+    affected, err := uc.DeleteUser(admin, "alice", domain.TaskPolicyReassign, "bob")
+    if err != nil || affected != 3 {
+        t.Fatalf("DeleteUser: affected %d, err=%v, want 3", affected, err)
+    }
+    if task, _, _ := tasks.GetTaskByID(shared, ids[0]); task.AssignedTo == nil || *task.AssignedTo != bob.ID {
+        t.Fatalf("DeleteUser: task of bob's organization assigned to %v, want bob", task.AssignedTo)
+    }
+    for i, orgID := range []primitive.ObjectID{invited, private} {
+        if task, _, _ := tasks.GetTaskByID(orgID, ids[i+1]); task.AssignedTo != nil {
+            t.Fatalf("DeleteUser: task of an organization bob is not a member of assigned to %v, want none", task.AssignedTo)
+        }
+    }
+}
+
+func TestDeleteUserTaskPolicies(t *testing.T) {
+    users := repositories.NewInMemoryUserRepository()
+    tasks := repositories.NewInMemoryTaskRepository()
+    uc := NewUserUseCase(users, tasks, repositories.NewInMemoryRefreshTokenRepository(), repositories.NewInMemoryTokenRevocationRepository(), repositories.NewInMemoryAccessTokenRepository(), repositories.NewInMemoryRoleRepository(), repositories.NewInMemoryOrganizationRepository(), domain.DefaultPasswordPolicy(), plainHasher{})
+    admin := &domain.Principal{UserID: primitive.NewObjectID(), Permissions: domain.AllPermissions}
+    orgID := primitive.NewObjectID()
+
+    // newUser creates a user with one task assigned to them and one task
+    // they created, and returns the IDs of both tasks.
+    newUser := func(username string) (assigned, created primitive.ObjectID) {
+        t.Helper()
+        user := &domain.User{Username: username, Password: "plain:x", Role: domain.RoleUser}
+        if err := users.CreateUser(user); err != nil {
+            t.Fatalf("CreateUser: %v", err)
+        }
+        assigned, created = primitive.NewObjectID(), primitive.NewObjectID()
+        for _, task := range []domain.Task{
+            {ID: assigned, OrgID: orgID, Title: "assigned", Status: "pending", AssignedTo: &user.ID},
+            {ID: created, OrgID: orgID, Title: "created", Status: "pending", CreatedBy: user.ID},
+        } {
+            if err := tasks.AddTask(task); err != nil {
+                t.Fatalf("AddTask: %v", err)
+            }
+        }
+        return assigned, created
+    }
+
+    assigned, created := newUser("alice")
+    for _, test := range []struct{ policy, reassignTo string }{
+        {"archive", ""},
+        {domain.TaskPolicyReassign, ""},
+        {domain.TaskPolicyReassign, "alice"},
+        {domain.TaskPolicyReassign, "nobody"},
+    } {
+        if _, err := uc.DeleteUser(admin, "alice", test.policy, test.reassignTo); !errors.Is(err, domain.ErrInvalidTaskPolicy) {
+            t.Fatalf("DeleteUser with policy %q and reassign_to %q: got %v, want %v", test.policy, test.reassignTo, err, domain.ErrInvalidTaskPolicy)
+        }
+    }
+    if _, err := users.GetUserByUsername("alice"); err != nil {
+        t.Fatalf("DeleteUser with an invalid policy deleted the user: %v", err)
+    }
+
+    // Tasks are unassigned by default.
+    if affected, err := uc.DeleteUser(admin, "alice", "", ""); err != nil || affected != 1 {
+        t.Fatalf("DeleteUser: affected %d, err=%v, want 1", affected, err)
+    }
+    if task, found, err := tasks.GetTaskByID(orgID, assigned); err != nil || !found || task.AssignedTo != nil {
+        t.Fatalf("DeleteUser: assigned task %+v, found %v, err=%v, want it unassigned", task, found, err)
+    }
+    if _, found, err := tasks.GetTaskByID(orgID, created); err != nil || !found {
+        t.Fatalf("DeleteUser: created task found %v, err=%v, want it kept", found, err)
+    }
+
+    assigned, created = newUser("bob")
+    if affected, err := uc.DeleteUser(admin, "bob", domain.TaskPolicyDelete, ""); err != nil || affected != 1 {
+        t.Fatalf("DeleteUser deleting tasks: affected %d, err=%v, want 1", affected, err)
+    }
+    if _, found, _ := tasks.GetTaskByID(orgID, assigned); found {
+        t.Fatal("DeleteUser deleting tasks: the assigned task was kept")
+    }
+    if _, found, err := tasks.GetTaskByID(orgID, created); err != nil || !found {
+        t.Fatalf("DeleteUser deleting tasks: created task found %v, err=%v, want it kept", found, err)
+    }
+}
+
+// failingOrganizationRepository fails the next RemoveUserMemberships call
+// when fail is set.
+type failingOrganizationRepository struct {
+    domain.OrganizationRepository
+    fail bool
+}
+
+func (r *failingOrganizationRepository) RemoveUserMemberships(userID primitive.ObjectID) error {
+    if r.fail {
+        r.fail = false
+        return errors.New("write failed")
+    }
+    return r.OrganizationRepository.RemoveUserMemberships(userID)
+}
+
+func TestDeleteUserChangesNothingFirst(t *testing.T) {
+    users := repositories.NewInMemoryUserRepository()
+    tasks := repositories.NewInMemoryTaskRepository()
+    access := repositories.NewInMemoryAccessTokenRepository()
+    orgs := &failingOrganizationRepository{OrganizationRepository: repositories.NewInMemoryOrganizationRepository()}
+    uc := NewUserUseCase(users, tasks, repositories.NewInMemoryRefreshTokenRepository(), repositories.NewInMemoryTokenRevocationRepository(), access, repositories.NewInMemoryRoleRepository(), orgs, domain.DefaultPasswordPolicy(), plainHasher{})
+    admin := &domain.Principal{UserID: primitive.NewObjectID(), Permissions: domain.AllPermissions}
+    orgID := primitive.NewObjectID()
+
+    root := &domain.User{Username: "root", Password: "plain:x", Role: domain.RoleAdmin}
+    alice := &domain.User{Username: "alice", Password: "plain:x", Role: domain.RoleUser}
+    for _, user := range []*domain.User{root, alice} {
+        if err := users.CreateUser(user); err != nil {
+            t.Fatalf("CreateUser: %v", err)
+        }
+        task := domain.Task{ID: primitive.NewObjectID(), OrgID: orgID, Title: "assigned", Status: "pending", AssignedTo: &user.ID}
+        if err := tasks.AddTask(task); err != nil {
+            t.Fatalf("AddTask: %v", err)
+        }
+    }
+
+    // The last active admin is refused before their tasks are touched.
+    if _, err := uc.DeleteUser(admin, "root", domain.TaskPolicyDelete, ""); !errors.Is(err, domain.ErrLastAdmin) {
+        t.Fatalf("DeleteUser of the last admin: got %v, want %v", err, domain.ErrLastAdmin)
+    }
+    if page, err := tasks.GetTasks(domain.TaskQuery{OrgID: orgID, AssignedTo: &root.ID}); err != nil || len(page.Tasks) != 1 {
+        t.Fatalf("GetTasks after refusing to delete the last admin: got %+v, err=%v, want the task kept", page, err)
+    }
+
+    // A deletion that fails halfway leaves the user signed out, and can be
+    // retried.
+    pat := &domain.PersonalAccessToken{UserID: alice.ID, Name: "ci", TokenHash: "hash", Scopes: []string{domain.ScopeTasksRead}, ExpiresAt: time.Now().Add(time.Hour)}
+    if err := access.CreateAccessToken(pat); err != nil {
+        t.Fatalf("CreateAccessToken: %v", err)
+    }
+    orgs.fail = true
+    if _, err := uc.DeleteUser(admin, "alice", domain.TaskPolicyDelete, ""); err == nil {
+        t.Fatal("DeleteUser: the failed write was not reported")
+    }
+    if user, err := users.GetUserByUsername("alice"); err != nil || !user.Deactivated {
+        t.Fatalf("GetUserByUsername after a failed deletion: got %+v, err=%v, want alice deactivated", user, err)
+    }
+    if token, err := access.GetAccessTokenByHash("hash"); err != nil || !token.Revoked {
+        t.Fatalf("GetAccessTokenByHash after a failed deletion: got %+v, err=%v, want it revoked", token, err)
+    }
+    if _, err := uc.DeleteUser(admin, "alice", domain.TaskPolicyDelete, ""); err != nil {
+        t.Fatalf("DeleteUser retried: %v", err)
+    }
+    if _, err := users.GetUserByUsername("alice"); !errors.Is(err, domain.ErrUserNotFound) {
+        t.Fatalf("GetUserByUsername after the deletion: got %v, want %v", err, domain.ErrUserNotFound)
+    }
+}